@@ -1,4 +1,4 @@
-import { API_BASE_URL } from "."
+import { API_BASE_URL, csrfHeaders } from "."
 import { ErrorCodes } from "../helpers/errors";
 import { AuthCredentials, ErrorResponse, LoginResponse, RegisterResponse, Result, VerifyAuthResponse } from "../types";
 
@@ -132,4 +132,19 @@ export async function verifyAuth(): Promise<Result<ErrorResponse, VerifyAuthResp
       }
     }
   }
-}
\ No newline at end of file
+}
+
+export async function logoutUser(): Promise<void> {
+  try {
+    await fetch(`${API_BASE_URL}/auth/logout`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        ...csrfHeaders(),
+      },
+      credentials: "include",
+    });
+  } catch (error) {
+    console.error("Logout error:", error);
+  }
+}
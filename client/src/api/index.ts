@@ -1,2 +1,11 @@
+import Cookies from "js-cookie";
+
 const ENV = import.meta.env.MODE
-export const API_BASE_URL = ENV != "production" ? "/api" : "/api/v1"
\ No newline at end of file
+export const API_BASE_URL = ENV != "production" ? "/api" : "/api/v1"
+
+// The API requires the CSRF token from the csrf_token cookie on state-changing
+// requests authenticated with the auth cookie.
+export function csrfHeaders(): Record<string, string> {
+  const token = Cookies.get("csrf_token");
+  return token ? { "X-CSRF-Token": token } : {};
+}
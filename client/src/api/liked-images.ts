@@ -1,4 +1,4 @@
-import { API_BASE_URL } from ".";
+import { API_BASE_URL, csrfHeaders } from ".";
 import { ErrorCodes } from "../helpers/errors";
 import { ErrorResponse, GetLikedImagesResponse, LikeDogImageResponse, Result } from "../types";
 
@@ -8,6 +8,7 @@ export async function likeDogImage(userId: string, imageUrl: string): Promise<Re
       method: "POST",
       headers: {
         "Content-Type": "application/json",
+        ...csrfHeaders(),
       },
       body: JSON.stringify({ imageURL: imageUrl }),
       credentials: "include",
@@ -52,6 +53,7 @@ export async function unlikeDogImage(userId: string, imageUrl: string): Promise<
       method: "DELETE",
       headers: {
         "Content-Type": "application/json",
+        ...csrfHeaders(),
       },
       body: JSON.stringify({ imageURL: imageUrl }),
       credentials: "include",
@@ -1,6 +1,6 @@
 import { useCallback, useState } from "react";
 import { AuthContext } from "../hooks/use-auth";
-import Cookies from "js-cookie";
+import { logoutUser } from "../api/auth";
 interface AuthProviderProps {
   children: React.ReactNode;
 }
@@ -14,7 +14,8 @@ const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
   );
 
   const logout = () => {
-    Cookies.remove("auth_token");
+    // The auth cookie is HttpOnly, only the API can clear it.
+    void logoutUser();
     updateUserId(null);
   };
 
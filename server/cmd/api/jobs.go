@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"server/config"
+	"server/internal/api/repositories"
+	"server/internal/api/services"
+	"server/internal/jobs"
+	"time"
+)
+
+// Types of the background jobs.
+const (
+	purgeUnlikedImagesJob = "liked_images.purge_unliked"
+	relayWebhookEventsJob = "webhooks.relay_events"
+	purgeDeliveriesJob    = "webhooks.purge_deliveries"
+	purgeIdempotencyJob   = "idempotency.purge_expired"
+	purgeSessionsJob      = "sessions.purge"
+)
+
+const (
+	// deliveryPurgeInterval is how often the webhook deliveries past their retention
+	// are purged.
+	deliveryPurgeInterval = time.Hour
+	// idempotencyPurgeInterval is how often the idempotency keys past their window
+	// are purged.
+	idempotencyPurgeInterval = time.Hour
+	// sessionPurgeInterval is how often the revoked and expired sessions are purged.
+	sessionPurgeInterval = time.Hour
+)
+
+// registerJobs registers the handlers of the background jobs on runner, and schedules
+// the periodic ones.
+func registerJobs(runner *jobs.Runner, cfg *config.Config, likedImagesService *services.LikedImagesService, webhookService *services.WebhookService, sessionService services.SessionService, idempotencyRepo repositories.IdempotencyRepository) {
+	jobs.Register(runner, purgeUnlikedImagesJob, func(ctx context.Context, _ struct{}) error {
+		purged, err := likedImagesService.PurgeUnlikedImages()
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("purged %d unliked images", purged)
+		}
+		return nil
+	})
+	if cfg.Likes.PurgeInterval > 0 {
+		runner.Schedule(purgeUnlikedImagesJob, jobs.Every(cfg.Likes.PurgeInterval), nil)
+	}
+
+	jobs.Register(runner, relayWebhookEventsJob, func(ctx context.Context, _ struct{}) error {
+		_, err := webhookService.RelayEvents(ctx)
+		return err
+	})
+	if cfg.Webhooks.RelayInterval > 0 {
+		runner.Schedule(relayWebhookEventsJob, jobs.Every(cfg.Webhooks.RelayInterval), nil)
+	}
+
+	jobs.Register(runner, services.DeliverWebhookJob, func(ctx context.Context, payload services.DeliverWebhookPayload) error {
+		return webhookService.Deliver(ctx, payload.DeliveryID)
+	})
+
+	jobs.Register(runner, purgeDeliveriesJob, func(ctx context.Context, _ struct{}) error {
+		purged, err := webhookService.PurgeDeliveries()
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("purged %d webhook deliveries", purged)
+		}
+		return nil
+	})
+	runner.Schedule(purgeDeliveriesJob, jobs.Every(deliveryPurgeInterval), nil)
+
+	jobs.Register(runner, purgeIdempotencyJob, func(ctx context.Context, _ struct{}) error {
+		purged, err := idempotencyRepo.PurgeExpired(time.Now().Add(-cfg.HTTP.IdempotencyWindow))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("purged %d expired idempotency keys", purged)
+		}
+		return nil
+	})
+	runner.Schedule(purgeIdempotencyJob, jobs.Every(idempotencyPurgeInterval), nil)
+
+	jobs.Register(runner, purgeSessionsJob, func(ctx context.Context, _ struct{}) error {
+		purged, err := sessionService.PurgeSessions()
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("purged %d revoked and expired sessions", purged)
+		}
+		return nil
+	})
+	runner.Schedule(purgeSessionsJob, jobs.Every(sessionPurgeInterval), nil)
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"server/config"
-	"server/db"
 	"server/docs"
 	"server/internal/api/handlers"
-	"server/internal/api/repositories"
 	"server/internal/api/services"
+	"server/internal/jobs"
+	"server/internal/pubsub"
 	"server/internal/server"
+	"server/internal/utils"
+	"syscall"
 )
 
 // @title						WTI-Tech-Interview API
@@ -19,7 +28,13 @@ import (
 // @securityDefinitions.apikey	BearerAuth
 // @in							header
 // @name						Authorization
+//
+// @securityDefinitions.apikey	AdminKey
+// @in							header
+// @name						X-Admin-Key
 func main() {
+	storageKind := flag.String("storage", storageDatabase, "storage backend: database, PostgreSQL or SQLite depending on the DB URL, or memory to run without a database")
+	flag.Parse()
 
 	cfg, err := config.LoadConfig()
 
@@ -27,29 +42,94 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
-	db, err := db.InitDB(cfg.DB.URL)
+	if cfg.HTTP.IdempotencySecret == "" {
+		if cfg.Env == "production" {
+			log.Fatalf("IDEMPOTENCY_SECRET is required in production")
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("failed to generate the idempotency secret: %v", err)
+		}
+		cfg.HTTP.IdempotencySecret = string(secret)
+		log.Printf("no IDEMPOTENCY_SECRET: request fingerprints use a random key and do not match across restarts")
+	}
+
+	keyRing, err := utils.LoadKeyRing(cfg)
+	if err != nil {
+		log.Fatalf("failed to load JWT signing keys: %v", err)
+	}
+	stopRotation := keyRing.StartRotation(cfg.JWT.RotationInterval)
+	defer stopRotation()
+
+	passwordHasher, err := utils.NewPasswordHasher(cfg.Password)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		log.Fatalf("failed to configure password hashing: %v", err)
 	}
-	defer db.Close()
+
+	passwordPolicy, err := utils.NewPasswordPolicy(cfg.PasswordPolicy, passwordHasher)
+	if err != nil {
+		log.Fatalf("failed to configure password policy: %v", err)
+	}
+
+	store, err := openStorage(*storageKind, cfg, passwordHasher)
+	if err != nil {
+		log.Fatalf("failed to open storage: %v", err)
+	}
+	defer store.close()
 
 	docs.SwaggerInfo.Schemes = []string{"http"}
 
-	userRepo := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepo)
-	userHandler := handlers.NewUserHandler(userService)
+	userService := services.NewUserService(store.users, store.mfa, store.sessions, passwordHasher)
+	userHandler := handlers.NewUserHandler(userService, passwordPolicy, cfg.Cookies)
+
+	sessionService := services.NewSessionService(store.sessions)
+	sessionHandler := handlers.NewSessionHandler(sessionService, cfg.Cookies)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	likeEvents := pubsub.NewBroker(cfg.Likes.StreamHistory)
+	if store.likeEvents != nil {
+		likeEvents.UseTransport()
+		go store.likeEvents.Listen(ctx, likeEvents)
+	}
 
-	likedImagesRepo := repositories.NewLikedImagesRepository(db)
-	likedImagesService := services.NewLikedImagesService(likedImagesRepo, userRepo)
-	likedImagesHandler := handlers.NewLikedImagesHandler(likedImagesService)
+	likedImagesService := services.NewLikedImagesService(store.likedImages, store.users, store.uow, likeEvents, cfg.Likes)
+	likedImagesHandler := handlers.NewLikedImagesHandler(likedImagesService, cfg.Likes)
 
-	dogRepo := repositories.NewDogAPIRepository(cfg.DogApiBaseURL)
-	dogService := services.NewDogService(dogRepo, likedImagesRepo)
+	dogService := services.NewDogService(store.dogs, store.likedImages)
 	dogHandler := handlers.NewDogHandler(dogService)
 
-	server := server.NewServer(*userHandler, *dogHandler, *likedImagesHandler)
+	swipeService := services.NewSwipeService(dogService, likedImagesService, cfg.Swipe)
+	swipeHandler := handlers.NewSwipeHandler(swipeService, cfg.Swipe, cfg.HTTP.CORS)
+
+	jwksHandler := handlers.NewJWKSHandler(keyRing)
+
+	oidcService := services.NewOIDCService(cfg.OIDC.Providers, store.users, store.identities, store.mfa, store.sessions, keyRing)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, cfg.OIDC.PostLoginRedirectURL, cfg.Cookies)
+
+	jobRunner := jobs.NewRunner(store.jobs, cfg.Jobs)
+	webhookService := services.NewWebhookService(store.webhooks, store.uow, jobRunner, cfg.Webhooks)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+
+	registerJobs(jobRunner, cfg, likedImagesService, webhookService, sessionService, store.idempotency)
+	jobRunner.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Jobs.ShutdownTimeout)
+		defer cancel()
+		if err := jobRunner.Shutdown(ctx); err != nil {
+			log.Printf("failed to stop background jobs: %v", err)
+		}
+	}()
+
+	server := server.NewServer(*userHandler, *dogHandler, *likedImagesHandler, *jwksHandler, *oidcHandler, *sessionHandler, *webhookHandler, *swipeHandler, sessionService, store.idempotency, cfg.Cookies, cfg.HTTP, cfg.AdminAPIKey)
+	// Streams and swipe sessions never end on their own, so they are closed when the
+	// server stops: streams for the shutdown not to wait for them, swipe sessions for
+	// their clients to be told the server is going away.
+	server.OnShutdown(likeEvents.Close)
+	server.OnShutdown(swipeHandler.Close)
 
-	if err := server.Run(":8080", "api/v1"); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+	if err := server.Run(ctx, ":8080", "api/v1"); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("failed to run server: %v", err)
 	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"server/config"
+	"server/db"
+	"server/internal/api/repositories"
+	"server/internal/api/repositories/memory"
+	"server/internal/pubsub"
+	"server/internal/utils"
+)
+
+// Storage backends selected with the --storage flag.
+const (
+	storageDatabase = "database"
+	storageMemory   = "memory"
+)
+
+// storage is the set of repositories the server runs on.
+type storage struct {
+	users       repositories.UserRepository
+	mfa         repositories.MFARepository
+	sessions    repositories.SessionRepository
+	likedImages repositories.LikedImagesRepository
+	identities  repositories.IdentityRepository
+	idempotency repositories.IdempotencyRepository
+	dogs        repositories.DogRepository
+	jobs        repositories.JobRepository
+	webhooks    repositories.WebhookRepository
+	uow         repositories.UnitOfWork
+	// likeEvents carries the like events between the servers sharing the database,
+	// nil when they stay within this server.
+	likeEvents *pubsub.PostgresTransport
+	close      func() error
+}
+
+// openStorage creates the repositories of a storage backend.
+//
+// Parameters:
+//   - kind: storageDatabase to use the database at cfg.DB.URL, PostgreSQL or SQLite, and the Dog API, or
+//     storageMemory to keep everything in memory, seeded with a demo user.
+//   - cfg: The configuration of the server.
+//   - hasher: The password hasher, used to seed the demo user.
+//
+// Returns:
+//   - *storage: The repositories; close must be called when the server stops.
+//   - error: An error if the backend is unknown or cannot be opened.
+func openStorage(kind string, cfg *config.Config, hasher utils.PasswordHasher) (*storage, error) {
+	switch kind {
+	case storageDatabase:
+		conn, err := db.OpenCluster(cfg.DB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		var likeEvents *pubsub.PostgresTransport
+		if cfg.DB.Driver() != config.DriverSQLite {
+			likeEvents = pubsub.NewPostgresTransport(cfg.DB.URL)
+		}
+		return &storage{
+			users:       repositories.NewUserRepository(conn),
+			mfa:         repositories.NewMFARepository(conn),
+			sessions:    repositories.NewSessionRepository(conn),
+			likedImages: repositories.NewLikedImagesRepository(conn),
+			identities:  repositories.NewIdentityRepository(conn),
+			idempotency: repositories.NewIdempotencyRepository(conn),
+			dogs:        repositories.NewDogAPIRepository(cfg.DogApiBaseURL),
+			jobs:        repositories.NewJobRepository(conn),
+			webhooks:    repositories.NewWebhookRepository(conn),
+			uow:         repositories.NewUnitOfWork(conn.DB),
+			likeEvents:  likeEvents,
+			close:       conn.Close,
+		}, nil
+
+	case storageMemory:
+		store := memory.NewStore()
+		passwordHash, err := hasher.Hash(memory.DemoUserPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash demo password: %w", err)
+		}
+		if _, err := memory.Seed(store, passwordHash); err != nil {
+			return nil, fmt.Errorf("failed to seed demo data: %w", err)
+		}
+		log.Printf("Using in-memory storage, data is lost on exit. Log in as %s with password %s", memory.DemoUserEmail, memory.DemoUserPassword)
+
+		return &storage{
+			users:       memory.NewUserRepository(store),
+			mfa:         memory.NewMFARepository(store),
+			sessions:    memory.NewSessionRepository(store),
+			likedImages: memory.NewLikedImagesRepository(store),
+			identities:  memory.NewIdentityRepository(store),
+			idempotency: memory.NewIdempotencyRepository(store),
+			dogs:        memory.NewDogRepository(memory.DogPictures),
+			jobs:        memory.NewJobRepository(store),
+			webhooks:    memory.NewWebhookRepository(store),
+			uow:         memory.NewUnitOfWork(store),
+			close:       func() error { return nil },
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage %q, expected %s or %s", kind, storageDatabase, storageMemory)
+	}
+}
@@ -2,18 +2,35 @@ package config
 
 import (
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
 type Config struct {
-	Logs          LogConfig
-	DB            DBConfig
-	JWTSecret     string
-	Port          string
-	DogApiBaseURL string
+	// Env is the SERVER_ENV the configuration was loaded for, e.g. "production".
+	Env            string
+	Logs           LogConfig
+	DB             DBConfig
+	JWT            JWTConfig
+	OIDC           OIDCConfig
+	Password       PasswordConfig
+	PasswordPolicy PasswordPolicyConfig
+	Cookies        CookieConfig
+	HTTP           HTTPConfig
+	Likes          LikesConfig
+	Jobs           JobsConfig
+	Webhooks       WebhooksConfig
+	Swipe          SwipeConfig
+	AdminAPIKey    string
+	JWTSecret      string
+	Port           string
+	DogApiBaseURL  string
 }
 
 type LogConfig struct {
@@ -21,12 +38,281 @@ type LogConfig struct {
 	Level string
 }
 
+// DBConfig holds the database settings.
+//
+// The driver is selected by the scheme of URL: sqlite://path opens the SQLite
+// database file at path (sqlite:///abs/path.db for an absolute path, sqlite://:memory:
+// for a transient one), any other URL is a PostgreSQL connection string.
+//
+// Fields:
+//   - ReplicaURLs: optional read replicas of URL, from the comma separated DB_REPLICA_URLS.
+//     Read-only queries that tolerate replication lag run on them, falling back to URL.
+//   - MaxOpenConns, MaxIdleConns: the pool size of each database, 0 for no limit and
+//     no idle connections respectively. SQLite always uses a single connection.
+//   - ConnMaxLifetime, ConnMaxIdleTime: how long a connection is reused and kept idle,
+//     0 for no limit.
+//   - StatementCacheCapacity: how many prepared statements each PostgreSQL connection
+//     keeps, 0 to prepare them for every query, e.g. behind a transaction pooler.
 type DBConfig struct {
-	Username string
-	Password string
-	Name     string
-	Port     string
-	URL      string
+	Username        string
+	Password        string
+	Name            string
+	Port            string
+	URL             string
+	ReplicaURLs     []string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	StatementCacheCapacity int
+}
+
+// Database drivers, see DBConfig.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+const sqliteScheme = "sqlite://"
+
+// Driver returns the database driver selected by the scheme of the URL.
+func (c DBConfig) Driver() string {
+	if strings.HasPrefix(c.URL, sqliteScheme) {
+		return DriverSQLite
+	}
+	return DriverPostgres
+}
+
+// DataSource returns the data source name the driver opens: the URL itself for
+// PostgreSQL and a file: URI, keeping the query parameters, for SQLite.
+func (c DBConfig) DataSource() string {
+	if c.Driver() == DriverSQLite {
+		return "file:" + strings.TrimPrefix(c.URL, sqliteScheme)
+	}
+	return c.URL
+}
+
+// JWTConfig holds the settings used to sign and verify JSON Web Tokens.
+//
+// Fields:
+//   - SigningAlgorithm: "RS256", "EdDSA" or "HS256" (legacy shared secret).
+//   - KeyDir: directory the signing keys are kept in, shared by the replicas and
+//     across restarts; rotated keys are written there.
+//   - PrivateKeyFile: PEM file used as the signing key, never rotated. Exclusive with
+//     KeyDir; one of them is required in production.
+//   - RotationInterval: how often a new signing key is generated, 0 disables rotation.
+//   - KeyRetention: how long a rotated key is still accepted for verification.
+//   - LegacyHS256Until: when HS256 tokens signed with JWTSecret stop being accepted.
+//     The zero time, when unset, accepts them for a JWT lifetime after startup; a
+//     time in the past rejects them.
+type JWTConfig struct {
+	SigningAlgorithm string
+	KeyDir           string
+	PrivateKeyFile   string
+	RotationInterval time.Duration
+	KeyRetention     time.Duration
+	LegacyHS256Until time.Time
+}
+
+// OIDCConfig holds the OpenID Connect providers users can sign in with.
+//
+// Providers are listed by name in OIDC_PROVIDERS (comma separated) and each one
+// is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET,
+// OIDC_<NAME>_REDIRECT_URL and optionally OIDC_<NAME>_SCOPES.
+//
+// When PostLoginRedirectURL is set the callback redirects there after setting the
+// auth cookie instead of answering with JSON.
+type OIDCConfig struct {
+	Providers            []OIDCProviderConfig
+	PostLoginRedirectURL string
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// PasswordConfig holds the password hashing settings.
+//
+// Fields:
+//   - Algorithm: "argon2id" or "bcrypt", used for new hashes.
+//   - BcryptCost: the bcrypt cost factor.
+//   - Argon2Memory: the Argon2id memory in KiB.
+//   - Argon2Iterations: the Argon2id number of passes over the memory.
+//   - Argon2Parallelism: the Argon2id number of threads.
+//
+// Stored hashes using another algorithm or weaker parameters are rehashed on the next login.
+type PasswordConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// PasswordPolicyConfig holds the rules new passwords must satisfy.
+//
+// Fields:
+//   - MinLength, MaxLength: the length bounds, counted in characters rather than bytes.
+//     Hashers limiting the length in bytes, such as bcrypt, further bound it.
+//   - RequireUpper, RequireLower, RequireDigit, RequireSpecial: the required character classes.
+//     Any character that is not a letter or a digit counts as special.
+//   - AllowUnicode: whether characters outside ASCII are accepted.
+//   - BreachedPasswordsFile: optional file listing known-breached passwords, one per line.
+type PasswordPolicyConfig struct {
+	MinLength             int
+	MaxLength             int
+	RequireUpper          bool
+	RequireLower          bool
+	RequireDigit          bool
+	RequireSpecial        bool
+	AllowUnicode          bool
+	BreachedPasswordsFile string
+}
+
+// CookieConfig holds the attributes of the cookies set by the API.
+//
+// Fields:
+//   - Domain: the cookie domain, empty for the host of the request.
+//   - Secure: whether cookies are only sent over HTTPS. Defaults to true in production.
+//   - SameSite: the SameSite mode, from COOKIE_SAMESITE ("lax", "strict" or "none").
+//     "none" requires Secure and falls back to "lax" otherwise.
+type CookieConfig struct {
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// HTTPConfig holds the browser-facing settings of the HTTP server.
+//
+// Fields:
+//   - CORS: the cross-origin requests accepted by the API.
+//   - SecurityHeaders: the security headers added to every response.
+//   - EnableSwagger: whether /swagger is served. Defaults to false in production.
+//   - IdempotencyWindow: how long Idempotency-Key responses are replayed.
+//   - IdempotencySecret: the key of the HMAC fingerprinting the requests sent with an
+//     Idempotency-Key, so stored fingerprints do not reveal their bodies. Required in
+//     production, shared by the replicas.
+//   - ShutdownTimeout: how long in-flight requests are waited for when the server stops.
+//   - TrustedProxies: the IPs and CIDRs of the proxies whose X-Forwarded-For header gives
+//     the client IP. None by default, so clients cannot set their own IP.
+type HTTPConfig struct {
+	CORS              CORSConfig
+	SecurityHeaders   SecurityHeadersConfig
+	EnableSwagger     bool
+	IdempotencyWindow time.Duration
+	IdempotencySecret string
+	ShutdownTimeout   time.Duration
+	TrustedProxies    []string
+}
+
+// LikesConfig holds the settings of unlikes, which can be undone for a while, and of
+// the streams of like activity.
+//
+// Fields:
+//   - UndoWindow: how long after an unlike it can be undone.
+//   - Retention: how long unliked images are kept before being purged.
+//   - PurgeInterval: how often unliked images past their retention are purged.
+//   - StreamHeartbeat: how often idle streams send a comment, keeping proxies from
+//     closing them.
+//   - StreamHistory: how many of the latest events are kept to resume streams.
+type LikesConfig struct {
+	UndoWindow      time.Duration
+	Retention       time.Duration
+	PurgeInterval   time.Duration
+	StreamHeartbeat time.Duration
+	StreamHistory   int
+}
+
+// JobsConfig holds the settings of the background job workers.
+//
+// Fields:
+//   - Workers: the number of jobs run concurrently. 0 disables the workers.
+//   - PollInterval: how often idle workers look for due jobs.
+//   - LockTimeout: how long a job may run; after it, the job is cancelled and
+//     claimed again, as its worker is assumed to have stopped.
+//   - MaxAttempts: how many times a job is tried before it is dead.
+//   - InitialBackoff, MaxBackoff: the delay before the first retry, doubled on every
+//     retry up to MaxBackoff.
+//   - ShutdownTimeout: how long running jobs are waited for when the server stops.
+type JobsConfig struct {
+	Workers         int
+	PollInterval    time.Duration
+	LockTimeout     time.Duration
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// WebhooksConfig holds the settings of the delivery of events to webhooks.
+//
+// Fields:
+//   - RelayInterval: how often the outbox is relayed to the webhooks.
+//   - Timeout: how long a webhook has to respond to a delivery.
+//   - MaxAttempts: how many times a delivery is tried before it fails.
+//   - DeliveryRetention: how long the delivery log is kept.
+//   - AllowPrivateNetworks: whether webhooks may point at loopback, private or
+//     link-local addresses; off outside development, as any user can register one.
+type WebhooksConfig struct {
+	RelayInterval        time.Duration
+	Timeout              time.Duration
+	MaxAttempts          int
+	DeliveryRetention    time.Duration
+	AllowPrivateNetworks bool
+}
+
+// SwipeConfig holds the settings of swipe sessions, streaming random images over a
+// WebSocket to be liked or skipped.
+//
+// Fields:
+//   - Prefetch: how many images each session fetches ahead of sending them.
+//   - Window: how many images are sent ahead of the actions of the client; once
+//     reached, the next image waits for an action.
+//   - PingInterval: how often the connection is pinged; it is closed when a ping
+//     is not answered before the next one.
+//   - WriteTimeout: how long a message may take to be sent before the client is
+//     considered gone.
+type SwipeConfig struct {
+	Prefetch     int
+	Window       int
+	PingInterval time.Duration
+	WriteTimeout time.Duration
+}
+
+// CORSConfig holds the Cross-Origin Resource Sharing settings.
+//
+// List values are read from comma separated environment variables. "*" in
+// AllowedOrigins allows every origin, which browsers refuse for credentialed
+// requests, so AllowCredentials is turned off in that case.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// SecurityHeadersConfig holds the security headers sent with every response.
+//
+// Fields:
+//   - HSTSMaxAge: the Strict-Transport-Security max-age, 0 disables the header.
+//     Defaults to one year in production.
+//   - HSTSIncludeSubdomains: whether HSTS also applies to subdomains.
+//   - FrameOptions: the X-Frame-Options value, "DENY" or "SAMEORIGIN", empty to omit it
+//     (FRAME_OPTIONS=none). The CSP frame-ancestors directive follows it.
+//   - ReferrerPolicy: the Referrer-Policy value, empty to omit it.
+type SecurityHeadersConfig struct {
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	FrameOptions          string
+	ReferrerPolicy        string
 }
 
 var (
@@ -53,18 +339,87 @@ func LoadConfig() (*Config, error) {
 		}
 
 		cfg = &Config{
+			Env:  env,
 			Port: os.Getenv("PORT"),
 			Logs: LogConfig{
 				Style: os.Getenv("LOG_STYLE"),
 				Level: os.Getenv("LOG_LEVEL"),
 			},
 			JWTSecret: os.Getenv("JWT_SECRET"),
+			JWT: JWTConfig{
+				SigningAlgorithm: getEnv("JWT_SIGNING_ALG", "RS256"),
+				KeyDir:           os.Getenv("JWT_KEY_DIR"),
+				PrivateKeyFile:   os.Getenv("JWT_PRIVATE_KEY_FILE"),
+				RotationInterval: getEnvDuration("JWT_ROTATION_INTERVAL", 7*24*time.Hour),
+				KeyRetention:     getEnvDuration("JWT_KEY_RETENTION", 48*time.Hour),
+				LegacyHS256Until: getEnvTime("JWT_LEGACY_HS256_UNTIL", time.Time{}),
+			},
+			OIDC: OIDCConfig{
+				Providers:            loadOIDCProviders(),
+				PostLoginRedirectURL: os.Getenv("OIDC_POST_LOGIN_REDIRECT_URL"),
+			},
+			Password: PasswordConfig{
+				Algorithm:         getEnv("PASSWORD_HASH_ALG", "argon2id"),
+				BcryptCost:        getEnvInt("PASSWORD_BCRYPT_COST", 12),
+				Argon2Memory:      uint32(getEnvInt("PASSWORD_ARGON2_MEMORY", 64*1024)),
+				Argon2Iterations:  uint32(getEnvInt("PASSWORD_ARGON2_ITERATIONS", 3)),
+				Argon2Parallelism: uint8(getEnvInt("PASSWORD_ARGON2_PARALLELISM", 2)),
+			},
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength:             getEnvInt("PASSWORD_MIN_LENGTH", 8),
+				MaxLength:             getEnvInt("PASSWORD_MAX_LENGTH", 128),
+				RequireUpper:          getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+				RequireLower:          getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+				RequireDigit:          getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+				RequireSpecial:        getEnvBool("PASSWORD_REQUIRE_SPECIAL", true),
+				AllowUnicode:          getEnvBool("PASSWORD_ALLOW_UNICODE", true),
+				BreachedPasswordsFile: os.Getenv("PASSWORD_BREACHED_FILE"),
+			},
+			Cookies: loadCookieConfig(env),
+			HTTP:    loadHTTPConfig(env),
+			Likes: LikesConfig{
+				UndoWindow:      getEnvDuration("LIKES_UNDO_WINDOW", 5*time.Minute),
+				Retention:       getEnvDuration("LIKES_RETENTION", 30*24*time.Hour),
+				PurgeInterval:   getEnvDuration("LIKES_PURGE_INTERVAL", time.Hour),
+				StreamHeartbeat: getEnvDuration("LIKES_STREAM_HEARTBEAT", 15*time.Second),
+				StreamHistory:   getEnvInt("LIKES_STREAM_HISTORY", 1000),
+			},
+			Jobs: JobsConfig{
+				Workers:         getEnvInt("JOBS_WORKERS", 4),
+				PollInterval:    getEnvDuration("JOBS_POLL_INTERVAL", time.Second),
+				LockTimeout:     getEnvDuration("JOBS_LOCK_TIMEOUT", 5*time.Minute),
+				MaxAttempts:     getEnvInt("JOBS_MAX_ATTEMPTS", 5),
+				InitialBackoff:  getEnvDuration("JOBS_INITIAL_BACKOFF", 10*time.Second),
+				MaxBackoff:      getEnvDuration("JOBS_MAX_BACKOFF", time.Hour),
+				ShutdownTimeout: getEnvDuration("JOBS_SHUTDOWN_TIMEOUT", 30*time.Second),
+			},
+			Webhooks: WebhooksConfig{
+				RelayInterval:        getEnvDuration("WEBHOOKS_RELAY_INTERVAL", 5*time.Second),
+				Timeout:              getEnvDuration("WEBHOOKS_TIMEOUT", 10*time.Second),
+				MaxAttempts:          getEnvInt("WEBHOOKS_MAX_ATTEMPTS", 8),
+				DeliveryRetention:    getEnvDuration("WEBHOOKS_DELIVERY_RETENTION", 30*24*time.Hour),
+				AllowPrivateNetworks: getEnvBool("WEBHOOKS_ALLOW_PRIVATE_NETWORKS", false),
+			},
+			Swipe: SwipeConfig{
+				Prefetch:     getEnvInt("SWIPE_PREFETCH", 5),
+				Window:       getEnvInt("SWIPE_WINDOW", 3),
+				PingInterval: getEnvDuration("SWIPE_PING_INTERVAL", 30*time.Second),
+				WriteTimeout: getEnvDuration("SWIPE_WRITE_TIMEOUT", 10*time.Second),
+			},
+			AdminAPIKey: os.Getenv("ADMIN_API_KEY"),
 			DB: DBConfig{
-				Username: os.Getenv("POSTGRES_USER"),
-				Password: os.Getenv("POSTGRES_PASSWORD"),
-				Port:     os.Getenv("POSTGRES_PORT"),
-				Name:     postgresDB,
-				URL:      dbURL,
+				Username:        os.Getenv("POSTGRES_USER"),
+				Password:        os.Getenv("POSTGRES_PASSWORD"),
+				Port:            os.Getenv("POSTGRES_PORT"),
+				Name:            postgresDB,
+				URL:             dbURL,
+				ReplicaURLs:     getEnvList("DB_REPLICA_URLS", nil),
+				MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+				MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 25),
+				ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+				ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+				StatementCacheCapacity: getEnvInt("DB_STATEMENT_CACHE_CAPACITY", 512),
 			},
 			DogApiBaseURL: "https://dog.ceo/api",
 		}
@@ -79,3 +434,198 @@ func GetConfig() *Config {
 	}
 	return cfg
 }
+
+// getEnv returns the value of the environment variable named by key,
+// or fallback if the variable is not set.
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvDuration parses the environment variable named by key as a time.Duration
+// (e.g. "24h", "15m"). It returns fallback if the variable is unset or invalid.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid duration for %s: %v, using default %s", key, err, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getEnvTime parses the environment variable named by key as an RFC 3339 time
+// (e.g. "2025-01-31T00:00:00Z"). It returns fallback if the variable is unset or invalid.
+func getEnvTime(key string, fallback time.Time) time.Time {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Printf("invalid time for %s: %v, using default %s", key, err, fallback)
+		return fallback
+	}
+	return t
+}
+
+// getEnvInt parses the environment variable named by key as an int.
+// It returns fallback if the variable is unset or invalid.
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid integer for %s: %v, using default %d", key, err, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvBool parses the environment variable named by key as a bool.
+// It returns fallback if the variable is unset or invalid.
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid boolean for %s: %v, using default %t", key, err, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getEnvList splits the environment variable named by key on commas, dropping
+// empty entries. It returns fallback if the variable is unset or empty.
+func getEnvList(key string, fallback []string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+// loadHTTPConfig reads the CORS and security header settings. Production defaults
+// to HSTS and hides the Swagger UI.
+func loadHTTPConfig(env string) HTTPConfig {
+	production := env == "production"
+
+	var hstsMaxAge time.Duration
+	if production {
+		hstsMaxAge = 365 * 24 * time.Hour
+	}
+
+	httpCfg := HTTPConfig{
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+			AllowedMethods:   getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}),
+			AllowedHeaders:   getEnvList("CORS_ALLOWED_HEADERS", []string{"Origin", "Accept", "Content-Type", "Authorization", "X-CSRF-Token", "Idempotency-Key", "If-Match", "If-None-Match"}),
+			ExposedHeaders:   getEnvList("CORS_EXPOSED_HEADERS", []string{"X-Request-ID", "Idempotent-Replayed", "ETag"}),
+			AllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		SecurityHeaders: SecurityHeadersConfig{
+			HSTSMaxAge:            getEnvDuration("HSTS_MAX_AGE", hstsMaxAge),
+			HSTSIncludeSubdomains: getEnvBool("HSTS_INCLUDE_SUBDOMAINS", false),
+			FrameOptions:          strings.ToUpper(getEnv("FRAME_OPTIONS", "DENY")),
+			ReferrerPolicy:        getEnv("REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		},
+		EnableSwagger:     getEnvBool("SWAGGER_ENABLED", !production),
+		IdempotencyWindow: getEnvDuration("IDEMPOTENCY_WINDOW", 24*time.Hour),
+		IdempotencySecret: os.Getenv("IDEMPOTENCY_SECRET"),
+		ShutdownTimeout:   getEnvDuration("HTTP_SHUTDOWN_TIMEOUT", 15*time.Second),
+		TrustedProxies:    getEnvList("TRUSTED_PROXIES", nil),
+	}
+
+	for _, origin := range httpCfg.CORS.AllowedOrigins {
+		if origin == "*" && httpCfg.CORS.AllowCredentials {
+			log.Printf("CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOWED_ORIGINS, disabling credentials")
+			httpCfg.CORS.AllowCredentials = false
+			break
+		}
+	}
+
+	switch httpCfg.SecurityHeaders.FrameOptions {
+	case "DENY", "SAMEORIGIN":
+	case "NONE":
+		httpCfg.SecurityHeaders.FrameOptions = ""
+	default:
+		log.Printf("invalid FRAME_OPTIONS %q, using DENY", httpCfg.SecurityHeaders.FrameOptions)
+		httpCfg.SecurityHeaders.FrameOptions = "DENY"
+	}
+
+	return httpCfg
+}
+
+// loadCookieConfig reads the cookie attributes, defaulting to secure cookies in production.
+func loadCookieConfig(env string) CookieConfig {
+	cookies := CookieConfig{
+		Domain: os.Getenv("COOKIE_DOMAIN"),
+		Secure: getEnvBool("COOKIE_SECURE", env == "production"),
+	}
+
+	switch strings.ToLower(getEnv("COOKIE_SAMESITE", "lax")) {
+	case "strict":
+		cookies.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookies.SameSite = http.SameSiteNoneMode
+	case "lax":
+		cookies.SameSite = http.SameSiteLaxMode
+	default:
+		log.Printf("invalid COOKIE_SAMESITE %q, using lax", os.Getenv("COOKIE_SAMESITE"))
+		cookies.SameSite = http.SameSiteLaxMode
+	}
+
+	if cookies.SameSite == http.SameSiteNoneMode && !cookies.Secure {
+		log.Printf("COOKIE_SAMESITE=none requires COOKIE_SECURE, using lax")
+		cookies.SameSite = http.SameSiteLaxMode
+	}
+
+	return cookies
+}
+
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS.
+// Providers missing an issuer or client ID are skipped.
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+
+		if provider.IssuerURL == "" || provider.ClientID == "" {
+			log.Printf("skipping OIDC provider %q: issuer and client ID are required", name)
+			continue
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"server/config"
+	"server/db/queries"
+	"sync/atomic"
+)
+
+// Cluster is a primary database and its read replicas. Queries run on the primary,
+// except the read-only queries run on Reader, see queries.Reader.
+type Cluster struct {
+	*sql.DB
+	replicas []*sql.DB
+	next     atomic.Uint64
+}
+
+// OpenCluster connects to the primary database of cfg, creating its schema, and to
+// its read replicas. Replicas are connected to lazily, so a replica that is down
+// only makes reads fall back to the other replicas and to the primary.
+func OpenCluster(cfg config.DBConfig) (*Cluster, error) {
+	primary, err := InitDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &Cluster{DB: primary}
+	for _, url := range cfg.ReplicaURLs {
+		replicaCfg := cfg
+		replicaCfg.URL = url
+
+		replica, err := openDB(replicaCfg)
+		if err != nil {
+			cluster.Close()
+			return nil, fmt.Errorf("error opening read replica: %w", err)
+		}
+		cluster.replicas = append(cluster.replicas, replica)
+	}
+
+	return cluster, nil
+}
+
+// Reader returns the DBTX running read-only queries on the replicas, in turn, or on
+// the primary if there are none.
+func (c *Cluster) Reader() queries.DBTX {
+	if len(c.replicas) == 0 {
+		return c.DB
+	}
+	return &replicaReader{cluster: c}
+}
+
+// Close closes the primary and the replicas.
+func (c *Cluster) Close() error {
+	err := c.DB.Close()
+	for _, replica := range c.replicas {
+		if closeErr := replica.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// inTurn returns the replicas in the order to try them, starting with the next one
+// in turn so reads are spread across replicas.
+func (c *Cluster) inTurn() []*sql.DB {
+	start := int(c.next.Add(1) % uint64(len(c.replicas)))
+	return append(c.replicas[start:len(c.replicas):len(c.replicas)], c.replicas[:start]...)
+}
+
+// replicaReader runs queries on the replicas of a cluster, falling back to the next
+// replica and then to the primary when one fails.
+type replicaReader struct {
+	cluster *Cluster
+}
+
+// Exec runs on the primary, as replicas are read-only.
+func (r *replicaReader) Exec(query string, args ...any) (sql.Result, error) {
+	return r.cluster.DB.Exec(query, args...)
+}
+
+func (r *replicaReader) Query(query string, args ...any) (*sql.Rows, error) {
+	for _, replica := range r.cluster.inTurn() {
+		rows, err := replica.Query(query, args...)
+		if err == nil {
+			return rows, nil
+		}
+		log.Printf("read replica query failed, falling back: %v", err)
+	}
+	return r.cluster.DB.Query(query, args...)
+}
+
+func (r *replicaReader) QueryRow(query string, args ...any) *sql.Row {
+	for _, replica := range r.cluster.inTurn() {
+		row := replica.QueryRow(query, args...)
+		if err := row.Err(); err != nil {
+			log.Printf("read replica query failed, falling back: %v", err)
+			continue
+		}
+		return row
+	}
+	return r.cluster.DB.QueryRow(query, args...)
+}
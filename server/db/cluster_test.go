@@ -0,0 +1,96 @@
+package db
+
+import (
+	"path/filepath"
+	"server/config"
+	"server/db/queries"
+	"server/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterReader(t *testing.T) {
+	sqliteURL := func(t *testing.T) string {
+		return "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+	}
+
+	// countUsers counts the users with a query run on the reader of db.
+	countUsers := func(t *testing.T, db queries.DBTX) int {
+		var count int
+		require.NoError(t, queries.Reader(db).QueryRow("SELECT COUNT(*) FROM users").Scan(&count))
+		return count
+	}
+
+	addUser := func(t *testing.T, db queries.DBTX, email string) {
+		_, err := queries.CreateUser(db, &models.User{Email: email, PasswordHash: "hash"})
+		require.NoError(t, err)
+	}
+
+	t.Run("reads from the primary without replicas", func(t *testing.T) {
+		cluster, err := OpenCluster(config.DBConfig{URL: sqliteURL(t)})
+		require.NoError(t, err)
+		t.Cleanup(func() { cluster.Close() })
+
+		addUser(t, cluster, "primary@example.com")
+
+		assert.Equal(t, 1, countUsers(t, cluster))
+	})
+
+	t.Run("reads from the replicas in turn", func(t *testing.T) {
+		replicaURLs := []string{sqliteURL(t), sqliteURL(t)}
+		for i, url := range replicaURLs {
+			replica, err := InitDB(config.DBConfig{URL: url})
+			require.NoError(t, err)
+			for j := 0; j <= i; j++ {
+				addUser(t, replica, string(rune('a'+j))+"@example.com")
+			}
+			replica.Close()
+		}
+
+		cluster, err := OpenCluster(config.DBConfig{URL: sqliteURL(t), ReplicaURLs: replicaURLs})
+		require.NoError(t, err)
+		t.Cleanup(func() { cluster.Close() })
+
+		counts := []int{countUsers(t, cluster), countUsers(t, cluster)}
+		assert.ElementsMatch(t, []int{1, 2}, counts)
+
+		rows, err := queries.Reader(cluster).Query("SELECT email FROM users")
+		require.NoError(t, err)
+		rows.Close()
+	})
+
+	t.Run("falls back to the primary when the replicas fail", func(t *testing.T) {
+		unreachable := "sqlite://" + filepath.Join(t.TempDir(), "missing", "replica.db")
+
+		cluster, err := OpenCluster(config.DBConfig{URL: sqliteURL(t), ReplicaURLs: []string{unreachable}})
+		require.NoError(t, err)
+		t.Cleanup(func() { cluster.Close() })
+
+		addUser(t, cluster, "primary@example.com")
+
+		assert.Equal(t, 1, countUsers(t, cluster))
+
+		emails, err := queries.Reader(cluster).Query("SELECT email FROM users")
+		require.NoError(t, err)
+		defer emails.Close()
+		require.True(t, emails.Next())
+	})
+
+	t.Run("transactions read from the primary", func(t *testing.T) {
+		unreachable := "sqlite://" + filepath.Join(t.TempDir(), "missing", "replica.db")
+
+		cluster, err := OpenCluster(config.DBConfig{URL: sqliteURL(t), ReplicaURLs: []string{unreachable}})
+		require.NoError(t, err)
+		t.Cleanup(func() { cluster.Close() })
+
+		err = queries.InTx(cluster, func(tx queries.DBTX) error {
+			addUser(t, tx, "primary@example.com")
+			assert.Equal(t, 1, countUsers(t, tx))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+}
@@ -4,13 +4,19 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"server/config"
+	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 // schemaFS adds the ability to embed files into the binary,
-// bundling the schema files into the binary and reading them at runtime
+// bundling the schema files into the binary and reading them at runtime.
+// Each driver has its own schema, in schema/<driver>/init.sql.
 //
-//go:embed schema/init.sql
+//go:embed schema/postgres/init.sql schema/sqlite/init.sql
 var schemaFS embed.FS
 
 type RetryConfig struct {
@@ -27,18 +33,20 @@ var DefaultRetryConfig = RetryConfig{
 	BackoffMultiplier: 2.0,
 }
 
-func InitDB(dbURL string) (*sql.DB, error) {
-	return InitDBWithRetry(dbURL, DefaultRetryConfig)
+// InitDB connects to the database of cfg, with the driver selected by the scheme
+// of its URL, and creates its schema.
+func InitDB(cfg config.DBConfig) (*sql.DB, error) {
+	return InitDBWithRetry(cfg, DefaultRetryConfig)
 }
 
-func InitDBWithRetry(dbURL string, config RetryConfig) (*sql.DB, error) {
-	db, err := connectToDBWithRetry(dbURL, config)
+func InitDBWithRetry(cfg config.DBConfig, retry RetryConfig) (*sql.DB, error) {
+	db, err := connectToDBWithRetry(cfg, retry)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if err := executeSchema(db); err != nil {
+	if err := executeSchema(db, cfg.Driver()); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("error executing schema: %w", err)
 	}
@@ -46,7 +54,66 @@ func InitDBWithRetry(dbURL string, config RetryConfig) (*sql.DB, error) {
 	return db, nil
 }
 
-func connectToDBWithRetry(dbURL string, config RetryConfig) (*sql.DB, error) {
+// openDB opens the database of cfg, without connecting to it yet. PostgreSQL is
+// accessed with pgx, caching the prepared statements of each connection.
+func openDB(cfg config.DBConfig) (*sql.DB, error) {
+	var db *sql.DB
+	switch cfg.Driver() {
+	case config.DriverSQLite:
+		var err error
+		if db, err = sql.Open("sqlite", dataSource(cfg)); err != nil {
+			return nil, err
+		}
+	default:
+		connConfig, err := pgx.ParseConfig(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		connConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+		if cfg.StatementCacheCapacity <= 0 {
+			// Without a cache, statements are prepared anew for every query, which
+			// also works behind poolers that do not keep prepared statements.
+			connConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+		}
+		db = stdlib.OpenDB(*connConfig)
+	}
+
+	configurePool(db, cfg)
+	return db, nil
+}
+
+// configurePool applies the pool settings of cfg to db.
+func configurePool(db *sql.DB, cfg config.DBConfig) {
+	if cfg.Driver() == config.DriverSQLite {
+		// SQLite allows a single writer, and every connection to :memory: is a
+		// database of its own, so the pool is limited to one connection, never closed.
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		return
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// dataSource returns the data source name of cfg, with the connection settings
+// the queries rely on for SQLite.
+func dataSource(cfg config.DBConfig) string {
+	dsn := cfg.DataSource()
+	if cfg.Driver() != config.DriverSQLite {
+		return dsn
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + sqliteParams
+}
+
+func connectToDBWithRetry(cfg config.DBConfig, config RetryConfig) (*sql.DB, error) {
 	var (
 		db      *sql.DB
 		err     error
@@ -54,7 +121,7 @@ func connectToDBWithRetry(dbURL string, config RetryConfig) (*sql.DB, error) {
 	)
 
 	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-		db, err = connectToDB(dbURL)
+		db, err = connectToDB(cfg)
 		if err == nil {
 			return db, nil
 		}
@@ -73,8 +140,8 @@ func connectToDBWithRetry(dbURL string, config RetryConfig) (*sql.DB, error) {
 	return nil, fmt.Errorf("error connecting to the database: %w", err)
 }
 
-func connectToDB(dbURL string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", dbURL)
+func connectToDB(cfg config.DBConfig) (*sql.DB, error) {
+	db, err := openDB(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
@@ -87,8 +154,8 @@ func connectToDB(dbURL string) (*sql.DB, error) {
 	return db, nil
 }
 
-func executeSchema(db *sql.DB) error {
-	schema, err := schemaFS.ReadFile("schema/init.sql")
+func executeSchema(db *sql.DB, driver string) error {
+	schema, err := schemaFS.ReadFile("schema/" + driver + "/init.sql")
 	if err != nil {
 		db.Close()
 		return fmt.Errorf("error reading schema file: %w", err)
@@ -0,0 +1,194 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// PostgreSQL error codes of the constraint violations reported to the repositories.
+// SQLite errors are reported with the same codes.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so every query can run on its own
+// or as part of a transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Copier is implemented by DBTX that can bulk load rows with the PostgreSQL COPY protocol.
+type Copier interface {
+	CopyFrom(table string, columns []string, rows [][]any) (int64, error)
+}
+
+// Tx is a transaction started with Begin.
+type Tx interface {
+	DBTX
+	Commit() error
+	Rollback() error
+}
+
+// Begin starts a transaction on a connection of db. On PostgreSQL, the transaction
+// is a Copier.
+func Begin(db *sql.DB) (Tx, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tx := &connTx{Tx: sqlTx, conn: conn, driver: db.Driver()}
+
+	var isPgx bool
+	conn.Raw(func(driverConn any) error {
+		_, isPgx = driverConn.(*stdlib.Conn)
+		return nil
+	})
+	if isPgx {
+		return &pgxTx{tx}, nil
+	}
+	return tx, nil
+}
+
+// connTx is a transaction releasing its connection when it ends.
+type connTx struct {
+	*sql.Tx
+	conn   *sql.Conn
+	driver driver.Driver
+}
+
+// Driver returns the driver of the database of the transaction.
+func (t *connTx) Driver() driver.Driver {
+	return t.driver
+}
+
+func (t *connTx) Commit() error {
+	defer t.conn.Close()
+	return t.Tx.Commit()
+}
+
+func (t *connTx) Rollback() error {
+	defer t.conn.Close()
+	return t.Tx.Rollback()
+}
+
+// pgxTx is a transaction on a pgx connection.
+type pgxTx struct {
+	*connTx
+}
+
+// CopyFrom loads rows in table with COPY, as part of the transaction.
+func (t *pgxTx) CopyFrom(table string, columns []string, rows [][]any) (int64, error) {
+	var copied int64
+	err := t.conn.Raw(func(driverConn any) error {
+		var err error
+		copied, err = driverConn.(*stdlib.Conn).Conn().CopyFrom(context.Background(), pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+		return err
+	})
+	return copied, err
+}
+
+// Reader returns the DBTX to run read-only queries of db on: its read replicas if db
+// has any, and db itself otherwise, including in a transaction. Replicas may lag
+// behind, so it is only meant for reads that do not need to see the latest writes.
+func Reader(db DBTX) DBTX {
+	if replicated, ok := db.(interface{ Reader() DBTX }); ok {
+		return replicated.Reader()
+	}
+	return db
+}
+
+// InTx runs fn in a transaction, committed if fn returns nil and rolled back otherwise.
+// If db is already a transaction, fn runs in it and committing is left to its owner.
+func InTx(db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(interface{ Begin() (*sql.Tx, error) })
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// skipLocked returns the clause making a SELECT lock its rows and skip the rows locked
+// by other transactions. SQLite has neither, as a single writer runs at a time, so it
+// is left out when db is known to be a SQLite database.
+func skipLocked(db DBTX) string {
+	if isSQLite(db) {
+		return ""
+	}
+	return " FOR UPDATE SKIP LOCKED"
+}
+
+// forUpdate returns the clause making a SELECT lock its rows until the end of the
+// transaction, left out on SQLite like skipLocked.
+func forUpdate(db DBTX) string {
+	if isSQLite(db) {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// isSQLite reports whether db is known to be a SQLite database.
+func isSQLite(db DBTX) bool {
+	if conn, ok := db.(interface{ Driver() driver.Driver }); ok {
+		_, isSQLite := conn.Driver().(*sqlite.Driver)
+		return isSQLite
+	}
+	return false
+}
+
+// IsUniqueViolation reports whether err was caused by a unique constraint violation.
+func IsUniqueViolation(err error) bool {
+	return errorCode(err) == uniqueViolation
+}
+
+// IsForeignKeyViolation reports whether err was caused by a foreign key violation,
+// such as a row referencing a user that does not exist.
+func IsForeignKeyViolation(err error) bool {
+	return errorCode(err) == foreignKeyViolation
+}
+
+// errorCode returns the PostgreSQL error code of err, if it comes from the database.
+func errorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return uniqueViolation
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return foreignKeyViolation
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package queries
+
+import (
+	"database/sql"
+	"encoding/json"
+	"server/internal/models"
+	"time"
+)
+
+// DeleteExpiredIdempotencyKey deletes the key of scope if it was created before the given time.
+// before is passed in UTC, as SQLite compares times as text.
+func DeleteExpiredIdempotencyKey(db DBTX, scope, key string, before time.Time) error {
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND created_at < $3", scope, key, before.UTC())
+	return err
+}
+
+// InsertIdempotencyKey stores a key in progress. It reports false if the key already exists.
+func InsertIdempotencyKey(db DBTX, record *models.IdempotencyRecord) (bool, error) {
+	result, err := db.Exec("INSERT INTO idempotency_keys (scope, key, fingerprint) VALUES ($1, $2, $3) ON CONFLICT (scope, key) DO NOTHING",
+		record.Scope, record.Key, record.Fingerprint)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// scanIdempotencyRecord maps a row of scope, key, fingerprint, status_code, content_type,
+// response_body, response_headers and created_at.
+func scanIdempotencyRecord(row rowScanner, record *models.IdempotencyRecord) error {
+	var statusCode sql.NullInt64
+	var headers string
+	if err := row.Scan(&record.Scope, &record.Key, &record.Fingerprint, &statusCode, &record.ContentType, &record.Body, &headers, &record.CreatedAt); err != nil {
+		return err
+	}
+	record.StatusCode = int(statusCode.Int64)
+	return json.Unmarshal([]byte(headers), &record.Headers)
+}
+
+// GetIdempotencyKey retrieves a key of scope.
+//
+// If no key is found, it returns (nil, nil).
+func GetIdempotencyKey(db DBTX, scope, key string) (*models.IdempotencyRecord, error) {
+	return queryOne(db.QueryRow("SELECT scope, key, fingerprint, status_code, content_type, response_body, response_headers, created_at FROM idempotency_keys WHERE scope = $1 AND key = $2",
+		scope, key), scanIdempotencyRecord)
+}
+
+// CompleteIdempotencyKey stores the response of a key in progress.
+func CompleteIdempotencyKey(db DBTX, record *models.IdempotencyRecord) error {
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		return err
+	}
+	if record.Headers == nil {
+		headers = []byte("{}")
+	}
+	_, err = db.Exec("UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5, response_headers = $6 WHERE scope = $1 AND key = $2",
+		record.Scope, record.Key, record.StatusCode, record.ContentType, record.Body, string(headers))
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys deletes the keys of every scope created before the given
+// time and returns how many there were.
+func PurgeExpiredIdempotencyKeys(db DBTX, before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM idempotency_keys WHERE created_at < $1", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteIdempotencyKey deletes a key of scope.
+func DeleteIdempotencyKey(db DBTX, scope, key string) error {
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2", scope, key)
+	return err
+}
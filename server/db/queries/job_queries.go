@@ -0,0 +1,98 @@
+package queries
+
+import (
+	"database/sql"
+	"server/internal/models"
+	"time"
+)
+
+const jobColumns = "id, type, payload, status, attempts, max_attempts, run_at, last_error, unique_key, created_at"
+
+// EnqueueJob stores a pending job and returns its ID. If a job with the same unique
+// key is already queued, nothing is stored and "" is returned. Times are passed in UTC,
+// as SQLite compares times as text.
+func EnqueueJob(db DBTX, job *models.Job) (string, error) {
+	var jobID string
+	err := db.QueryRow(`
+		INSERT INTO jobs (type, payload, max_attempts, run_at, unique_key) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unique_key) DO NOTHING
+		RETURNING id`,
+		job.Type, string(job.Payload), job.MaxAttempts, job.RunAt.UTC(), nullString(job.UniqueKey)).
+		Scan(&jobID)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return jobID, nil
+}
+
+// scanJob maps a row of jobColumns.
+func scanJob(row rowScanner, job *models.Job) error {
+	var (
+		payload   string
+		status    string
+		lastError sql.NullString
+		uniqueKey sql.NullString
+	)
+	if err := row.Scan(&job.ID, &job.Type, &payload, &status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &lastError, &uniqueKey, &job.CreatedAt); err != nil {
+		return err
+	}
+	job.Payload = []byte(payload)
+	job.Status = models.JobStatus(status)
+	job.LastError = lastError.String
+	job.UniqueKey = uniqueKey.String
+	return nil
+}
+
+// ClaimJob marks the next due job as running, counting an attempt, and returns it.
+// Running jobs locked before staleBefore are claimed again, as their worker is
+// assumed to have stopped. On PostgreSQL, concurrent workers skip the jobs being
+// claimed by each other.
+//
+// If no job is due, it returns (nil, nil).
+func ClaimJob(db DBTX, staleBefore time.Time) (*models.Job, error) {
+	return queryOne(db.QueryRow(`
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW()) OR (status = 'running' AND locked_at < $1)
+			ORDER BY run_at
+			LIMIT 1`+skipLocked(db)+`
+		)
+		RETURNING `+jobColumns, staleBefore.UTC()), scanJob)
+}
+
+// GetJob retrieves a queued or dead job by its ID.
+//
+// If no job is found, it returns (nil, nil).
+func GetJob(db DBTX, jobID string) (*models.Job, error) {
+	return queryOne(db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = $1", jobID), scanJob)
+}
+
+// DeleteJob deletes a succeeded job.
+func DeleteJob(db DBTX, jobID string) error {
+	_, err := db.Exec("DELETE FROM jobs WHERE id = $1", jobID)
+	return err
+}
+
+// RescheduleJob makes a failed job pending again, to run at runAt.
+func RescheduleJob(db DBTX, jobID string, runAt time.Time, lastError string) error {
+	_, err := db.Exec("UPDATE jobs SET status = 'pending', run_at = $2, last_error = $3, locked_at = NULL, updated_at = NOW() WHERE id = $1",
+		jobID, runAt.UTC(), lastError)
+	return err
+}
+
+// BuryJob marks a failed job as dead, so it is no longer run.
+func BuryJob(db DBTX, jobID string, lastError string) error {
+	_, err := db.Exec("UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = NOW() WHERE id = $1",
+		jobID, lastError)
+	return err
+}
+
+// nullString maps "" to NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
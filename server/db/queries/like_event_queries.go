@@ -0,0 +1,30 @@
+package queries
+
+import (
+	"encoding/json"
+	"server/internal/models"
+)
+
+// LikeEventsChannel is the PostgreSQL channel the like events are notified on.
+const LikeEventsChannel = "like_events"
+
+// NotifyLikeEvents notifies events on LikeEventsChannel, one notification each so none
+// exceeds the payload limit. In a transaction, they are only sent once it commits.
+// SQLite has no notifications, as its database is never shared by several servers,
+// so nothing is sent when db is known to be a SQLite database.
+func NotifyLikeEvents(db DBTX, events []models.LikeStreamEvent) error {
+	if isSQLite(db) {
+		return nil
+	}
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec("SELECT pg_notify($1, $2)", LikeEventsChannel, string(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,62 +3,166 @@ package queries
 import (
 	"database/sql"
 	"log"
-
-	_ "github.com/lib/pq"
+	"server/internal/models"
+	"time"
 )
 
-// AddLikedImage adds an image URL to the list of liked images for a given user.
-func AddLikedImage(db *sql.DB, userID, imageURL string) error {
-	_, err := db.Exec("INSERT INTO liked_images (user_id, image_url) VALUES ($1, $2)", userID, imageURL)
-	if err != nil {
-		return err
-	}
-	return nil
+// copyThreshold is the number of images from which AddLikedImages loads them with
+// COPY rather than one statement per image.
+const copyThreshold = 10
+
+// likeImage is the statement liking an image. Unlikes are soft deletes, so liking an
+// unliked image restores its row as a new like. It changes no row if the image is
+// already liked.
+const likeImage = `
+	INSERT INTO liked_images (user_id, image_url) VALUES ($1, $2)
+	ON CONFLICT (user_id, image_url) DO UPDATE SET deleted_at = NULL, created_at = NOW()
+	WHERE liked_images.deleted_at IS NOT NULL`
+
+// AddLikedImage adds an image URL to the list of liked images for a given user and
+// reports whether it was added, false if the user already liked the image. It does
+// not fail on a second like, so it can be used within a transaction without aborting
+// it. The user's likes version is bumped by a trigger.
+func AddLikedImage(db DBTX, userID, imageURL string) (bool, error) {
+	return execChanged(db, likeImage, userID, imageURL)
 }
 
-// RemoveLikedImage removes the like for a given image URL by a specific user.
-func RemoveLikedImage(db *sql.DB, userID, imageURL string) error {
+// RemoveLikedImage unlikes an image for a given user and reports whether it was liked.
+// The like is only marked as deleted, so it can be restored by RestoreLikedImage until
+// it is purged. The user's likes version is bumped by a trigger.
+func RemoveLikedImage(db DBTX, userID, imageURL string) (bool, error) {
 	log.Println("Removing liked image")
-	_, err := db.Exec("DELETE FROM liked_images WHERE user_id = $1 AND image_url = $2", userID, imageURL)
+	return execChanged(db, "UPDATE liked_images SET deleted_at = NOW() WHERE user_id = $1 AND image_url = $2 AND deleted_at IS NULL", userID, imageURL)
+}
+
+// RestoreLikedImage restores a like removed after since, keeping its original date,
+// and reports whether there was one. The user's likes version is bumped by a trigger.
+func RestoreLikedImage(db DBTX, userID, imageURL string, since time.Time) (bool, error) {
+	return execChanged(db, "UPDATE liked_images SET deleted_at = NULL WHERE user_id = $1 AND image_url = $2 AND deleted_at > $3", userID, imageURL, since.UTC())
+}
+
+// PurgeDeletedLikedImages permanently deletes the likes removed before the given time
+// and returns how many there were. Likes versions are left as they are, since the
+// purged likes were already removed from the lists.
+func PurgeDeletedLikedImages(db DBTX, before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM liked_images WHERE deleted_at < $1", before.UTC())
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return result.RowsAffected()
 }
 
-// GetLikedImages retrieves a list of image URLs liked by a specific user.
-func GetLikedImages(db *sql.DB, userID string) ([]string, error) {
-	var images []string
-	rows, err := db.Query("SELECT image_url FROM liked_images WHERE user_id = $1", userID)
-	if err == sql.ErrNoRows {
-		return []string{}, nil
+// execChanged runs a statement changing at most one liked image and reports whether it did.
+func execChanged(db DBTX, query string, args ...any) (bool, error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return false, err
 	}
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
+	return rows == 1, nil
+}
 
-	for rows.Next() {
-		var imageURL string
-		if err := rows.Scan(&imageURL); err != nil {
-			return nil, err
+// AddLikedImages likes several images with AddLikedImage and reports which
+// were added, in order. An image listed twice is only added the first time.
+//
+// When db can COPY and there are enough images, they are loaded in a temporary table
+// and liked with a single statement. This must then run in a transaction.
+func AddLikedImages(db DBTX, userID string, imageURLs []string) ([]bool, error) {
+	copier, ok := db.(Copier)
+	if !ok || len(imageURLs) < copyThreshold {
+		added := make([]bool, len(imageURLs))
+		for i, imageURL := range imageURLs {
+			var err error
+			if added[i], err = AddLikedImage(db, userID, imageURL); err != nil {
+				return nil, err
+			}
 		}
-		images = append(images, imageURL)
+		return added, nil
 	}
 
-	if err := rows.Err(); err != nil {
+	if _, err := db.Exec("CREATE TEMPORARY TABLE liked_images_import (position INTEGER NOT NULL, image_url TEXT NOT NULL) ON COMMIT DROP"); err != nil {
+		return nil, err
+	}
+	values := make([][]any, len(imageURLs))
+	for i, imageURL := range imageURLs {
+		values[i] = []any{i, imageURL}
+	}
+	if _, err := copier.CopyFrom("liked_images_import", []string{"position", "image_url"}, values); err != nil {
 		return nil, err
 	}
 
-	return images, nil
+	// An upsert cannot change a row twice, so each image is only inserted once.
+	rows, err := db.Query(`
+		INSERT INTO liked_images (user_id, image_url)
+		SELECT $1::uuid, image_url FROM (
+			SELECT DISTINCT ON (image_url) image_url, position
+			FROM liked_images_import ORDER BY image_url, position
+		) AS first_occurrences ORDER BY position
+		ON CONFLICT (user_id, image_url) DO UPDATE SET deleted_at = NULL, created_at = NOW()
+		WHERE liked_images.deleted_at IS NOT NULL
+		RETURNING image_url`, userID)
+	inserted, err := queryAll(rows, err, scanString)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("DROP TABLE liked_images_import"); err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]bool, len(inserted))
+	for _, imageURL := range inserted {
+		pending[imageURL] = true
+	}
+	added := make([]bool, len(imageURLs))
+	for i, imageURL := range imageURLs {
+		added[i] = pending[imageURL]
+		delete(pending, imageURL)
+	}
+	return added, nil
+}
+
+// GetLikedImages retrieves a list of image URLs liked by a specific user, leaving out
+// the unliked ones.
+func GetLikedImages(db DBTX, userID string) ([]string, error) {
+	rows, err := db.Query("SELECT image_url FROM liked_images WHERE user_id = $1 AND deleted_at IS NULL", userID)
+	return queryAll(rows, err, scanString)
 }
 
-// GetLikedImage reports whether a user has liked a specific image.
-func GetLikedImage(db *sql.DB, userID, imageURL string) (bool, error) {
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM liked_images WHERE user_id = $1 AND image_url = $2)", userID, imageURL).Scan(&exists)
+// GetLikesVersion retrieves the likes version of a user. Users who never liked an
+// image have version 0 and a zero UpdatedAt.
+func GetLikesVersion(db DBTX, userID string) (models.LikesVersion, error) {
+	return scanLikesVersion(db.QueryRow("SELECT version, updated_at FROM liked_images_versions WHERE user_id = $1", userID))
+}
+
+// LockLikesVersion retrieves the likes version of a user like GetLikesVersion and
+// locks it until the end of the transaction db is part of, so concurrent changes to
+// the likes of the user are checked against their version one at a time. The version
+// of users who never liked an image is created first, for it to be locked too.
+func LockLikesVersion(db DBTX, userID string) (models.LikesVersion, error) {
+	_, err := db.Exec("INSERT INTO liked_images_versions (user_id, version, updated_at) VALUES ($1, 0, NULL) ON CONFLICT (user_id) DO NOTHING", userID)
 	if err != nil {
-		return false, err
+		return models.LikesVersion{}, err
+	}
+	return scanLikesVersion(db.QueryRow("SELECT version, updated_at FROM liked_images_versions WHERE user_id = $1"+forUpdate(db), userID))
+}
+
+// scanLikesVersion scans a likes version, a missing one being version 0. Versions
+// created by LockLikesVersion have no update time until the first change.
+func scanLikesVersion(row *sql.Row) (models.LikesVersion, error) {
+	var (
+		version   models.LikesVersion
+		updatedAt sql.NullTime
+	)
+	err := row.Scan(&version.Version, &updatedAt)
+	if err == sql.ErrNoRows {
+		return models.LikesVersion{}, nil
+	}
+	if err != nil {
+		return models.LikesVersion{}, err
 	}
-	return exists, nil
+	version.UpdatedAt = updatedAt.Time
+	return version, nil
 }
@@ -0,0 +1,104 @@
+package queries
+
+import (
+	"database/sql"
+	"server/internal/models"
+	"time"
+)
+
+// scanUserMFA maps a row of user_id, totp_secret, enabled, last_used_counter, failed_attempts and locked_until.
+func scanUserMFA(row rowScanner, mfa *models.UserMFA) error {
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&mfa.UserID, &mfa.Secret, &mfa.Enabled, &mfa.LastUsedCounter, &mfa.FailedAttempts, &lockedUntil); err != nil {
+		return err
+	}
+	if lockedUntil.Valid {
+		mfa.LockedUntil = &lockedUntil.Time
+	}
+	return nil
+}
+
+// GetUserMFA retrieves the TOTP enrollment of a user.
+//
+// If the user never enrolled, it returns (nil, nil).
+func GetUserMFA(db DBTX, userID string) (*models.UserMFA, error) {
+	return queryOne(db.QueryRow("SELECT user_id, totp_secret, enabled, last_used_counter, failed_attempts, locked_until FROM user_mfa WHERE user_id = $1", userID),
+		scanUserMFA)
+}
+
+// UpsertPendingMFA stores a new, not yet confirmed, TOTP secret for a user.
+// It does not overwrite an enabled enrollment.
+func UpsertPendingMFA(db DBTX, userID, secret string) error {
+	_, err := db.Exec(`INSERT INTO user_mfa (user_id, totp_secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET totp_secret = EXCLUDED.totp_secret, last_used_counter = 0,
+		failed_attempts = 0, locked_until = NULL, created_at = NOW()
+		WHERE user_mfa.enabled = FALSE`, userID, secret)
+	return err
+}
+
+// EnableMFA marks the enrollment as confirmed and replaces the user's recovery codes.
+func EnableMFA(db DBTX, userID string, counter int64, recoveryCodeHashes []string) error {
+	return InTx(db, func(tx DBTX) error {
+		_, err := tx.Exec("UPDATE user_mfa SET enabled = TRUE, confirmed_at = NOW(), last_used_counter = $2, failed_attempts = 0 WHERE user_id = $1", userID, counter)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID)
+		if err != nil {
+			return err
+		}
+
+		for _, hash := range recoveryCodeHashes {
+			_, err = tx.Exec("INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteMFA removes the enrollment and recovery codes of a user.
+func DeleteMFA(db DBTX, userID string) error {
+	return InTx(db, func(tx DBTX) error {
+		if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM user_mfa WHERE user_id = $1", userID)
+		return err
+	})
+}
+
+// AdvanceMFACounter records a successful TOTP verification.
+// It reports false when a code of the same or a later time step was already used,
+// which rejects replays even under concurrent requests.
+func AdvanceMFACounter(db DBTX, userID string, counter int64) (bool, error) {
+	res, err := db.Exec("UPDATE user_mfa SET last_used_counter = $2, failed_attempts = 0, locked_until = NULL WHERE user_id = $1 AND last_used_counter < $2", userID, counter)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode marks a recovery code as used. It reports false if the code does not exist or was already used.
+func UseRecoveryCode(db DBTX, userID, codeHash string) (bool, error) {
+	res, err := db.Exec("UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL", userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RecordMFAFailure increments the failed attempts of a user and locks the second factor
+// until lockUntil once maxAttempts is reached.
+func RecordMFAFailure(db DBTX, userID string, maxAttempts int, lockUntil time.Time) error {
+	_, err := db.Exec(`UPDATE user_mfa SET
+		failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+		locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE user_id = $1`, userID, maxAttempts, lockUntil.UTC())
+	return err
+}
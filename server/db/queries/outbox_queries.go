@@ -0,0 +1,43 @@
+package queries
+
+import "server/internal/models"
+
+// InsertOutboxEvents stores events in the outbox, in order.
+func InsertOutboxEvents(db DBTX, events []models.OutboxEvent) error {
+	for _, event := range events {
+		_, err := db.Exec("INSERT INTO outbox_events (type, user_id, data) VALUES ($1, $2, $3)",
+			event.Type, event.UserID, string(event.Data))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanOutboxEvent maps a row of id, type, user_id, data and created_at.
+func scanOutboxEvent(row rowScanner, event *models.OutboxEvent) error {
+	var data string
+	if err := row.Scan(&event.ID, &event.Type, &event.UserID, &data, &event.CreatedAt); err != nil {
+		return err
+	}
+	event.Data = []byte(data)
+	return nil
+}
+
+// ClaimOutboxEvents retrieves up to limit events, oldest first, locking them until the
+// end of the transaction. On PostgreSQL, events locked by another transaction are
+// skipped, so relays can run concurrently.
+func ClaimOutboxEvents(db DBTX, limit int) ([]models.OutboxEvent, error) {
+	rows, err := db.Query("SELECT id, type, user_id, data, created_at FROM outbox_events ORDER BY seq LIMIT $1"+skipLocked(db), limit)
+	return queryAll(rows, err, scanOutboxEvent)
+}
+
+// DeleteOutboxEvents deletes relayed events.
+func DeleteOutboxEvents(db DBTX, eventIDs []string) error {
+	for _, eventID := range eventIDs {
+		if _, err := db.Exec("DELETE FROM outbox_events WHERE id = $1", eventID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package queries
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanFunc maps the current row of a query to a T.
+type scanFunc[T any] func(row rowScanner, value *T) error
+
+// queryOne maps the row of a query returning at most one row.
+//
+// If there is no row, it returns (nil, nil).
+func queryOne[T any](row *sql.Row, scan scanFunc[T]) (*T, error) {
+	var value T
+	err := scan(row, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// queryAll maps every row of a query, taking the results of db.Query as is.
+func queryAll[T any](rows *sql.Rows, err error, scan scanFunc[T]) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []T
+	for rows.Next() {
+		var value T
+		if err := scan(rows, &value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// scanString maps a row of a single text column.
+func scanString(row rowScanner, value *string) error {
+	return row.Scan(value)
+}
@@ -0,0 +1,76 @@
+package queries
+
+import (
+	"database/sql"
+	"server/internal/models"
+	"time"
+)
+
+const sessionColumns = "id, user_id, user_agent, ip_address, created_at, last_seen_at, revoked_at"
+
+// CreateSession stores a new session and returns its ID.
+func CreateSession(db DBTX, session *models.Session) (string, error) {
+	var sessionID string
+	err := db.QueryRow("INSERT INTO sessions (user_id, user_agent, ip_address) VALUES ($1, $2, $3) RETURNING id",
+		session.UserID, session.UserAgent, session.IPAddress).
+		Scan(&sessionID)
+
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// scanSession maps a row of sessionColumns.
+func scanSession(row rowScanner, session *models.Session) error {
+	var revokedAt sql.NullTime
+	if err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastSeenAt, &revokedAt); err != nil {
+		return err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return nil
+}
+
+// GetSession retrieves a session by its ID, revoked or not.
+//
+// If no session is found, it returns (nil, nil).
+func GetSession(db DBTX, sessionID string) (*models.Session, error) {
+	return queryOne(db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = $1", sessionID), scanSession)
+}
+
+// GetActiveSessions retrieves the sessions of a user that are not revoked and were seen after since,
+// most recently seen first. since is passed in UTC, as SQLite compares times as text.
+func GetActiveSessions(db DBTX, userID string, since time.Time) ([]models.Session, error) {
+	rows, err := db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2 ORDER BY last_seen_at DESC",
+		userID, since.UTC())
+	return queryAll(rows, err, scanSession)
+}
+
+// TouchSession updates the last seen time of an active session.
+func TouchSession(db DBTX, sessionID string) error {
+	_, err := db.Exec("UPDATE sessions SET last_seen_at = NOW() WHERE id = $1 AND revoked_at IS NULL", sessionID)
+	return err
+}
+
+// PurgeSessions deletes the sessions revoked or last seen before the given time and
+// returns how many there were. before is passed in UTC, as SQLite compares times as text.
+func PurgeSessions(db DBTX, before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM sessions WHERE revoked_at < $1 OR last_seen_at < $1", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RevokeSession revokes an active session of a user.
+// It reports false when the user has no such active session.
+func RevokeSession(db DBTX, userID, sessionID string) (bool, error) {
+	res, err := db.Exec("UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,37 @@
+package queries
+
+import (
+	"database/sql"
+	"server/internal/models"
+)
+
+// scanUserIdentity maps a row of id, user_id, provider, subject, email and created_at.
+func scanUserIdentity(row rowScanner, identity *models.UserIdentity) error {
+	var email sql.NullString
+	if err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &email, &identity.CreatedAt); err != nil {
+		return err
+	}
+	identity.Email = email.String
+	return nil
+}
+
+// GetUserIdentity retrieves the identity issued by a provider for the given subject.
+//
+// If no identity is found, it returns (nil, nil).
+func GetUserIdentity(db DBTX, provider, subject string) (*models.UserIdentity, error) {
+	return queryOne(db.QueryRow("SELECT id, user_id, provider, subject, email, created_at FROM user_identities WHERE provider = $1 AND subject = $2", provider, subject),
+		scanUserIdentity)
+}
+
+// CreateUserIdentity links an external identity to an existing user.
+func CreateUserIdentity(db DBTX, identity *models.UserIdentity) (string, error) {
+	var identityID string
+	err := db.QueryRow("INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4) RETURNING id",
+		identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identityID)
+
+	if err != nil {
+		return "", err
+	}
+	return identityID, nil
+}
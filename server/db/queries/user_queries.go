@@ -1,48 +1,38 @@
 package queries
 
 import (
-	"database/sql"
 	"log"
 	"server/internal/models"
-
-	_ "github.com/lib/pq"
 )
 
+const userColumns = "id, email, password_hash, created_at, updated_at"
+
+// scanUser maps a row of userColumns.
+func scanUser(row rowScanner, user *models.User) error {
+	return row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+}
+
 // GetUserByEmail retrieves a user from the database by their email address.
 //
 // If no user is found with the given email, it returns (nil, nil).
-func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
-	user := &models.User{}
-	err := db.QueryRow("SELECT id, email, password_hash, created_at, updated_at FROM users WHERE email = $1", email).
-		Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+func GetUserByEmail(db DBTX, email string) (*models.User, error) {
+	return queryOne(db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = $1", email), scanUser)
 }
 
 // GetUserByID retrieves a user from the database by their ID.
 //
 // If no user is found with the given ID, it returns (nil, nil).
-func GetUserByID(db *sql.DB, id string) (*models.User, error) {
-	user := &models.User{}
-	err := db.QueryRow("SELECT id, email, password_hash, created_at, updated_at FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+func GetUserByID(db DBTX, id string) (*models.User, error) {
+	return queryOne(db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id), scanUser)
+}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+// UpdatePasswordHash replaces the password hash of a user.
+func UpdatePasswordHash(db DBTX, userID, passwordHash string) error {
+	_, err := db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
+	return err
 }
 
-func CreateUser(db *sql.DB, user *models.User) (string, error) {
+func CreateUser(db DBTX, user *models.User) (string, error) {
 	log.Println("Creating user:", user.Email)
 	var userID string
 	err := db.QueryRow("INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id", user.Email, user.PasswordHash).
@@ -0,0 +1,139 @@
+package queries
+
+import (
+	"database/sql"
+	"server/internal/models"
+	"time"
+)
+
+const (
+	webhookColumns  = "id, user_id, url, secret, created_at"
+	deliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, response_status, error, created_at, attempted_at"
+)
+
+// CreateWebhook stores a webhook, owned by no user if its UserID is empty, and returns
+// its ID and creation time.
+func CreateWebhook(db DBTX, webhook *models.Webhook) (string, time.Time, error) {
+	var (
+		webhookID string
+		createdAt time.Time
+	)
+	err := db.QueryRow("INSERT INTO webhooks (user_id, url, secret) VALUES ($1, $2, $3) RETURNING id, created_at",
+		nullString(webhook.UserID), webhook.URL, webhook.Secret).
+		Scan(&webhookID, &createdAt)
+	return webhookID, createdAt, err
+}
+
+// scanWebhook maps a row of webhookColumns.
+func scanWebhook(row rowScanner, webhook *models.Webhook) error {
+	var userID sql.NullString
+	if err := row.Scan(&webhook.ID, &userID, &webhook.URL, &webhook.Secret, &webhook.CreatedAt); err != nil {
+		return err
+	}
+	webhook.UserID = userID.String
+	return nil
+}
+
+// GetWebhook retrieves a webhook by its ID.
+//
+// If no webhook is found, it returns (nil, nil).
+func GetWebhook(db DBTX, webhookID string) (*models.Webhook, error) {
+	return queryOne(db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", webhookID), scanWebhook)
+}
+
+// GetWebhooks retrieves the webhooks of a user, or the admin webhooks if userID is
+// empty, oldest first.
+func GetWebhooks(db DBTX, userID string) ([]models.Webhook, error) {
+	if userID == "" {
+		rows, err := db.Query("SELECT " + webhookColumns + " FROM webhooks WHERE user_id IS NULL ORDER BY created_at")
+		return queryAll(rows, err, scanWebhook)
+	}
+	rows, err := db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE user_id = $1 ORDER BY created_at", userID)
+	return queryAll(rows, err, scanWebhook)
+}
+
+// GetSubscribedWebhooks retrieves the webhooks receiving the events of a user: theirs
+// and the admin webhooks.
+func GetSubscribedWebhooks(db DBTX, userID string) ([]models.Webhook, error) {
+	rows, err := db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE user_id = $1 OR user_id IS NULL ORDER BY created_at", userID)
+	return queryAll(rows, err, scanWebhook)
+}
+
+// DeleteWebhook deletes a webhook with its deliveries and reports whether it existed.
+func DeleteWebhook(db DBTX, webhookID string) (bool, error) {
+	result, err := db.Exec("DELETE FROM webhooks WHERE id = $1", webhookID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// CreateWebhookDelivery stores a pending delivery and returns its ID and creation time.
+func CreateWebhookDelivery(db DBTX, delivery *models.WebhookDelivery) (string, time.Time, error) {
+	var (
+		deliveryID string
+		createdAt  time.Time
+	)
+	err := db.QueryRow("INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		delivery.WebhookID, delivery.EventID, delivery.EventType, string(delivery.Payload)).
+		Scan(&deliveryID, &createdAt)
+	return deliveryID, createdAt, err
+}
+
+// scanWebhookDelivery maps a row of deliveryColumns.
+func scanWebhookDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	var (
+		payload        string
+		status         string
+		responseStatus sql.NullInt64
+		deliveryErr    sql.NullString
+		attemptedAt    sql.NullTime
+	)
+	if err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload, &status, &delivery.Attempts,
+		&responseStatus, &deliveryErr, &delivery.CreatedAt, &attemptedAt); err != nil {
+		return err
+	}
+	delivery.Payload = []byte(payload)
+	delivery.Status = models.DeliveryStatus(status)
+	delivery.ResponseStatus = int(responseStatus.Int64)
+	delivery.Error = deliveryErr.String
+	if attemptedAt.Valid {
+		delivery.AttemptedAt = &attemptedAt.Time
+	}
+	return nil
+}
+
+// GetWebhookDelivery retrieves a delivery by its ID.
+//
+// If no delivery is found, it returns (nil, nil).
+func GetWebhookDelivery(db DBTX, deliveryID string) (*models.WebhookDelivery, error) {
+	return queryOne(db.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1", deliveryID), scanWebhookDelivery)
+}
+
+// GetWebhookDeliveries retrieves the latest deliveries of a webhook, most recent first.
+func GetWebhookDeliveries(db DBTX, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.Query("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2", webhookID, limit)
+	return queryAll(rows, err, scanWebhookDelivery)
+}
+
+// RecordWebhookDeliveryAttempt stores the outcome of an attempt of a delivery.
+func RecordWebhookDeliveryAttempt(db DBTX, deliveryID string, attempt models.DeliveryAttempt) error {
+	_, err := db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, response_status = $3, error = $4, attempted_at = NOW()
+		WHERE id = $1`,
+		deliveryID, string(attempt.Status), sql.NullInt64{Int64: int64(attempt.ResponseStatus), Valid: attempt.ResponseStatus != 0},
+		nullString(attempt.Error))
+	return err
+}
+
+// DeleteWebhookDeliveries deletes the deliveries created before the given time and
+// returns how many there were.
+func DeleteWebhookDeliveries(db DBTX, before time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM webhook_deliveries WHERE created_at < $1", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
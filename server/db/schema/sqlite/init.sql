@@ -0,0 +1,183 @@
+-- SQLite counterpart of postgres/init.sql, keep both in sync.
+--
+-- UUIDs are text, timestamps are text in UTC and booleans are integers. NOW() and
+-- gen_random_uuid() are registered by the db package, so queries and defaults
+-- are the same as in PostgreSQL.
+
+CREATE TABLE IF NOT EXISTS users (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  email TEXT NOT NULL UNIQUE,
+  password_hash TEXT NOT NULL,
+  created_at TIMESTAMP DEFAULT (NOW()),
+  updated_at TIMESTAMP DEFAULT (NOW())
+);
+
+-- Trigger for auto-updating 'updated_at', unless the update sets it
+CREATE TRIGGER IF NOT EXISTS set_updated_at
+AFTER UPDATE ON users
+FOR EACH ROW
+WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+  UPDATE users SET updated_at = NOW() WHERE id = NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS liked_images (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  image_url TEXT NOT NULL,
+  created_at TIMESTAMP DEFAULT (NOW()),
+  deleted_at TIMESTAMP,
+  UNIQUE(user_id, image_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_liked_images_user_id ON liked_images(user_id);
+CREATE INDEX IF NOT EXISTS idx_liked_images_deleted_at ON liked_images(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Bumped on every like and unlike, so clients can cache the list of liked images.
+CREATE TABLE IF NOT EXISTS liked_images_versions (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  version BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP DEFAULT (NOW())
+);
+
+-- Triggers for bumping the likes version on every like, unlike and undo.
+-- Purging unliked rows does not change the list, so deletes are left out.
+DROP TRIGGER IF EXISTS bump_liked_images_version_on_delete;
+
+CREATE TRIGGER IF NOT EXISTS bump_liked_images_version_on_insert
+AFTER INSERT ON liked_images
+FOR EACH ROW
+BEGIN
+  INSERT INTO liked_images_versions (user_id, version, updated_at)
+  VALUES (NEW.user_id, 1, NOW())
+  ON CONFLICT (user_id) DO UPDATE
+  SET version = liked_images_versions.version + 1, updated_at = NOW();
+END;
+
+CREATE TRIGGER IF NOT EXISTS bump_liked_images_version_on_update
+AFTER UPDATE OF deleted_at ON liked_images
+FOR EACH ROW
+BEGIN
+  INSERT INTO liked_images_versions (user_id, version, updated_at)
+  VALUES (NEW.user_id, 1, NOW())
+  ON CONFLICT (user_id) DO UPDATE
+  SET version = liked_images_versions.version + 1, updated_at = NOW();
+END;
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT,
+  created_at TIMESTAMP DEFAULT (NOW()),
+  UNIQUE(provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  totp_secret TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_used_counter BIGINT NOT NULL DEFAULT 0,
+  failed_attempts INTEGER NOT NULL DEFAULT 0,
+  locked_until TIMESTAMP,
+  created_at TIMESTAMP DEFAULT (NOW()),
+  confirmed_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMP,
+  UNIQUE(user_id, code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent TEXT NOT NULL DEFAULT '',
+  ip_address TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP DEFAULT (NOW()),
+  last_seen_at TIMESTAMP DEFAULT (NOW()),
+  revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  scope TEXT NOT NULL,
+  key TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  status_code INTEGER,
+  content_type TEXT NOT NULL DEFAULT '',
+  response_body BLOB,
+  response_headers TEXT NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP DEFAULT (NOW()),
+  PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+-- Background jobs. SQLite has a single writer, so claiming a job needs no row lock.
+-- Succeeded jobs are deleted and jobs out of attempts are kept as dead letters.
+CREATE TABLE IF NOT EXISTS jobs (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  type TEXT NOT NULL,
+  payload TEXT NOT NULL DEFAULT '{}',
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL,
+  run_at TIMESTAMP NOT NULL DEFAULT (NOW()),
+  locked_at TIMESTAMP,
+  last_error TEXT,
+  unique_key TEXT UNIQUE,
+  created_at TIMESTAMP DEFAULT (NOW()),
+  updated_at TIMESTAMP DEFAULT (NOW())
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_pending_run_at ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_running_locked_at ON jobs(locked_at) WHERE status = 'running';
+
+-- Transactional outbox: events are written in the transaction of the change they
+-- describe, then relayed to the webhooks in order of seq and deleted.
+CREATE TABLE IF NOT EXISTS outbox_events (
+  seq INTEGER PRIMARY KEY AUTOINCREMENT,
+  id TEXT NOT NULL UNIQUE DEFAULT (gen_random_uuid()),
+  type TEXT NOT NULL,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  data TEXT NOT NULL,
+  created_at TIMESTAMP DEFAULT (NOW())
+);
+
+-- Webhook endpoints, registered by a user for their events or, without a user, by
+-- an admin for the events of every user.
+CREATE TABLE IF NOT EXISTS webhooks (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  created_at TIMESTAMP DEFAULT (NOW())
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+
+-- Deliveries of events to webhooks, with the outcome of their latest attempt.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+  webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  event_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER,
+  error TEXT,
+  created_at TIMESTAMP DEFAULT (NOW()),
+  attempted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
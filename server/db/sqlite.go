@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+)
+
+// sqliteParams are appended to the data source name of SQLite databases: foreign
+// keys are enforced like in PostgreSQL, writers wait for each other instead of
+// failing, and times are written in a format SQLite and the driver both parse.
+const sqliteParams = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+// sqliteTimeFormat is the format of the times written with _time_format=sqlite.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// The functions PostgreSQL provides to the schema and queries are registered for
+// SQLite, so both databases run the same SQL. Times are always in UTC, so that
+// they compare correctly as text.
+func init() {
+	sqlite.MustRegisterScalarFunction("now", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+	sqlite.MustRegisterScalarFunction("gen_random_uuid", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return uuid.NewString(), nil
+	})
+}
@@ -29,7 +29,7 @@ func NewDogHandler(dogService *services.DogService) *DogHandler {
 //	@Param			userID	query	string	false	"User ID"
 //
 //	@Success		200		{string}	models.GetRandomImageResponse
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.ProblemDetails
 //	@Router			/dog/random [get]
 func (h *DogHandler) GetRandomImage(c *gin.Context) {
 	userID := c.DefaultQuery("userID", "")
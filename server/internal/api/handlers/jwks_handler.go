@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keys *utils.KeyRing
+}
+
+func NewJWKSHandler(keys *utils.KeyRing) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// GetJWKS returns the public keys used to verify tokens issued by this server,
+// in JSON Web Key Set format. It is served at /.well-known/jwks.json, outside of
+// the versioned API, so other services can verify our tokens without sharing a secret.
+//
+// Keys that were rotated out are still listed until their retention period ends.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -1,22 +1,39 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"io"
 	"log"
 	"net/http"
+	"server/config"
 	"server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/i18n"
 	"server/internal/models"
 	"server/internal/utils"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	mimeCSV = "text/csv"
+	// maxImportSize is the maximum size of an import body.
+	maxImportSize = 1 << 20
+)
+
 type LikedImagesHandler struct {
 	likedImagesService *services.LikedImagesService
+	cfg                config.LikesConfig
 }
 
-func NewLikedImagesHandler(likedImagesService *services.LikedImagesService) *LikedImagesHandler {
+func NewLikedImagesHandler(likedImagesService *services.LikedImagesService, cfg config.LikesConfig) *LikedImagesHandler {
 	return &LikedImagesHandler{
 		likedImagesService: likedImagesService,
+		cfg:                cfg,
 	}
 }
 
@@ -28,8 +45,11 @@ func NewLikedImagesHandler(likedImagesService *services.LikedImagesService) *Lik
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path	string	true	"User ID"
+//	@Param			If-None-Match	header	string	false	"ETag of the cached list"
+//	@Param			If-Modified-Since	header	string	false	"Last-Modified of the cached list"
 //	@Success		200		{object}	models.GetLikedImagesResponse
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Success		304		"The cached list is still current"
+//	@Failure		400		{object}	utils.ProblemDetails
 //
 //	@Security		BearerAuth
 //
@@ -38,17 +58,33 @@ func (h *LikedImagesHandler) GetLikedImages(c *gin.Context) {
 	log.Default().Println("Getting liked images")
 	var req models.GetLikedImagesRequest
 	if err := c.ShouldBindUri(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
 	}
-	imgs, err := h.likedImagesService.GetLikedImages(req.UserID)
 
+	// Only the version is read to answer 304. The list is then read with its own
+	// version, the one sent as ETag, since the list may have changed in between.
+	version, err := h.likedImagesService.GetLikesVersion(req.UserID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
+	c.Header("Cache-Control", "private, no-cache")
+	if utils.NotModified(c, likesETag(version), version.UpdatedAt) {
+		utils.SetValidators(c, likesETag(version), version.UpdatedAt)
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	imgs, version, err := h.likedImagesService.GetVersionedLikedImages(req.UserID)
+
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SetValidators(c, likesETag(version), version.UpdatedAt)
+
 	c.JSON(http.StatusOK, gin.H{
 		"images": imgs,
 	})
@@ -63,8 +99,13 @@ func (h *LikedImagesHandler) GetLikedImages(c *gin.Context) {
 //	@Produce		json
 //	@Param			id	path	string	true	"User ID"
 //	@Param			request body models.LikeImageRequestBody true "Image URL"
+//	@Param			If-Match	header	string	false	"ETag the list must still have"
+//	@Param			Idempotency-Key	header	string	false	"Key making retries of the request safe"
 //	@Success		201		{object}	models.LikeImageResponse
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		409		{object}	utils.ProblemDetails
+//	@Failure		412		{object}	utils.ProblemDetails
+//	@Failure		422		{object}	utils.ProblemDetails
 //
 //	@Security		BearerAuth
 //
@@ -72,24 +113,23 @@ func (h *LikedImagesHandler) GetLikedImages(c *gin.Context) {
 func (h *LikedImagesHandler) LikeImage(c *gin.Context) {
 	var body models.LikeImageRequestBody
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid image URL is required", err))
+		return
 	}
 
 	var req models.LikeImageRequestURL
 	if err := c.ShouldBindUri(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
 	}
 
-	err := h.likedImagesService.LikeImage(req.UserID, body.ImageURL)
+	version, err := h.likedImagesService.LikeImage(req.UserID, body.ImageURL, ifMatch(c))
 
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
+	setLikesETag(c, version)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"success": "true",
@@ -106,8 +146,10 @@ func (h *LikedImagesHandler) LikeImage(c *gin.Context) {
 //	@Produce		json
 //	@Param			id	path	string	true	"User ID"
 //	@Param			request body models.UnlikeImageRequestBody true "Image URL"
+//	@Param			If-Match	header	string	false	"ETag the list must still have"
 //	@Success		200		{object}	models.UnlikeImageResponse
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		412		{object}	utils.ProblemDetails
 //
 //	@Security		BearerAuth
 //
@@ -116,24 +158,414 @@ func (h *LikedImagesHandler) UnlikeImage(c *gin.Context) {
 
 	var body models.UnlikeImageRequestBody
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid image URL is required", err))
 		return
 	}
 
 	var req models.UnlikeImageRequestURL
 	if err := c.ShouldBindUri(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID in URI"})
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
+	}
+
+	version, err := h.likedImagesService.UnlikeImage(req.UserID, body.ImageURL, ifMatch(c))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	setLikesETag(c, version)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": "true",
+		"image":   body.ImageURL,
+	})
+}
+
+// UndoUnlike godoc
+//
+//	@Summary		Undoes an unlike.
+//	@Description	Likes again an image the user unliked within the undo window, keeping the date it was first liked.
+//	@Tags			liked_images
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			request body models.UndoUnlikeRequestBody true "Image URL"
+//	@Param			If-Match	header	string	false	"ETag the list must still have"
+//	@Param			Idempotency-Key	header	string	false	"Key making retries of the request safe"
+//	@Success		200		{object}	models.UndoUnlikeResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		404		{object}	utils.ProblemDetails
+//	@Failure		412		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/liked_images/{id}/undo [post]
+func (h *LikedImagesHandler) UndoUnlike(c *gin.Context) {
+	var body models.UndoUnlikeRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid image URL is required", err))
+		return
+	}
+
+	var req models.UndoUnlikeRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
 		return
 	}
 
-	err := h.likedImagesService.UnlikeImage(req.UserID, body.ImageURL)
+	version, err := h.likedImagesService.UndoUnlike(req.UserID, body.ImageURL, ifMatch(c))
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
+	setLikesETag(c, version)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": "true",
 		"image":   body.ImageURL,
 	})
 }
+
+// BatchLikeImages godoc
+//
+//	@Summary		Likes and unlikes several images.
+//	@Description	Applies up to 100 like and unlike operations in a single transaction. Operations that fail, because the URL is invalid, the image is already liked or it is not liked, are reported in their result without affecting the others.
+//	@Tags			liked_images
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			request body models.BatchLikeImagesRequestBody true "Operations"
+//	@Param			If-Match	header	string	false	"ETag the list must still have"
+//	@Param			Idempotency-Key	header	string	false	"Key making retries of the request safe"
+//	@Success		200		{object}	models.BatchLikeImagesResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		409		{object}	utils.ProblemDetails
+//	@Failure		412		{object}	utils.ProblemDetails
+//	@Failure		422		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/liked_images/{id}/batch [post]
+func (h *LikedImagesHandler) BatchLikeImages(c *gin.Context) {
+	var body models.BatchLikeImagesRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a list of like and unlike operations is required", err))
+		return
+	}
+
+	var req models.BatchLikeImagesRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
+	}
+
+	results, version, err := h.likedImagesService.ApplyBatch(req.UserID, body.Operations, ifMatch(c))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	setLikesETag(c, version)
+
+	c.JSON(http.StatusOK, newBatchResponse(c, results))
+}
+
+// ImportLikedImages godoc
+//
+//	@Summary		Imports liked images.
+//	@Description	Likes up to 1000 images, in the JSON format of the export or as CSV with one image URL per line and an optional image_url header. Images already liked are reported as failed without affecting the others.
+//	@Tags			liked_images
+//	@Accept			json,text/csv
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			request body models.ImportLikedImagesRequestBody true "Images"
+//	@Param			If-Match	header	string	false	"ETag the list must still have"
+//	@Param			Idempotency-Key	header	string	false	"Key making retries of the request safe"
+//	@Success		200		{object}	models.ImportLikedImagesResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		409		{object}	utils.ProblemDetails
+//	@Failure		412		{object}	utils.ProblemDetails
+//	@Failure		422		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/liked_images/{id}/import [post]
+func (h *LikedImagesHandler) ImportLikedImages(c *gin.Context) {
+	var req models.ImportLikedImagesRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var images []string
+	if c.ContentType() == mimeCSV {
+		var err error
+		if images, err = readCSVImages(c.Request.Body); err != nil {
+			utils.HandleError(c, e.NewValidationError(e.InvalidRequest, "a CSV list of image URLs is required", []e.RuleViolation{{
+				Rule:    utils.RuleMalformed,
+				Message: "request is not valid CSV",
+			}}))
+			return
+		}
+	} else {
+		var body models.ImportLikedImagesRequestBody
+		if err := c.ShouldBindJSON(&body); err != nil {
+			utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a list of image URLs is required", err))
+			return
+		}
+		images = body.Images
+	}
+
+	results, version, err := h.likedImagesService.ImportLikedImages(req.UserID, images, ifMatch(c))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	setLikesETag(c, version)
+
+	c.JSON(http.StatusOK, models.ImportLikedImagesResponse(newBatchResponse(c, results)))
+}
+
+// ExportLikedImages godoc
+//
+//	@Summary		Exports liked images.
+//	@Description	Downloads the liked images as JSON, in the format accepted by the import, or as CSV. The format is taken from the format query parameter, or else from the Accept header.
+//	@Tags			liked_images
+//	@Produce		json,text/csv
+//	@Param			id	path	string	true	"User ID"
+//	@Param			format	query	string	false	"Export format"	Enums(json, csv)
+//	@Success		200		{object}	models.GetLikedImagesResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/liked_images/{id}/export [get]
+func (h *LikedImagesHandler) ExportLikedImages(c *gin.Context) {
+	var req models.ExportLikedImagesRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
+	}
+
+	var query models.ExportLikedImagesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "format must be json or csv", err))
+		return
+	}
+
+	imgs, err := h.likedImagesService.GetLikedImages(req.UserID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	if imgs == nil {
+		imgs = []string{}
+	}
+
+	format := query.Format
+	if format == "" && c.NegotiateFormat(gin.MIMEJSON, mimeCSV) == mimeCSV {
+		format = models.ExportFormatCSV
+	}
+
+	if format != models.ExportFormatCSV {
+		c.Header("Content-Disposition", `attachment; filename="liked_images.json"`)
+		c.JSON(http.StatusOK, models.GetLikedImagesResponse{Images: imgs})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="liked_images.csv"`)
+	c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{csvImageURLHeader})
+	for _, img := range imgs {
+		w.Write([]string{img})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("failed to write CSV export: %v", err)
+	}
+}
+
+// Events of the like activity streams, besides the like events themselves.
+const (
+	// streamReadyEvent is sent first, when the stream is in sync after the replay of
+	// the missed events that follow it.
+	streamReadyEvent = "ready"
+	// streamResetEvent is sent when events were missed and the liked images must be
+	// fetched again.
+	streamResetEvent = "reset"
+)
+
+// StreamLikedImages godoc
+//
+//	@Summary		Streams the like activity.
+//	@Description	Pushes the likes and unlikes of the user as Server-Sent Events, named image.liked and image.unliked, with the likes version after the change as ID.
+//	@Description	The stream starts with a ready event, followed by the events missed since the Last-Event-ID header, or with a reset event when they are no longer known,
+//	@Description	in which case the liked images must be fetched again. A reset is also sent when events are missed during the stream. The data of ready and reset is the current version.
+//	@Description	Streams get a comment periodically, so idle streams are not closed by proxies. Reconnecting with the ID of the last event received resumes the stream.
+//	@Tags			liked_images
+//	@Produce		text/event-stream
+//	@Param			id	path	string	true	"User ID"
+//	@Param			Last-Event-ID	header	integer	false	"ID of the last event received"
+//	@Success		200		{object}	models.LikeStreamEvent
+//	@Failure		400		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/liked_images/{id}/stream [get]
+func (h *LikedImagesHandler) StreamLikedImages(c *gin.Context) {
+	var req models.StreamLikedImagesRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
+	}
+
+	var lastEventID *int64
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			utils.HandleError(c, e.NewError(e.UserErr, e.InvalidRequest, "Last-Event-ID must be an event ID", err))
+			return
+		}
+		lastEventID = &id
+	}
+
+	stream, err := h.likedImagesService.SubscribeLikes(req.UserID, lastEventID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	// Keeps reverse proxies such as nginx from buffering the events.
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if stream.Reset {
+		sendSync(c, streamResetEvent, stream.LastEventID, stream.LastEventID)
+	} else {
+		sendSync(c, streamReadyEvent, stream.LastEventID-int64(len(stream.Replay)), stream.Version)
+		for _, event := range stream.Replay {
+			sendEvent(c, strconv.FormatInt(event.ID, 10), event.Type, event)
+		}
+	}
+	c.Writer.Flush()
+
+	var heartbeat <-chan time.Time
+	if h.cfg.StreamHeartbeat > 0 {
+		ticker := time.NewTicker(h.cfg.StreamHeartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	last := stream.LastEventID
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-stream.Events():
+			if !ok {
+				// Closed on shutdown or because the client is too slow: it reconnects
+				// and resumes after the last event it received.
+				return
+			}
+			switch {
+			case event.ID <= last:
+				continue
+			case event.ID == last+1:
+				sendEvent(c, strconv.FormatInt(event.ID, 10), event.Type, event)
+			default:
+				sendSync(c, streamResetEvent, event.ID, event.ID)
+			}
+			last = event.ID
+		}
+		c.Writer.Flush()
+	}
+}
+
+// sendSync sends a ready or reset event with the ID to resume after and the version
+// of the liked images.
+func sendSync(c *gin.Context, name string, id, version int64) {
+	sendEvent(c, strconv.FormatInt(id, 10), name, models.LikesStreamSync{Version: version})
+}
+
+// sendEvent sends a Server-Sent Event with its data encoded as JSON.
+func sendEvent(c *gin.Context, id, name string, data any) {
+	c.Render(-1, sse.Event{Id: id, Event: name, Data: data})
+}
+
+// csvImageURLHeader is the header of the image URL column of CSV exports.
+const csvImageURLHeader = "image_url"
+
+// readCSVImages reads the image URLs in the first column of a CSV document, skipping
+// the header if there is one.
+func readCSVImages(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]string, 0, len(records))
+	for i, record := range records {
+		image := strings.TrimSpace(record[0])
+		if i == 0 && (strings.EqualFold(image, csvImageURLHeader) || strings.EqualFold(image, models.ImageURLField)) {
+			continue
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// newBatchResponse counts the applied and failed operations and translates the
+// messages of the failed ones to the locale of the request.
+func newBatchResponse(c *gin.Context, results []models.LikeOperationResult) models.BatchLikeImagesResponse {
+	resp := models.BatchLikeImagesResponse{Results: results}
+	locale := utils.Locale(c)
+	for i, result := range results {
+		if result.Success {
+			resp.Applied++
+			continue
+		}
+		resp.Failed++
+		resp.Results[i].Detail = i18n.ErrorMessage(locale, result.Code, result.Detail)
+	}
+	return resp
+}
+
+// likesETag returns the entity tag of a version of the list of liked images.
+func likesETag(version models.LikesVersion) string {
+	return utils.StrongETag("likes-" + strconv.FormatInt(version.Version, 10))
+}
+
+// ifMatch returns the precondition of the If-Match header of a change to the liked
+// images, failing if the list changed since the client read it, or nil if the request
+// has no If-Match header. The service checks it in the transaction of the change.
+func ifMatch(c *gin.Context) services.LikesPrecondition {
+	if c.GetHeader("If-Match") == "" {
+		return nil
+	}
+	return func(version models.LikesVersion) bool {
+		return !utils.PreconditionFailed(c, likesETag(version))
+	}
+}
+
+// setLikesETag adds the ETag of the version written by a change, so the client can
+// send it in If-Match on its next change without reading the list again. A zero
+// version, of a batch without valid operations, reads nothing and sets no ETag.
+func setLikesETag(c *gin.Context, version models.LikesVersion) {
+	if version == (models.LikesVersion{}) {
+		return
+	}
+	utils.SetValidators(c, likesETag(version), version.UpdatedAt)
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/api/repositories/memory"
+	"server/internal/api/services"
+	"server/internal/models"
+	"server/internal/pubsub"
+	"server/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// likedImageURL returns the URL of the i-th image liked in the tests.
+func likedImageURL(i int) string {
+	return fmt.Sprintf("https://example.com/image%d.jpg", i)
+}
+
+// streamEvent is a Server-Sent Event, or a comment when Comment is set.
+type streamEvent struct {
+	ID      string
+	Event   string
+	Data    string
+	Comment string
+}
+
+// readStreamEvent reads the next event or comment of a Server-Sent Events stream.
+func readStreamEvent(t *testing.T, r *bufio.Reader) streamEvent {
+	t.Helper()
+	var event streamEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			event.Comment = value
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			event.Data = value
+		}
+	}
+}
+
+// likedImagesFixture is a handler on a memory store, and a user who liked images.
+type likedImagesFixture struct {
+	handler *LikedImagesHandler
+	service *services.LikedImagesService
+	broker  *pubsub.Broker
+	userID  string
+}
+
+// newLikedImagesFixture creates a handler for a user who liked likes images, with a
+// broker keeping historySize events.
+func newLikedImagesFixture(t *testing.T, cfg config.LikesConfig, historySize, likes int) *likedImagesFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+	user, err := users.Create(&models.User{Email: "likes@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	broker := pubsub.NewBroker(historySize)
+	service := services.NewLikedImagesService(memory.NewLikedImagesRepository(store), users, memory.NewUnitOfWork(store), broker, cfg)
+	for i := range likes {
+		_, err := service.LikeImage(user.ID, likedImageURL(i), nil)
+		require.NoError(t, err)
+	}
+
+	return &likedImagesFixture{handler: NewLikedImagesHandler(service, cfg), service: service, broker: broker, userID: user.ID}
+}
+
+// likesStream serves the like stream of the user of a fixture.
+type likesStream struct {
+	*likedImagesFixture
+	server *httptest.Server
+}
+
+// newLikesStream serves the stream of a user who liked likes images, with a broker
+// keeping historySize events and heartbeats every heartbeat if it is positive.
+func newLikesStream(t *testing.T, historySize, likes int, heartbeat time.Duration) *likesStream {
+	t.Helper()
+	fixture := newLikedImagesFixture(t, config.LikesConfig{UndoWindow: time.Minute, StreamHeartbeat: heartbeat}, historySize, likes)
+
+	router := gin.New()
+	router.GET("/liked_images/:id/stream", fixture.handler.StreamLikedImages)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &likesStream{likedImagesFixture: fixture, server: server}
+}
+
+// open opens the stream, resuming after lastEventID if it is not empty.
+func (s *likesStream) open(t *testing.T, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.server.URL+"/liked_images/"+s.userID+"/stream", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+func syncVersion(t *testing.T, event streamEvent) int64 {
+	t.Helper()
+	var sync models.LikesStreamSync
+	require.NoError(t, json.Unmarshal([]byte(event.Data), &sync))
+	return sync.Version
+}
+
+func TestStreamLikedImages(t *testing.T) {
+	t.Run("new stream - ready at the current version, then the new events", func(t *testing.T) {
+		stream := newLikesStream(t, 10, 1, 0)
+		r := stream.open(t, "")
+
+		ready := readStreamEvent(t, r)
+		assert.Equal(t, streamReadyEvent, ready.Event)
+		assert.Equal(t, "1", ready.ID)
+		assert.Equal(t, int64(1), syncVersion(t, ready))
+
+		_, err := stream.service.UnlikeImage(stream.userID, likedImageURL(0), nil)
+		require.NoError(t, err)
+		event := readStreamEvent(t, r)
+		assert.Equal(t, "2", event.ID)
+		assert.Equal(t, models.ImageUnlikedEvent, event.Event)
+	})
+
+	t.Run("resumed stream - replays the events after Last-Event-ID", func(t *testing.T) {
+		stream := newLikesStream(t, 10, 3, 0)
+		r := stream.open(t, "1")
+
+		ready := readStreamEvent(t, r)
+		assert.Equal(t, streamReadyEvent, ready.Event)
+		assert.Equal(t, "1", ready.ID)
+		assert.Equal(t, int64(3), syncVersion(t, ready))
+
+		var replayed []string
+		for range 2 {
+			event := readStreamEvent(t, r)
+			assert.Equal(t, models.ImageLikedEvent, event.Event)
+			replayed = append(replayed, event.ID)
+		}
+		assert.Equal(t, []string{"2", "3"}, replayed)
+	})
+
+	t.Run("resumed stream - resets when the missed events are no longer known", func(t *testing.T) {
+		stream := newLikesStream(t, 1, 3, 0)
+		r := stream.open(t, "1")
+
+		reset := readStreamEvent(t, r)
+		assert.Equal(t, streamResetEvent, reset.Event)
+		assert.Equal(t, "3", reset.ID)
+		assert.Equal(t, int64(3), syncVersion(t, reset))
+	})
+
+	t.Run("missed event during the stream - resets", func(t *testing.T) {
+		stream := newLikesStream(t, 10, 1, 0)
+		r := stream.open(t, "")
+		assert.Equal(t, streamReadyEvent, readStreamEvent(t, r).Event)
+
+		stream.broker.Deliver(models.LikeStreamEvent{ID: 3, Type: models.ImageLikedEvent, UserID: stream.userID, ImageURL: likedImageURL(1)})
+
+		reset := readStreamEvent(t, r)
+		assert.Equal(t, streamResetEvent, reset.Event)
+		assert.Equal(t, "3", reset.ID)
+		assert.Equal(t, int64(3), syncVersion(t, reset))
+	})
+
+	t.Run("idle stream - sends heartbeats", func(t *testing.T) {
+		stream := newLikesStream(t, 10, 1, 10*time.Millisecond)
+		r := stream.open(t, "")
+		assert.Equal(t, streamReadyEvent, readStreamEvent(t, r).Event)
+
+		assert.Equal(t, streamEvent{Comment: "heartbeat"}, readStreamEvent(t, r))
+	})
+
+	t.Run("invalid Last-Event-ID - returns 400", func(t *testing.T) {
+		stream := newLikesStream(t, 10, 0, 0)
+		req, err := http.NewRequest(http.MethodGet, stream.server.URL+"/liked_images/"+stream.userID+"/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "abc")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestReadCSVImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "export header - skipped", csv: "image_url\nhttps://a.com/1.jpg\n", want: []string{"https://a.com/1.jpg"}},
+		{name: "JSON field header - skipped", csv: "imageURL\nhttps://a.com/1.jpg\n", want: []string{"https://a.com/1.jpg"}},
+		{name: "header of another case - skipped", csv: " Image_URL \nhttps://a.com/1.jpg\n", want: []string{"https://a.com/1.jpg"}},
+		{name: "no header", csv: "https://a.com/1.jpg\nhttps://a.com/2.jpg\n", want: []string{"https://a.com/1.jpg", "https://a.com/2.jpg"}},
+		{name: "header after the first line - kept", csv: "https://a.com/1.jpg\nimage_url\n", want: []string{"https://a.com/1.jpg", "image_url"}},
+		{name: "extra columns - ignored", csv: "image_url,liked_at\nhttps://a.com/1.jpg,2024-01-01\nhttps://a.com/2.jpg\n", want: []string{"https://a.com/1.jpg", "https://a.com/2.jpg"}},
+		{name: "CRLF and spaces", csv: "image_url\r\n  https://a.com/1.jpg \r\n", want: []string{"https://a.com/1.jpg"}},
+		{name: "empty document", csv: "", want: []string{}},
+		{name: "malformed quotes - error", csv: "\"https://a.com/1.jpg\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := readCSVImages(strings.NewReader(tt.csv))
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, images)
+		})
+	}
+}
+
+func TestImportLikedImagesCSV(t *testing.T) {
+	serve := func(t *testing.T, body string) (*httptest.ResponseRecorder, *likedImagesFixture) {
+		fixture := newLikedImagesFixture(t, config.LikesConfig{}, 0, 0)
+		router := gin.New()
+		router.POST("/liked_images/:id/import", fixture.handler.ImportLikedImages)
+
+		req := httptest.NewRequest(http.MethodPost, "/liked_images/"+fixture.userID+"/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp, fixture
+	}
+
+	t.Run("CSV export - imported", func(t *testing.T) {
+		resp, fixture := serve(t, "image_url\n"+likedImageURL(0)+"\n"+likedImageURL(1)+"\n")
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		images, err := fixture.service.GetLikedImages(fixture.userID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{likedImageURL(0), likedImageURL(1)}, images)
+	})
+
+	t.Run("body over 1 MiB - rejected", func(t *testing.T) {
+		line := likedImageURL(0) + "\n"
+		resp, fixture := serve(t, strings.Repeat(line, maxImportSize/len(line)+1))
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), utils.RuleMalformed)
+		images, err := fixture.service.GetLikedImages(fixture.userID)
+		require.NoError(t, err)
+		assert.Empty(t, images)
+	})
+}
+
+func TestExportLikedImages(t *testing.T) {
+	fixture := newLikedImagesFixture(t, config.LikesConfig{}, 0, 2)
+	router := gin.New()
+	router.GET("/liked_images/:id/export", fixture.handler.ExportLikedImages)
+
+	const (
+		csvExport  = "image_url\nhttps://example.com/image0.jpg\nhttps://example.com/image1.jpg\n"
+		jsonExport = `{"images":["https://example.com/image0.jpg","https://example.com/image1.jpg"]}`
+	)
+	tests := []struct {
+		name        string
+		query       string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{name: "no preference - JSON", status: http.StatusOK, contentType: gin.MIMEJSON, body: jsonExport},
+		{name: "Accept text/csv - CSV", accept: "text/csv", status: http.StatusOK, contentType: mimeCSV, body: csvExport},
+		{name: "Accept JSON over CSV - JSON", accept: "application/json, text/csv;q=0.5", status: http.StatusOK, contentType: gin.MIMEJSON, body: jsonExport},
+		{name: "format csv over Accept JSON - CSV", query: "?format=csv", accept: gin.MIMEJSON, status: http.StatusOK, contentType: mimeCSV, body: csvExport},
+		{name: "format json over Accept text/csv - JSON", query: "?format=json", accept: "text/csv", status: http.StatusOK, contentType: gin.MIMEJSON, body: jsonExport},
+		{name: "unknown format - 400", query: "?format=xml", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/liked_images/"+fixture.userID+"/export"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.status, resp.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+			assert.True(t, strings.HasPrefix(resp.Header().Get("Content-Type"), tt.contentType), "Content-Type %q", resp.Header().Get("Content-Type"))
+			assert.Equal(t, tt.body, resp.Body.String())
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	oidcService          services.OIDCService
+	postLoginRedirectURL string
+	cookies              config.CookieConfig
+}
+
+func NewOIDCHandler(oidcService services.OIDCService, postLoginRedirectURL string, cookies config.CookieConfig) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:          oidcService,
+		postLoginRedirectURL: postLoginRedirectURL,
+		cookies:              cookies,
+	}
+}
+
+// ListProviders godoc
+//
+//	@Summary		Lists the identity providers.
+//	@Description	Lists the OpenID Connect providers users can sign in with.
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	models.OIDCProvidersResponse
+//	@Router			/auth/oidc/providers [get]
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.OIDCProvidersResponse{
+		Providers: h.oidcService.Providers(),
+	})
+}
+
+// Login godoc
+//
+//	@Summary		Starts an OpenID Connect login.
+//	@Description	Redirects the user to the identity provider using the authorization code flow with PKCE.
+//	@Tags			auth
+//	@Param			provider	path	string	true	"Provider name"
+//	@Success		302
+//	@Failure		400	{object}	utils.ProblemDetails
+//	@Router			/auth/oidc/{provider}/login [get]
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, stateToken, err := h.oidcService.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SetCookie(c, h.stateCookies(), oidcStateCookie, stateToken, 600, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+//
+//	@Summary		Completes an OpenID Connect login.
+//	@Description	Exchanges the authorization code, links the external identity to a user and logs the user in.
+//	@Description	When the user enabled two-factor authentication, no token is issued and no redirect happens: the response carries an mfa_token to send with the code to /auth/login/2fa, as for a password login.
+//	@Tags			auth
+//	@Produce		json
+//	@Param			provider	path	string	true	"Provider name"
+//	@Param			code		query	string	true	"Authorization code"
+//	@Param			state		query	string	true	"State"
+//	@Success		200	{object}	models.LoginUserResponse
+//	@Failure		400	{object}	utils.ProblemDetails
+//	@Failure		401	{object}	utils.ProblemDetails
+//	@Router			/auth/oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidOIDCState, "code and state are required", err))
+		return
+	}
+
+	stateToken, err := c.Cookie(oidcStateCookie)
+	if err != nil {
+		utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidOIDCState, "missing login state", err))
+		return
+	}
+
+	res, err := h.oidcService.CompleteLogin(c.Request.Context(), c.Param("provider"), req.Code, req.State, stateToken, utils.ClientInfo(c))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SetCookie(c, h.stateCookies(), oidcStateCookie, "", -1, true)
+	if res.MFARequired {
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    res.MFAToken,
+		})
+		return
+	}
+
+	if err := utils.SetAuthCookies(c, h.cookies, res.Token); err != nil {
+		utils.HandleError(c, e.NewError(e.InternalErr, e.CSRFTokenInvalid, "failed to issue CSRF token", err))
+		return
+	}
+	c.Header("Authorization", "Bearer "+res.Token)
+
+	if h.postLoginRedirectURL != "" {
+		c.Redirect(http.StatusFound, h.postLoginRedirectURL)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User logged in successfully",
+		"token":   res.Token,
+		"userID":  res.ID,
+	})
+}
+
+// stateCookies returns the attributes of the state cookie. The provider redirects back
+// with a cross-site top-level navigation, so the cookie must not be SameSite=Strict.
+func (h *OIDCHandler) stateCookies() config.CookieConfig {
+	cookies := h.cookies
+	if cookies.SameSite == http.SameSiteStrictMode {
+		cookies.SameSite = http.SameSiteLaxMode
+	}
+	return cookies
+}
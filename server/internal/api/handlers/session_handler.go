@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/internal/api/services"
+	"server/internal/models"
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService services.SessionService
+	cookies        config.CookieConfig
+}
+
+func NewSessionHandler(sessionService services.SessionService, cookies config.CookieConfig) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		cookies:        cookies,
+	}
+}
+
+// ListSessions godoc
+//
+//	@Summary		Lists active sessions.
+//	@Description	Lists the devices the user is logged in on. The session of the request is flagged as current.
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Success		200		{object}	models.SessionsResponse
+//	@Failure		401		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/user/{id}/sessions [get]
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.sessionService.List(c.Param("id"), c.GetString("sessionID"))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SessionsResponse{
+		Sessions: sessions,
+	})
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revokes a session.
+//	@Description	Logs the user out of a device. Tokens of the revoked session are rejected from then on.
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			sid	path	string	true	"Session ID"
+//	@Success		200		{object}	string
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		401		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/user/{id}/sessions/{sid} [delete]
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	if err := h.sessionService.Revoke(c.Param("id"), c.Param("sid")); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked",
+	})
+}
+
+// Logout godoc
+//
+//	@Summary		Logs out.
+//	@Description	Revokes the session of the request and clears the auth and CSRF cookies.
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200		{object}	string
+//	@Failure		401		{object}	utils.ProblemDetails
+//	@Failure		403		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/auth/logout [post]
+func (h *SessionHandler) Logout(c *gin.Context) {
+	if sid := c.GetString("sessionID"); sid != "" {
+		if err := h.sessionService.Revoke(c.GetString("userID"), sid); err != nil {
+			utils.HandleError(c, err)
+			return
+		}
+	}
+
+	utils.ClearAuthCookies(c, h.cookies)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User logged out successfully",
+	})
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"log"
+	"net/http"
+	"net/url"
+	"server/config"
+	"server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/i18n"
+	"server/internal/models"
+	"server/internal/utils"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// maxSwipeMessageSize is the maximum size of a message of the client; larger ones
+// close the connection.
+const maxSwipeMessageSize = 4 << 10
+
+// SwipeHandler serves swipe sessions over WebSocket connections.
+type SwipeHandler struct {
+	swipeService *services.SwipeService
+	cfg          config.SwipeConfig
+	cors         config.CORSConfig
+	upgrader     websocket.Upgrader
+	conns        *swipeConnections
+}
+
+// swipeConnections tracks the open connections, so they are closed when the server
+// stops: hijacked connections are not waited for by the HTTP server.
+type swipeConnections struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	closing chan struct{}
+}
+
+// NewSwipeHandler creates a SwipeHandler. Browsers may open sessions from the origin
+// of the API, or from the origins of cors when it allows credentials, as sessions
+// are authenticated with the auth cookie there.
+func NewSwipeHandler(swipeService *services.SwipeService, cfg config.SwipeConfig, cors config.CORSConfig) *SwipeHandler {
+	return &SwipeHandler{
+		swipeService: swipeService,
+		cfg:          cfg,
+		cors:         cors,
+		// The origin is checked before upgrading, to answer with problem details.
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		conns:    &swipeConnections{closing: make(chan struct{})},
+	}
+}
+
+// Swipe godoc
+//
+//	@Summary		Opens a swipe session.
+//	@Description	Upgrades to a WebSocket streaming random images to like or skip. Every message is a JSON object.
+//	@Description	The server sends {"type":"image","image":{"imageURL":"...","liked":false}} messages, up to a few ahead of the actions of the client, which answers each image with {"action":"like"|"skip","imageURL":"..."}.
+//	@Description	Every action gets a {"type":"result","result":{...}} message, failed likes having the code and detail of the error. Invalid messages get a {"type":"error","error":{"code":"...","detail":"..."}} message.
+//	@Description	The connection is pinged periodically and closed with status 1001 when the server stops.
+//	@Tags			dog
+//	@Param			id	path	string	true	"User ID"
+//	@Success		101		{object}	models.SwipeMessage
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		403		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/dog/{id}/swipe [get]
+func (h *SwipeHandler) Swipe(c *gin.Context) {
+	var req models.SwipeRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid user ID is required", err))
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		utils.HandleError(c, e.NewError(e.UserErr, e.InvalidRequest, "a WebSocket upgrade is required", nil))
+		return
+	}
+	if !h.allowedOrigin(c.Request) {
+		utils.HandleError(c, e.NewError(e.ForbiddenErr, e.InvalidRequest, "origin not allowed", nil))
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has answered the request.
+		log.Printf("failed to open swipe session: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if !h.conns.add() {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), h.writeDeadline())
+		return
+	}
+	defer h.conns.wg.Done()
+
+	session := h.swipeService.Start(req.UserID)
+	defer session.Close()
+
+	h.serve(conn, session, utils.Locale(c))
+}
+
+// swipeRead is a message read from the client, or the error decoding it.
+type swipeRead struct {
+	action models.SwipeAction
+	err    error
+}
+
+// serve runs a session until the client leaves, a message cannot be sent in time or
+// the server stops. The actions of the client are read by another goroutine and
+// applied in turn, so a client sending faster than they are applied is slowed down
+// by the connection.
+func (h *SwipeHandler) serve(conn *websocket.Conn, session *services.SwipeSession, locale string) {
+	conn.SetReadLimit(maxSwipeMessageSize)
+	var ping <-chan time.Time
+	if h.cfg.PingInterval > 0 {
+		// A client must answer a ping before the next one.
+		pongWait := h.cfg.PingInterval + h.cfg.WriteTimeout
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		ticker := time.NewTicker(h.cfg.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	reads := make(chan swipeRead)
+	readErr := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go readSwipeActions(conn, reads, readErr, stop)
+
+	for {
+		var err error
+		select {
+		case fetch := <-session.Next():
+			if fetch.Err != nil {
+				err = h.send(conn, swipeErrorMessage(locale, fetch.Err))
+				break
+			}
+			session.Offer(fetch.Image)
+			err = h.send(conn, models.SwipeMessage{Type: models.SwipeImageMessage, Image: &fetch.Image})
+
+		case read := <-reads:
+			if read.err != nil {
+				err = h.send(conn, swipeErrorMessage(locale, read.err))
+				break
+			}
+			result, actErr := session.Act(read.action)
+			if actErr != nil {
+				err = h.send(conn, swipeErrorMessage(locale, actErr))
+				break
+			}
+			if !result.Success {
+				result.Detail = i18n.ErrorMessage(locale, result.Code, result.Detail)
+			}
+			err = h.send(conn, models.SwipeMessage{Type: models.SwipeResultMessage, Result: &result})
+
+		case err := <-readErr:
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				log.Printf("swipe session ended: %v", err)
+			}
+			return
+
+		case <-ping:
+			err = conn.WriteControl(websocket.PingMessage, nil, h.writeDeadline())
+
+		case <-h.conns.closing:
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), h.writeDeadline())
+			return
+		}
+
+		if err != nil {
+			// The client is gone or does not read its messages.
+			log.Printf("swipe session ended: %v", err)
+			return
+		}
+	}
+}
+
+// readSwipeActions reads the messages of the client until the connection fails,
+// sending malformed messages as errors so the session goes on.
+func readSwipeActions(conn *websocket.Conn, reads chan<- swipeRead, readErr chan<- error, stop <-chan struct{}) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			readErr <- err
+			return
+		}
+
+		var read swipeRead
+		if err := json.Unmarshal(data, &read.action); err != nil {
+			read.err = e.NewError(e.UserErr, e.InvalidRequest, "messages must be JSON actions", err)
+		}
+		select {
+		case reads <- read:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// send sends a message, failing if the client does not take it within the write
+// timeout.
+func (h *SwipeHandler) send(conn *websocket.Conn, msg models.SwipeMessage) error {
+	if err := conn.SetWriteDeadline(h.writeDeadline()); err != nil {
+		return err
+	}
+	return conn.WriteJSON(msg)
+}
+
+// writeDeadline returns the deadline of a write started now, zero for none.
+func (h *SwipeHandler) writeDeadline() time.Time {
+	if h.cfg.WriteTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(h.cfg.WriteTimeout)
+}
+
+// allowedOrigin reports whether a session may be opened by r. Requests without an
+// Origin header do not come from browsers and are authenticated by their token.
+func (h *SwipeHandler) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return h.cors.AllowCredentials && slices.Contains(h.cors.AllowedOrigins, origin)
+}
+
+// swipeErrorMessage returns the error message of err, in locale. Internal errors are
+// logged and their details never sent.
+func swipeErrorMessage(locale string, err error) models.SwipeMessage {
+	swipeErr := &models.SwipeError{Detail: "Unknown error ocurred"}
+
+	var appErr e.AppError
+	if stderrors.As(err, &appErr) {
+		swipeErr.Code = appErr.Code()
+		swipeErr.Detail = i18n.ErrorMessage(locale, appErr.Code(), appErr.PublicMessage())
+		if appErr.Status() >= http.StatusInternalServerError {
+			log.Printf("Internal error: Code=%s, Detail=%v", appErr.Code(), err)
+		}
+	} else {
+		log.Printf("Unknown error: %v", err)
+	}
+	return models.SwipeMessage{Type: models.SwipeErrorMessage, Error: swipeErr}
+}
+
+// Close closes the open sessions, telling their clients the server is going away,
+// and waits for them to end. Sessions opened afterwards are closed right away.
+func (h *SwipeHandler) Close() {
+	h.conns.mu.Lock()
+	if !h.conns.closed {
+		h.conns.closed = true
+		close(h.conns.closing)
+	}
+	h.conns.mu.Unlock()
+
+	h.conns.wg.Wait()
+}
+
+// add tracks a new connection, unless the connections are closed.
+func (c *swipeConnections) add() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.wg.Add(1)
+	return true
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/api/repositories/memory"
+	"server/internal/api/services"
+	"server/internal/models"
+	"server/internal/pubsub"
+	"server/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// swipeServer serves swipe sessions of a user on a memory store.
+type swipeServer struct {
+	server  *httptest.Server
+	handler *SwipeHandler
+	url     string
+}
+
+func newSwipeServer(t *testing.T, cfg config.SwipeConfig, cors config.CORSConfig) *swipeServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+	user, err := users.Create(&models.User{Email: "swipe@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	likedImagesRepo := memory.NewLikedImagesRepository(store)
+	likes := services.NewLikedImagesService(likedImagesRepo, users, memory.NewUnitOfWork(store), pubsub.NewBroker(0), config.LikesConfig{})
+	dogs := services.NewDogService(memory.NewDogRepository(memory.DogPictures), likedImagesRepo)
+	handler := NewSwipeHandler(services.NewSwipeService(dogs, likes, cfg), cfg, cors)
+
+	router := gin.New()
+	router.GET("/dog/:id/swipe", handler.Swipe)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &swipeServer{
+		server:  server,
+		handler: handler,
+		url:     "ws" + strings.TrimPrefix(server.URL, "http") + "/dog/" + user.ID + "/swipe",
+	}
+}
+
+// dial opens a session from origin, none if it is empty.
+func (s *swipeServer) dial(t *testing.T, origin string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	return s.dialWith(t, websocket.DefaultDialer, origin)
+}
+
+func (s *swipeServer) dialWith(t *testing.T, dialer *websocket.Dialer, origin string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, resp, err := dialer.Dial(s.url, header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readSwipeMessage reads the next message of the server.
+func readSwipeMessage(t *testing.T, conn *websocket.Conn) models.SwipeMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg models.SwipeMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestSwipeOrigin(t *testing.T) {
+	const allowedOrigin = "https://app.example.com"
+	swipeConfig := config.SwipeConfig{Prefetch: 1, Window: 1, WriteTimeout: time.Second}
+
+	tests := []struct {
+		name   string
+		cors   config.CORSConfig
+		origin func(s *swipeServer) string
+		status int
+	}{
+		{
+			name:   "no origin - accepted",
+			origin: func(*swipeServer) string { return "" },
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "same origin - accepted",
+			origin: func(s *swipeServer) string { return s.server.URL },
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "allowed CORS origin with credentials - accepted",
+			cors:   config.CORSConfig{AllowedOrigins: []string{allowedOrigin}, AllowCredentials: true},
+			origin: func(*swipeServer) string { return allowedOrigin },
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "allowed CORS origin without credentials - forbidden",
+			cors:   config.CORSConfig{AllowedOrigins: []string{allowedOrigin}},
+			origin: func(*swipeServer) string { return allowedOrigin },
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "foreign origin - forbidden",
+			cors:   config.CORSConfig{AllowedOrigins: []string{allowedOrigin}, AllowCredentials: true},
+			origin: func(*swipeServer) string { return "https://evil.example.com" },
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSwipeServer(t, swipeConfig, tt.cors)
+
+			conn, resp, err := s.dial(t, tt.origin(s))
+
+			require.NotNil(t, resp)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			if tt.status != http.StatusSwitchingProtocols {
+				assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+				assert.Equal(t, utils.MIMEProblemJSON, resp.Header.Get("Content-Type"))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, models.SwipeImageMessage, readSwipeMessage(t, conn).Type)
+		})
+	}
+}
+
+func TestSwipeClose(t *testing.T) {
+	s := newSwipeServer(t, config.SwipeConfig{Prefetch: 1, Window: 1, WriteTimeout: time.Second}, config.CORSConfig{})
+	conn, _, err := s.dial(t, "")
+	require.NoError(t, err)
+	assert.Equal(t, models.SwipeImageMessage, readSwipeMessage(t, conn).Type)
+
+	closed := make(chan struct{})
+	go func() {
+		s.handler.Close()
+		close(closed)
+	}()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Close did not wait for the session to end")
+	}
+
+	t.Run("sessions opened after Close are closed right away", func(t *testing.T) {
+		conn, _, err := s.dial(t, "")
+		require.NoError(t, err)
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	})
+}
+
+func TestSwipeBackpressure(t *testing.T) {
+	s := newSwipeServer(t, config.SwipeConfig{Prefetch: 1, Window: 1, WriteTimeout: 50 * time.Millisecond}, config.CORSConfig{})
+	// A small receive buffer fills up quickly.
+	dialer := &websocket.Dialer{NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return conn, conn.(*net.TCPConn).SetReadBuffer(1 << 10)
+	}}
+	conn, _, err := s.dialWith(t, dialer, "")
+	require.NoError(t, err)
+
+	// Every invalid message gets an error message, which the client does not read
+	// until its writes block, the buffers being full.
+	for {
+		require.NoError(t, conn.SetWriteDeadline(time.Now().Add(200*time.Millisecond)))
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+			break
+		}
+	}
+
+	// The server failed to send within the write timeout and dropped the connection,
+	// so the client reads the messages sent until then and the end of the connection.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "the server kept the connection of a client not reading its messages: %v", err)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"server/config"
 	"server/internal/api/services"
 	e "server/internal/errors"
 	"server/internal/models"
@@ -12,12 +13,16 @@ import (
 )
 
 type UserHandler struct {
-	userService services.UserService
+	userService    services.UserService
+	passwordPolicy *utils.PasswordPolicy
+	cookies        config.CookieConfig
 }
 
-func NewUserHandler(userService services.UserService) *UserHandler {
+func NewUserHandler(userService services.UserService, passwordPolicy *utils.PasswordPolicy, cookies config.CookieConfig) *UserHandler {
 	return &UserHandler{
 		userService,
+		passwordPolicy,
+		cookies,
 	}
 }
 
@@ -29,20 +34,26 @@ func NewUserHandler(userService services.UserService) *UserHandler {
 //	@Accept			json
 //	@Produce		json
 //	@Param			request	body		models.CreateUserRequest	true	"User registration request"
+//	@Param			Idempotency-Key	header	string	false	"Key making retries of the request safe"
 //	@Success		201		{object}	models.CreateUserResponse
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		409		{object}	utils.ProblemDetails
+//	@Failure		422		{object}	utils.ProblemDetails
 //	@Router			/auth/register [post]
 func (h *UserHandler) Register(c *gin.Context) {
 	var req models.CreateUserRequest
 
 	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
-		err := e.NewError(e.UserErr, e.InvalidCredentials, "correct email and password are required", err)
+		err := utils.NewBindingError(e.InvalidRequest, "correct email and password are required", err)
 		utils.HandleError(c, err)
 		return
 	}
 
-	if !utils.IsValidPassword(req.Password) {
-		err := e.NewError(e.UserErr, e.InvalidCredentials, "password must contain at least 8 characters, at most 32 characters, at least one uppercase letter, at least one lowercase letter, at least one number, and at least one special character", nil)
+	if violations := h.passwordPolicy.Validate(req.Password); len(violations) > 0 {
+		for i := range violations {
+			violations[i].Field = "password"
+		}
+		err := e.NewValidationError(e.WeakPassword, "password does not meet the password policy", violations)
 		utils.HandleError(c, err)
 		return
 	}
@@ -68,27 +79,73 @@ func (h *UserHandler) Register(c *gin.Context) {
 //	@Produce		json
 //	@Param			request	body		models.LoginUserRequest	true	"User login request"
 //	@Success		200		{object}	models.LoginUserResponse
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.ProblemDetails
 //	@Router			/auth/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var req models.LoginUserRequest
 
 	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
-		err := e.NewError(e.UserErr, e.InvalidCredentials, "correct email and password are required", err)
+		err := utils.NewBindingError(e.InvalidRequest, "correct email and password are required", err)
 		utils.HandleError(c, err)
 		return
 	}
 
-	res, err := h.userService.Login(req.Email, req.Password)
+	res, err := h.userService.Login(req.Email, req.Password, utils.ClientInfo(c))
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
+	if res.MFARequired {
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    res.MFAToken,
+		})
+		return
+	}
+
+	h.setAuthToken(c, res)
+}
+
+// LoginMFA godoc
+//
+//	@Summary		Completes a two-factor login.
+//	@Description	Exchanges the MFA challenge token returned by login and a TOTP or recovery code for a JWT.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.MFALoginRequest	true	"Two-factor login request"
+//	@Success		200		{object}	models.LoginUserResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		401		{object}	utils.ProblemDetails
+//	@Router			/auth/login/2fa [post]
+func (h *UserHandler) LoginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		err := utils.NewBindingError(e.InvalidMFACode, "mfa token and code are required", err)
+		utils.HandleError(c, err)
+		return
+	}
+
+	res, err := h.userService.VerifyMFALogin(req.MFAToken, req.Code, utils.ClientInfo(c))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	h.setAuthToken(c, res)
+}
+
+// setAuthToken sends the JWT of a successful login in the Authorization header,
+// the HttpOnly auth_token cookie and the response body, and issues a CSRF token.
+func (h *UserHandler) setAuthToken(c *gin.Context, res models.LoginUserResponse) {
+	if err := utils.SetAuthCookies(c, h.cookies, res.Token); err != nil {
+		utils.HandleError(c, e.NewError(e.InternalErr, e.CSRFTokenInvalid, "failed to issue CSRF token", err))
+		return
+	}
 	c.Header("Authorization", "Bearer "+res.Token)
-	// not http only, secure, localhost as domain
-	// would update for prod app
-	c.SetCookie("auth_token", "Bearer "+res.Token, 3600, "/", "", false, false)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User logged in successfully",
 		"token":   res.Token,
@@ -105,7 +162,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 //	@Produce		json
 //	@Param			id	path	string	true	"User ID"
 //	@Success		200		{object}	models.User
-//	@Failure		400		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.ProblemDetails
 //
 //	@Security		BearerAuth
 //
@@ -114,7 +171,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	log.Println("Getting user by ID")
 	var id = c.Param("id")
 	if id == "" {
-		err := e.NewError(e.UserErr, e.InvalidCredentials, "user ID is required", nil)
+		err := e.NewError(e.ValidationErr, e.InvalidRequest, "user ID is required", nil)
 		utils.HandleError(c, err)
 		return
 	}
@@ -150,7 +207,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 func (h *UserHandler) VerifyAuth(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		utils.HandleError(c, e.NewError(e.UserErr, e.InvalidToken, "unauthorized", nil))
+		utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidToken, "unauthorized", nil))
 		return
 	}
 
@@ -159,3 +216,96 @@ func (h *UserHandler) VerifyAuth(c *gin.Context) {
 		"userID":  userID,
 	})
 }
+
+// EnrollMFA godoc
+//
+//	@Summary		Starts two-factor enrollment.
+//	@Description	Generates a TOTP secret and returns the otpauth URI to scan with an authenticator app.
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Success		200		{object}	models.MFAEnrollmentResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/user/{id}/2fa/enroll [post]
+func (h *UserHandler) EnrollMFA(c *gin.Context) {
+	res, err := h.userService.EnrollMFA(c.Param("id"))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// ConfirmMFA godoc
+//
+//	@Summary		Confirms two-factor enrollment.
+//	@Description	Enables two-factor authentication with a first TOTP code and returns single-use recovery codes.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"User ID"
+//	@Param			request	body		models.MFACodeRequest	true	"TOTP code"
+//	@Success		200		{object}	models.MFAConfirmResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/user/{id}/2fa/confirm [post]
+func (h *UserHandler) ConfirmMFA(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		err := utils.NewBindingError(e.InvalidMFACode, "code is required", err)
+		utils.HandleError(c, err)
+		return
+	}
+
+	codes, err := h.userService.ConfirmMFA(c.Param("id"), req.Code)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAConfirmResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableMFA godoc
+//
+//	@Summary		Disables two-factor authentication.
+//	@Description	Disables two-factor authentication. Requires the password and a current TOTP code.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"User ID"
+//	@Param			request	body		models.MFADisableRequest	true	"Password and TOTP code"
+//	@Success		200		{object}	string
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		401		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//
+//	@Router			/user/{id}/2fa/disable [post]
+func (h *UserHandler) DisableMFA(c *gin.Context) {
+	var req models.MFADisableRequest
+
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		err := utils.NewBindingError(e.InvalidRequest, "password and code are required", err)
+		utils.HandleError(c, err)
+		return
+	}
+
+	if err := h.userService.DisableMFA(c.Param("id"), req.Password, req.Code); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled",
+	})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler serves the webhooks of a user under /user/{id}/webhooks and the admin
+// webhooks under /admin/webhooks. The owner of the webhooks is the user of the path,
+// or the admins when the path has no user.
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// ListWebhooks godoc
+//
+//	@Summary		Lists webhooks.
+//	@Description	Lists the webhooks of the user, or the admin webhooks, oldest first. Secrets are not returned.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Success		200		{object}	models.WebhooksResponse
+//	@Failure		401		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//	@Security		AdminKey
+//
+//	@Router			/user/{id}/webhooks [get]
+//	@Router			/admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhookService.List(c.Param("id"))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebhooksResponse{
+		Webhooks: webhooks,
+	})
+}
+
+// CreateWebhook godoc
+//
+//	@Summary		Registers a webhook.
+//	@Description	Registers an endpoint receiving the image.liked and image.unliked events of the user, or of every user for admin webhooks.
+//	@Description	Events are POSTed as JSON with the X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and X-Webhook-Signature headers.
+//	@Description	The signature is "sha256=" followed by the hex HMAC-SHA256, keyed with the secret, of the timestamp, a dot and the body.
+//	@Description	The secret is only returned in this response.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			request body models.CreateWebhookRequestBody true "Webhook URL"
+//	@Success		201		{object}	models.WebhookResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		401		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//	@Security		AdminKey
+//
+//	@Router			/user/{id}/webhooks [post]
+//	@Router			/admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var body models.CreateWebhookRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid webhook URL is required", err))
+		return
+	}
+
+	webhook, err := h.webhookService.Create(c.Param("id"), body.URL)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// DeleteWebhook godoc
+//
+//	@Summary		Deletes a webhook.
+//	@Description	Deletes a webhook and its delivery log. Pending deliveries are dropped.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			webhook_id	path	string	true	"Webhook ID"
+//	@Success		200		{object}	string
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		404		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//	@Security		AdminKey
+//
+//	@Router			/user/{id}/webhooks/{webhook_id} [delete]
+//	@Router			/admin/webhooks/{webhook_id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	var req models.WebhookRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid webhook ID is required", err))
+		return
+	}
+
+	if err := h.webhookService.Delete(c.Param("id"), req.WebhookID); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted",
+	})
+}
+
+// ListDeliveries godoc
+//
+//	@Summary		Lists the deliveries of a webhook.
+//	@Description	Lists the latest 100 deliveries of a webhook, most recent first, with the outcome of their latest attempt.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			webhook_id	path	string	true	"Webhook ID"
+//	@Success		200		{object}	models.WebhookDeliveriesResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		404		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//	@Security		AdminKey
+//
+//	@Router			/user/{id}/webhooks/{webhook_id}/deliveries [get]
+//	@Router			/admin/webhooks/{webhook_id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var req models.WebhookRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "a valid webhook ID is required", err))
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Param("id"), req.WebhookID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebhookDeliveriesResponse{
+		Deliveries: deliveries,
+	})
+}
+
+// Redeliver godoc
+//
+//	@Summary		Redelivers an event.
+//	@Description	Sends the event of a delivery to the webhook again, as a new delivery retried on its own.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path	string	true	"User ID"
+//	@Param			webhook_id	path	string	true	"Webhook ID"
+//	@Param			delivery_id	path	string	true	"Delivery ID"
+//	@Success		202		{object}	models.WebhookDeliveryResponse
+//	@Failure		400		{object}	utils.ProblemDetails
+//	@Failure		404		{object}	utils.ProblemDetails
+//
+//	@Security		BearerAuth
+//	@Security		AdminKey
+//
+//	@Router			/user/{id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver [post]
+//	@Router			/admin/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	var req models.WebhookDeliveryRequestURL
+	if err := c.ShouldBindUri(&req); err != nil {
+		utils.HandleError(c, utils.NewBindingError(e.InvalidRequest, "valid webhook and delivery IDs are required", err))
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(c.Param("id"), req.WebhookID, req.DeliveryID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
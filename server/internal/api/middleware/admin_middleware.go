@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	e "server/internal/errors"
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHeader is the header carrying the admin API key.
+const AdminKeyHeader = "X-Admin-Key"
+
+// RequireAdminKey is a middleware restricting a route to admins, authenticated by the
+// admin API key in the X-Admin-Key header. The keys are compared in constant time. An
+// empty key rejects every request, so admin routes are closed unless a key is set.
+func RequireAdminKey(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(AdminKeyHeader)
+		if key == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+			utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidCredentials, "invalid admin key", nil))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/internal/api/middleware"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdminKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(key, provided string) int {
+		router := gin.New()
+		router.GET("/admin", middleware.RequireAdminKey(key), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+		if provided != "" {
+			req.Header.Set(middleware.AdminKeyHeader, provided)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	t.Run("valid key - passes", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve("s3cret", "s3cret"))
+	})
+
+	t.Run("missing or wrong key - returns unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve("s3cret", ""))
+		assert.Equal(t, http.StatusUnauthorized, serve("s3cret", "guess"))
+	})
+
+	t.Run("no key configured - rejects every request", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve("", ""))
+	})
+}
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"server/config"
 	e "server/internal/errors"
+	"server/internal/models"
 	"server/internal/utils"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,11 +13,20 @@ import (
 )
 
 type AuthMiddleware struct {
-	jwtSecret []byte
+	keys     *utils.KeyRing
+	sessions SessionValidator
+	cookies  config.CookieConfig
+}
+
+// SessionValidator checks that the session a token was issued for is still active, and
+// starts sessions for the tokens issued before sessions existed.
+type SessionValidator interface {
+	ValidateSession(userID, sessionID string) error
+	StartSession(userID string, client models.ClientInfo) (string, error)
 }
 
 // NewAuthMiddleware creates a new instance of AuthMiddleware with the provided JWT secret.
-// The JWT secret is used to sign and verify JWT tokens.
+// The JWT secret is used to sign and verify HS256 JWT tokens.
 //
 // Parameters:
 //   - jwtSecret: A string representing the secret key used for JWT authentication.
@@ -22,51 +34,99 @@ type AuthMiddleware struct {
 // Returns:
 //   - A pointer to an AuthMiddleware instance initialized with the provided JWT secret.
 func NewAuthMiddleware(jwtSecret string) *AuthMiddleware {
+	return NewAuthMiddlewareWithKeyRing(utils.NewLegacyKeyRing(jwtSecret))
+}
+
+// NewAuthMiddlewareWithKeyRing creates a new instance of AuthMiddleware that verifies tokens
+// against every key of the provided KeyRing and refreshes them with its active key.
+//
+// Parameters:
+//   - keys: The KeyRing holding the signing and verification keys.
+//
+// Returns:
+//   - A pointer to an AuthMiddleware instance initialized with the provided KeyRing.
+func NewAuthMiddlewareWithKeyRing(keys *utils.KeyRing) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret: []byte(jwtSecret),
+		keys: keys,
 	}
 }
 
+// WithSessions makes VerifyJWT require a "sid" claim and reject tokens whose session
+// is not active according to sessions. Legacy HS256 tokens without one, issued before
+// sessions existed, are accepted during the migration window and bound to a new
+// session when refreshed.
+//
+// Parameters:
+//   - sessions: The SessionValidator checking the "sid" claim of each token.
+//
+// Returns:
+//   - The AuthMiddleware, for chaining.
+func (a *AuthMiddleware) WithSessions(sessions SessionValidator) *AuthMiddleware {
+	a.sessions = sessions
+	return a
+}
+
+// WithCookies sets the attributes of the auth cookie re-issued when VerifyJWT refreshes
+// a token read from it.
+//
+// Parameters:
+//   - cookies: The attributes of the auth and CSRF cookies.
+//
+// Returns:
+//   - The AuthMiddleware, for chaining.
+func (a *AuthMiddleware) WithCookies(cookies config.CookieConfig) *AuthMiddleware {
+	a.cookies = cookies
+	return a
+}
+
 // AuthMiddleware is a middleware function that handles
 // authentication by validating the JWT token present in the "Authorization" header
 // of the incoming request. If the token is missing, invalid, or expired, it aborts
 // the request and responds with a 401 Unauthorized status. If the token is valid,
 // it allows the request to proceed to the next handler.
 //
-// The JWT token is expected to be in the format "Bearer <token>" and is validated
-// using the HMAC signing method with the secret key stored in the AuthMiddleware
-// struct.
+// The JWT token is expected to be in the format "Bearer <token>", in the Authorization header
+// or else in the auth_token cookie, and is validated
+// against the KeyRing stored in the AuthMiddleware struct: asymmetric tokens are
+// matched by their "kid" header, HS256 tokens by the legacy secret while allowed.
+// When sessions are configured, tokens of revoked sessions are rejected as well.
+//
+// Requests authenticated with the cookie are flagged with "authViaCookie" in the context
+// so VerifyCSRF can require a CSRF token from them. Their refreshed token is stored
+// in a new auth cookie, which scripts cannot write, alongside a new CSRF token.
 func (a *AuthMiddleware) VerifyJWT() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
-		var tokenString string
-
-		headerTokenString := c.GetHeader("Authorization")
-		cookieTokenSlice := c.Request.CookiesNamed("auth_token")
+		tokenString := c.GetHeader("Authorization")
+		viaCookie := false
 
-		if len(cookieTokenSlice) > 0 {
-			tokenString = cookieTokenSlice[0].Value
-		} else {
-			tokenString = headerTokenString
+		if tokenString == "" {
+			if cookieTokenSlice := c.Request.CookiesNamed(utils.AuthCookieName); len(cookieTokenSlice) > 0 {
+				tokenString = cookieTokenSlice[0].Value
+				viaCookie = true
+			}
 		}
 
-		if tokenString == "" {
+		tokenString, ok := strings.CutPrefix(tokenString, "Bearer ")
+		if !ok || tokenString == "" {
 			utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidToken, "unauthorized", nil))
 			return
 		}
 
-		token, err := jwt.Parse(tokenString[len("Bearer "):], func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrTokenSignatureInvalid
-			}
-			return a.jwtSecret, nil
-		})
+		token, err := jwt.Parse(tokenString, a.keys.Keyfunc, jwt.WithValidMethods(a.keys.ValidMethods()))
 
 		if err != nil || !token.Valid {
 			utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidToken, "unauthorized", nil))
 			return
 		}
 
+		// Purpose-bound tokens, such as two-factor login challenges, carry a "typ"
+		// claim and must not be usable as access tokens.
+		if claims, ok := token.Claims.(jwt.MapClaims); ok && claims["typ"] != nil {
+			utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidToken, "unauthorized", nil))
+			return
+		}
+
 		sub, err := token.Claims.GetSubject()
 
 		if err != nil || sub == "" {
@@ -74,6 +134,23 @@ func (a *AuthMiddleware) VerifyJWT() gin.HandlerFunc {
 			return
 		}
 
+		sid, _ := token.Claims.(jwt.MapClaims)["sid"].(string)
+		sessionless := false
+		if a.sessions != nil {
+			switch {
+			case sid != "":
+				if err := a.sessions.ValidateSession(sub, sid); err != nil {
+					utils.HandleError(c, err)
+					return
+				}
+			case a.keys.IsLegacyToken(token):
+				sessionless = true
+			default:
+				utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidToken, "unauthorized", nil))
+				return
+			}
+		}
+
 		// if token has 8 or less hours left, refresh it
 		expirationTime, err := token.Claims.GetExpirationTime()
 		if err != nil {
@@ -82,14 +159,28 @@ func (a *AuthMiddleware) VerifyJWT() gin.HandlerFunc {
 		}
 
 		if expirationTime != nil && time.Until(expirationTime.Time) < 8*time.Hour {
-			newToken, err := utils.RefreshJWT(tokenString[len("Bearer "):])
+			if sessionless {
+				if sid, err = a.sessions.StartSession(sub, utils.ClientInfo(c)); err != nil {
+					utils.HandleError(c, err)
+					return
+				}
+			}
+			newToken, err := a.keys.RefreshWithSession(tokenString, sid)
 			if err != nil {
 				utils.HandleError(c, e.NewError(e.AuthorizationErr, e.InvalidToken, "unauthorized", nil))
 				return
 			}
+			if viaCookie {
+				if err := utils.SetAuthCookies(c, a.cookies, newToken); err != nil {
+					utils.HandleError(c, e.NewError(e.InternalErr, e.CSRFTokenInvalid, "failed to issue CSRF token", err))
+					return
+				}
+			}
 			c.Header("Authorization", "Bearer "+newToken)
 		}
 		c.Set("userID", sub)
+		c.Set("sessionID", sid)
+		c.Set("authViaCookie", viaCookie)
 
 		c.Next()
 	}
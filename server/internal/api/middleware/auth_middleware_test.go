@@ -3,8 +3,13 @@ package middleware_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"server/config"
 	"server/internal/api/middleware"
+	"server/internal/api/services"
+	testing_mocks "server/internal/testing"
+	"server/internal/utils"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -12,8 +17,8 @@ import (
 )
 
 var jwtSecret = "secret"
-var authErrJSON = `{"error":"Authentication error","code":"invalid_token","detail":"unauthorized"}`
-var forbiddenErrJSON = `{"error":"Forbidden", "code":"invalid_token", "detail":"forbidden"}`
+var authErrJSON = `{"type":"/problems/invalid_token","title":"Authentication error","status":401,"code":"invalid_token","detail":"unauthorized","instance":"/test"}`
+var forbiddenErrJSON = `{"type":"/problems/invalid_token","title":"Forbidden","status":403,"code":"invalid_token","detail":"forbidden","instance":"/test/456"}`
 
 func TestVerifyJWT(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -79,6 +84,120 @@ func TestVerifyJWT(t *testing.T) {
 		assert.Equal(t, http.StatusOK, resp.Code)
 	})
 
+	t.Run("valid asymmetric token", func(t *testing.T) {
+		keyRing, _ := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, jwtSecret, time.Now().Add(time.Hour))
+		tokenString, _ := keyRing.Sign(jwt.MapClaims{
+			"sub": "1234567890",
+			"iat": 1516239022,
+		})
+
+		router := gin.New()
+		a := middleware.NewAuthMiddlewareWithKeyRing(keyRing)
+
+		router.Use(a.VerifyJWT())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("legacy token after migration window", func(t *testing.T) {
+		keyRing, _ := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, jwtSecret, time.Time{})
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "1234567890",
+			"iat": 1516239022,
+		})
+		tokenString, _ := token.SignedString([]byte(jwtSecret))
+
+		router := gin.New()
+		a := middleware.NewAuthMiddlewareWithKeyRing(keyRing)
+
+		router.Use(a.VerifyJWT())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.JSONEq(t, authErrJSON, resp.Body.String())
+	})
+
+	t.Run("expiring legacy token is refreshed with active key", func(t *testing.T) {
+		keyRing, _ := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, jwtSecret, time.Now().Add(time.Hour))
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "1234567890",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		tokenString, _ := token.SignedString([]byte(jwtSecret))
+
+		router := gin.New()
+		a := middleware.NewAuthMiddlewareWithKeyRing(keyRing)
+
+		router.Use(a.VerifyJWT())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		refreshed, _, err := jwt.NewParser().ParseUnverified(resp.Header().Get("Authorization")[len("Bearer "):], jwt.MapClaims{})
+		assert.NoError(t, err)
+		assert.Equal(t, utils.AlgEdDSA, refreshed.Method.Alg())
+		assert.Equal(t, keyRing.ActiveKeyID(), refreshed.Header["kid"])
+	})
+
+	t.Run("expiring cookie token is refreshed in a new cookie", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "1234567890",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		tokenString, _ := token.SignedString([]byte(jwtSecret))
+
+		router := gin.New()
+		a := middleware.NewAuthMiddleware(jwtSecret).WithCookies(config.CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode})
+
+		router.Use(a.VerifyJWT())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.AddCookie(&http.Cookie{Name: utils.AuthCookieName, Value: "Bearer " + tokenString})
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		cookies := map[string]*http.Cookie{}
+		for _, cookie := range resp.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		if assert.Contains(t, cookies, utils.AuthCookieName) {
+			assert.Equal(t, resp.Header().Get("Authorization"), cookies[utils.AuthCookieName].Value)
+			assert.NotEqual(t, "Bearer "+tokenString, cookies[utils.AuthCookieName].Value)
+			assert.True(t, cookies[utils.AuthCookieName].HttpOnly)
+			assert.True(t, cookies[utils.AuthCookieName].Secure)
+		}
+		assert.Contains(t, cookies, utils.CSRFCookieName)
+	})
+
 	t.Run("userID set in gin context", func(t *testing.T) {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 			"sub": "1234567890",
@@ -181,8 +300,131 @@ func TestVerifyRequestOwnership(t *testing.T) {
 
 		router.ServeHTTP(resp, req)
 
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.JSONEq(t, `{"type":"/problems/invalid_token","title":"Authentication error","status":401,"code":"invalid_token","detail":"unauthorized","instance":"/test/123"}`, resp.Body.String())
+	})
+
+}
+
+func TestVerifyJWTSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keyRing, _ := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+
+	serve := func(sessionBuilder *testing_mocks.MockSessionBuilder, claims jwt.MapClaims) *httptest.ResponseRecorder {
+		tokenString, _ := keyRing.Sign(claims)
+
+		router := gin.New()
+		a := middleware.NewAuthMiddlewareWithKeyRing(keyRing).WithSessions(services.NewSessionService(sessionBuilder.Build()))
+		router.Use(a.VerifyJWT())
+		router.GET("/test", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString("sessionID"))
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("active session", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithSession("1", time.Now())
+
+		resp := serve(sessionBuilder, jwt.MapClaims{"sub": "1", "sid": testing_mocks.SessionID})
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, testing_mocks.SessionID, resp.Body.String())
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("stale session is touched", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithSession("1", time.Now().Add(-time.Hour)).WithTouch(testing_mocks.SessionID)
+
+		resp := serve(sessionBuilder, jwt.MapClaims{"sub": "1", "sid": testing_mocks.SessionID})
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("revoked session", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithRevokedSession("1")
+
+		resp := serve(sessionBuilder, jwt.MapClaims{"sub": "1", "sid": testing_mocks.SessionID})
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.JSONEq(t, `{"type":"/problems/session_revoked","title":"Authentication error","status":401,"code":"session_revoked","detail":"session revoked","instance":"/test"}`, resp.Body.String())
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithSession("2", time.Now())
+
+		resp := serve(sessionBuilder, jwt.MapClaims{"sub": "1", "sid": testing_mocks.SessionID})
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("token without session", func(t *testing.T) {
+		resp := serve(testing_mocks.NewSessionMockBuilder(), jwt.MapClaims{"sub": "1"})
+
 		assert.Equal(t, http.StatusUnauthorized, resp.Code)
 		assert.JSONEq(t, authErrJSON, resp.Body.String())
 	})
 
+	legacyServe := func(sessionBuilder *testing_mocks.MockSessionBuilder, legacyUntil time.Time, exp time.Duration) *httptest.ResponseRecorder {
+		legacyRing, _ := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, jwtSecret, legacyUntil)
+		// Shaped like the tokens issued before sessions existed.
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "wti-tech-interview",
+			"sub": "1",
+			"exp": time.Now().Add(exp).Unix(),
+			"nbf": time.Now().Unix(),
+			"iat": time.Now().Unix(),
+			"jti": "0b8e2a7c-4d1f-4f5e-9c3a-7e6d5b4a3c21",
+		}).SignedString([]byte(jwtSecret))
+
+		router := gin.New()
+		a := middleware.NewAuthMiddlewareWithKeyRing(legacyRing).WithSessions(services.NewSessionService(sessionBuilder.Build()))
+		router.Use(a.VerifyJWT())
+		router.GET("/test", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString("sessionID"))
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("legacy token without session during the migration window", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder()
+
+		resp := legacyServe(sessionBuilder, time.Now().Add(time.Hour), 20*time.Hour)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Empty(t, resp.Body.String())
+		assert.Empty(t, resp.Header().Get("Authorization"))
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("expiring legacy token without session is refreshed with a new session", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("1")
+
+		resp := legacyServe(sessionBuilder, time.Now().Add(time.Hour), time.Hour)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, testing_mocks.SessionID, resp.Body.String())
+		refreshed, _, err := jwt.NewParser().ParseUnverified(resp.Header().Get("Authorization")[len("Bearer "):], jwt.MapClaims{})
+		assert.NoError(t, err)
+		assert.Equal(t, utils.AlgEdDSA, refreshed.Method.Alg())
+		assert.Equal(t, testing_mocks.SessionID, refreshed.Claims.(jwt.MapClaims)["sid"])
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("legacy token without session after the migration window", func(t *testing.T) {
+		resp := legacyServe(testing_mocks.NewSessionMockBuilder(), time.Now().Add(-time.Hour), 20*time.Hour)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.JSONEq(t, authErrJSON, resp.Body.String())
+	})
 }
@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/api/middleware"
+	"server/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+var csrfErrJSON = `{"type":"/problems/csrf_token_invalid","title":"Forbidden","status":403,"code":"csrf_token_invalid","detail":"missing or invalid CSRF token","instance":"/test"}`
+
+func TestVerifyCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keyRing, _ := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+	tokenString, _ := keyRing.Sign(jwt.MapClaims{"sub": "1"})
+	cookies := config.CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode}
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(middleware.NewAuthMiddlewareWithKeyRing(keyRing).VerifyJWT(), middleware.NewCSRFMiddleware(cookies).VerifyCSRF())
+		router.Any("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	withAuthCookie := func(method string) *http.Request {
+		req, _ := http.NewRequest(method, "/test", nil)
+		req.AddCookie(&http.Cookie{Name: utils.AuthCookieName, Value: "Bearer " + tokenString})
+		return req
+	}
+
+	t.Run("header authentication does not need a CSRF token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+
+		resp := serve(req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("cookie authentication without CSRF token", func(t *testing.T) {
+		resp := serve(withAuthCookie(http.MethodPost))
+
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		assert.JSONEq(t, csrfErrJSON, resp.Body.String())
+	})
+
+	t.Run("cookie authentication with mismatched CSRF token", func(t *testing.T) {
+		req := withAuthCookie(http.MethodDelete)
+		req.AddCookie(&http.Cookie{Name: utils.CSRFCookieName, Value: "cookie-token"})
+		req.Header.Set(utils.CSRFHeaderName, "other-token")
+
+		resp := serve(req)
+
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("cookie authentication with matching CSRF token", func(t *testing.T) {
+		req := withAuthCookie(http.MethodPost)
+		req.AddCookie(&http.Cookie{Name: utils.CSRFCookieName, Value: "cookie-token"})
+		req.Header.Set(utils.CSRFHeaderName, "cookie-token")
+
+		resp := serve(req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+
+	t.Run("safe request issues a missing CSRF cookie", func(t *testing.T) {
+		resp := serve(withAuthCookie(http.MethodGet))
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		cookie := resp.Result().Cookies()
+		if assert.Len(t, cookie, 1) {
+			assert.Equal(t, utils.CSRFCookieName, cookie[0].Name)
+			assert.NotEmpty(t, cookie[0].Value)
+			assert.True(t, cookie[0].Secure)
+			assert.False(t, cookie[0].HttpOnly)
+			assert.Equal(t, http.SameSiteStrictMode, cookie[0].SameSite)
+		}
+	})
+}
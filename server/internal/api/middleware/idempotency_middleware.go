@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// replayedHeaders are the response headers stored with a key and sent back to its
+// retries, such as the validators of the next conditional request.
+var replayedHeaders = []string{"ETag", "Last-Modified", "Location"}
+
+type IdempotencyMiddleware struct {
+	repo   repositories.IdempotencyRepository
+	window time.Duration
+	secret []byte
+	now    func() time.Time
+}
+
+// NewIdempotencyMiddleware creates a new instance of IdempotencyMiddleware.
+//
+// Parameters:
+//   - repo: The repository storing the keys and their responses.
+//   - window: How long a key is remembered.
+//   - secret: The HMAC key of the request fingerprints.
+//
+// Returns:
+//   - A pointer to an IdempotencyMiddleware instance.
+func NewIdempotencyMiddleware(repo repositories.IdempotencyRepository, window time.Duration, secret []byte) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		repo:   repo,
+		window: window,
+		secret: secret,
+		now:    time.Now,
+	}
+}
+
+// Idempotent is a middleware making retries of a request sent with an Idempotency-Key
+// header safe. It should be used after VerifyJWT on protected routes, so keys are
+// scoped to the user; on public routes keys share a single scope.
+//
+// The first request with a key is handled normally and its response is stored with
+// a fingerprint of the method, path and body, an HMAC so the passwords of the stored
+// registrations cannot be cracked from it. Retries with the same key within the
+// window get the stored response back, with its replayedHeaders and the
+// Idempotent-Replayed header, without running the handler again. A retry with a
+// different fingerprint is rejected with 422, and a retry while the first request is
+// still running with 409. Responses with a 5xx status are not stored, and neither are
+// the requests whose handler panics, so the request can be retried.
+func (m *IdempotencyMiddleware) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.HandleError(c, e.NewError(e.UserErr, e.InvalidIdempotencyKey, "idempotency key must be at most 255 characters long", nil))
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				utils.HandleError(c, e.NewError(e.ValidationErr, e.InvalidRequest, "failed to read request body", err))
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		record := &models.IdempotencyRecord{
+			Scope:       c.GetString("userID"),
+			Key:         key,
+			Fingerprint: m.fingerprint(c.Request.Method, c.Request.URL.Path, body),
+		}
+
+		existing, reserved, err := m.repo.Reserve(record, m.now().Add(-m.window))
+		if err != nil {
+			utils.HandleError(c, err)
+			return
+		}
+		if !reserved {
+			m.replay(c, record, existing)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			if p := recover(); p != nil {
+				m.release(record)
+				panic(p)
+			}
+		}()
+		c.Next()
+
+		if status := writer.Status(); status >= 500 {
+			m.release(record)
+		} else {
+			record.StatusCode = status
+			record.ContentType = writer.Header().Get("Content-Type")
+			record.Body = writer.body.Bytes()
+			for _, name := range replayedHeaders {
+				if value := writer.Header().Get(name); value != "" {
+					if record.Headers == nil {
+						record.Headers = make(map[string]string)
+					}
+					record.Headers[name] = value
+				}
+			}
+			if err := m.repo.Complete(record); err != nil {
+				log.Printf("failed to store idempotent response: %v", err)
+			}
+		}
+	}
+}
+
+// release forgets the key of a request that failed, so it can be retried.
+func (m *IdempotencyMiddleware) release(record *models.IdempotencyRecord) {
+	if err := m.repo.Release(record.Scope, record.Key); err != nil {
+		log.Printf("failed to release idempotency key: %v", err)
+	}
+}
+
+// replay answers a retry with the response stored for its key.
+func (m *IdempotencyMiddleware) replay(c *gin.Context, record, existing *models.IdempotencyRecord) {
+	switch {
+	case existing.Fingerprint != record.Fingerprint:
+		utils.HandleError(c, e.NewError(e.UserErr, e.IdempotencyKeyReused, "idempotency key was already used for another request", nil))
+	case existing.StatusCode == 0:
+		utils.HandleError(c, e.NewError(e.UserErr, e.IdempotencyInProgress, "a request with this idempotency key is in progress", nil))
+	default:
+		for name, value := range existing.Headers {
+			c.Header(name, value)
+		}
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+		c.Abort()
+	}
+}
+
+func (m *IdempotencyMiddleware) fingerprint(method, path string, body []byte) string {
+	h := hmac.New(sha256.New, m.secret)
+	io.WriteString(h, method+"\n"+path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
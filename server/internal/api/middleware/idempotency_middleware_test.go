@@ -0,0 +1,201 @@
+package middleware_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/api/middleware"
+	"server/internal/api/repositories/memory"
+	"server/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(status int) (*gin.Engine, *int) {
+		repo := memory.NewIdempotencyRepository(memory.NewStore())
+		calls := 0
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("userID", c.GetHeader("X-User"))
+		})
+		router.POST("/likes", middleware.NewIdempotencyMiddleware(repo, time.Hour, []byte("secret")).Idempotent(), func(c *gin.Context) {
+			calls++
+			c.Header("ETag", fmt.Sprintf(`"%d"`, calls))
+			c.Header("X-Not-Replayed", "true")
+			c.JSON(status, gin.H{"call": calls})
+		})
+		return router, &calls
+	}
+
+	send := func(router *gin.Engine, key, user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/likes", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		req.Header.Set("X-User", user)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("retries replay the stored response", func(t *testing.T) {
+		router, calls := setup(http.StatusCreated)
+
+		first := send(router, "key-1", "user-1", `{"url":"a"}`)
+		retry := send(router, "key-1", "user-1", `{"url":"a"}`)
+
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, `"1"`, retry.Header().Get("ETag"))
+		assert.Empty(t, retry.Header().Get("X-Not-Replayed"))
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+	})
+
+	t.Run("requests without a key are not deduplicated", func(t *testing.T) {
+		router, calls := setup(http.StatusCreated)
+
+		send(router, "", "user-1", `{}`)
+		send(router, "", "user-1", `{}`)
+
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("keys are scoped to the user", func(t *testing.T) {
+		router, calls := setup(http.StatusCreated)
+
+		send(router, "key-1", "user-1", `{}`)
+		resp := send(router, "key-1", "user-2", `{}`)
+
+		assert.Equal(t, 2, *calls)
+		assert.Empty(t, resp.Header().Get(middleware.IdempotentReplayedHeader))
+	})
+
+	t.Run("reusing a key with another body", func(t *testing.T) {
+		router, calls := setup(http.StatusCreated)
+
+		send(router, "key-1", "user-1", `{"url":"a"}`)
+		resp := send(router, "key-1", "user-1", `{"url":"b"}`)
+
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"code":"idempotency_key_reused"`)
+	})
+
+	t.Run("server errors can be retried", func(t *testing.T) {
+		router, calls := setup(http.StatusInternalServerError)
+
+		send(router, "key-1", "user-1", `{}`)
+		send(router, "key-1", "user-1", `{}`)
+
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		router, calls := setup(http.StatusCreated)
+
+		resp := send(router, strings.Repeat("k", 256), "user-1", `{}`)
+
+		assert.Equal(t, 0, *calls)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func TestIdempotentPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := memory.NewIdempotencyRepository(memory.NewStore())
+
+	calls := 0
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.POST("/likes", middleware.NewIdempotencyMiddleware(repo, time.Hour, []byte("secret")).Idempotent(), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/likes", strings.NewReader(`{}`))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	first := send()
+	retry := send()
+
+	assert.Equal(t, http.StatusInternalServerError, first.Code)
+	assert.Equal(t, http.StatusCreated, retry.Code, "the key of a panicking request is released")
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := memory.NewIdempotencyRepository(memory.NewStore())
+	idempotency := middleware.NewIdempotencyMiddleware(repo, time.Hour, []byte("secret"))
+
+	send := func(router *gin.Engine) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/register", nil)
+		req.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	var concurrent *httptest.ResponseRecorder
+	router := gin.New()
+	router.POST("/register", idempotency.Idempotent(), func(c *gin.Context) {
+		if concurrent == nil {
+			// Retry while the first request is still being handled.
+			concurrent = &httptest.ResponseRecorder{}
+			concurrent = send(router)
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	first := send(router)
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusConflict, concurrent.Code)
+	assert.Contains(t, concurrent.Body.String(), `"code":"idempotency_in_progress"`)
+}
+
+func TestIdempotentFingerprint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := memory.NewIdempotencyRepository(memory.NewStore())
+	router := gin.New()
+	router.POST("/register", middleware.NewIdempotencyMiddleware(repo, time.Hour, []byte("secret")).Idempotent(), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	body := `{"email":"john@example.com","password":"Password1!"}`
+
+	req, _ := http.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	req.Header.Set(middleware.IdempotencyKeyHeader, "key-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	stored, reserved, err := repo.Reserve(&models.IdempotencyRecord{Key: "key-1"}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.False(t, reserved)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST\n/register\n" + body))
+	plain := sha256.Sum256([]byte("POST\n/register\n" + body))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), stored.Fingerprint)
+	assert.NotEqual(t, hex.EncodeToString(plain[:]), stored.Fingerprint, "the body cannot be brute-forced without the secret")
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"server/internal/i18n"
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale is a middleware selecting the language of the messages sent to the client.
+// The locale cookie, set when the user picks a language, is preferred over the
+// Accept-Language header; the first supported locale is stored in the context under
+// utils.LocaleKey, and i18n.DefaultLocale is used if none is supported.
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		preference, _ := c.Cookie(utils.LocaleCookieName)
+		c.Set(utils.LocaleKey, i18n.Match(preference, c.GetHeader("Accept-Language")))
+		c.Next()
+	}
+}
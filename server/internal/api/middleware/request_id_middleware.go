@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds the ids accepted from clients, which end up in logs and responses.
+const maxRequestIDLength = 128
+
+// RequestID is a middleware assigning an id to every request. The X-Request-ID header
+// of the request is reused when it holds a printable ASCII id, so ids set by a proxy
+// can be followed across services; otherwise a random UUID is generated. The id is
+// stored in the context under utils.RequestIDKey and echoed in the response header.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(utils.RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(utils.RequestIDKey, id)
+		c.Header(utils.RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
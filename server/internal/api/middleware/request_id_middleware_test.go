@@ -0,0 +1,56 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/internal/api/middleware"
+	"server/internal/utils"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, utils.RequestID(c))
+	})
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		if id != "" {
+			req.Header.Set(utils.RequestIDHeader, id)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("generates an id", func(t *testing.T) {
+		resp := serve("")
+
+		_, err := uuid.Parse(resp.Body.String())
+		assert.NoError(t, err)
+		assert.Equal(t, resp.Body.String(), resp.Header().Get(utils.RequestIDHeader))
+	})
+
+	t.Run("reuses the id of the client", func(t *testing.T) {
+		resp := serve("proxy-1234")
+
+		assert.Equal(t, "proxy-1234", resp.Body.String())
+		assert.Equal(t, "proxy-1234", resp.Header().Get(utils.RequestIDHeader))
+	})
+
+	t.Run("replaces invalid ids", func(t *testing.T) {
+		for _, id := range []string{"has space", strings.Repeat("a", 129)} {
+			resp := serve(id)
+
+			assert.NotEqual(t, id, resp.Body.String())
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"server/config"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	cors "github.com/rs/cors/wrapper/gin"
+)
+
+const (
+	// apiContentSecurityPolicy forbids loading anything, the API only answers with data.
+	apiContentSecurityPolicy = "default-src 'none'"
+	// swaggerContentSecurityPolicy lets the Swagger UI load its bundled assets and run
+	// the inline script that initializes it.
+	swaggerContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:"
+)
+
+// NewCORSMiddleware returns a middleware answering preflight requests and adding the
+// CORS headers allowed by cfg.
+//
+// Parameters:
+//   - cfg: The allowed origins, methods and headers.
+//
+// Returns:
+//   - A gin.HandlerFunc handling cross-origin requests.
+func NewCORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	return cors.New(cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
+	})
+}
+
+type SecurityHeadersMiddleware struct {
+	cfg            config.SecurityHeadersConfig
+	hsts           string
+	frameAncestors string
+}
+
+// NewSecurityHeadersMiddleware creates a new instance of SecurityHeadersMiddleware.
+//
+// Parameters:
+//   - cfg: The security header settings.
+//
+// Returns:
+//   - A pointer to a SecurityHeadersMiddleware instance.
+func NewSecurityHeadersMiddleware(cfg config.SecurityHeadersConfig) *SecurityHeadersMiddleware {
+	m := &SecurityHeadersMiddleware{cfg: cfg}
+
+	if cfg.HSTSMaxAge > 0 {
+		m.hsts = fmt.Sprintf("max-age=%d", int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			m.hsts += "; includeSubDomains"
+		}
+	}
+
+	switch cfg.FrameOptions {
+	case "DENY":
+		m.frameAncestors = "'none'"
+	case "SAMEORIGIN":
+		m.frameAncestors = "'self'"
+	}
+
+	return m
+}
+
+// SetHeaders is a middleware adding the security headers to every response, with a
+// Content-Security-Policy that forbids loading any resource.
+func (m *SecurityHeadersMiddleware) SetHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Security-Policy", m.contentSecurityPolicy(apiContentSecurityPolicy))
+		if m.hsts != "" {
+			header.Set("Strict-Transport-Security", m.hsts)
+		}
+		if m.cfg.FrameOptions != "" {
+			header.Set("X-Frame-Options", m.cfg.FrameOptions)
+		}
+		if m.cfg.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", m.cfg.ReferrerPolicy)
+		}
+		c.Next()
+	}
+}
+
+// SwaggerCSP is a middleware relaxing the Content-Security-Policy set by SetHeaders
+// so the Swagger UI can render. It should only be used on the Swagger routes.
+func (m *SecurityHeadersMiddleware) SwaggerCSP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Content-Security-Policy", m.contentSecurityPolicy(swaggerContentSecurityPolicy))
+		c.Next()
+	}
+}
+
+// contentSecurityPolicy appends the frame-ancestors directive matching X-Frame-Options.
+func (m *SecurityHeadersMiddleware) contentSecurityPolicy(policy string) string {
+	if m.frameAncestors == "" {
+		return policy
+	}
+	return strings.Join([]string{policy, "frame-ancestors " + m.frameAncestors}, "; ")
+}
@@ -0,0 +1,113 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/api/middleware"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(cfg config.SecurityHeadersConfig, path string) *httptest.ResponseRecorder {
+		security := middleware.NewSecurityHeadersMiddleware(cfg)
+		router := gin.New()
+		router.Use(security.SetHeaders())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		router.GET("/swagger/*any", security.SwaggerCSP(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("all headers", func(t *testing.T) {
+		resp := serve(config.SecurityHeadersConfig{
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			HSTSIncludeSubdomains: true,
+			FrameOptions:          "DENY",
+			ReferrerPolicy:        "no-referrer",
+		}, "/test")
+
+		assert.Equal(t, "max-age=31536000; includeSubDomains", resp.Header().Get("Strict-Transport-Security"))
+		assert.Equal(t, "nosniff", resp.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", resp.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "no-referrer", resp.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", resp.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("disabled headers are omitted", func(t *testing.T) {
+		resp := serve(config.SecurityHeadersConfig{}, "/test")
+
+		assert.Empty(t, resp.Header().Get("Strict-Transport-Security"))
+		assert.Empty(t, resp.Header().Get("X-Frame-Options"))
+		assert.Empty(t, resp.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "nosniff", resp.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "default-src 'none'", resp.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("swagger policy allows its assets", func(t *testing.T) {
+		resp := serve(config.SecurityHeadersConfig{FrameOptions: "SAMEORIGIN"}, "/swagger/index.html")
+
+		csp := resp.Header().Get("Content-Security-Policy")
+		assert.Contains(t, csp, "script-src 'self' 'unsafe-inline'")
+		assert.Contains(t, csp, "frame-ancestors 'self'")
+	})
+}
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.NewCORSMiddleware(config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodDelete},
+		AllowedHeaders:   []string{"Content-Type", "X-CSRF-Token"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	router.Any("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	preflight := func(origin, method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodOptions, "/test", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "x-csrf-token")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("allowed origin", func(t *testing.T) {
+		resp := preflight("https://app.example.com", http.MethodDelete)
+
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "600", resp.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("unknown origin", func(t *testing.T) {
+		resp := preflight("https://evil.example.com", http.MethodDelete)
+
+		assert.Empty(t, resp.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		resp := preflight("https://app.example.com", http.MethodPut)
+
+		assert.Empty(t, resp.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"server/db/queries"
+	e "server/internal/errors"
+)
+
+// queryError converts the error of a query to an internal/errors error. A foreign key
+// violation means the user the row belongs to does not exist, which is reported as
+// UserNotFound. Any other error is a DatabaseError described by message.
+func queryError(err error, message string) error {
+	if queries.IsForeignKeyViolation(err) {
+		return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+	}
+	return e.NewError(e.InternalErr, e.DatabaseError, message, err)
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+	"time"
+)
+
+// IdempotencyRepository defines the interface for idempotency key database operations.
+type IdempotencyRepository interface {
+	Reserve(record *models.IdempotencyRecord, expiredBefore time.Time) (*models.IdempotencyRecord, bool, error)
+	Complete(record *models.IdempotencyRecord) error
+	Release(scope, key string) error
+	PurgeExpired(before time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db queries.DBTX
+}
+
+// NewIdempotencyRepository creates a new instance of IdempotencyRepository.
+func NewIdempotencyRepository(db queries.DBTX) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve stores record as in progress unless its key is already used. Keys created
+// before expiredBefore are forgotten first.
+//
+// Returns:
+//   - *models.IdempotencyRecord: the stored record of the key when it was already used.
+//   - bool: true if record was stored, false if the key was already used.
+//   - error: an error if the database operation fails.
+func (r *idempotencyRepository) Reserve(record *models.IdempotencyRecord, expiredBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	if err := queries.DeleteExpiredIdempotencyKey(r.db, record.Scope, record.Key, expiredBefore); err != nil {
+		return nil, false, queryError(err, "failed to delete expired idempotency key")
+	}
+
+	inserted, err := queries.InsertIdempotencyKey(r.db, record)
+	if err != nil {
+		return nil, false, queryError(err, "failed to store idempotency key")
+	}
+	if inserted {
+		return nil, true, nil
+	}
+
+	existing, err := queries.GetIdempotencyKey(r.db, record.Scope, record.Key)
+	if err != nil {
+		return nil, false, queryError(err, "failed to get idempotency key")
+	}
+	if existing == nil {
+		// The key expired and was deleted by a concurrent request since the insert.
+		return r.Reserve(record, expiredBefore)
+	}
+	return existing, false, nil
+}
+
+// Complete stores the response of a reserved key.
+func (r *idempotencyRepository) Complete(record *models.IdempotencyRecord) error {
+	if err := queries.CompleteIdempotencyKey(r.db, record); err != nil {
+		return queryError(err, "failed to store idempotent response")
+	}
+	return nil
+}
+
+// Release deletes a reserved key so the request can be retried.
+func (r *idempotencyRepository) Release(scope, key string) error {
+	if err := queries.DeleteIdempotencyKey(r.db, scope, key); err != nil {
+		return queryError(err, "failed to delete idempotency key")
+	}
+	return nil
+}
+
+// PurgeExpired deletes the keys created before the given time, which are no longer
+// replayed, and returns how many there were.
+func (r *idempotencyRepository) PurgeExpired(before time.Time) (int64, error) {
+	purged, err := queries.PurgeExpiredIdempotencyKeys(r.db, before)
+	if err != nil {
+		return 0, queryError(err, "failed to purge expired idempotency keys")
+	}
+	return purged, nil
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+)
+
+// IdentityRepository defines the interface for external identity database operations.
+type IdentityRepository interface {
+	FindIdentity(provider, subject string) (*models.UserIdentity, error)
+	CreateIdentity(identity *models.UserIdentity) error
+}
+
+type identityRepository struct {
+	db queries.DBTX
+}
+
+// NewIdentityRepository creates a new instance of IdentityRepository.
+func NewIdentityRepository(db queries.DBTX) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+// FindIdentity retrieves the identity a provider issued for a subject.
+// If the identity does not exist, both the identity and the error are nil.
+//
+// Parameters:
+//   - provider: The name of the OIDC provider.
+//   - subject: The "sub" claim of the provider's ID token.
+//
+// Returns:
+//   - *models.UserIdentity: The linked identity if found, otherwise nil.
+//   - error: An error if there was an issue retrieving the identity.
+func (r *identityRepository) FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	identity, err := queries.GetUserIdentity(r.db, provider, subject)
+	if err != nil {
+		return nil, queryError(err, "failed to get user identity")
+	}
+	return identity, nil
+}
+
+// CreateIdentity links an external identity to a user and sets its ID.
+//
+// Parameters:
+//   - identity: The identity to store. UserID, Provider and Subject are required.
+//
+// Returns:
+//   - error: An error if the identity could not be stored.
+func (r *identityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	id, err := queries.CreateUserIdentity(r.db, identity)
+	if err != nil {
+		return queryError(err, "failed to create user identity")
+	}
+	identity.ID = id
+	return nil
+}
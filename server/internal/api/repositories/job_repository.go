@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+	"time"
+)
+
+// JobRepository is the queue of background jobs.
+type JobRepository interface {
+	Enqueue(job *models.Job) (string, error)
+	Claim(staleBefore time.Time) (*models.Job, error)
+	Find(jobID string) (*models.Job, error)
+	Complete(jobID string) error
+	Retry(jobID string, runAt time.Time, lastError string) error
+	Bury(jobID string, lastError string) error
+}
+
+type jobRepository struct {
+	db queries.DBTX
+}
+
+// NewJobRepository creates a new instance of JobRepository storing jobs in the jobs table.
+func NewJobRepository(db queries.DBTX) JobRepository {
+	return &jobRepository{db: db}
+}
+
+// Enqueue adds a pending job, to run at its RunAt, and returns its ID.
+// If a job with the same UniqueKey is queued, the job is not added and "" is returned.
+//
+// Parameters:
+//   - job: The type, payload, maximum attempts, run time and unique key of the job.
+//
+// Returns:
+//   - string: The ID of the job, or "" if it was not added.
+//   - error: An error if the job could not be stored.
+func (r *jobRepository) Enqueue(job *models.Job) (string, error) {
+	jobID, err := queries.EnqueueJob(r.db, job)
+	if err != nil {
+		return "", queryError(err, "failed to enqueue job")
+	}
+	return jobID, nil
+}
+
+// Claim marks the next due job as running and returns it, or nil if no job is due.
+// Jobs running since before staleBefore are claimed again. Concurrent workers never
+// claim the same job.
+//
+// Parameters:
+//   - staleBefore: The time before which running jobs are considered abandoned.
+//
+// Returns:
+//   - *models.Job: The claimed job, its Attempts including this one, or nil.
+//   - error: An error if the queue could not be read.
+func (r *jobRepository) Claim(staleBefore time.Time) (*models.Job, error) {
+	job, err := queries.ClaimJob(r.db, staleBefore)
+	if err != nil {
+		return nil, queryError(err, "failed to claim job")
+	}
+	return job, nil
+}
+
+// Find retrieves a queued or dead job by its ID, or nil if there is none.
+func (r *jobRepository) Find(jobID string) (*models.Job, error) {
+	job, err := queries.GetJob(r.db, jobID)
+	if err != nil {
+		return nil, queryError(err, "failed to find job")
+	}
+	return job, nil
+}
+
+// Complete removes a job that succeeded.
+func (r *jobRepository) Complete(jobID string) error {
+	if err := queries.DeleteJob(r.db, jobID); err != nil {
+		return queryError(err, "failed to complete job")
+	}
+	return nil
+}
+
+// Retry makes a failed job pending again, to run at runAt.
+func (r *jobRepository) Retry(jobID string, runAt time.Time, lastError string) error {
+	if err := queries.RescheduleJob(r.db, jobID, runAt, lastError); err != nil {
+		return queryError(err, "failed to retry job")
+	}
+	return nil
+}
+
+// Bury moves a failed job to the dead letters, where it is kept without being run.
+func (r *jobRepository) Bury(jobID string, lastError string) error {
+	if err := queries.BuryJob(r.db, jobID, lastError); err != nil {
+		return queryError(err, "failed to bury job")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+)
+
+// LikeEventRepository sends the like activity to the streams of every server sharing
+// the database. Events are sent in the transaction of the change they describe, so
+// they are received if and only if the change is committed.
+type LikeEventRepository interface {
+	Notify(events []models.LikeStreamEvent) error
+}
+
+type likeEventRepository struct {
+	db queries.DBTX
+}
+
+// NewLikeEventRepository creates a new instance of LikeEventRepository notifying
+// events with PostgreSQL NOTIFY, received by pubsub.PostgresTransport.
+func NewLikeEventRepository(db queries.DBTX) LikeEventRepository {
+	return &likeEventRepository{db: db}
+}
+
+// Notify sends events, in order. On SQLite, whose database is not shared, nothing is
+// sent.
+func (r *likeEventRepository) Notify(events []models.LikeStreamEvent) error {
+	if err := queries.NotifyLikeEvents(r.db, events); err != nil {
+		return queryError(err, "failed to notify like events")
+	}
+	return nil
+}
@@ -1,29 +1,36 @@
 package repositories
 
 import (
-	"database/sql"
 	"server/db/queries"
 	e "server/internal/errors"
+	"server/internal/models"
+	"time"
 )
 
 type LikedImagesRepository interface {
 	GetLikedImages(userID string) ([]string, error)
 	AddLikedImage(userID string, image string) error
 	RemoveLikedImage(userID string, imageID string) error
+	GetLikesVersion(userID string) (models.LikesVersion, error)
+	LockLikesVersion(userID string) (models.LikesVersion, error)
+	ApplyLikeOperations(userID string, operations []models.LikeOperation) ([]models.LikeOperationResult, error)
+	RestoreLikedImage(userID string, imageURL string, unlikedSince time.Time) error
+	PurgeUnlikedImages(before time.Time) (int64, error)
 }
 
 type likedImagesRepository struct {
-	db *sql.DB
+	db queries.DBTX
 }
 
-func NewLikedImagesRepository(db *sql.DB) *likedImagesRepository {
+func NewLikedImagesRepository(db queries.DBTX) *likedImagesRepository {
 	return &likedImagesRepository{
 		db: db,
 	}
 }
 
 // AddLikedImage adds an image URL to the list of liked images for a given user.
-// If the user does not have any liked images yet, a new entry is created.
+// A second like of the same image, even under concurrent requests, is reported
+// as ImageAlreadyLiked.
 //
 // Parameters:
 //   - userID: The ID of the user liking the image.
@@ -32,45 +39,82 @@ func NewLikedImagesRepository(db *sql.DB) *likedImagesRepository {
 // Returns:
 //   - error: An error if the operation fails, otherwise nil.
 func (r *likedImagesRepository) AddLikedImage(userID, imageURL string) error {
-	exists, err := queries.GetLikedImage(r.db, userID, imageURL)
+	added, err := queries.AddLikedImage(r.db, userID, imageURL)
 
 	if err != nil {
-		return err
+		return queryError(err, "failed to add liked image")
 	}
 
-	if exists {
-		return e.NewError(e.ValidationErr, e.ImageAlreadyLiked, "image already liked", nil)
-	}
-
-	err = queries.AddLikedImage(r.db, userID, imageURL)
-
-	if err != nil {
-		return e.NewError(e.InternalErr, e.DatabaseError, "failed to add liked image", err)
+	if !added {
+		return e.NewError(e.UserErr, e.ImageAlreadyLiked, "image already liked", nil)
 	}
 
 	return nil
 }
 
 // RemoveLikedImage removes the like for a given image URL by a specific user.
-// If the user has not liked the image, the function does nothing.
+// If the user has not liked the image, it returns ImageNotLiked. The like can
+// be restored with RestoreLikedImage until it is purged.
+//
 // Parameters:
 //   - userID: The ID of the user unliking the image.
 //   - imageURL: The URL of the image to be unliked.
 //
 // Returns:
-//   - error: An error if any issues occur during retrieval
+//   - error: An error if the image is not liked or the operation fails.
 func (r *likedImagesRepository) RemoveLikedImage(userID, imageURL string) error {
-	err := queries.RemoveLikedImage(r.db, userID, imageURL)
+	removed, err := queries.RemoveLikedImage(r.db, userID, imageURL)
 
 	if err != nil {
-		return e.NewError(e.InternalErr, e.DatabaseError, "failed to remove liked image", err)
+		return queryError(err, "failed to remove liked image")
+	}
+
+	if !removed {
+		return e.NewError(e.UserErr, e.ImageNotLiked, "image not liked", nil)
 	}
 
 	return nil
 }
 
+// RestoreLikedImage restores a like removed after unlikedSince, undoing the unlike.
+// If there is no such like, it returns NothingToUndo.
+//
+// Parameters:
+//   - userID: The ID of the user who unliked the image.
+//   - imageURL: The URL of the unliked image.
+//   - unlikedSince: The earliest time the image can have been unliked at.
+//
+// Returns:
+//   - error: An error if there is nothing to undo or the operation fails.
+func (r *likedImagesRepository) RestoreLikedImage(userID, imageURL string, unlikedSince time.Time) error {
+	restored, err := queries.RestoreLikedImage(r.db, userID, imageURL, unlikedSince)
+
+	if err != nil {
+		return queryError(err, "failed to restore liked image")
+	}
+
+	if !restored {
+		return e.NewError(e.UserErr, e.NothingToUndo, "no unlike to undo", nil)
+	}
+
+	return nil
+}
+
+// PurgeUnlikedImages permanently deletes the likes of every user removed before the
+// given time, which can no longer be restored, and returns how many there were.
+func (r *likedImagesRepository) PurgeUnlikedImages(before time.Time) (int64, error) {
+	purged, err := queries.PurgeDeletedLikedImages(r.db, before)
+
+	if err != nil {
+		return 0, queryError(err, "failed to purge unliked images")
+	}
+
+	return purged, nil
+}
+
 // GetLikedImages retrieves a list of image URLs liked by a specific user.
 // It returns a slice of image URLs and an error if any occurred.
+// Outside of a transaction, it reads from a replica if there is one.
 //
 // Parameters:
 //   - userID: The ID of the user whose liked images are to be retrieved.
@@ -79,11 +123,129 @@ func (r *likedImagesRepository) RemoveLikedImage(userID, imageURL string) error
 //   - []string: A slice of image URLs liked by the user.
 //   - error: An error if any issues occur during retrieval.
 func (r *likedImagesRepository) GetLikedImages(userID string) ([]string, error) {
-	imgs, err := queries.GetLikedImages(r.db, userID)
+	imgs, err := queries.GetLikedImages(queries.Reader(r.db), userID)
 
 	if err != nil {
-		return nil, e.NewError(e.InternalErr, e.DatabaseError, "failed to get liked images", err)
+		return nil, queryError(err, "failed to get liked images")
 	}
 
 	return imgs, nil
 }
+
+// GetLikesVersion retrieves the version of the list of images liked by a user. It is
+// always read from the primary, as it is sent as the ETag of the list.
+//
+// Parameters:
+//   - userID: The ID of the user whose likes version is to be retrieved.
+//
+// Returns:
+//   - models.LikesVersion: The version, zero if the user never liked an image.
+//   - error: An error if any issues occur during retrieval.
+func (r *likedImagesRepository) GetLikesVersion(userID string) (models.LikesVersion, error) {
+	version, err := queries.GetLikesVersion(r.db, userID)
+
+	if err != nil {
+		return models.LikesVersion{}, queryError(err, "failed to get likes version")
+	}
+
+	return version, nil
+}
+
+// LockLikesVersion retrieves the version of the list of images liked by a user and
+// locks it until the end of the UnitOfWork it runs in, so a change checked against
+// the version cannot race with another change of the likes of the user.
+//
+// Parameters:
+//   - userID: The ID of the user whose likes version is to be locked.
+//
+// Returns:
+//   - models.LikesVersion: The version, zero if the user never liked an image.
+//   - error: An error if any issues occur during retrieval.
+func (r *likedImagesRepository) LockLikesVersion(userID string) (models.LikesVersion, error) {
+	version, err := queries.LockLikesVersion(r.db, userID)
+
+	if err != nil {
+		return models.LikesVersion{}, queryError(err, "failed to lock likes version")
+	}
+
+	return version, nil
+}
+
+// ApplyLikeOperations likes and unlikes images. Liking an image twice or unliking an
+// image that is not liked fails that operation only. It should run in a UnitOfWork,
+// so a database error rolls back every operation. Operations that only like images,
+// such as imports, are applied in bulk.
+//
+// Parameters:
+//   - userID: The ID of the user liking and unliking the images.
+//   - operations: The operations, applied in order.
+//
+// Returns:
+//   - []models.LikeOperationResult: The result of each operation, in the same order.
+//   - error: An error if an operation could not be applied.
+func (r *likedImagesRepository) ApplyLikeOperations(userID string, operations []models.LikeOperation) ([]models.LikeOperationResult, error) {
+	results := make([]models.LikeOperationResult, len(operations))
+
+	if onlyLikes(operations) {
+		imageURLs := make([]string, len(operations))
+		for i, op := range operations {
+			imageURLs[i] = op.ImageURL
+		}
+
+		added, err := queries.AddLikedImages(r.db, userID, imageURLs)
+		if err != nil {
+			return nil, queryError(err, "failed to apply like operations")
+		}
+		for i, op := range operations {
+			results[i] = likeOperationResult(op, added[i])
+		}
+		return results, nil
+	}
+
+	for i, op := range operations {
+		var (
+			applied bool
+			err     error
+		)
+		switch op.Action {
+		case models.LikeAction:
+			applied, err = queries.AddLikedImage(r.db, userID, op.ImageURL)
+		case models.UnlikeAction:
+			applied, err = queries.RemoveLikedImage(r.db, userID, op.ImageURL)
+		}
+		if err != nil {
+			return nil, queryError(err, "failed to apply like operations")
+		}
+
+		results[i] = likeOperationResult(op, applied)
+	}
+
+	return results, nil
+}
+
+// onlyLikes reports whether every operation likes an image.
+func onlyLikes(operations []models.LikeOperation) bool {
+	for _, op := range operations {
+		if op.Action != models.LikeAction {
+			return false
+		}
+	}
+	return true
+}
+
+// likeOperationResult returns the result of an operation, failed with ImageAlreadyLiked
+// or ImageNotLiked if it was not applied.
+func likeOperationResult(op models.LikeOperation, applied bool) models.LikeOperationResult {
+	result := models.LikeOperationResult{Action: op.Action, ImageURL: op.ImageURL, Success: applied}
+	if applied {
+		return result
+	}
+
+	switch op.Action {
+	case models.LikeAction:
+		result.Code, result.Detail = e.ImageAlreadyLiked, "image already liked"
+	case models.UnlikeAction:
+		result.Code, result.Detail = e.ImageNotLiked, "image not liked"
+	}
+	return result
+}
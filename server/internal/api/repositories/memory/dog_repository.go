@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"errors"
+	"math/rand/v2"
+	"server/internal/api/repositories"
+	"slices"
+)
+
+type dogRepository struct {
+	pictures []string
+}
+
+// NewDogRepository creates a new instance of DogRepository picking its random pictures
+// among pictures, such as DogPictures, instead of calling the Dog API.
+func NewDogRepository(pictures []string) repositories.DogRepository {
+	return &dogRepository{pictures: slices.Clone(pictures)}
+}
+
+// GetRandomPicture returns one of the pictures of the repository at random.
+func (r *dogRepository) GetRandomPicture() (string, error) {
+	if len(r.pictures) == 0 {
+		return "", errors.New("failed to fetch random dog picture")
+	}
+	return r.pictures[rand.IntN(len(r.pictures))], nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import "server/internal/models"
+
+// Demo credentials of the user created by Seed.
+const (
+	DemoUserEmail    = "demo@example.com"
+	DemoUserPassword = "Demo-password-1"
+)
+
+// DogPictures are pictures of the Dog API, served by the in-memory DogRepository.
+var DogPictures = []string{
+	"https://images.dog.ceo/breeds/hound-afghan/n02088094_1003.jpg",
+	"https://images.dog.ceo/breeds/retriever-golden/n02099601_3004.jpg",
+	"https://images.dog.ceo/breeds/husky/n02110185_1469.jpg",
+	"https://images.dog.ceo/breeds/pug/n02110958_15626.jpg",
+	"https://images.dog.ceo/breeds/labrador/n02099712_4323.jpg",
+	"https://images.dog.ceo/breeds/shiba/shiba-8.jpg",
+	"https://images.dog.ceo/breeds/corgi-cardigan/n02113186_1030.jpg",
+	"https://images.dog.ceo/breeds/dalmatian/cooper2.jpg",
+}
+
+// Seed creates the demo user, with DemoUserPassword hashed as passwordHash, and likes
+// the first DogPictures for them.
+//
+// Parameters:
+//   - store: The store to seed.
+//   - passwordHash: The hash of DemoUserPassword.
+//
+// Returns:
+//   - string: The ID of the demo user.
+//   - error: An error if the store was already seeded.
+func Seed(store *Store, passwordHash string) (string, error) {
+	user, err := NewUserRepository(store).Create(&models.User{Email: DemoUserEmail, PasswordHash: passwordHash})
+	if err != nil {
+		return "", err
+	}
+
+	likedImages := NewLikedImagesRepository(store)
+	for _, picture := range DogPictures[:3] {
+		if err := likedImages.AddLikedImage(user.ID, picture); err != nil {
+			return "", err
+		}
+	}
+	return user.ID, nil
+}
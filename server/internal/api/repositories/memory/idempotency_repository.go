@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"maps"
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"slices"
+	"time"
+)
+
+type idempotencyKey struct {
+	scope string
+	key   string
+}
+
+type idempotencyRepository struct {
+	store *Store
+}
+
+// NewIdempotencyRepository creates a new instance of IdempotencyRepository storing keys in store.
+func NewIdempotencyRepository(store *Store) repositories.IdempotencyRepository {
+	return &idempotencyRepository{store: store}
+}
+
+// Reserve stores record as in progress unless its key is already used. Keys created
+// before expiredBefore are forgotten first.
+func (r *idempotencyRepository) Reserve(record *models.IdempotencyRecord, expiredBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	defer r.store.lock(false)()
+
+	key := idempotencyKey{record.Scope, record.Key}
+	if existing, ok := r.store.idempotency[key]; ok && !existing.CreatedAt.Before(expiredBefore) {
+		existing.Body = slices.Clone(existing.Body)
+		return &existing, false, nil
+	}
+
+	r.store.idempotency[key] = models.IdempotencyRecord{
+		Scope:       record.Scope,
+		Key:         record.Key,
+		Fingerprint: record.Fingerprint,
+		CreatedAt:   r.store.now(),
+	}
+	return nil, true, nil
+}
+
+// Complete stores the response of a reserved key.
+func (r *idempotencyRepository) Complete(record *models.IdempotencyRecord) error {
+	defer r.store.lock(false)()
+
+	key := idempotencyKey{record.Scope, record.Key}
+	stored, ok := r.store.idempotency[key]
+	if !ok {
+		return nil
+	}
+	stored.StatusCode = record.StatusCode
+	stored.ContentType = record.ContentType
+	stored.Body = slices.Clone(record.Body)
+	stored.Headers = maps.Clone(record.Headers)
+	r.store.idempotency[key] = stored
+	return nil
+}
+
+// Release deletes a reserved key so the request can be retried.
+func (r *idempotencyRepository) Release(scope, key string) error {
+	defer r.store.lock(false)()
+
+	delete(r.store.idempotency, idempotencyKey{scope, key})
+	return nil
+}
+
+// PurgeExpired deletes the keys created before the given time and returns how many
+// there were.
+func (r *idempotencyRepository) PurgeExpired(before time.Time) (int64, error) {
+	defer r.store.lock(false)()
+
+	var purged int64
+	for key, record := range r.store.idempotency {
+		if record.CreatedAt.Before(before) {
+			delete(r.store.idempotency, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"errors"
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type identityKey struct {
+	provider string
+	subject  string
+}
+
+type identityRepository struct {
+	store *Store
+}
+
+// NewIdentityRepository creates a new instance of IdentityRepository storing identities in store.
+func NewIdentityRepository(store *Store) repositories.IdentityRepository {
+	return &identityRepository{store: store}
+}
+
+// FindIdentity retrieves the identity a provider issued for a subject, or nil if there is none.
+func (r *identityRepository) FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	defer r.store.lock(false)()
+
+	identity, ok := r.store.identities[identityKey{provider, subject}]
+	if !ok {
+		return nil, nil
+	}
+	return &identity, nil
+}
+
+// CreateIdentity links an external identity to a user and sets its ID.
+func (r *identityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	defer r.store.lock(false)()
+
+	key := identityKey{identity.Provider, identity.Subject}
+	if _, ok := r.store.identities[key]; ok {
+		return e.NewError(e.InternalErr, e.DatabaseError, "failed to create user identity", errors.New("identity already exists"))
+	}
+	if _, ok := r.store.users[identity.UserID]; !ok {
+		return e.NewError(e.UserErr, e.UserNotFound, "user not found", errUnknownUser)
+	}
+
+	stored := *identity
+	stored.ID = uuid.NewString()
+	stored.CreatedAt = r.store.now()
+	r.store.identities[key] = stored
+	identity.ID = stored.ID
+	return nil
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// storedJob is a job with the time it was claimed at, if it is running.
+type storedJob struct {
+	models.Job
+	lockedAt time.Time
+}
+
+type jobRepository struct {
+	store *Store
+	inTx  bool
+}
+
+// NewJobRepository creates a new instance of JobRepository keeping jobs in store,
+// which makes an in-memory queue for tests and for running without a database.
+func NewJobRepository(store *Store) repositories.JobRepository {
+	return &jobRepository{store: store}
+}
+
+// Enqueue adds a pending job and returns its ID, or "" if a job with the same unique
+// key is queued.
+func (r *jobRepository) Enqueue(job *models.Job) (string, error) {
+	defer r.store.lock(r.inTx)()
+
+	if job.UniqueKey != "" {
+		for _, queued := range r.store.jobs {
+			if queued.UniqueKey == job.UniqueKey {
+				return "", nil
+			}
+		}
+	}
+
+	stored := models.Job{
+		ID:          uuid.NewString(),
+		Type:        job.Type,
+		Payload:     slices.Clone(job.Payload),
+		Status:      models.JobPending,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		UniqueKey:   job.UniqueKey,
+		CreatedAt:   r.store.now(),
+	}
+	r.store.jobs[stored.ID] = storedJob{Job: stored}
+	return stored.ID, nil
+}
+
+// Claim marks the due job with the earliest run time as running and returns it, or
+// nil if no job is due. Jobs running since before staleBefore are claimed again.
+func (r *jobRepository) Claim(staleBefore time.Time) (*models.Job, error) {
+	defer r.store.lock(r.inTx)()
+
+	now := r.store.now()
+	var next *storedJob
+	for _, job := range r.store.jobs {
+		due := job.Status == models.JobPending && !job.RunAt.After(now)
+		stale := job.Status == models.JobRunning && job.lockedAt.Before(staleBefore)
+		if (due || stale) && (next == nil || job.RunAt.Before(next.RunAt)) {
+			next = &job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Status = models.JobRunning
+	next.Attempts++
+	next.lockedAt = now
+	r.store.jobs[next.ID] = *next
+	return cloneJob(next.Job), nil
+}
+
+// Find retrieves a queued or dead job by its ID, or nil if there is none.
+func (r *jobRepository) Find(jobID string) (*models.Job, error) {
+	defer r.store.lock(r.inTx)()
+
+	job, ok := r.store.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return cloneJob(job.Job), nil
+}
+
+// Complete removes a job that succeeded.
+func (r *jobRepository) Complete(jobID string) error {
+	defer r.store.lock(r.inTx)()
+
+	delete(r.store.jobs, jobID)
+	return nil
+}
+
+// Retry makes a failed job pending again, to run at runAt.
+func (r *jobRepository) Retry(jobID string, runAt time.Time, lastError string) error {
+	defer r.store.lock(r.inTx)()
+
+	if job, ok := r.store.jobs[jobID]; ok {
+		job.Status, job.RunAt, job.LastError, job.lockedAt = models.JobPending, runAt, lastError, time.Time{}
+		r.store.jobs[jobID] = job
+	}
+	return nil
+}
+
+// Bury moves a failed job to the dead letters, where it is kept without being run.
+func (r *jobRepository) Bury(jobID string, lastError string) error {
+	defer r.store.lock(r.inTx)()
+
+	if job, ok := r.store.jobs[jobID]; ok {
+		job.Status, job.LastError, job.lockedAt = models.JobDead, lastError, time.Time{}
+		r.store.jobs[jobID] = job
+	}
+	return nil
+}
+
+func cloneJob(job models.Job) *models.Job {
+	job.Payload = slices.Clone(job.Payload)
+	return &job
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"slices"
+	"time"
+)
+
+type likedImagesRepository struct {
+	store *Store
+	inTx  bool
+}
+
+// NewLikedImagesRepository creates a new instance of LikedImagesRepository storing
+// likes in store.
+func NewLikedImagesRepository(store *Store) repositories.LikedImagesRepository {
+	return &likedImagesRepository{store: store}
+}
+
+// AddLikedImage likes an image, failing with ImageAlreadyLiked if it is already liked.
+// Like the foreign key of liked_images, the user must exist.
+func (r *likedImagesRepository) AddLikedImage(userID, imageURL string) error {
+	defer r.store.lock(r.inTx)()
+
+	added, err := r.addLikedImage(userID, imageURL)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return e.NewError(e.UserErr, e.ImageAlreadyLiked, "image already liked", nil)
+	}
+	return nil
+}
+
+// RemoveLikedImage removes a like, failing with ImageNotLiked if the image is not liked.
+// The like can be restored until it is purged.
+func (r *likedImagesRepository) RemoveLikedImage(userID, imageURL string) error {
+	defer r.store.lock(r.inTx)()
+
+	if !r.removeLikedImage(userID, imageURL) {
+		return e.NewError(e.UserErr, e.ImageNotLiked, "image not liked", nil)
+	}
+	return nil
+}
+
+// RestoreLikedImage restores a like removed after unlikedSince, failing with
+// NothingToUndo if there is none.
+func (r *likedImagesRepository) RestoreLikedImage(userID, imageURL string, unlikedSince time.Time) error {
+	defer r.store.lock(r.inTx)()
+
+	unlikedAt, ok := r.store.unlikedImages[userID][imageURL]
+	if !ok || !unlikedAt.After(unlikedSince) {
+		return e.NewError(e.UserErr, e.NothingToUndo, "no unlike to undo", nil)
+	}
+	delete(r.store.unlikedImages[userID], imageURL)
+	r.store.likedImages[userID] = append(r.store.likedImages[userID], imageURL)
+	r.store.bumpLikesVersion(userID)
+	return nil
+}
+
+// PurgeUnlikedImages forgets the likes removed before the given time, which can no
+// longer be restored, and returns how many there were.
+func (r *likedImagesRepository) PurgeUnlikedImages(before time.Time) (int64, error) {
+	defer r.store.lock(r.inTx)()
+
+	var purged int64
+	for userID, images := range r.store.unlikedImages {
+		for imageURL, unlikedAt := range images {
+			if unlikedAt.Before(before) {
+				delete(images, imageURL)
+				purged++
+			}
+		}
+		if len(images) == 0 {
+			delete(r.store.unlikedImages, userID)
+		}
+	}
+	return purged, nil
+}
+
+// GetLikedImages retrieves the images liked by a user, in the order they were liked.
+func (r *likedImagesRepository) GetLikedImages(userID string) ([]string, error) {
+	defer r.store.lock(r.inTx)()
+
+	return slices.Clone(r.store.likedImages[userID]), nil
+}
+
+// GetLikesVersion retrieves the version of the list of images liked by a user.
+func (r *likedImagesRepository) GetLikesVersion(userID string) (models.LikesVersion, error) {
+	defer r.store.lock(r.inTx)()
+
+	return r.store.likesVersions[userID], nil
+}
+
+// LockLikesVersion retrieves the version of the list of images liked by a user. A
+// UnitOfWork holds the store until it ends, which locks the version too.
+func (r *likedImagesRepository) LockLikesVersion(userID string) (models.LikesVersion, error) {
+	return r.GetLikesVersion(userID)
+}
+
+// ApplyLikeOperations likes and unlikes images, failing single operations like the
+// PostgreSQL repository. It should run in a UnitOfWork to be atomic.
+func (r *likedImagesRepository) ApplyLikeOperations(userID string, operations []models.LikeOperation) ([]models.LikeOperationResult, error) {
+	defer r.store.lock(r.inTx)()
+
+	results := make([]models.LikeOperationResult, len(operations))
+	for i, op := range operations {
+		results[i] = models.LikeOperationResult{Action: op.Action, ImageURL: op.ImageURL}
+
+		switch op.Action {
+		case models.LikeAction:
+			added, err := r.addLikedImage(userID, op.ImageURL)
+			if err != nil {
+				return nil, err
+			}
+			if !added {
+				results[i].Code, results[i].Detail = e.ImageAlreadyLiked, "image already liked"
+				continue
+			}
+			results[i].Success = true
+		case models.UnlikeAction:
+			if !r.removeLikedImage(userID, op.ImageURL) {
+				results[i].Code, results[i].Detail = e.ImageNotLiked, "image not liked"
+				continue
+			}
+			results[i].Success = true
+		}
+	}
+	return results, nil
+}
+
+// addLikedImage likes an image, reporting false if it is already liked.
+func (r *likedImagesRepository) addLikedImage(userID, imageURL string) (bool, error) {
+	if _, ok := r.store.users[userID]; !ok {
+		return false, e.NewError(e.UserErr, e.UserNotFound, "user not found", errUnknownUser)
+	}
+	if slices.Contains(r.store.likedImages[userID], imageURL) {
+		return false, nil
+	}
+	r.store.likedImages[userID] = append(r.store.likedImages[userID], imageURL)
+	delete(r.store.unlikedImages[userID], imageURL)
+	r.store.bumpLikesVersion(userID)
+	return true, nil
+}
+
+// removeLikedImage removes a like, remembering when, and reports false if the image
+// is not liked.
+func (r *likedImagesRepository) removeLikedImage(userID, imageURL string) bool {
+	images := r.store.likedImages[userID]
+	i := slices.Index(images, imageURL)
+	if i < 0 {
+		return false
+	}
+	r.store.likedImages[userID] = slices.Delete(slices.Clone(images), i, i+1)
+	if r.store.unlikedImages[userID] == nil {
+		r.store.unlikedImages[userID] = make(map[string]time.Time)
+	}
+	r.store.unlikedImages[userID][imageURL] = r.store.now()
+	r.store.bumpLikesVersion(userID)
+	return true
+}
+
+func (s *Store) bumpLikesVersion(userID string) {
+	version := s.likesVersions[userID]
+	version.Version++
+	version.UpdatedAt = s.now()
+	s.likesVersions[userID] = version
+}
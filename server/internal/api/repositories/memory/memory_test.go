@@ -0,0 +1,55 @@
+package memory_test
+
+import (
+	"server/internal/api/repositories/memory"
+	"server/internal/api/repositories/repotest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		store := memory.NewStore()
+		return repotest.Backend{
+			Users:       memory.NewUserRepository(store),
+			LikedImages: memory.NewLikedImagesRepository(store),
+			Sessions:    memory.NewSessionRepository(store),
+			UnitOfWork:  memory.NewUnitOfWork(store),
+			Jobs:        memory.NewJobRepository(store),
+			Webhooks:    memory.NewWebhookRepository(store),
+			Idempotency: memory.NewIdempotencyRepository(store),
+			MFA:         memory.NewMFARepository(store),
+			Identities:  memory.NewIdentityRepository(store),
+		}
+	})
+}
+
+func TestDogRepository(t *testing.T) {
+	picture, err := memory.NewDogRepository(memory.DogPictures).GetRandomPicture()
+	require.NoError(t, err)
+	assert.Contains(t, memory.DogPictures, picture)
+
+	_, err = memory.NewDogRepository(nil).GetRandomPicture()
+	assert.Error(t, err)
+}
+
+func TestSeed(t *testing.T) {
+	store := memory.NewStore()
+
+	userID, err := memory.Seed(store, "hash")
+	require.NoError(t, err)
+
+	user, err := memory.NewUserRepository(store).FindByEmail(memory.DemoUserEmail)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, userID, user.ID)
+
+	images, err := memory.NewLikedImagesRepository(store).GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Len(t, images, 3)
+
+	_, err = memory.Seed(store, "hash")
+	assert.Error(t, err)
+}
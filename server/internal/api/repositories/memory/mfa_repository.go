@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"time"
+)
+
+type mfaRepository struct {
+	store *Store
+}
+
+// NewMFARepository creates a new instance of MFARepository storing enrollments in store.
+func NewMFARepository(store *Store) repositories.MFARepository {
+	return &mfaRepository{store: store}
+}
+
+// GetMFA retrieves the TOTP enrollment of a user, or nil if the user never enrolled.
+func (r *mfaRepository) GetMFA(userID string) (*models.UserMFA, error) {
+	defer r.store.lock(false)()
+
+	mfa, ok := r.store.mfa[userID]
+	if !ok {
+		return nil, nil
+	}
+	if mfa.LockedUntil != nil {
+		lockedUntil := *mfa.LockedUntil
+		mfa.LockedUntil = &lockedUntil
+	}
+	return &mfa, nil
+}
+
+// SavePendingMFA stores a TOTP secret awaiting confirmation. It does not overwrite an
+// enabled enrollment.
+func (r *mfaRepository) SavePendingMFA(userID, secret string) error {
+	defer r.store.lock(false)()
+
+	if r.store.mfa[userID].Enabled {
+		return nil
+	}
+	r.store.mfa[userID] = models.UserMFA{UserID: userID, Secret: secret}
+	return nil
+}
+
+// EnableMFA confirms the enrollment of a user and replaces their recovery codes.
+func (r *mfaRepository) EnableMFA(userID string, counter int64, recoveryCodeHashes []string) error {
+	defer r.store.lock(false)()
+
+	if mfa, ok := r.store.mfa[userID]; ok {
+		mfa.Enabled = true
+		mfa.LastUsedCounter = counter
+		mfa.FailedAttempts = 0
+		r.store.mfa[userID] = mfa
+	}
+
+	codes := make(map[string]bool, len(recoveryCodeHashes))
+	for _, hash := range recoveryCodeHashes {
+		codes[hash] = false
+	}
+	r.store.recoveryCodes[userID] = codes
+	return nil
+}
+
+// DisableMFA removes the enrollment and recovery codes of a user.
+func (r *mfaRepository) DisableMFA(userID string) error {
+	defer r.store.lock(false)()
+
+	delete(r.store.mfa, userID)
+	delete(r.store.recoveryCodes, userID)
+	return nil
+}
+
+// AdvanceCounter records a successful TOTP verification. It reports false when a code
+// of the same or a later time step was already used.
+func (r *mfaRepository) AdvanceCounter(userID string, counter int64) (bool, error) {
+	defer r.store.lock(false)()
+
+	mfa, ok := r.store.mfa[userID]
+	if !ok || mfa.LastUsedCounter >= counter {
+		return false, nil
+	}
+	mfa.LastUsedCounter = counter
+	mfa.FailedAttempts = 0
+	mfa.LockedUntil = nil
+	r.store.mfa[userID] = mfa
+	return true, nil
+}
+
+// UseRecoveryCode marks a recovery code as used. It reports false if the code does not
+// exist or was already used.
+func (r *mfaRepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	defer r.store.lock(false)()
+
+	used, ok := r.store.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.store.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+// RecordFailure increments the failed attempts of a user and locks the second factor
+// until lockUntil once maxAttempts is reached.
+func (r *mfaRepository) RecordFailure(userID string, maxAttempts int, lockUntil time.Time) error {
+	defer r.store.lock(false)()
+
+	mfa, ok := r.store.mfa[userID]
+	if !ok {
+		return nil
+	}
+	if mfa.FailedAttempts+1 >= maxAttempts {
+		mfa.FailedAttempts = 0
+		mfa.LockedUntil = &lockUntil
+	} else {
+		mfa.FailedAttempts++
+	}
+	r.store.mfa[userID] = mfa
+	return nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+type outboxRepository struct {
+	store *Store
+	inTx  bool
+}
+
+// NewOutboxRepository creates a new instance of OutboxRepository keeping events in store.
+func NewOutboxRepository(store *Store) repositories.OutboxRepository {
+	return &outboxRepository{store: store}
+}
+
+// Add stores events in the outbox, in order. Like the foreign key of outbox_events,
+// their users must exist.
+func (r *outboxRepository) Add(events ...models.OutboxEvent) error {
+	defer r.store.lock(r.inTx)()
+
+	for _, event := range events {
+		if _, ok := r.store.users[event.UserID]; !ok {
+			return e.NewError(e.UserErr, e.UserNotFound, "user not found", errUnknownUser)
+		}
+	}
+	for _, event := range events {
+		event.ID = uuid.NewString()
+		event.Data = slices.Clone(event.Data)
+		event.CreatedAt = r.store.now()
+		r.store.outbox = append(r.store.outbox, event)
+	}
+	return nil
+}
+
+// Claim retrieves up to limit events, oldest first. Units of work hold the store, so
+// the events are not claimed by another one until the claiming unit of work ends.
+func (r *outboxRepository) Claim(limit int) ([]models.OutboxEvent, error) {
+	defer r.store.lock(r.inTx)()
+
+	events := slices.Clone(r.store.outbox[:min(limit, len(r.store.outbox))])
+	for i := range events {
+		events[i].Data = slices.Clone(events[i].Data)
+	}
+	return events, nil
+}
+
+// Delete removes relayed events from the outbox.
+func (r *outboxRepository) Delete(eventIDs []string) error {
+	defer r.store.lock(r.inTx)()
+
+	r.store.outbox = slices.DeleteFunc(slices.Clone(r.store.outbox), func(event models.OutboxEvent) bool {
+		return slices.Contains(eventIDs, event.ID)
+	})
+	return nil
+}
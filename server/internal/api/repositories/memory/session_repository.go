@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type sessionRepository struct {
+	store *Store
+}
+
+// NewSessionRepository creates a new instance of SessionRepository storing sessions in store.
+func NewSessionRepository(store *Store) repositories.SessionRepository {
+	return &sessionRepository{store: store}
+}
+
+// Create stores a new session and returns its ID.
+func (r *sessionRepository) Create(session *models.Session) (string, error) {
+	defer r.store.lock(false)()
+
+	now := r.store.now()
+	stored := models.Session{
+		ID:         uuid.NewString(),
+		UserID:     session.UserID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	r.store.sessions[stored.ID] = stored
+	return stored.ID, nil
+}
+
+// Find retrieves a session by its ID, revoked or not, or nil if there is none.
+func (r *sessionRepository) Find(sessionID string) (*models.Session, error) {
+	defer r.store.lock(false)()
+
+	session, ok := r.store.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// ListActive retrieves the sessions of a user that are not revoked and were seen
+// after since, most recently seen first.
+func (r *sessionRepository) ListActive(userID string, since time.Time) ([]models.Session, error) {
+	defer r.store.lock(false)()
+
+	var sessions []models.Session
+	for _, session := range r.store.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.LastSeenAt.After(since) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b models.Session) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// Touch updates the last seen time of an active session.
+func (r *sessionRepository) Touch(sessionID string) error {
+	defer r.store.lock(false)()
+
+	session, ok := r.store.sessions[sessionID]
+	if ok && session.RevokedAt == nil {
+		session.LastSeenAt = r.store.now()
+		r.store.sessions[sessionID] = session
+	}
+	return nil
+}
+
+// Revoke revokes an active session of a user. It reports false when the user has no
+// such active session.
+func (r *sessionRepository) Revoke(userID, sessionID string) (bool, error) {
+	defer r.store.lock(false)()
+
+	session, ok := r.store.sessions[sessionID]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return false, nil
+	}
+	now := r.store.now()
+	session.RevokedAt = &now
+	r.store.sessions[sessionID] = session
+	return true, nil
+}
+
+// Purge deletes the sessions revoked or last seen before the given time and returns
+// how many there were.
+func (r *sessionRepository) Purge(before time.Time) (int64, error) {
+	defer r.store.lock(false)()
+
+	var purged int64
+	for id, session := range r.store.sessions {
+		if (session.RevokedAt != nil && session.RevokedAt.Before(before)) || session.LastSeenAt.Before(before) {
+			delete(r.store.sessions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
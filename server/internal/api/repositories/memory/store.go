@@ -0,0 +1,180 @@
+// Package memory implements the repositories in memory, for tests and for running the
+// server without a database. Every repository of a Store sees the same data and
+// behaves like its PostgreSQL counterpart, including the errors it returns.
+package memory
+
+import (
+	"errors"
+	"maps"
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"slices"
+	"sync"
+	"time"
+)
+
+// errUnknownUser is the cause of the UserNotFound errors returned where PostgreSQL
+// would report a foreign key violation on the user ID.
+var errUnknownUser = errors.New("user does not exist")
+
+// Store holds the data of the in-memory repositories. The zero value is not usable,
+// use NewStore.
+type Store struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	users         map[string]models.User
+	likedImages   map[string][]string
+	unlikedImages map[string]map[string]time.Time
+	likesVersions map[string]models.LikesVersion
+	sessions      map[string]models.Session
+	mfa           map[string]models.UserMFA
+	recoveryCodes map[string]map[string]bool
+	identities    map[identityKey]models.UserIdentity
+	idempotency   map[idempotencyKey]models.IdempotencyRecord
+	jobs          map[string]storedJob
+
+	outbox            []models.OutboxEvent
+	webhooks          map[string]models.Webhook
+	webhookDeliveries map[string]models.WebhookDelivery
+}
+
+// NewStore creates an empty Store.
+func NewStore() *Store {
+	return &Store{
+		now:           time.Now,
+		users:         make(map[string]models.User),
+		likedImages:   make(map[string][]string),
+		unlikedImages: make(map[string]map[string]time.Time),
+		likesVersions: make(map[string]models.LikesVersion),
+		sessions:      make(map[string]models.Session),
+		mfa:           make(map[string]models.UserMFA),
+		recoveryCodes: make(map[string]map[string]bool),
+		identities:    make(map[identityKey]models.UserIdentity),
+		idempotency:   make(map[idempotencyKey]models.IdempotencyRecord),
+		jobs:          make(map[string]storedJob),
+
+		webhooks:          make(map[string]models.Webhook),
+		webhookDeliveries: make(map[string]models.WebhookDelivery),
+	}
+}
+
+// lock locks the store for a repository call, unless the repository belongs to a unit
+// of work, which holds the lock for its whole duration.
+func (s *Store) lock(inTx bool) func() {
+	if inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// unitOfWork runs its work with the store locked, so units of work are serializable,
+// and restores the data they can change if the work fails.
+type unitOfWork struct {
+	store *Store
+}
+
+// NewUnitOfWork creates a new instance of UnitOfWork running on store.
+func NewUnitOfWork(store *Store) repositories.UnitOfWork {
+	return &unitOfWork{store: store}
+}
+
+func (u *unitOfWork) Do(fn func(tx repositories.Tx) error) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	snapshot := u.store.snapshot()
+	if err := fn(&tx{store: u.store}); err != nil {
+		u.store.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+type tx struct {
+	store *Store
+}
+
+func (t *tx) Users() repositories.UserRepository {
+	return &userRepository{store: t.store, inTx: true}
+}
+
+func (t *tx) LikedImages() repositories.LikedImagesRepository {
+	return &likedImagesRepository{store: t.store, inTx: true}
+}
+
+func (t *tx) Outbox() repositories.OutboxRepository {
+	return &outboxRepository{store: t.store, inTx: true}
+}
+
+func (t *tx) Webhooks() repositories.WebhookRepository {
+	return &webhookRepository{store: t.store, inTx: true}
+}
+
+func (t *tx) Jobs() repositories.JobRepository {
+	return &jobRepository{store: t.store, inTx: true}
+}
+
+func (t *tx) LikeEvents() repositories.LikeEventRepository {
+	return likeEventRepository{}
+}
+
+// likeEventRepository sends no events, as the store is never shared by several
+// servers; the broker of the server delivers them.
+type likeEventRepository struct{}
+
+func (likeEventRepository) Notify([]models.LikeStreamEvent) error {
+	return nil
+}
+
+// snapshot is a copy of the data a unit of work can change.
+type snapshot struct {
+	users         map[string]models.User
+	likedImages   map[string][]string
+	unlikedImages map[string]map[string]time.Time
+	likesVersions map[string]models.LikesVersion
+	jobs          map[string]storedJob
+
+	outbox            []models.OutboxEvent
+	webhooks          map[string]models.Webhook
+	webhookDeliveries map[string]models.WebhookDelivery
+}
+
+func (s *Store) snapshot() snapshot {
+	snap := snapshot{
+		users:         make(map[string]models.User, len(s.users)),
+		likedImages:   make(map[string][]string, len(s.likedImages)),
+		unlikedImages: make(map[string]map[string]time.Time, len(s.unlikedImages)),
+		likesVersions: make(map[string]models.LikesVersion, len(s.likesVersions)),
+		jobs:          maps.Clone(s.jobs),
+
+		outbox:            slices.Clone(s.outbox),
+		webhooks:          maps.Clone(s.webhooks),
+		webhookDeliveries: maps.Clone(s.webhookDeliveries),
+	}
+	for id, user := range s.users {
+		snap.users[id] = user
+	}
+	for userID, images := range s.likedImages {
+		snap.likedImages[userID] = append([]string(nil), images...)
+	}
+	for userID, images := range s.unlikedImages {
+		snap.unlikedImages[userID] = maps.Clone(images)
+	}
+	for userID, version := range s.likesVersions {
+		snap.likesVersions[userID] = version
+	}
+	return snap
+}
+
+func (s *Store) restore(snap snapshot) {
+	s.users = snap.users
+	s.likedImages = snap.likedImages
+	s.unlikedImages = snap.unlikedImages
+	s.likesVersions = snap.likesVersions
+	s.jobs = snap.jobs
+	s.outbox = snap.outbox
+	s.webhooks = snap.webhooks
+	s.webhookDeliveries = snap.webhookDeliveries
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type userRepository struct {
+	store *Store
+	inTx  bool
+}
+
+// NewUserRepository creates a new instance of UserRepository storing users in store.
+func NewUserRepository(store *Store) repositories.UserRepository {
+	return &userRepository{store: store}
+}
+
+// Create stores a new user. Emails are unique, like the users.email column.
+func (r *userRepository) Create(user *models.User) (models.CreateUserResponse, error) {
+	defer r.store.lock(r.inTx)()
+
+	for _, existing := range r.store.users {
+		if existing.Email == user.Email {
+			return models.CreateUserResponse{}, e.NewError(e.UserErr, e.EmailAlreadyExists, "email already exists", nil)
+		}
+	}
+
+	now := r.store.now()
+	stored := models.User{
+		ID:           uuid.NewString(),
+		Email:        user.Email,
+		PasswordHash: user.PasswordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	r.store.users[stored.ID] = stored
+
+	return models.CreateUserResponse{
+		ID:    stored.ID,
+		Email: stored.Email,
+	}, nil
+}
+
+// FindByEmail retrieves a user by their email address, or nil if there is none.
+func (r *userRepository) FindByEmail(email string) (*models.User, error) {
+	defer r.store.lock(r.inTx)()
+
+	for _, user := range r.store.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindByID retrieves a user by their ID, or nil if there is none.
+func (r *userRepository) FindByID(id string) (*models.User, error) {
+	defer r.store.lock(r.inTx)()
+
+	user, ok := r.store.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// UpdatePasswordHash replaces the stored password hash of a user.
+func (r *userRepository) UpdatePasswordHash(userID, passwordHash string) error {
+	defer r.store.lock(r.inTx)()
+
+	user, ok := r.store.users[userID]
+	if !ok {
+		return nil
+	}
+	user.PasswordHash = passwordHash
+	user.UpdatedAt = r.store.now()
+	r.store.users[user.ID] = user
+	return nil
+}
@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type webhookRepository struct {
+	store *Store
+	inTx  bool
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository keeping webhooks
+// and their deliveries in store.
+func NewWebhookRepository(store *Store) repositories.WebhookRepository {
+	return &webhookRepository{store: store}
+}
+
+// Create stores a webhook and sets its ID and CreatedAt. Like the foreign key of
+// webhooks, the user of a user webhook must exist.
+func (r *webhookRepository) Create(webhook *models.Webhook) error {
+	defer r.store.lock(r.inTx)()
+
+	if _, ok := r.store.users[webhook.UserID]; webhook.UserID != "" && !ok {
+		return e.NewError(e.UserErr, e.UserNotFound, "user not found", errUnknownUser)
+	}
+	webhook.ID, webhook.CreatedAt = uuid.NewString(), r.store.now()
+	r.store.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+// Find retrieves a webhook by its ID, or nil if there is none.
+func (r *webhookRepository) Find(webhookID string) (*models.Webhook, error) {
+	defer r.store.lock(r.inTx)()
+
+	webhook, ok := r.store.webhooks[webhookID]
+	if !ok {
+		return nil, nil
+	}
+	return &webhook, nil
+}
+
+// List retrieves the webhooks of a user, or the admin webhooks if userID is empty,
+// oldest first.
+func (r *webhookRepository) List(userID string) ([]models.Webhook, error) {
+	defer r.store.lock(r.inTx)()
+
+	return r.webhooksOf(func(webhook models.Webhook) bool { return webhook.UserID == userID }), nil
+}
+
+// ListSubscribers retrieves the webhooks receiving the events of a user: theirs and
+// the admin webhooks.
+func (r *webhookRepository) ListSubscribers(userID string) ([]models.Webhook, error) {
+	defer r.store.lock(r.inTx)()
+
+	return r.webhooksOf(func(webhook models.Webhook) bool {
+		return webhook.UserID == userID || webhook.UserID == ""
+	}), nil
+}
+
+// Delete deletes a webhook with its deliveries and reports whether it existed.
+func (r *webhookRepository) Delete(webhookID string) (bool, error) {
+	defer r.store.lock(r.inTx)()
+
+	if _, ok := r.store.webhooks[webhookID]; !ok {
+		return false, nil
+	}
+	delete(r.store.webhooks, webhookID)
+	for deliveryID, delivery := range r.store.webhookDeliveries {
+		if delivery.WebhookID == webhookID {
+			delete(r.store.webhookDeliveries, deliveryID)
+		}
+	}
+	return true, nil
+}
+
+// CreateDelivery stores a pending delivery of an event to a webhook and sets its ID,
+// Status and CreatedAt.
+func (r *webhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	defer r.store.lock(r.inTx)()
+
+	stored := models.WebhookDelivery{
+		ID:        uuid.NewString(),
+		WebhookID: delivery.WebhookID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Payload:   slices.Clone(delivery.Payload),
+		Status:    models.DeliveryPending,
+		CreatedAt: r.store.now(),
+	}
+	r.store.webhookDeliveries[stored.ID] = stored
+	delivery.ID, delivery.Status, delivery.CreatedAt = stored.ID, stored.Status, stored.CreatedAt
+	return nil
+}
+
+// FindDelivery retrieves a delivery by its ID, or nil if there is none.
+func (r *webhookRepository) FindDelivery(deliveryID string) (*models.WebhookDelivery, error) {
+	defer r.store.lock(r.inTx)()
+
+	delivery, ok := r.store.webhookDeliveries[deliveryID]
+	if !ok {
+		return nil, nil
+	}
+	return cloneDelivery(delivery), nil
+}
+
+// ListDeliveries retrieves the latest deliveries of a webhook, most recent first.
+func (r *webhookRepository) ListDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	defer r.store.lock(r.inTx)()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range r.store.webhookDeliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, *cloneDelivery(delivery))
+		}
+	}
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return deliveries[:min(limit, len(deliveries))], nil
+}
+
+// RecordAttempt stores the outcome of an attempt of a delivery, counting the attempt.
+func (r *webhookRepository) RecordAttempt(deliveryID string, attempt models.DeliveryAttempt) error {
+	defer r.store.lock(r.inTx)()
+
+	delivery, ok := r.store.webhookDeliveries[deliveryID]
+	if !ok {
+		return nil
+	}
+	attemptedAt := r.store.now()
+	delivery.Status = attempt.Status
+	delivery.Attempts++
+	delivery.ResponseStatus, delivery.Error = attempt.ResponseStatus, attempt.Error
+	delivery.AttemptedAt = &attemptedAt
+	r.store.webhookDeliveries[deliveryID] = delivery
+	return nil
+}
+
+// PurgeDeliveries deletes the deliveries created before the given time and returns
+// how many there were.
+func (r *webhookRepository) PurgeDeliveries(before time.Time) (int64, error) {
+	defer r.store.lock(r.inTx)()
+
+	var purged int64
+	for deliveryID, delivery := range r.store.webhookDeliveries {
+		if delivery.CreatedAt.Before(before) {
+			delete(r.store.webhookDeliveries, deliveryID)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// webhooksOf returns the webhooks matching keep, oldest first.
+func (r *webhookRepository) webhooksOf(keep func(models.Webhook) bool) []models.Webhook {
+	var webhooks []models.Webhook
+	for _, webhook := range r.store.webhooks {
+		if keep(webhook) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	slices.SortFunc(webhooks, func(a, b models.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return webhooks
+}
+
+func cloneDelivery(delivery models.WebhookDelivery) *models.WebhookDelivery {
+	delivery.Payload = slices.Clone(delivery.Payload)
+	return &delivery
+}
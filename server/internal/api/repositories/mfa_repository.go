@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+	"time"
+)
+
+// MFARepository defines the interface for two-factor authentication database operations.
+type MFARepository interface {
+	GetMFA(userID string) (*models.UserMFA, error)
+	SavePendingMFA(userID, secret string) error
+	EnableMFA(userID string, counter int64, recoveryCodeHashes []string) error
+	DisableMFA(userID string) error
+	AdvanceCounter(userID string, counter int64) (bool, error)
+	UseRecoveryCode(userID, codeHash string) (bool, error)
+	RecordFailure(userID string, maxAttempts int, lockUntil time.Time) error
+}
+
+type mfaRepository struct {
+	db queries.DBTX
+}
+
+// NewMFARepository creates a new instance of MFARepository.
+func NewMFARepository(db queries.DBTX) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// GetMFA retrieves the TOTP enrollment of a user.
+// If the user never enrolled, both the enrollment and the error are nil.
+func (r *mfaRepository) GetMFA(userID string) (*models.UserMFA, error) {
+	mfa, err := queries.GetUserMFA(r.db, userID)
+	if err != nil {
+		return nil, queryError(err, "failed to get two-factor settings")
+	}
+	return mfa, nil
+}
+
+// SavePendingMFA stores a TOTP secret awaiting confirmation, replacing any previous pending secret.
+func (r *mfaRepository) SavePendingMFA(userID, secret string) error {
+	if err := queries.UpsertPendingMFA(r.db, userID, secret); err != nil {
+		return queryError(err, "failed to save two-factor secret")
+	}
+	return nil
+}
+
+// EnableMFA confirms the enrollment and stores the hashed recovery codes.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - counter: The time step of the code used to confirm, so it cannot be replayed.
+//   - recoveryCodeHashes: The hashes of the new recovery codes.
+//
+// Returns:
+//   - error: An error if the enrollment could not be updated.
+func (r *mfaRepository) EnableMFA(userID string, counter int64, recoveryCodeHashes []string) error {
+	if err := queries.EnableMFA(r.db, userID, counter, recoveryCodeHashes); err != nil {
+		return queryError(err, "failed to enable two-factor authentication")
+	}
+	return nil
+}
+
+// DisableMFA removes the enrollment and recovery codes of a user.
+func (r *mfaRepository) DisableMFA(userID string) error {
+	if err := queries.DeleteMFA(r.db, userID); err != nil {
+		return queryError(err, "failed to disable two-factor authentication")
+	}
+	return nil
+}
+
+// AdvanceCounter records the time step of a verified code.
+// It reports false when the code was already used.
+func (r *mfaRepository) AdvanceCounter(userID string, counter int64) (bool, error) {
+	ok, err := queries.AdvanceMFACounter(r.db, userID, counter)
+	if err != nil {
+		return false, queryError(err, "failed to update two-factor settings")
+	}
+	return ok, nil
+}
+
+// UseRecoveryCode consumes a recovery code. It reports false when the code is unknown or already used.
+func (r *mfaRepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	ok, err := queries.UseRecoveryCode(r.db, userID, codeHash)
+	if err != nil {
+		return false, queryError(err, "failed to use recovery code")
+	}
+	return ok, nil
+}
+
+// RecordFailure counts a failed verification and locks the second factor until lockUntil
+// once maxAttempts failures were recorded.
+func (r *mfaRepository) RecordFailure(userID string, maxAttempts int, lockUntil time.Time) error {
+	if err := queries.RecordMFAFailure(r.db, userID, maxAttempts, lockUntil); err != nil {
+		return queryError(err, "failed to update two-factor settings")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+)
+
+// OutboxRepository holds the events waiting to be relayed to the webhooks. Events are
+// added in the transaction of the change they describe, so they are stored if and only
+// if the change is.
+type OutboxRepository interface {
+	Add(events ...models.OutboxEvent) error
+	Claim(limit int) ([]models.OutboxEvent, error)
+	Delete(eventIDs []string) error
+}
+
+type outboxRepository struct {
+	db queries.DBTX
+}
+
+// NewOutboxRepository creates a new instance of OutboxRepository storing events in the
+// outbox_events table.
+func NewOutboxRepository(db queries.DBTX) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// Add stores events in the outbox, in order.
+func (r *outboxRepository) Add(events ...models.OutboxEvent) error {
+	if err := queries.InsertOutboxEvents(r.db, events); err != nil {
+		return queryError(err, "failed to add outbox events")
+	}
+	return nil
+}
+
+// Claim retrieves up to limit events, oldest first. Within a transaction, the events
+// are locked until it ends and concurrent relays claim other events.
+//
+// Parameters:
+//   - limit: The maximum number of events to retrieve.
+//
+// Returns:
+//   - []models.OutboxEvent: The events, in the order they were added.
+//   - error: An error if the outbox could not be read.
+func (r *outboxRepository) Claim(limit int) ([]models.OutboxEvent, error) {
+	events, err := queries.ClaimOutboxEvents(r.db, limit)
+	if err != nil {
+		return nil, queryError(err, "failed to claim outbox events")
+	}
+	return events, nil
+}
+
+// Delete removes relayed events from the outbox.
+func (r *outboxRepository) Delete(eventIDs []string) error {
+	if err := queries.DeleteOutboxEvents(r.db, eventIDs); err != nil {
+		return queryError(err, "failed to delete outbox events")
+	}
+	return nil
+}
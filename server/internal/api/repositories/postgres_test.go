@@ -0,0 +1,39 @@
+package repositories_test
+
+import (
+	"os"
+	"server/config"
+	"server/db"
+	"server/internal/api/repositories"
+	"server/internal/api/repositories/repotest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresConformance runs the conformance suite against the database at
+// TEST_DATABASE_URL. It is skipped when the variable is not set.
+func TestPostgresConformance(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := db.InitDB(config.DBConfig{URL: url})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		return repotest.Backend{
+			Users:       repositories.NewUserRepository(conn),
+			LikedImages: repositories.NewLikedImagesRepository(conn),
+			Sessions:    repositories.NewSessionRepository(conn),
+			UnitOfWork:  repositories.NewUnitOfWork(conn),
+			Jobs:        repositories.NewJobRepository(conn),
+			Webhooks:    repositories.NewWebhookRepository(conn),
+			Idempotency: repositories.NewIdempotencyRepository(conn),
+			MFA:         repositories.NewMFARepository(conn),
+			Identities:  repositories.NewIdentityRepository(conn),
+		}
+	})
+}
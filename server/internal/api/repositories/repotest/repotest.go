@@ -0,0 +1,789 @@
+// Package repotest is a conformance suite for the repositories. Every implementation
+// runs it, so they all behave the same way and services can be tested against the
+// in-memory one.
+package repotest
+
+import (
+	stderrors "errors"
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Backend is the set of repositories under test. They must share their data, like the
+// repositories of a single database.
+type Backend struct {
+	Users       repositories.UserRepository
+	LikedImages repositories.LikedImagesRepository
+	Sessions    repositories.SessionRepository
+	UnitOfWork  repositories.UnitOfWork
+	Jobs        repositories.JobRepository
+	Webhooks    repositories.WebhookRepository
+	Idempotency repositories.IdempotencyRepository
+	MFA         repositories.MFARepository
+	Identities  repositories.IdentityRepository
+}
+
+// Run runs the conformance suite. newBackend is called once per test; the suite only
+// creates users with unique emails, so backends can be shared between tests.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	t.Run("users", func(t *testing.T) { testUsers(t, newBackend(t)) })
+	t.Run("liked images", func(t *testing.T) { testLikedImages(t, newBackend(t)) })
+	t.Run("sessions", func(t *testing.T) { testSessions(t, newBackend(t)) })
+	t.Run("unit of work", func(t *testing.T) { testUnitOfWork(t, newBackend(t)) })
+	t.Run("bulk likes", func(t *testing.T) { testBulkLikes(t, newBackend(t)) })
+	t.Run("undo unlikes", func(t *testing.T) { testUndoUnlikes(t, newBackend(t)) })
+	t.Run("likes version lock", func(t *testing.T) { testLikesVersionLock(t, newBackend(t)) })
+	t.Run("jobs", func(t *testing.T) { testJobs(t, newBackend(t)) })
+	t.Run("outbox", func(t *testing.T) { testOutbox(t, newBackend(t)) })
+	t.Run("webhooks", func(t *testing.T) { testWebhooks(t, newBackend(t)) })
+	t.Run("idempotency keys", func(t *testing.T) { testIdempotency(t, newBackend(t)) })
+	t.Run("two-factor authentication", func(t *testing.T) { testMFA(t, newBackend(t)) })
+	t.Run("identities", func(t *testing.T) { testIdentities(t, newBackend(t)) })
+}
+
+func uniqueEmail() string {
+	return uuid.NewString() + "@example.com"
+}
+
+func createUser(t *testing.T, b Backend) string {
+	t.Helper()
+	user, err := b.Users.Create(&models.User{Email: uniqueEmail(), PasswordHash: "hash"})
+	require.NoError(t, err)
+	return user.ID
+}
+
+func assertCode(t *testing.T, err error, code e.ErrorCode) {
+	t.Helper()
+	var appErr e.AppError
+	if assert.True(t, stderrors.As(err, &appErr), "expected an AppError, got %v", err) {
+		assert.Equal(t, code, appErr.Code())
+	}
+}
+
+func testUsers(t *testing.T, b Backend) {
+	email := uniqueEmail()
+	created, err := b.Users.Create(&models.User{Email: email, PasswordHash: "hash"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, email, created.Email)
+
+	byEmail, err := b.Users.FindByEmail(email)
+	require.NoError(t, err)
+	require.NotNil(t, byEmail)
+	assert.Equal(t, created.ID, byEmail.ID)
+	assert.Equal(t, "hash", byEmail.PasswordHash)
+
+	byID, err := b.Users.FindByID(created.ID)
+	require.NoError(t, err)
+	require.NotNil(t, byID)
+	assert.Equal(t, email, byID.Email)
+
+	_, err = b.Users.Create(&models.User{Email: email, PasswordHash: "other"})
+	assertCode(t, err, e.EmailAlreadyExists)
+
+	require.NoError(t, b.Users.UpdatePasswordHash(created.ID, "rehashed"))
+	byID, err = b.Users.FindByID(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rehashed", byID.PasswordHash)
+
+	missing, err := b.Users.FindByEmail(uniqueEmail())
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	missing, err = b.Users.FindByID(uuid.NewString())
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func testLikedImages(t *testing.T, b Backend) {
+	const (
+		first  = "https://images.dog.ceo/breeds/pug/1.jpg"
+		second = "https://images.dog.ceo/breeds/pug/2.jpg"
+	)
+	userID := createUser(t, b)
+
+	images, err := b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	version, err := b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Zero(t, version.Version)
+
+	require.NoError(t, b.LikedImages.AddLikedImage(userID, first))
+	require.NoError(t, b.LikedImages.AddLikedImage(userID, second))
+	assertCode(t, b.LikedImages.AddLikedImage(userID, first), e.ImageAlreadyLiked)
+
+	images, err = b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{first, second}, images)
+
+	version, err = b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version.Version)
+	assert.False(t, version.UpdatedAt.IsZero())
+
+	require.NoError(t, b.LikedImages.RemoveLikedImage(userID, first))
+	assertCode(t, b.LikedImages.RemoveLikedImage(userID, first), e.ImageNotLiked)
+
+	images, err = b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{second}, images)
+
+	version, err = b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version.Version, "failed operations must not bump the version")
+
+	results, err := b.LikedImages.ApplyLikeOperations(userID, []models.LikeOperation{
+		{Action: models.LikeAction, ImageURL: first},
+		{Action: models.LikeAction, ImageURL: first},
+		{Action: models.UnlikeAction, ImageURL: second},
+		{Action: models.UnlikeAction, ImageURL: second},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []models.LikeOperationResult{
+		{Action: models.LikeAction, ImageURL: first, Success: true},
+		{Action: models.LikeAction, ImageURL: first, Code: e.ImageAlreadyLiked, Detail: "image already liked"},
+		{Action: models.UnlikeAction, ImageURL: second, Success: true},
+		{Action: models.UnlikeAction, ImageURL: second, Code: e.ImageNotLiked, Detail: "image not liked"},
+	}, results)
+
+	images, err = b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first}, images)
+
+	assertCode(t, b.LikedImages.AddLikedImage(uuid.NewString(), first), e.UserNotFound)
+}
+
+func testSessions(t *testing.T, b Backend) {
+	userID := createUser(t, b)
+	before := time.Now().Add(-time.Minute)
+
+	first, err := b.Sessions.Create(&models.Session{UserID: userID, UserAgent: "agent", IPAddress: "127.0.0.1"})
+	require.NoError(t, err)
+	second, err := b.Sessions.Create(&models.Session{UserID: userID})
+	require.NoError(t, err)
+
+	session, err := b.Sessions.Find(first)
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	assert.Equal(t, userID, session.UserID)
+	assert.Equal(t, "agent", session.UserAgent)
+	assert.Nil(t, session.RevokedAt)
+
+	require.NoError(t, b.Sessions.Touch(first))
+	active, err := b.Sessions.ListActive(userID, before)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	assert.Equal(t, first, active[0].ID, "the most recently seen session comes first")
+
+	revoked, err := b.Sessions.Revoke(userID, second)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = b.Sessions.Revoke(userID, second)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = b.Sessions.Revoke(createUser(t, b), first)
+	require.NoError(t, err)
+	assert.False(t, revoked, "sessions can only be revoked by their user")
+
+	session, err = b.Sessions.Find(second)
+	require.NoError(t, err)
+	assert.NotNil(t, session.RevokedAt)
+
+	active, err = b.Sessions.ListActive(userID, before)
+	require.NoError(t, err)
+	assert.Len(t, active, 1)
+
+	missing, err := b.Sessions.Find(uuid.NewString())
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// Purges affect every user, so they are checked through the sessions of the test
+	// user only.
+	_, err = b.Sessions.Purge(before)
+	require.NoError(t, err)
+	session, err = b.Sessions.Find(second)
+	require.NoError(t, err)
+	assert.NotNil(t, session, "sessions are kept until revoked or last seen before the purge time")
+
+	purged, err := b.Sessions.Purge(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(2))
+	for _, id := range []string{first, second} {
+		session, err = b.Sessions.Find(id)
+		require.NoError(t, err)
+		assert.Nil(t, session)
+	}
+}
+
+func testUnitOfWork(t *testing.T, b Backend) {
+	const image = "https://images.dog.ceo/breeds/pug/1.jpg"
+	userID := createUser(t, b)
+
+	t.Run("commits on success", func(t *testing.T) {
+		email := uniqueEmail()
+		err := b.UnitOfWork.Do(func(tx repositories.Tx) error {
+			if _, err := tx.Users().Create(&models.User{Email: email, PasswordHash: "hash"}); err != nil {
+				return err
+			}
+			if err := tx.LikedImages().AddLikedImage(userID, image); err != nil {
+				return err
+			}
+			return tx.LikeEvents().Notify([]models.LikeStreamEvent{{ID: 1, Type: models.ImageLikedEvent, UserID: userID, ImageURL: image}})
+		})
+		require.NoError(t, err)
+
+		user, err := b.Users.FindByEmail(email)
+		require.NoError(t, err)
+		assert.NotNil(t, user)
+		images, err := b.LikedImages.GetLikedImages(userID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{image}, images)
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		email := uniqueEmail()
+		err := b.UnitOfWork.Do(func(tx repositories.Tx) error {
+			if _, err := tx.Users().Create(&models.User{Email: email, PasswordHash: "hash"}); err != nil {
+				return err
+			}
+			if err := tx.LikedImages().AddLikedImage(userID, image+"?again"); err != nil {
+				return err
+			}
+			return tx.LikedImages().RemoveLikedImage(userID, image+"?never-liked")
+		})
+		assertCode(t, err, e.ImageNotLiked)
+
+		user, err := b.Users.FindByEmail(email)
+		require.NoError(t, err)
+		assert.Nil(t, user)
+		images, err := b.LikedImages.GetLikedImages(userID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{image}, images)
+		version, err := b.LikedImages.GetLikesVersion(userID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), version.Version)
+	})
+}
+
+// testBulkLikes applies enough likes for them to be loaded in bulk where supported,
+// which must not change their results.
+func testBulkLikes(t *testing.T, b Backend) {
+	userID := createUser(t, b)
+	image := func(i int) string {
+		return "https://images.dog.ceo/breeds/pug/" + string(rune('a'+i)) + ".jpg"
+	}
+	require.NoError(t, b.LikedImages.AddLikedImage(userID, image(0)))
+
+	var operations []models.LikeOperation
+	for i := range 20 {
+		operations = append(operations, models.LikeOperation{Action: models.LikeAction, ImageURL: image(i % 15)})
+	}
+
+	var results []models.LikeOperationResult
+	err := b.UnitOfWork.Do(func(tx repositories.Tx) error {
+		var err error
+		results, err = tx.LikedImages().ApplyLikeOperations(userID, operations)
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, results, len(operations))
+	for i, result := range results {
+		assert.Equal(t, operations[i].ImageURL, result.ImageURL)
+		if i == 0 || i >= 15 {
+			assert.Equal(t, e.ImageAlreadyLiked, result.Code, "operation %d", i)
+			assert.False(t, result.Success, "operation %d", i)
+		} else {
+			assert.True(t, result.Success, "operation %d", i)
+		}
+	}
+
+	images, err := b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Len(t, images, 15)
+	version, err := b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), version.Version)
+
+	_, err = b.LikedImages.ApplyLikeOperations(uuid.NewString(), operations)
+	assertCode(t, err, e.UserNotFound)
+}
+
+// testUndoUnlikes restores and purges unliked images. Purges affect every user, so
+// they are checked through the likes of the test user only.
+func testUndoUnlikes(t *testing.T, b Backend) {
+	const image = "https://images.dog.ceo/breeds/pug/1.jpg"
+	userID := createUser(t, b)
+
+	assertCode(t, b.LikedImages.RestoreLikedImage(userID, image, time.Now().Add(-time.Minute)), e.NothingToUndo)
+
+	require.NoError(t, b.LikedImages.AddLikedImage(userID, image))
+	require.NoError(t, b.LikedImages.RemoveLikedImage(userID, image))
+	assertCode(t, b.LikedImages.RestoreLikedImage(userID, image, time.Now().Add(time.Minute)), e.NothingToUndo)
+
+	require.NoError(t, b.LikedImages.RestoreLikedImage(userID, image, time.Now().Add(-time.Minute)))
+	assertCode(t, b.LikedImages.RestoreLikedImage(userID, image, time.Now().Add(-time.Minute)), e.NothingToUndo)
+
+	images, err := b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{image}, images)
+	version, err := b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version.Version)
+
+	require.NoError(t, b.LikedImages.RemoveLikedImage(userID, image))
+	_, err = b.LikedImages.PurgeUnlikedImages(time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, b.LikedImages.RestoreLikedImage(userID, image, time.Now().Add(-time.Minute)),
+		"unlikes are kept until their retention ends")
+
+	require.NoError(t, b.LikedImages.RemoveLikedImage(userID, image))
+	purged, err := b.LikedImages.PurgeUnlikedImages(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+	assertCode(t, b.LikedImages.RestoreLikedImage(userID, image, time.Now().Add(-time.Minute)), e.NothingToUndo)
+
+	version, err = b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), version.Version, "purges must not bump the version")
+
+	require.NoError(t, b.LikedImages.AddLikedImage(userID, image))
+	images, err = b.LikedImages.GetLikedImages(userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{image}, images)
+}
+
+// testLikesVersionLock races two units of work liking an image if the likes version
+// is still the one both read. The second one must wait for the first to commit and
+// see the version it bumped.
+func testLikesVersionLock(t *testing.T, b Backend) {
+	userID := createUser(t, b)
+
+	version, err := b.LikedImages.LockLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Zero(t, version.Version)
+	assert.True(t, version.UpdatedAt.IsZero(), "locking a version does not change it")
+
+	likeIfUnchanged := func(imageURL string, locked chan<- struct{}) error {
+		return b.UnitOfWork.Do(func(tx repositories.Tx) error {
+			version, err := tx.LikedImages().LockLikesVersion(userID)
+			if err != nil {
+				return err
+			}
+			if locked != nil {
+				close(locked)
+				time.Sleep(50 * time.Millisecond)
+			}
+			if version.Version != 0 {
+				return e.NewError(e.UserErr, e.PreconditionFailed, "liked images changed", nil)
+			}
+			return tx.LikedImages().AddLikedImage(userID, imageURL)
+		})
+	}
+
+	locked := make(chan struct{})
+	first := make(chan error, 1)
+	go func() { first <- likeIfUnchanged("https://images.dog.ceo/breeds/pug/1.jpg", locked) }()
+	<-locked
+	second := likeIfUnchanged("https://images.dog.ceo/breeds/pug/2.jpg", nil)
+
+	require.NoError(t, <-first)
+	assertCode(t, second, e.PreconditionFailed)
+	version, err = b.LikedImages.GetLikesVersion(userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version.Version)
+	assert.False(t, version.UpdatedAt.IsZero())
+}
+
+// testJobs claims, retries, buries and completes jobs. The queue is shared, so jobs
+// left by other runs are drained first.
+func testJobs(t *testing.T, b Backend) {
+	farFuture := time.Now().Add(time.Hour)
+	for {
+		job, err := b.Jobs.Claim(farFuture)
+		require.NoError(t, err)
+		if job == nil {
+			break
+		}
+		require.NoError(t, b.Jobs.Complete(job.ID))
+	}
+
+	key := "test@" + uuid.NewString()
+	dueID, err := b.Jobs.Enqueue(&models.Job{Type: "test", Payload: []byte(`{"n":1}`), MaxAttempts: 3, RunAt: time.Now().Add(-time.Second), UniqueKey: key})
+	require.NoError(t, err)
+	require.NotEmpty(t, dueID)
+	laterID, err := b.Jobs.Enqueue(&models.Job{Type: "test", Payload: []byte(`{}`), MaxAttempts: 3, RunAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	duplicateID, err := b.Jobs.Enqueue(&models.Job{Type: "test", Payload: []byte(`{}`), MaxAttempts: 3, RunAt: time.Now(), UniqueKey: key})
+	require.NoError(t, err)
+	assert.Empty(t, duplicateID, "jobs with a queued unique key are not added")
+
+	job, err := b.Jobs.Claim(time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, dueID, job.ID)
+	assert.Equal(t, "test", job.Type)
+	assert.JSONEq(t, `{"n":1}`, string(job.Payload))
+	assert.Equal(t, models.JobRunning, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, 3, job.MaxAttempts)
+	assert.Equal(t, key, job.UniqueKey)
+
+	job, err = b.Jobs.Claim(time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, job, "running and future jobs are not claimed")
+
+	job, err = b.Jobs.Claim(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, job, "stale running jobs are claimed again")
+	assert.Equal(t, dueID, job.ID)
+	assert.Equal(t, 2, job.Attempts)
+
+	require.NoError(t, b.Jobs.Retry(dueID, time.Now().Add(-time.Second), "boom"))
+	job, err = b.Jobs.Find(dueID)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, models.JobPending, job.Status)
+	assert.Equal(t, "boom", job.LastError)
+
+	job, err = b.Jobs.Claim(time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, 3, job.Attempts)
+
+	require.NoError(t, b.Jobs.Bury(dueID, "gave up"))
+	job, err = b.Jobs.Find(dueID)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, models.JobDead, job.Status)
+	assert.Equal(t, "gave up", job.LastError)
+
+	job, err = b.Jobs.Claim(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, job, "dead jobs are not claimed")
+
+	require.NoError(t, b.Jobs.Complete(laterID))
+	job, err = b.Jobs.Find(laterID)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+}
+
+// claimOutbox claims and deletes the events of the outbox in a unit of work, like a
+// relay, and returns them.
+func claimOutbox(t *testing.T, b Backend) []models.OutboxEvent {
+	t.Helper()
+	var events []models.OutboxEvent
+	err := b.UnitOfWork.Do(func(tx repositories.Tx) error {
+		var err error
+		if events, err = tx.Outbox().Claim(1000); err != nil {
+			return err
+		}
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		return tx.Outbox().Delete(ids)
+	})
+	require.NoError(t, err)
+	return events
+}
+
+func testOutbox(t *testing.T, b Backend) {
+	claimOutbox(t, b)
+	userID := createUser(t, b)
+
+	errRollback := stderrors.New("rollback")
+	err := b.UnitOfWork.Do(func(tx repositories.Tx) error {
+		require.NoError(t, tx.Outbox().Add(models.NewLikeEvent(models.ImageLikedEvent, userID, "https://example.com/rolled-back.jpg")))
+		_, err := tx.Jobs().Enqueue(&models.Job{Type: "test", Payload: []byte(`{}`), MaxAttempts: 1, RunAt: time.Now(), UniqueKey: "outbox@" + userID})
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	assert.Empty(t, claimOutbox(t, b), "events of a rolled back unit of work are discarded")
+	jobID, err := b.Jobs.Enqueue(&models.Job{Type: "test", Payload: []byte(`{}`), MaxAttempts: 1, RunAt: time.Now(), UniqueKey: "outbox@" + userID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, jobID, "jobs of a rolled back unit of work are discarded")
+	require.NoError(t, b.Jobs.Complete(jobID))
+
+	err = b.UnitOfWork.Do(func(tx repositories.Tx) error {
+		return tx.Outbox().Add(
+			models.NewLikeEvent(models.ImageLikedEvent, userID, "https://example.com/a.jpg"),
+			models.NewLikeEvent(models.ImageUnlikedEvent, userID, "https://example.com/a.jpg"),
+		)
+	})
+	require.NoError(t, err)
+
+	events := claimOutbox(t, b)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.ImageLikedEvent, events[0].Type)
+	assert.Equal(t, models.ImageUnlikedEvent, events[1].Type)
+	assert.NotEmpty(t, events[0].ID)
+	assert.Equal(t, userID, events[0].UserID)
+	assert.JSONEq(t, `{"user_id":"`+userID+`","image_url":"https://example.com/a.jpg"}`, string(events[0].Data))
+	assert.WithinDuration(t, time.Now(), events[0].CreatedAt, time.Minute)
+	assert.Empty(t, claimOutbox(t, b), "relayed events are deleted")
+
+	err = b.UnitOfWork.Do(func(tx repositories.Tx) error {
+		return tx.Outbox().Add(models.NewLikeEvent(models.ImageLikedEvent, uuid.NewString(), "https://example.com/a.jpg"))
+	})
+	assertCode(t, err, e.UserNotFound)
+}
+
+func testWebhooks(t *testing.T, b Backend) {
+	userID, otherID := createUser(t, b), createUser(t, b)
+
+	userHook := &models.Webhook{UserID: userID, URL: "https://example.com/user", Secret: "user-secret"}
+	require.NoError(t, b.Webhooks.Create(userHook))
+	assert.NotEmpty(t, userHook.ID)
+	assert.False(t, userHook.CreatedAt.IsZero())
+	otherHook := &models.Webhook{UserID: otherID, URL: "https://example.com/other", Secret: "other-secret"}
+	require.NoError(t, b.Webhooks.Create(otherHook))
+	adminHook := &models.Webhook{URL: "https://example.com/admin", Secret: "admin-secret"}
+	require.NoError(t, b.Webhooks.Create(adminHook))
+	t.Cleanup(func() { b.Webhooks.Delete(adminHook.ID) })
+
+	err := b.Webhooks.Create(&models.Webhook{UserID: uuid.NewString(), URL: "https://example.com", Secret: "s"})
+	assertCode(t, err, e.UserNotFound)
+
+	found, err := b.Webhooks.Find(userHook.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, userID, found.UserID)
+	assert.Equal(t, "user-secret", found.Secret)
+	missing, err := b.Webhooks.Find(uuid.NewString())
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	listed, err := b.Webhooks.List(userID)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, userHook.ID, listed[0].ID)
+	admin, err := b.Webhooks.List("")
+	require.NoError(t, err)
+	assert.Contains(t, webhookIDs(admin), adminHook.ID)
+	assert.NotContains(t, webhookIDs(admin), userHook.ID)
+
+	subscribers, err := b.Webhooks.ListSubscribers(userID)
+	require.NoError(t, err)
+	assert.Contains(t, webhookIDs(subscribers), userHook.ID)
+	assert.Contains(t, webhookIDs(subscribers), adminHook.ID)
+	assert.NotContains(t, webhookIDs(subscribers), otherHook.ID)
+
+	eventID := uuid.NewString()
+	first := &models.WebhookDelivery{WebhookID: userHook.ID, EventID: eventID, EventType: models.ImageLikedEvent, Payload: []byte(`{"id":"1"}`)}
+	require.NoError(t, b.Webhooks.CreateDelivery(first))
+	assert.NotEmpty(t, first.ID)
+	assert.Equal(t, models.DeliveryPending, first.Status)
+	second := &models.WebhookDelivery{WebhookID: userHook.ID, EventID: eventID, EventType: models.ImageLikedEvent, Payload: []byte(`{"id":"1"}`)}
+	require.NoError(t, b.Webhooks.CreateDelivery(second))
+
+	require.NoError(t, b.Webhooks.RecordAttempt(first.ID, models.DeliveryAttempt{
+		Status: models.DeliveryPending, ResponseStatus: 503, Error: "webhook responded with status 503",
+	}))
+	require.NoError(t, b.Webhooks.RecordAttempt(first.ID, models.DeliveryAttempt{Status: models.DeliverySucceeded, ResponseStatus: 200}))
+
+	delivery, err := b.Webhooks.FindDelivery(first.ID)
+	require.NoError(t, err)
+	require.NotNil(t, delivery)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, 200, delivery.ResponseStatus)
+	assert.Empty(t, delivery.Error)
+	assert.Equal(t, eventID, delivery.EventID)
+	assert.Equal(t, models.ImageLikedEvent, delivery.EventType)
+	assert.JSONEq(t, `{"id":"1"}`, string(delivery.Payload))
+	require.NotNil(t, delivery.AttemptedAt)
+
+	deliveries, err := b.Webhooks.ListDeliveries(userHook.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	limited, err := b.Webhooks.ListDeliveries(userHook.ID, 1)
+	require.NoError(t, err)
+	assert.Len(t, limited, 1)
+
+	purged, err := b.Webhooks.PurgeDeliveries(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged, "recent deliveries are kept")
+
+	deleted, err := b.Webhooks.Delete(userHook.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = b.Webhooks.Delete(userHook.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	delivery, err = b.Webhooks.FindDelivery(first.ID)
+	require.NoError(t, err)
+	assert.Nil(t, delivery, "deliveries are deleted with their webhook")
+}
+
+// testIdempotency reserves, completes and purges keys. Purges affect every scope, so
+// they are checked through the key of the test only.
+func testIdempotency(t *testing.T, b Backend) {
+	record := &models.IdempotencyRecord{Scope: uuid.NewString(), Key: "key-1", Fingerprint: "fingerprint"}
+
+	_, reserved, err := b.Idempotency.Reserve(record, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	record.StatusCode, record.ContentType, record.Body = 201, "application/json", []byte(`{}`)
+	record.Headers = map[string]string{"ETag": `"3"`}
+	require.NoError(t, b.Idempotency.Complete(record))
+	existing, reserved, err := b.Idempotency.Reserve(record, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	require.NotNil(t, existing)
+	assert.Equal(t, 201, existing.StatusCode)
+	assert.Equal(t, []byte(`{}`), existing.Body)
+	assert.Equal(t, map[string]string{"ETag": `"3"`}, existing.Headers)
+
+	_, err = b.Idempotency.PurgeExpired(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	_, reserved, err = b.Idempotency.Reserve(record, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.False(t, reserved, "keys are kept until their window ends")
+
+	purged, err := b.Idempotency.PurgeExpired(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+	existing, reserved, err = b.Idempotency.Reserve(record, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, reserved, "purged keys can be used again")
+	assert.Nil(t, existing)
+
+	require.NoError(t, b.Idempotency.Release(record.Scope, record.Key))
+}
+
+func testMFA(t *testing.T, b Backend) {
+	userID, otherID := createUser(t, b), createUser(t, b)
+
+	mfa, err := b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	assert.Nil(t, mfa)
+	require.NoError(t, b.MFA.RecordFailure(userID, 3, time.Now()), "failures of users not enrolled are ignored")
+
+	require.NoError(t, b.MFA.SavePendingMFA(userID, "first-secret"))
+	require.NoError(t, b.MFA.SavePendingMFA(userID, "second-secret"))
+	mfa, err = b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	require.NotNil(t, mfa)
+	assert.Equal(t, "second-secret", mfa.Secret, "pending secrets are replaced")
+	assert.False(t, mfa.Enabled)
+
+	codeHash, otherCodeHash := uuid.NewString(), uuid.NewString()
+	require.NoError(t, b.MFA.EnableMFA(userID, 10, []string{codeHash, otherCodeHash}))
+	require.NoError(t, b.MFA.SavePendingMFA(userID, "third-secret"))
+	mfa, err = b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	assert.True(t, mfa.Enabled)
+	assert.Equal(t, "second-secret", mfa.Secret, "enabled secrets are kept")
+	assert.Equal(t, int64(10), mfa.LastUsedCounter)
+
+	// A code can only be used once, and codes older than the last one used are rejected.
+	for _, counter := range []int64{10, 9} {
+		advanced, err := b.MFA.AdvanceCounter(userID, counter)
+		require.NoError(t, err)
+		assert.False(t, advanced, "counter %d was replayed", counter)
+	}
+	advanced, err := b.MFA.AdvanceCounter(userID, 11)
+	require.NoError(t, err)
+	assert.True(t, advanced)
+	advanced, err = b.MFA.AdvanceCounter(userID, 11)
+	require.NoError(t, err)
+	assert.False(t, advanced)
+	advanced, err = b.MFA.AdvanceCounter(otherID, 11)
+	require.NoError(t, err)
+	assert.False(t, advanced, "users not enrolled have no counter")
+
+	used, err := b.MFA.UseRecoveryCode(otherID, codeHash)
+	require.NoError(t, err)
+	assert.False(t, used, "recovery codes belong to their user")
+	used, err = b.MFA.UseRecoveryCode(userID, codeHash)
+	require.NoError(t, err)
+	assert.True(t, used)
+	used, err = b.MFA.UseRecoveryCode(userID, codeHash)
+	require.NoError(t, err)
+	assert.False(t, used, "recovery codes are single-use")
+	used, err = b.MFA.UseRecoveryCode(userID, uuid.NewString())
+	require.NoError(t, err)
+	assert.False(t, used)
+
+	lockUntil := time.Now().Add(15 * time.Minute).Truncate(time.Second)
+	for range 2 {
+		require.NoError(t, b.MFA.RecordFailure(userID, 3, lockUntil))
+	}
+	mfa, err = b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, mfa.FailedAttempts)
+	assert.Nil(t, mfa.LockedUntil)
+
+	require.NoError(t, b.MFA.RecordFailure(userID, 3, lockUntil))
+	mfa, err = b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, mfa.FailedAttempts, "the count restarts once locked")
+	if assert.NotNil(t, mfa.LockedUntil) {
+		assert.WithinDuration(t, lockUntil, *mfa.LockedUntil, time.Second)
+	}
+
+	advanced, err = b.MFA.AdvanceCounter(userID, 12)
+	require.NoError(t, err)
+	assert.True(t, advanced)
+	mfa, err = b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	assert.Nil(t, mfa.LockedUntil, "a verified code lifts the lock")
+
+	require.NoError(t, b.MFA.DisableMFA(userID))
+	mfa, err = b.MFA.GetMFA(userID)
+	require.NoError(t, err)
+	assert.Nil(t, mfa)
+	used, err = b.MFA.UseRecoveryCode(userID, otherCodeHash)
+	require.NoError(t, err)
+	assert.False(t, used, "recovery codes are removed with the enrollment")
+}
+
+func testIdentities(t *testing.T, b Backend) {
+	userID := createUser(t, b)
+	subject := uuid.NewString()
+
+	missing, err := b.Identities.FindIdentity("google", subject)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	identity := &models.UserIdentity{UserID: userID, Provider: "google", Subject: subject, Email: "identity@example.com"}
+	require.NoError(t, b.Identities.CreateIdentity(identity))
+	assert.NotEmpty(t, identity.ID)
+
+	found, err := b.Identities.FindIdentity("google", subject)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, identity.ID, found.ID)
+	assert.Equal(t, userID, found.UserID)
+	assert.Equal(t, "identity@example.com", found.Email)
+
+	other, err := b.Identities.FindIdentity("github", subject)
+	require.NoError(t, err)
+	assert.Nil(t, other, "subjects are scoped to their provider")
+
+	err = b.Identities.CreateIdentity(&models.UserIdentity{UserID: userID, Provider: "google", Subject: subject})
+	assertCode(t, err, e.DatabaseError)
+	err = b.Identities.CreateIdentity(&models.UserIdentity{UserID: uuid.NewString(), Provider: "google", Subject: uuid.NewString()})
+	assertCode(t, err, e.UserNotFound)
+}
+
+func webhookIDs(webhooks []models.Webhook) []string {
+	ids := make([]string, len(webhooks))
+	for i, webhook := range webhooks {
+		ids[i] = webhook.ID
+	}
+	return ids
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+	"time"
+)
+
+// SessionRepository defines the interface for session database operations.
+type SessionRepository interface {
+	Create(session *models.Session) (string, error)
+	Find(sessionID string) (*models.Session, error)
+	ListActive(userID string, since time.Time) ([]models.Session, error)
+	Touch(sessionID string) error
+	Revoke(userID, sessionID string) (bool, error)
+	Purge(before time.Time) (int64, error)
+}
+
+type sessionRepository struct {
+	db queries.DBTX
+}
+
+// NewSessionRepository creates a new instance of SessionRepository.
+func NewSessionRepository(db queries.DBTX) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create stores a new session and returns its ID.
+func (r *sessionRepository) Create(session *models.Session) (string, error) {
+	sessionID, err := queries.CreateSession(r.db, session)
+	if err != nil {
+		return "", queryError(err, "failed to create session")
+	}
+	return sessionID, nil
+}
+
+// Find retrieves a session by its ID.
+// If the session does not exist, both the session and the error are nil.
+func (r *sessionRepository) Find(sessionID string) (*models.Session, error) {
+	session, err := queries.GetSession(r.db, sessionID)
+	if err != nil {
+		return nil, queryError(err, "failed to get session")
+	}
+	return session, nil
+}
+
+// ListActive retrieves the sessions of a user that are not revoked and were seen after since.
+func (r *sessionRepository) ListActive(userID string, since time.Time) ([]models.Session, error) {
+	sessions, err := queries.GetActiveSessions(r.db, userID, since)
+	if err != nil {
+		return nil, queryError(err, "failed to get sessions")
+	}
+	return sessions, nil
+}
+
+// Touch records that a session was just used.
+func (r *sessionRepository) Touch(sessionID string) error {
+	if err := queries.TouchSession(r.db, sessionID); err != nil {
+		return queryError(err, "failed to update session")
+	}
+	return nil
+}
+
+// Revoke revokes an active session of a user. It reports false when the user has no such active session.
+func (r *sessionRepository) Revoke(userID, sessionID string) (bool, error) {
+	ok, err := queries.RevokeSession(r.db, userID, sessionID)
+	if err != nil {
+		return false, queryError(err, "failed to revoke session")
+	}
+	return ok, nil
+}
+
+// Purge deletes the sessions of every user revoked or last seen before the given time
+// and returns how many there were.
+func (r *sessionRepository) Purge(before time.Time) (int64, error) {
+	purged, err := queries.PurgeSessions(r.db, before)
+	if err != nil {
+		return 0, queryError(err, "failed to purge sessions")
+	}
+	return purged, nil
+}
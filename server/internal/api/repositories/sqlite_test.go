@@ -0,0 +1,34 @@
+package repositories_test
+
+import (
+	"path/filepath"
+	"server/config"
+	"server/db"
+	"server/internal/api/repositories"
+	"server/internal/api/repositories/repotest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLiteConformance runs the conformance suite against a new SQLite database
+// for every test.
+func TestSQLiteConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		conn, err := db.InitDB(config.DBConfig{URL: "sqlite://" + filepath.Join(t.TempDir(), "test.db")})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		return repotest.Backend{
+			Users:       repositories.NewUserRepository(conn),
+			LikedImages: repositories.NewLikedImagesRepository(conn),
+			Sessions:    repositories.NewSessionRepository(conn),
+			UnitOfWork:  repositories.NewUnitOfWork(conn),
+			Jobs:        repositories.NewJobRepository(conn),
+			Webhooks:    repositories.NewWebhookRepository(conn),
+			Idempotency: repositories.NewIdempotencyRepository(conn),
+			MFA:         repositories.NewMFARepository(conn),
+			Identities:  repositories.NewIdentityRepository(conn),
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"database/sql"
+	"server/db/queries"
+	e "server/internal/errors"
+)
+
+// Tx gives access to repositories sharing a single transaction.
+type Tx interface {
+	Users() UserRepository
+	LikedImages() LikedImagesRepository
+	Outbox() OutboxRepository
+	Webhooks() WebhookRepository
+	Jobs() JobRepository
+	LikeEvents() LikeEventRepository
+}
+
+// UnitOfWork runs several repository operations atomically.
+type UnitOfWork interface {
+	// Do runs fn in a transaction, committed if fn returns nil and rolled back
+	// otherwise. The error of fn is returned as is.
+	Do(fn func(tx Tx) error) error
+}
+
+type unitOfWork struct {
+	db *sql.DB
+}
+
+// NewUnitOfWork creates a new instance of UnitOfWork running its transactions on db.
+func NewUnitOfWork(db *sql.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Do(fn func(tx Tx) error) error {
+	tx, err := queries.Begin(u.db)
+	if err != nil {
+		return e.NewError(e.InternalErr, e.DatabaseError, "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqlTx{db: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return e.NewError(e.InternalErr, e.DatabaseError, "failed to commit transaction", err)
+	}
+	return nil
+}
+
+// sqlTx builds the repositories on the transaction they share.
+type sqlTx struct {
+	db queries.DBTX
+}
+
+func (t *sqlTx) Users() UserRepository {
+	return NewUserRepository(t.db)
+}
+
+func (t *sqlTx) LikedImages() LikedImagesRepository {
+	return NewLikedImagesRepository(t.db)
+}
+
+func (t *sqlTx) Outbox() OutboxRepository {
+	return NewOutboxRepository(t.db)
+}
+
+func (t *sqlTx) Webhooks() WebhookRepository {
+	return NewWebhookRepository(t.db)
+}
+
+func (t *sqlTx) Jobs() JobRepository {
+	return NewJobRepository(t.db)
+}
+
+func (t *sqlTx) LikeEvents() LikeEventRepository {
+	return NewLikeEventRepository(t.db)
+}
@@ -1,7 +1,6 @@
 package repositories
 
 import (
-	"database/sql"
 	"server/db/queries"
 	e "server/internal/errors"
 	"server/internal/models"
@@ -12,17 +11,18 @@ type UserRepository interface {
 	Create(user *models.User) (models.CreateUserResponse, error)
 	FindByEmail(email string) (*models.User, error)
 	FindByID(id string) (*models.User, error)
+	UpdatePasswordHash(userID, passwordHash string) error
 }
 
 // userRepository is a struct that provides methods to interact with the user data in the repository.
 type userRepository struct {
-	db *sql.DB
+	db queries.DBTX
 }
 
 // NewUserRepository creates a new instance of UserRepository.
 //
 // It returns a pointer to a userRepository struct that implements the UserRepository interface.
-func NewUserRepository(db *sql.DB) UserRepository {
+func NewUserRepository(db queries.DBTX) UserRepository {
 	return &userRepository{db: db}
 }
 
@@ -40,8 +40,11 @@ func NewUserRepository(db *sql.DB) UserRepository {
 //	and an error if the email already exists or if there was an issue creating the user.
 func (r *userRepository) Create(user *models.User) (models.CreateUserResponse, error) {
 	userID, err := queries.CreateUser(r.db, user)
+	if queries.IsUniqueViolation(err) {
+		return models.CreateUserResponse{}, e.NewError(e.UserErr, e.EmailAlreadyExists, "email already exists", err)
+	}
 	if err != nil {
-		return models.CreateUserResponse{}, e.NewError(e.InternalErr, e.DatabaseError, "error creating user", err)
+		return models.CreateUserResponse{}, queryError(err, "error creating user")
 	}
 
 	return models.CreateUserResponse{
@@ -67,6 +70,7 @@ func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 // FindByID retrieves a user by their ID.
 // It returns a pointer to the User model if found, otherwise it returns nil.
 // If the user does not exist, the error will also be nil.
+// Outside of a transaction, it reads from a replica if there is one.
 //
 // Parameters:
 //   - id: The ID of the user to be retrieved.
@@ -75,5 +79,14 @@ func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 //   - *models.User: A pointer to the User model if found, otherwise nil.
 //   - error: An error if there was an issue retrieving the user
 func (r *userRepository) FindByID(id string) (*models.User, error) {
-	return queries.GetUserByID(r.db, id)
+	return queries.GetUserByID(queries.Reader(r.db), id)
+}
+
+// UpdatePasswordHash replaces the stored password hash of a user, e.g. after
+// rehashing it with a newer algorithm.
+func (r *userRepository) UpdatePasswordHash(userID, passwordHash string) error {
+	if err := queries.UpdatePasswordHash(r.db, userID, passwordHash); err != nil {
+		return queryError(err, "failed to update password")
+	}
+	return nil
 }
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"server/db/queries"
+	"server/internal/models"
+	"time"
+)
+
+// WebhookRepository stores the webhooks and the log of their deliveries.
+type WebhookRepository interface {
+	Create(webhook *models.Webhook) error
+	Find(webhookID string) (*models.Webhook, error)
+	List(userID string) ([]models.Webhook, error)
+	ListSubscribers(userID string) ([]models.Webhook, error)
+	Delete(webhookID string) (bool, error)
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	FindDelivery(deliveryID string) (*models.WebhookDelivery, error)
+	ListDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error)
+	RecordAttempt(deliveryID string, attempt models.DeliveryAttempt) error
+	PurgeDeliveries(before time.Time) (int64, error)
+}
+
+type webhookRepository struct {
+	db queries.DBTX
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository storing webhooks in
+// the webhooks and webhook_deliveries tables.
+func NewWebhookRepository(db queries.DBTX) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// Create stores a webhook and sets its ID and CreatedAt. A webhook without UserID is an
+// admin webhook, receiving the events of every user.
+func (r *webhookRepository) Create(webhook *models.Webhook) error {
+	webhookID, createdAt, err := queries.CreateWebhook(r.db, webhook)
+	if err != nil {
+		return queryError(err, "failed to create webhook")
+	}
+	webhook.ID, webhook.CreatedAt = webhookID, createdAt
+	return nil
+}
+
+// Find retrieves a webhook by its ID, or nil if there is none.
+func (r *webhookRepository) Find(webhookID string) (*models.Webhook, error) {
+	webhook, err := queries.GetWebhook(r.db, webhookID)
+	if err != nil {
+		return nil, queryError(err, "failed to find webhook")
+	}
+	return webhook, nil
+}
+
+// List retrieves the webhooks of a user, or the admin webhooks if userID is empty,
+// oldest first.
+func (r *webhookRepository) List(userID string) ([]models.Webhook, error) {
+	webhooks, err := queries.GetWebhooks(r.db, userID)
+	if err != nil {
+		return nil, queryError(err, "failed to list webhooks")
+	}
+	return webhooks, nil
+}
+
+// ListSubscribers retrieves the webhooks receiving the events of a user: theirs and
+// the admin webhooks.
+func (r *webhookRepository) ListSubscribers(userID string) ([]models.Webhook, error) {
+	webhooks, err := queries.GetSubscribedWebhooks(r.db, userID)
+	if err != nil {
+		return nil, queryError(err, "failed to list subscribed webhooks")
+	}
+	return webhooks, nil
+}
+
+// Delete deletes a webhook with its deliveries and reports whether it existed.
+func (r *webhookRepository) Delete(webhookID string) (bool, error) {
+	deleted, err := queries.DeleteWebhook(r.db, webhookID)
+	if err != nil {
+		return false, queryError(err, "failed to delete webhook")
+	}
+	return deleted, nil
+}
+
+// CreateDelivery stores a pending delivery of an event to a webhook and sets its ID,
+// Status and CreatedAt.
+func (r *webhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	deliveryID, createdAt, err := queries.CreateWebhookDelivery(r.db, delivery)
+	if err != nil {
+		return queryError(err, "failed to create webhook delivery")
+	}
+	delivery.ID, delivery.Status, delivery.CreatedAt = deliveryID, models.DeliveryPending, createdAt
+	return nil
+}
+
+// FindDelivery retrieves a delivery by its ID, or nil if there is none.
+func (r *webhookRepository) FindDelivery(deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := queries.GetWebhookDelivery(r.db, deliveryID)
+	if err != nil {
+		return nil, queryError(err, "failed to find webhook delivery")
+	}
+	return delivery, nil
+}
+
+// ListDeliveries retrieves the latest deliveries of a webhook, most recent first.
+func (r *webhookRepository) ListDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	deliveries, err := queries.GetWebhookDeliveries(r.db, webhookID, limit)
+	if err != nil {
+		return nil, queryError(err, "failed to list webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of an attempt of a delivery, counting the attempt.
+func (r *webhookRepository) RecordAttempt(deliveryID string, attempt models.DeliveryAttempt) error {
+	if err := queries.RecordWebhookDeliveryAttempt(r.db, deliveryID, attempt); err != nil {
+		return queryError(err, "failed to record webhook delivery attempt")
+	}
+	return nil
+}
+
+// PurgeDeliveries deletes the deliveries created before the given time and returns
+// how many there were.
+func (r *webhookRepository) PurgeDeliveries(before time.Time) (int64, error) {
+	purged, err := queries.DeleteWebhookDeliveries(r.db, before)
+	if err != nil {
+		return 0, queryError(err, "failed to purge webhook deliveries")
+	}
+	return purged, nil
+}
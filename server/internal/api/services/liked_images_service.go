@@ -1,23 +1,51 @@
 package services
 
 import (
+	stderrors "errors"
+	"fmt"
+	"server/config"
 	"server/internal/api/repositories"
 	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/pubsub"
 	"server/internal/utils"
+	"time"
 )
 
+const (
+	// MaxBatchOperations is the maximum number of operations of a batch request.
+	MaxBatchOperations = 100
+	// MaxImportImages is the maximum number of images of an import.
+	MaxImportImages = 1000
+)
+
+// LikesPrecondition is checked against the version of the liked images of a user
+// before they are changed, such as a version matching the If-Match header of the
+// request. It runs in the transaction of the change, with the version locked, and the
+// change fails with PreconditionFailed if it returns false. A nil precondition always
+// passes.
+type LikesPrecondition func(version models.LikesVersion) bool
+
 type LikedImagesService struct {
 	likedRepo repositories.LikedImagesRepository
 	userRepo  repositories.UserRepository
+	uow       repositories.UnitOfWork
+	broker    *pubsub.Broker
+	cfg       config.LikesConfig
 }
 
-func NewLikedImagesService(likedRepo repositories.LikedImagesRepository, userRepo repositories.UserRepository) *LikedImagesService {
+func NewLikedImagesService(likedRepo repositories.LikedImagesRepository, userRepo repositories.UserRepository, uow repositories.UnitOfWork, broker *pubsub.Broker, cfg config.LikesConfig) *LikedImagesService {
 	return &LikedImagesService{
 		likedRepo,
 		userRepo,
+		uow,
+		broker,
+		cfg,
 	}
 }
 
+// GetLikedImages returns the images liked by a user. They are read from a replica if
+// there is one, so recent changes may be missing.
 func (s *LikedImagesService) GetLikedImages(userID string) ([]string, error) {
 	_, err := s.userRepo.FindByID(userID)
 	if err != nil {
@@ -27,72 +55,398 @@ func (s *LikedImagesService) GetLikedImages(userID string) ([]string, error) {
 	return s.likedRepo.GetLikedImages(userID)
 }
 
-func (s *LikedImagesService) LikeImage(userID, imageURL string) error {
+// GetVersionedLikedImages returns the images liked by a user with the version of the
+// list, for the version to be used as its ETag. Both are read in one transaction on
+// the primary, the version first, so a change racing with the read can only make the
+// version older than the list, never newer.
+func (s *LikedImagesService) GetVersionedLikedImages(userID string) ([]string, models.LikesVersion, error) {
+	var (
+		images  []string
+		version models.LikesVersion
+	)
+	err := s.uow.Do(func(tx repositories.Tx) error {
+		user, err := tx.Users().FindByID(userID)
+		if err != nil || user == nil {
+			return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+		}
+
+		version, err = tx.LikedImages().GetLikesVersion(userID)
+		if err != nil {
+			return err
+		}
+		images, err = tx.LikedImages().GetLikedImages(userID)
+		return err
+	})
+	if err != nil {
+		return nil, models.LikesVersion{}, err
+	}
+	return images, version, nil
+}
+
+// GetLikesVersion returns the version of the list of images liked by a user, which
+// changes on every like and unlike.
+func (s *LikedImagesService) GetLikesVersion(userID string) (models.LikesVersion, error) {
+	_, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return models.LikesVersion{}, e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+	}
+
+	return s.likedRepo.GetLikesVersion(userID)
+}
+
+// LikeImage likes an image for a user, records an image.liked event for the webhooks
+// and publishes it to the streams of the user. Concurrent likes of the same image are settled by the database, the later
+// ones failing with ImageAlreadyLiked. It returns the likes version written by the like.
+func (s *LikedImagesService) LikeImage(userID, imageURL string, precondition LikesPrecondition) (models.LikesVersion, error) {
+	var (
+		events  []models.LikeStreamEvent
+		version models.LikesVersion
+	)
+	err := s.uow.Do(func(tx repositories.Tx) error {
+		user, err := tx.Users().FindByID(userID)
+		if err != nil || user == nil {
+			return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+		}
+
+		if err := utils.ValidateImageURL(models.ImageURLField, imageURL); err != nil {
+			return err
+		}
+		if err := checkPrecondition(tx, userID, precondition); err != nil {
+			return err
+		}
+
+		if err := tx.LikedImages().AddLikedImage(userID, imageURL); err != nil {
+			return err
+		}
+		version, err = s.recordChanges(tx, userID, &events, likeChange{models.ImageLikedEvent, imageURL})
+		return err
+	})
+	s.publish(err, events)
+	return version, err
+}
+
+// UnlikeImage removes the like of an image for a user, failing with ImageNotLiked if
+// the user did not like it, and records and publishes an image.unliked event. The
+// unlike can be undone with UndoUnlike for a while. It returns the likes version
+// written by the unlike.
+func (s *LikedImagesService) UnlikeImage(userID, imageURL string, precondition LikesPrecondition) (models.LikesVersion, error) {
+	var (
+		events  []models.LikeStreamEvent
+		version models.LikesVersion
+	)
+	err := s.uow.Do(func(tx repositories.Tx) error {
+		user, err := tx.Users().FindByID(userID)
+		if err != nil || user == nil {
+			return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+		}
+
+		if err := utils.ValidateImageURL(models.ImageURLField, imageURL); err != nil {
+			return err
+		}
+		if err := checkPrecondition(tx, userID, precondition); err != nil {
+			return err
+		}
+
+		if err := tx.LikedImages().RemoveLikedImage(userID, imageURL); err != nil {
+			return err
+		}
+		version, err = s.recordChanges(tx, userID, &events, likeChange{models.ImageUnlikedEvent, imageURL})
+		return err
+	})
+	s.publish(err, events)
+	return version, err
+}
+
+// UndoUnlike restores the like of an image unliked within the undo window, failing
+// with NothingToUndo if there is no such unlike. It returns the likes version written
+// by the restored like.
+func (s *LikedImagesService) UndoUnlike(userID, imageURL string, precondition LikesPrecondition) (models.LikesVersion, error) {
+	var (
+		events  []models.LikeStreamEvent
+		version models.LikesVersion
+	)
+	err := s.uow.Do(func(tx repositories.Tx) error {
+		user, err := tx.Users().FindByID(userID)
+		if err != nil || user == nil {
+			return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+		}
+
+		if err := utils.ValidateImageURL(models.ImageURLField, imageURL); err != nil {
+			return err
+		}
+		if err := checkPrecondition(tx, userID, precondition); err != nil {
+			return err
+		}
+
+		if err := tx.LikedImages().RestoreLikedImage(userID, imageURL, time.Now().Add(-s.cfg.UndoWindow)); err != nil {
+			return err
+		}
+		version, err = s.recordChanges(tx, userID, &events, likeChange{models.ImageLikedEvent, imageURL})
+		return err
+	})
+	s.publish(err, events)
+	return version, err
+}
+
+// PurgeUnlikedImages permanently deletes the images unliked longer than the retention
+// ago and returns how many there were.
+func (s *LikedImagesService) PurgeUnlikedImages() (int64, error) {
+	return s.likedRepo.PurgeUnlikedImages(time.Now().Add(-s.cfg.Retention))
+}
+
+// ApplyBatch likes and unlikes up to MaxBatchOperations images at once. Operations
+// with an invalid URL, liking an image already liked or unliking an image that is not
+// liked fail on their own; the other operations are applied in a single transaction.
+//
+// Parameters:
+//   - userID: The ID of the user liking and unliking the images.
+//   - operations: The operations, applied in order.
+//   - precondition: The precondition of the batch, nil if there is none.
+//
+// Returns:
+//   - []models.LikeOperationResult: The result of each operation, in the same order.
+//   - models.LikesVersion: The likes version after the batch, read in its transaction;
+//     zero if no operation was valid, in which case the likes were not read.
+//   - error: An error if the batch is rejected as a whole or could not be applied.
+func (s *LikedImagesService) ApplyBatch(userID string, operations []models.LikeOperation, precondition LikesPrecondition) ([]models.LikeOperationResult, models.LikesVersion, error) {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil || user == nil {
-		return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+		return nil, models.LikesVersion{}, e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
 	}
 
-	if utils.IsEmptyString(imageURL) {
-		return e.NewError(e.ValidationErr, e.EmptyImageURL, "empty image URL", nil)
+	if len(operations) > MaxBatchOperations {
+		return nil, models.LikesVersion{}, utils.NewMaxItemsError(e.InvalidRequest, "operations", MaxBatchOperations)
 	}
 
-	if utils.ContainsEmptySpace(imageURL) {
-		return e.NewError(e.ValidationErr, e.MalformedURL, "URL contains empty spaces", nil)
+	return s.applyOperations(userID, operations, precondition, "operations[%d]."+models.ImageURLField)
+}
+
+// ImportLikedImages likes up to MaxImportImages images at once, typically exported
+// from another account. Images already liked are reported as failed operations.
+//
+// Parameters:
+//   - userID: The ID of the user importing the images.
+//   - images: The URLs of the images to like.
+//   - precondition: The precondition of the import, nil if there is none.
+//
+// Returns:
+//   - []models.LikeOperationResult: The result of each like, in the same order.
+//   - models.LikesVersion: The likes version after the import, as for ApplyBatch.
+//   - error: An error if the import is rejected as a whole or could not be applied.
+func (s *LikedImagesService) ImportLikedImages(userID string, images []string, precondition LikesPrecondition) ([]models.LikeOperationResult, models.LikesVersion, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, models.LikesVersion{}, e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
 	}
 
-	if utils.IsInvalidProtocol(imageURL) || utils.IsMalformedURL(imageURL) {
-		return e.NewError(e.ValidationErr, e.MalformedURL, "malformed or invalid image URL", nil)
+	if len(images) > MaxImportImages {
+		return nil, models.LikesVersion{}, utils.NewMaxItemsError(e.InvalidRequest, "images", MaxImportImages)
 	}
 
-	if !utils.HasImageValidExtension(imageURL) {
-		return e.NewError(e.ValidationErr, e.InvalidImageExtension, "invalid image extension", nil)
+	operations := make([]models.LikeOperation, len(images))
+	for i, image := range images {
+		operations[i] = models.LikeOperation{Action: models.LikeAction, ImageURL: image}
 	}
 
-	imgs, err := s.likedRepo.GetLikedImages(userID)
+	return s.applyOperations(userID, operations, precondition, "images[%d]")
+}
+
+// applyOperations validates the URL of each operation, reporting the field with
+// fieldFormat and the index of the operation, and applies the valid ones if the
+// likes pass precondition. It returns the likes version after them.
+func (s *LikedImagesService) applyOperations(userID string, operations []models.LikeOperation, precondition LikesPrecondition, fieldFormat string) ([]models.LikeOperationResult, models.LikesVersion, error) {
+	results := make([]models.LikeOperationResult, len(operations))
+	valid := make([]models.LikeOperation, 0, len(operations))
+	positions := make([]int, 0, len(operations))
 
+	for i, op := range operations {
+		if err := utils.ValidateImageURL(fmt.Sprintf(fieldFormat, i), op.ImageURL); err != nil {
+			results[i] = failedOperation(op, err)
+			continue
+		}
+		valid = append(valid, op)
+		positions = append(positions, i)
+	}
+
+	if len(valid) == 0 {
+		return results, models.LikesVersion{}, nil
+	}
+
+	var (
+		applied []models.LikeOperationResult
+		events  []models.LikeStreamEvent
+		version models.LikesVersion
+	)
+	err := s.uow.Do(func(tx repositories.Tx) error {
+		if err := checkPrecondition(tx, userID, precondition); err != nil {
+			return err
+		}
+
+		var err error
+		applied, err = tx.LikedImages().ApplyLikeOperations(userID, valid)
+		if err != nil {
+			return err
+		}
+		version, err = s.recordChanges(tx, userID, &events, likeChanges(applied)...)
+		return err
+	})
+	s.publish(err, events)
 	if err != nil {
-		return e.NewError(e.InternalErr, e.DatabaseError, "error retrieving liked images", err)
+		return nil, models.LikesVersion{}, err
 	}
+	for i, result := range applied {
+		results[positions[i]] = result
+	}
+
+	return results, version, nil
+}
 
-	if utils.IsImageLiked(imgs, imageURL) {
-		return e.NewError(e.ValidationErr, e.ImageAlreadyLiked, "image already liked", nil)
+// checkPrecondition locks the likes version of a user in tx and fails with
+// PreconditionFailed if it does not pass precondition. The lock is held until tx ends,
+// so of concurrent changes made with the same precondition, only the first one passes.
+func checkPrecondition(tx repositories.Tx, userID string, precondition LikesPrecondition) error {
+	if precondition == nil {
+		return nil
 	}
 
-	return s.likedRepo.AddLikedImage(userID, imageURL)
+	version, err := tx.LikedImages().LockLikesVersion(userID)
+	if err != nil {
+		return err
+	}
+	if !precondition(version) {
+		return e.NewError(e.UserErr, e.PreconditionFailed, "liked images changed since they were read", nil)
+	}
+	return nil
 }
 
-func (s *LikedImagesService) UnlikeImage(userID, imageURL string) error {
-	user, err := s.userRepo.FindByID(userID)
-	if err != nil || user == nil {
-		return e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+// likeChange is a like or unlike applied to the liked images of a user, eventType
+// being ImageLikedEvent or ImageUnlikedEvent.
+type likeChange struct {
+	eventType string
+	imageURL  string
+}
+
+// likeChanges returns the changes of the successful operations among results.
+func likeChanges(results []models.LikeOperationResult) []likeChange {
+	var changes []likeChange
+	for _, result := range results {
+		if !result.Success {
+			continue
+		}
+		eventType := models.ImageLikedEvent
+		if result.Action == models.UnlikeAction {
+			eventType = models.ImageUnlikedEvent
+		}
+		changes = append(changes, likeChange{eventType, result.ImageURL})
 	}
+	return changes
+}
 
-	if utils.IsEmptyString(imageURL) {
-		return e.NewError(e.ValidationErr, e.EmptyImageURL, "empty image URL", nil)
+// recordChanges records the events of changes, applied in tx, for the webhooks, sends
+// them to the streams of the other servers with tx and sets events to the stream
+// events to publish once tx is committed. Every change bumps the likes version, so
+// the events are numbered up to the version after the changes, which is returned.
+func (s *LikedImagesService) recordChanges(tx repositories.Tx, userID string, events *[]models.LikeStreamEvent, changes ...likeChange) (models.LikesVersion, error) {
+	version, err := tx.LikedImages().GetLikesVersion(userID)
+	if err != nil || len(changes) == 0 {
+		return version, err
 	}
 
-	if utils.ContainsEmptySpace(imageURL) {
-		return e.NewError(e.ValidationErr, e.MalformedURL, "URL contains empty spaces", nil)
+	outbox := make([]models.OutboxEvent, len(changes))
+	for i, change := range changes {
+		outbox[i] = models.NewLikeEvent(change.eventType, userID, change.imageURL)
+	}
+	if err := tx.Outbox().Add(outbox...); err != nil {
+		return models.LikesVersion{}, err
 	}
 
-	if utils.IsInvalidProtocol(imageURL) || utils.IsMalformedURL(imageURL) {
-		return e.NewError(e.ValidationErr, e.MalformedURL, "malformed or invalid image URL", nil)
+	first := version.Version - int64(len(changes)) + 1
+	*events = make([]models.LikeStreamEvent, len(changes))
+	for i, change := range changes {
+		(*events)[i] = models.LikeStreamEvent{
+			ID:        first + int64(i),
+			Type:      change.eventType,
+			UserID:    userID,
+			ImageURL:  change.imageURL,
+			CreatedAt: version.UpdatedAt,
+		}
 	}
+	return version, tx.LikeEvents().Notify(*events)
+}
 
-	if !utils.HasImageValidExtension(imageURL) {
-		return e.NewError(e.ValidationErr, e.InvalidImageExtension, "invalid image extension", nil)
+// publish publishes the events of a unit of work to the streams if it succeeded.
+func (s *LikedImagesService) publish(err error, events []models.LikeStreamEvent) {
+	if err == nil {
+		s.broker.Publish(events...)
 	}
+}
+
+// LikesStream is a subscription to the like activity of a user, with what to send
+// first to get the subscriber in sync.
+type LikesStream struct {
+	*pubsub.Subscription
+	// Version is the current version of the liked images of the user.
+	Version int64
+	// LastEventID is the ID of the latest event the subscriber is in sync with once
+	// sent the replay, or the reset. Events of the subscription up to it are stale.
+	LastEventID int64
+	// Replay are the events missed by a resuming subscriber, oldest first.
+	Replay []models.LikeStreamEvent
+	// Reset is set when the missed events are no longer known, in which case the
+	// subscriber must fetch the liked images again.
+	Reset bool
+}
 
-	imgs, err := s.likedRepo.GetLikedImages(userID)
+// SubscribeLikes subscribes to the like activity of a user, resuming after the event
+// lastEventID if set. The stream must be closed when it is no longer used.
+func (s *LikedImagesService) SubscribeLikes(userID string, lastEventID *int64) (*LikesStream, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, e.NewError(e.UserErr, e.UserNotFound, "user not found", err)
+	}
 
+	// Subscribing first, events published from now on are either in the history
+	// read below or received by the subscription.
+	sub := s.broker.Subscribe(userID)
+	version, err := s.likedRepo.GetLikesVersion(userID)
 	if err != nil {
-		return e.NewError(e.InternalErr, e.DatabaseError, "error retrieving liked images", err)
+		sub.Close()
+		return nil, err
 	}
 
-	if !utils.IsImageLiked(imgs, imageURL) {
-		return e.NewError(e.ValidationErr, e.ImageNotLiked, "image not liked", nil)
+	stream := &LikesStream{Subscription: sub, Version: version.Version}
+	from := version.Version
+	if lastEventID != nil {
+		from = *lastEventID
 	}
 
-	return s.likedRepo.RemoveLikedImage(userID, imageURL)
+	replay := s.broker.History(userID, from)
+	for i, event := range replay {
+		if event.ID != from+int64(i)+1 {
+			replay = nil
+			stream.Reset = true
+			break
+		}
+	}
+	if from+int64(len(replay)) < version.Version {
+		stream.Reset = true
+	}
+
+	if stream.Reset {
+		stream.LastEventID = version.Version
+	} else {
+		stream.Replay = replay
+		stream.LastEventID = from + int64(len(replay))
+	}
+	return stream, nil
+}
+
+func failedOperation(op models.LikeOperation, err error) models.LikeOperationResult {
+	result := models.LikeOperationResult{Action: op.Action, ImageURL: op.ImageURL}
+
+	var appErr e.AppError
+	if stderrors.As(err, &appErr) {
+		result.Code, result.Detail = appErr.Code(), appErr.PublicMessage()
+	}
+	return result
 }
@@ -1,11 +1,18 @@
 package services_test
 
 import (
+	"server/config"
+	"server/internal/api/repositories/memory"
 	s "server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/pubsub"
 	testing_mocks "server/internal/testing"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -14,6 +21,26 @@ const (
 
 const successImageURL = "https://example.com/image.jpg"
 
+var likesConfig = config.LikesConfig{UndoWindow: 5 * time.Minute, Retention: 30 * 24 * time.Hour}
+
+func newLikedImagesService(likedImagesBuilder *testing_mocks.MockLikedImagesBuilder, userBuilder *testing_mocks.MockBuilder) *s.LikedImagesService {
+	userRepo, likedImagesRepo := userBuilder.Build(), likedImagesBuilder.Build()
+	return s.NewLikedImagesService(likedImagesRepo, userRepo, testing_mocks.NewUnitOfWork(userRepo, likedImagesRepo), pubsub.NewBroker(0), likesConfig)
+}
+
+// published returns the events received by sub so far.
+func published(sub *pubsub.Subscription) []models.LikeStreamEvent {
+	var events []models.LikeStreamEvent
+	for {
+		select {
+		case event := <-sub.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
 func TestGetLikedImages(t *testing.T) {
 	t.Run("successful liked images retrieval", func(t *testing.T) {
 		images := []string{"https://example.com/image1.jpg", "https://example.com/image2.jpg"}
@@ -22,7 +49,7 @@ func TestGetLikedImages(t *testing.T) {
 		}
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
 		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithGetLikedImages(userID)
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
 		response, err := service.GetLikedImages(userID)
 
@@ -35,7 +62,7 @@ func TestGetLikedImages(t *testing.T) {
 	t.Run("empty image array retrieval", func(t *testing.T) {
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
 		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithGetLikedImages(userID)
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
 		_, err := service.GetLikedImages(userID)
 
@@ -45,14 +72,71 @@ func TestGetLikedImages(t *testing.T) {
 	})
 }
 
-func TestAddLikedImage(t *testing.T) {
-	t.Run("successful liked image - returns void", func(t *testing.T) {
+func TestGetLikesVersion(t *testing.T) {
+	t.Run("successful version retrieval", func(t *testing.T) {
+		version := models.LikesVersion{Version: 3, UpdatedAt: time.Now()}
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
-		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithGetLikedImages(userID).WithAddLikedImage(userID, successImageURL)
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithGetLikesVersion(userID, version)
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		response, err := service.GetLikesVersion(userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, version, response)
+		userBuilder.AssertExpectations(t)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+}
+
+func TestGetVersionedLikedImages(t *testing.T) {
+	t.Run("successful retrieval - reads the version and the list in one transaction", func(t *testing.T) {
+		images := []string{successImageURL}
+		version := models.LikesVersion{Version: 1, UpdatedAt: time.Now()}
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(map[string][]string{userID: images}).WithGetLikesVersion(userID, version).WithGetLikedImages(userID)
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, pubsub.NewBroker(0), likesConfig)
+
+		gotImages, gotVersion, err := service.GetVersionedLikedImages(userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, images, gotImages)
+		assert.Equal(t, version, gotVersion)
+		assert.Equal(t, 1, uow.Committed)
+		userBuilder.AssertExpectations(t)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("user not found - returns error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithErrorFindByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		_, _, err := service.GetVersionedLikedImages(userID)
+
+		assert.Error(t, err)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+}
 
-		err := service.LikeImage(userID, successImageURL)
+func TestAddLikedImage(t *testing.T) {
+	t.Run("successful liked image - returns the version written", func(t *testing.T) {
+		version := models.LikesVersion{Version: 4, UpdatedAt: time.Now()}
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithAddLikedImage(userID, successImageURL).WithGetLikesVersion(userID, version)
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		broker := pubsub.NewBroker(0)
+		sub := broker.Subscribe(userID)
+		defer sub.Close()
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, broker, likesConfig)
+
+		written, err := service.LikeImage(userID, successImageURL, nil)
 		assert.NoError(t, err)
+		assert.Equal(t, version, written)
+		assert.Equal(t, []models.OutboxEvent{models.NewLikeEvent(models.ImageLikedEvent, userID, successImageURL)}, uow.Events)
+		assert.Equal(t, []models.LikeStreamEvent{
+			{ID: 4, Type: models.ImageLikedEvent, UserID: userID, ImageURL: successImageURL, CreatedAt: version.UpdatedAt},
+		}, published(sub))
 
 		userBuilder.AssertExpectations(t)
 		likedImagesBuilder.AssertExpectations(t)
@@ -61,9 +145,9 @@ func TestAddLikedImage(t *testing.T) {
 	t.Run("empty image URL - returns validation error", func(t *testing.T) {
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
 		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
-		err := service.LikeImage(userID, "")
+		_, err := service.LikeImage(userID, "", nil)
 		assert.Error(t, err)
 
 		userBuilder.AssertExpectations(t)
@@ -76,12 +160,19 @@ func TestAddLikedImage(t *testing.T) {
 			userID: images,
 		}
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
-		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithGetLikedImages(userID)
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
-
-		err := service.LikeImage(userID, successImageURL)
-
-		assert.Error(t, err)
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithAddLikedImageError(userID, successImageURL)
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		broker := pubsub.NewBroker(0)
+		sub := broker.Subscribe(userID)
+		defer sub.Close()
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, broker, likesConfig)
+
+		_, err := service.LikeImage(userID, successImageURL, nil)
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.ImageAlreadyLiked, "", nil))
+		assert.Equal(t, 1, uow.RolledBack)
+		assert.Empty(t, uow.Events)
+		assert.Empty(t, published(sub), "nothing is published when the change fails")
 		userBuilder.AssertExpectations(t)
 		likedImagesBuilder.AssertExpectations(t)
 	})
@@ -89,9 +180,9 @@ func TestAddLikedImage(t *testing.T) {
 	t.Run("user not found - returns db error", func(t *testing.T) {
 		userBuilder := testing_mocks.NewMockBuilder().WithErrorFindByID()
 		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
-		err := service.LikeImage(userID, successImageURL)
+		_, err := service.LikeImage(userID, successImageURL, nil)
 
 		assert.Error(t, err)
 		userBuilder.AssertExpectations(t)
@@ -106,11 +197,13 @@ func TestUnlikeImage(t *testing.T) {
 			userID: images,
 		}
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
-		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithGetLikedImages(userID).WithRemoveLikedImage(userID, successImageURL)
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithRemoveLikedImage(userID, successImageURL).WithGetLikesVersion(userID, models.LikesVersion{Version: 2})
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, pubsub.NewBroker(0), likesConfig)
 
-		err := service.UnlikeImage(userID, successImageURL)
+		_, err := service.UnlikeImage(userID, successImageURL, nil)
 		assert.NoError(t, err)
+		assert.Equal(t, []models.OutboxEvent{models.NewLikeEvent(models.ImageUnlikedEvent, userID, successImageURL)}, uow.Events)
 
 		userBuilder.AssertExpectations(t)
 		likedImagesBuilder.AssertExpectations(t)
@@ -119,9 +212,9 @@ func TestUnlikeImage(t *testing.T) {
 	t.Run("empty image URL - returns validation error", func(t *testing.T) {
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
 		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
-		err := service.UnlikeImage(userID, "")
+		_, err := service.UnlikeImage(userID, "", nil)
 		assert.Error(t, err)
 
 		userBuilder.AssertExpectations(t)
@@ -134,25 +227,351 @@ func TestUnlikeImage(t *testing.T) {
 			userID: images,
 		}
 		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
-		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithGetLikedImages(userID)
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithRemoveLikedImageError(userID, successImageURL)
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		_, err := service.UnlikeImage(userID, successImageURL, nil)
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.ImageNotLiked, "", nil))
+		userBuilder.AssertExpectations(t)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("user not found - returns db error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithErrorFindByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
-		err := service.UnlikeImage(userID, successImageURL)
+		_, err := service.UnlikeImage(userID, successImageURL, nil)
 
 		assert.Error(t, err)
 		userBuilder.AssertExpectations(t)
 		likedImagesBuilder.AssertExpectations(t)
 	})
+}
+
+func TestUndoUnlike(t *testing.T) {
+	t.Run("recent unlike - restores the like", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithRestoreLikedImage(userID, successImageURL).WithGetLikesVersion(userID, models.LikesVersion{Version: 3})
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		start := time.Now()
+		_, err := service.UndoUnlike(userID, successImageURL, nil)
+
+		assert.NoError(t, err)
+		since := likedImagesBuilder.Build().Calls[0].Arguments.Get(2).(time.Time)
+		assert.WithinRange(t, since, start.Add(-likesConfig.UndoWindow), time.Now().Add(-likesConfig.UndoWindow))
+		userBuilder.AssertExpectations(t)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("nothing to undo - returns not found error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithRestoreLikedImageError(userID, successImageURL)
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, pubsub.NewBroker(0), likesConfig)
+
+		_, err := service.UndoUnlike(userID, successImageURL, nil)
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.NothingToUndo, "", nil))
+		assert.Equal(t, 1, uow.RolledBack)
+		userBuilder.AssertExpectations(t)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("invalid image URL - returns validation error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		_, err := service.UndoUnlike(userID, "not a url", nil)
+
+		assert.Error(t, err)
+		likedImagesBuilder.AssertExpectations(t)
+	})
 
 	t.Run("user not found - returns db error", func(t *testing.T) {
 		userBuilder := testing_mocks.NewMockBuilder().WithErrorFindByID()
 		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
-		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build())
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
 
-		err := service.UnlikeImage(userID, successImageURL)
+		_, err := service.UndoUnlike(userID, successImageURL, nil)
 
 		assert.Error(t, err)
 		userBuilder.AssertExpectations(t)
 		likedImagesBuilder.AssertExpectations(t)
 	})
 }
+
+func TestLikesPrecondition(t *testing.T) {
+	const otherImageURL = "https://example.com/other.png"
+	atVersion := func(expected int64) s.LikesPrecondition {
+		return func(version models.LikesVersion) bool { return version.Version == expected }
+	}
+
+	t.Run("likes changed - fails without changing them", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithLockLikesVersion(userID, models.LikesVersion{Version: 2})
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, pubsub.NewBroker(0), likesConfig)
+
+		_, err := service.LikeImage(userID, successImageURL, atVersion(1))
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.PreconditionFailed, "", nil))
+		assert.Equal(t, 1, uow.RolledBack)
+		assert.Empty(t, uow.Events)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("likes unchanged - applies the batch", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithLockLikesVersion(userID, models.LikesVersion{Version: 1}).WithApplyLikeOperations(userID).WithGetLikesVersion(userID, models.LikesVersion{Version: 2})
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		results, _, err := service.ApplyBatch(userID, []models.LikeOperation{{Action: models.LikeAction, ImageURL: successImageURL}}, atVersion(1))
+
+		assert.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.True(t, results[0].Success)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("concurrent changes with the same version - only the first one applies", func(t *testing.T) {
+		store := memory.NewStore()
+		user, err := memory.NewUserRepository(store).Create(&models.User{Email: "precondition@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		service := s.NewLikedImagesService(memory.NewLikedImagesRepository(store), memory.NewUserRepository(store), memory.NewUnitOfWork(store), pubsub.NewBroker(0), likesConfig)
+		_, err = service.LikeImage(user.ID, successImageURL, nil)
+		require.NoError(t, err)
+
+		errs := make(chan error, 2)
+		for _, imageURL := range []string{otherImageURL, "https://example.com/another.png"} {
+			go func() {
+				_, err := service.LikeImage(user.ID, imageURL, atVersion(1))
+				errs <- err
+			}()
+		}
+		first, second := <-errs, <-errs
+
+		if first != nil {
+			first, second = second, first
+		}
+		assert.NoError(t, first)
+		assert.ErrorIs(t, second, e.NewError(e.UserErr, e.PreconditionFailed, "", nil))
+		version, err := service.GetLikesVersion(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), version.Version)
+	})
+}
+
+func TestPurgeUnlikedImages(t *testing.T) {
+	likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithPurgeUnlikedImages(3)
+	service := newLikedImagesService(likedImagesBuilder, testing_mocks.NewMockBuilder())
+
+	start := time.Now()
+	purged, err := service.PurgeUnlikedImages()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	before := likedImagesBuilder.Build().Calls[0].Arguments.Get(0).(time.Time)
+	assert.WithinRange(t, before, start.Add(-likesConfig.Retention), time.Now().Add(-likesConfig.Retention))
+	likedImagesBuilder.AssertExpectations(t)
+}
+
+func TestApplyBatch(t *testing.T) {
+	const otherImageURL = "https://example.com/other.png"
+
+	t.Run("mixed operations - returns a result per operation", func(t *testing.T) {
+		initialLikedImages := map[string][]string{
+			userID: {successImageURL},
+		}
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithApplyLikeOperations(userID).WithGetLikesVersion(userID, models.LikesVersion{Version: 7})
+		uow := testing_mocks.NewUnitOfWork(userBuilder.Build(), likedImagesBuilder.Build())
+		broker := pubsub.NewBroker(0)
+		sub := broker.Subscribe(userID)
+		defer sub.Close()
+		service := s.NewLikedImagesService(likedImagesBuilder.Build(), userBuilder.Build(), uow, broker, likesConfig)
+
+		results, version, err := service.ApplyBatch(userID, []models.LikeOperation{
+			{Action: models.LikeAction, ImageURL: otherImageURL},
+			{Action: models.LikeAction, ImageURL: "not a url"},
+			{Action: models.LikeAction, ImageURL: successImageURL},
+			{Action: models.UnlikeAction, ImageURL: successImageURL},
+		}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), version.Version, "the version after the batch, read in its transaction")
+		assert.Len(t, results, 4)
+		assert.True(t, results[0].Success)
+		assert.False(t, results[1].Success)
+		assert.Equal(t, e.MalformedURL, results[1].Code)
+		assert.Equal(t, "not a url", results[1].ImageURL)
+		assert.False(t, results[2].Success)
+		assert.Equal(t, e.ImageAlreadyLiked, results[2].Code)
+		assert.True(t, results[3].Success)
+		assert.Equal(t, []models.OutboxEvent{
+			models.NewLikeEvent(models.ImageLikedEvent, userID, otherImageURL),
+			models.NewLikeEvent(models.ImageUnlikedEvent, userID, successImageURL),
+		}, uow.Events)
+		assert.Equal(t, []models.LikeStreamEvent{
+			{ID: 6, Type: models.ImageLikedEvent, UserID: userID, ImageURL: otherImageURL},
+			{ID: 7, Type: models.ImageUnlikedEvent, UserID: userID, ImageURL: successImageURL},
+		}, published(sub), "the events are numbered up to the version after the batch")
+		userBuilder.AssertExpectations(t)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("only invalid operations - does not touch the repository", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		results, _, err := service.ApplyBatch(userID, []models.LikeOperation{{Action: models.LikeAction, ImageURL: ""}}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, e.EmptyImageURL, results[0].Code)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("too many operations - returns validation error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		_, _, err := service.ApplyBatch(userID, make([]models.LikeOperation, s.MaxBatchOperations+1), nil)
+
+		var validationErr *e.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "max_items", validationErr.Violations[0].Rule)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("user not found - returns error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithErrorFindByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		_, _, err := service.ApplyBatch(userID, nil, nil)
+
+		assert.Error(t, err)
+		userBuilder.AssertExpectations(t)
+	})
+}
+
+func TestImportLikedImages(t *testing.T) {
+	t.Run("successful import - likes every new image", func(t *testing.T) {
+		initialLikedImages := map[string][]string{
+			userID: {successImageURL},
+		}
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder().WithInitialLikedImages(initialLikedImages).WithApplyLikeOperations(userID).WithGetLikesVersion(userID, models.LikesVersion{Version: 2})
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		results, _, err := service.ImportLikedImages(userID, []string{successImageURL, "https://example.com/new.gif"}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, e.ImageAlreadyLiked, results[0].Code)
+		assert.True(t, results[1].Success)
+		assert.Equal(t, models.LikeAction, results[1].Action)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+
+	t.Run("too many images - returns validation error", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		likedImagesBuilder := testing_mocks.NewLikedImagesMockBuilder()
+		service := newLikedImagesService(likedImagesBuilder, userBuilder)
+
+		_, _, err := service.ImportLikedImages(userID, make([]string, s.MaxImportImages+1), nil)
+
+		assert.Error(t, err)
+		likedImagesBuilder.AssertExpectations(t)
+	})
+}
+
+func TestSubscribeLikes(t *testing.T) {
+	const otherImageURL = "https://example.com/other.png"
+
+	// newService returns a service on a memory store, whose broker keeps historySize
+	// events, and a user who liked an image.
+	newService := func(t *testing.T, historySize int) (*s.LikedImagesService, string) {
+		store := memory.NewStore()
+		user, err := memory.NewUserRepository(store).Create(&models.User{Email: "stream@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		service := s.NewLikedImagesService(memory.NewLikedImagesRepository(store), memory.NewUserRepository(store), memory.NewUnitOfWork(store), pubsub.NewBroker(historySize), likesConfig)
+		_, err = service.LikeImage(user.ID, successImageURL, nil)
+		require.NoError(t, err)
+		return service, user.ID
+	}
+	ptr := func(id int64) *int64 { return &id }
+
+	t.Run("new subscriber - receives the events from the current version", func(t *testing.T) {
+		service, userID := newService(t, 10)
+
+		stream, err := service.SubscribeLikes(userID, nil)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		assert.Equal(t, int64(1), stream.Version)
+		assert.Equal(t, int64(1), stream.LastEventID)
+		assert.Empty(t, stream.Replay)
+		assert.False(t, stream.Reset)
+
+		_, err = service.UnlikeImage(userID, successImageURL, nil)
+		require.NoError(t, err)
+		events := published(stream.Subscription)
+		require.Len(t, events, 1)
+		assert.Equal(t, int64(2), events[0].ID)
+		assert.Equal(t, models.ImageUnlikedEvent, events[0].Type)
+	})
+
+	t.Run("resuming subscriber - replays the missed events", func(t *testing.T) {
+		service, userID := newService(t, 10)
+		_, err := service.LikeImage(userID, otherImageURL, nil)
+		require.NoError(t, err)
+
+		stream, err := service.SubscribeLikes(userID, ptr(1))
+		require.NoError(t, err)
+		defer stream.Close()
+
+		assert.False(t, stream.Reset)
+		require.Len(t, stream.Replay, 1)
+		assert.Equal(t, int64(2), stream.Replay[0].ID)
+		assert.Equal(t, otherImageURL, stream.Replay[0].ImageURL)
+		assert.Equal(t, int64(2), stream.LastEventID)
+	})
+
+	t.Run("up to date subscriber - replays nothing", func(t *testing.T) {
+		service, userID := newService(t, 10)
+
+		stream, err := service.SubscribeLikes(userID, ptr(1))
+		require.NoError(t, err)
+		defer stream.Close()
+
+		assert.False(t, stream.Reset)
+		assert.Empty(t, stream.Replay)
+	})
+
+	t.Run("missed events no longer in the history - resets", func(t *testing.T) {
+		service, userID := newService(t, 1)
+		_, err := service.LikeImage(userID, otherImageURL, nil)
+		require.NoError(t, err)
+
+		stream, err := service.SubscribeLikes(userID, ptr(0))
+		require.NoError(t, err)
+		defer stream.Close()
+
+		assert.True(t, stream.Reset)
+		assert.Empty(t, stream.Replay)
+		assert.Equal(t, int64(2), stream.LastEventID)
+	})
+
+	t.Run("user not found - returns error", func(t *testing.T) {
+		service, _ := newService(t, 10)
+
+		_, err := service.SubscribeLikes("unknown", nil)
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.UserNotFound, "", nil))
+	})
+}
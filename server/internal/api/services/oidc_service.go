@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"server/config"
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// oidcStateTTL bounds how long a user has to complete the login at the provider.
+const oidcStateTTL = 10 * time.Minute
+
+const oidcStateTokenType = "oidc_state"
+
+type OIDCService interface {
+	Providers() []string
+	BeginLogin(ctx context.Context, provider string) (authURL string, stateToken string, err error)
+	CompleteLogin(ctx context.Context, provider, code, state, stateToken string, client models.ClientInfo) (models.LoginUserResponse, error)
+}
+
+type oidcService struct {
+	providers    map[string]*oidcProvider
+	userRepo     repositories.UserRepository
+	identityRepo repositories.IdentityRepository
+	mfaRepo      repositories.MFARepository
+	sessionRepo  repositories.SessionRepository
+	keys         *utils.KeyRing
+}
+
+// oidcProvider wraps a configured provider. Discovery happens on first use so the
+// server can start while a provider is unreachable.
+type oidcProvider struct {
+	cfg      config.OIDCProviderConfig
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// oidcStateClaims is the payload of the signed state cookie that ties the
+// provider callback to the browser that started the login.
+type oidcStateClaims struct {
+	Type     string `json:"typ"`
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// idTokenClaims are the ID token claims used to find or create the local user.
+type idTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// NewOIDCService creates a new instance of OIDCService for the configured providers.
+//
+// Parameters:
+//   - providers: The OpenID Connect providers users can sign in with.
+//   - userRepo: An implementation of the UserRepository interface.
+//   - identityRepo: An implementation of the IdentityRepository interface.
+//   - mfaRepo: An implementation of the MFARepository interface.
+//   - sessionRepo: An implementation of the SessionRepository interface.
+//   - keys: The KeyRing used to sign the login state.
+//
+// Returns:
+//   - OIDCService: An instance of the OIDCService interface.
+func NewOIDCService(providers []config.OIDCProviderConfig, userRepo repositories.UserRepository, identityRepo repositories.IdentityRepository, mfaRepo repositories.MFARepository, sessionRepo repositories.SessionRepository, keys *utils.KeyRing) OIDCService {
+	s := &oidcService{
+		providers:    make(map[string]*oidcProvider, len(providers)),
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		mfaRepo:      mfaRepo,
+		sessionRepo:  sessionRepo,
+		keys:         keys,
+	}
+	for _, p := range providers {
+		s.providers[p.Name] = &oidcProvider{cfg: p}
+	}
+	return s
+}
+
+// Providers returns the names of the configured providers in alphabetical order.
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginLogin starts an authorization code flow with PKCE for the given provider.
+//
+// Parameters:
+//   - ctx: The request context, used for provider discovery.
+//   - provider: The name of the configured provider.
+//
+// Returns:
+//   - string: The provider URL the user must be redirected to.
+//   - string: A signed state token the caller must hand back to CompleteLogin,
+//     typically through an HttpOnly cookie.
+//   - error: An error if the provider is unknown or unreachable.
+func (s *oidcService) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", e.NewError(e.UserErr, e.UnknownOIDCProvider, "unknown identity provider", nil)
+	}
+
+	conf, _, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", "", e.NewError(e.InternalErr, e.ExternalAPIError, "identity provider unavailable", err)
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", e.NewError(e.InternalErr, e.JWTError, "failed to start login", err)
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", e.NewError(e.InternalErr, e.JWTError, "failed to start login", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	now := time.Now()
+	stateToken, err := s.keys.Sign(oidcStateClaims{
+		Type:     oidcStateTokenType,
+		Provider: provider,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+		},
+	})
+	if err != nil {
+		return "", "", e.NewError(e.InternalErr, e.JWTError, "failed to start login", err)
+	}
+
+	authURL := conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce))
+
+	return authURL, stateToken, nil
+}
+
+// CompleteLogin finishes the authorization code flow started by BeginLogin.
+//
+// The code is exchanged with the PKCE verifier, the ID token is verified, and the
+// external identity is resolved to a local user:
+//   - an identity that was seen before signs in its linked user,
+//   - otherwise a user with the same email is linked, provided the provider verified the email,
+//   - otherwise a new user without a password is created.
+//
+// As with a password login, a user who enabled two-factor authentication gets an MFA
+// challenge token, to be exchanged with VerifyMFALogin, instead of a JWT.
+//
+// Parameters:
+//   - ctx: The request context.
+//   - provider: The name of the provider from the callback URL.
+//   - code: The authorization code returned by the provider.
+//   - state: The state returned by the provider.
+//   - stateToken: The token returned by BeginLogin.
+//   - client: The user agent and IP address recorded on the session.
+//
+// Returns:
+//   - models.LoginUserResponse: Our JWT, or an MFA challenge token, and the ID of the
+//     signed in user.
+//   - error: An error if any step of the flow fails.
+func (s *oidcService) CompleteLogin(ctx context.Context, provider, code, state, stateToken string, client models.ClientInfo) (models.LoginUserResponse, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return models.LoginUserResponse{}, e.NewError(e.UserErr, e.UnknownOIDCProvider, "unknown identity provider", nil)
+	}
+
+	claims, err := s.verifyState(provider, state, stateToken)
+	if err != nil {
+		return models.LoginUserResponse{}, err
+	}
+
+	conf, oidcProvider, err := p.oauth2Config(ctx)
+	if err != nil {
+		return models.LoginUserResponse{}, e.NewError(e.InternalErr, e.ExternalAPIError, "identity provider unavailable", err)
+	}
+
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(claims.Verifier))
+	if err != nil {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.OIDCExchangeFailed, "failed to exchange authorization code", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.OIDCExchangeFailed, "identity provider did not return an ID token", nil)
+	}
+
+	idToken, err := oidcProvider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.InvalidToken, "invalid ID token", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(claims.Nonce)) != 1 {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.InvalidToken, "invalid ID token nonce", nil)
+	}
+
+	var profile idTokenClaims
+	if err := idToken.Claims(&profile); err != nil {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.InvalidToken, "invalid ID token claims", err)
+	}
+
+	userID, err := s.resolveUser(provider, idToken.Subject, profile)
+	if err != nil {
+		return models.LoginUserResponse{}, err
+	}
+
+	return completeLogin(s.mfaRepo, s.sessionRepo, userID, client)
+}
+
+// verifyState checks the signed state token against the provider and state of the callback.
+func (s *oidcService) verifyState(provider, state, stateToken string) (*oidcStateClaims, error) {
+	claims := &oidcStateClaims{}
+	_, err := jwt.ParseWithClaims(stateToken, claims, s.keys.Keyfunc,
+		jwt.WithValidMethods(s.keys.ValidMethods()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, e.NewError(e.AuthorizationErr, e.InvalidOIDCState, "invalid or expired login state", err)
+	}
+
+	if claims.Type != oidcStateTokenType ||
+		claims.Provider != provider ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return nil, e.NewError(e.AuthorizationErr, e.InvalidOIDCState, "invalid or expired login state", nil)
+	}
+
+	return claims, nil
+}
+
+// resolveUser returns the ID of the local user for an external identity,
+// linking or creating the user when the identity is new.
+func (s *oidcService) resolveUser(provider, subject string, profile idTokenClaims) (string, error) {
+	identity, err := s.identityRepo.FindIdentity(provider, subject)
+	if err != nil {
+		return "", err
+	}
+	if identity != nil {
+		return identity.UserID, nil
+	}
+
+	if utils.IsEmptyString(profile.Email) {
+		return "", e.NewError(e.UserErr, e.InvalidEmail, "identity provider did not return an email", nil)
+	}
+
+	user, err := s.userRepo.FindByEmail(profile.Email)
+	if err != nil {
+		return "", e.NewError(e.InternalErr, e.DatabaseError, "internal server error", err)
+	}
+
+	var userID string
+	if user != nil {
+		// Linking to an existing account is only safe when the provider vouches
+		// for the email, otherwise anyone could claim someone else's address.
+		if !profile.EmailVerified {
+			return "", e.NewError(e.AuthorizationErr, e.UnverifiedEmail, "email must be verified by the identity provider to link an existing account", nil)
+		}
+		userID = user.ID
+	} else {
+		created, err := s.userRepo.Create(&models.User{
+			Email: profile.Email,
+		})
+		if err != nil {
+			return "", e.NewError(e.InternalErr, e.DatabaseError, "failed to create user", err)
+		}
+		userID = created.ID
+	}
+
+	err = s.identityRepo.CreateIdentity(&models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    profile.Email,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// oauth2Config discovers the provider if needed and returns its OAuth2 configuration.
+func (p *oidcProvider) oauth2Config(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		// The provider keeps the context to refresh its signing keys later on,
+		// so it must outlive the request that triggered discovery.
+		provider, err := oidc.NewProvider(context.WithoutCancel(ctx), p.cfg.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error discovering provider %s: %w", p.cfg.Name, err)
+		}
+		p.provider = provider
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     p.provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}, p.provider, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,219 @@
+package services_test
+
+import (
+	"context"
+	"server/config"
+	s "server/internal/api/services"
+	e "server/internal/errors"
+	testing_mocks "server/internal/testing"
+	"server/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oidcProviderName = "stub"
+
+func newOIDCTestService(t *testing.T, stub *testing_mocks.OIDCProviderStub, userBuilder *testing_mocks.MockBuilder, identityBuilder *testing_mocks.MockIdentityBuilder) (s.OIDCService, *utils.KeyRing) {
+	return newOIDCTestServiceWithSessions(t, stub, userBuilder, identityBuilder, testing_mocks.NewMFAMockBuilder(), testing_mocks.NewSessionMockBuilder())
+}
+
+func newOIDCTestServiceWithSessions(t *testing.T, stub *testing_mocks.OIDCProviderStub, userBuilder *testing_mocks.MockBuilder, identityBuilder *testing_mocks.MockIdentityBuilder, mfaBuilder *testing_mocks.MockMFABuilder, sessionBuilder *testing_mocks.MockSessionBuilder) (s.OIDCService, *utils.KeyRing) {
+	t.Helper()
+	loadTestKeyRing(t)
+
+	keys, err := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+	require.NoError(t, err)
+
+	providers := []config.OIDCProviderConfig{{
+		Name:         oidcProviderName,
+		IssuerURL:    stub.Issuer(),
+		ClientID:     stub.ClientID,
+		ClientSecret: stub.ClientSecret,
+		RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/stub/callback",
+		Scopes:       []string{"openid", "email"},
+	}}
+
+	return s.NewOIDCService(providers, userBuilder.Build(), identityBuilder.Build(), mfaBuilder.Build(), sessionBuilder.Build(), keys), keys
+}
+
+// runOIDCLogin drives a full login against the stub provider.
+func runOIDCLogin(t *testing.T, service s.OIDCService, stub *testing_mocks.OIDCProviderStub) (string, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, stateToken, err := service.BeginLogin(ctx, oidcProviderName)
+	require.NoError(t, err)
+
+	code, state, err := stub.Authorize(authURL)
+	require.NoError(t, err)
+
+	res, err := service.CompleteLogin(ctx, oidcProviderName, code, state, stateToken, client)
+	return res.ID, err
+}
+
+func TestOIDCLogin(t *testing.T) {
+	stub := testing_mocks.NewOIDCProviderStub("client-id", "client-secret")
+	defer stub.Close()
+
+	t.Run("new identity creates a user", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithSuccessfulUserNotFound(stub.Email).WithSuccessfulCreate()
+		identityBuilder := testing_mocks.NewIdentityMockBuilder().
+			WithIdentityNotFound(oidcProviderName, stub.Subject).
+			WithCreateIdentity(oidcProviderName, stub.Subject, "1")
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFADisabled("1")
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("1")
+		service, _ := newOIDCTestServiceWithSessions(t, stub, userBuilder, identityBuilder, mfaBuilder, sessionBuilder)
+
+		userID, err := runOIDCLogin(t, service, stub)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1", userID)
+		userBuilder.AssertExpectations(t)
+		identityBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("known identity signs in the linked user", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder()
+		identityBuilder := testing_mocks.NewIdentityMockBuilder().WithIdentityFound(oidcProviderName, stub.Subject, "42")
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFADisabled("42")
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("42")
+		service, _ := newOIDCTestServiceWithSessions(t, stub, userBuilder, identityBuilder, mfaBuilder, sessionBuilder)
+
+		userID, err := runOIDCLogin(t, service, stub)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "42", userID)
+		userBuilder.AssertExpectations(t)
+		identityBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("user with 2FA gets a challenge instead of a JWT", func(t *testing.T) {
+		ctx := context.Background()
+		identityBuilder := testing_mocks.NewIdentityMockBuilder().WithIdentityFound(oidcProviderName, stub.Subject, "42")
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("42", mfaSecret, true)
+		sessionBuilder := testing_mocks.NewSessionMockBuilder()
+		service, _ := newOIDCTestServiceWithSessions(t, stub, testing_mocks.NewMockBuilder(), identityBuilder, mfaBuilder, sessionBuilder)
+
+		authURL, stateToken, err := service.BeginLogin(ctx, oidcProviderName)
+		require.NoError(t, err)
+		code, state, err := stub.Authorize(authURL)
+		require.NoError(t, err)
+		res, err := service.CompleteLogin(ctx, oidcProviderName, code, state, stateToken, client)
+
+		require.NoError(t, err)
+		assert.Empty(t, res.Token)
+		assert.True(t, res.MFARequired)
+		userID, err := utils.ParseMFAChallengeJWT(res.MFAToken)
+		require.NoError(t, err)
+		assert.Equal(t, "42", userID)
+		mfaBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("verified email is linked to the existing account", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByEmail(stub.Email)
+		identityBuilder := testing_mocks.NewIdentityMockBuilder().
+			WithIdentityNotFound(oidcProviderName, stub.Subject).
+			WithCreateIdentity(oidcProviderName, stub.Subject, "1")
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFADisabled("1")
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("1")
+		service, _ := newOIDCTestServiceWithSessions(t, stub, userBuilder, identityBuilder, mfaBuilder, sessionBuilder)
+
+		userID, err := runOIDCLogin(t, service, stub)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1", userID)
+		userBuilder.AssertExpectations(t)
+		identityBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("unverified email is not linked", func(t *testing.T) {
+		stub.EmailVerified = false
+		defer func() { stub.EmailVerified = true }()
+
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByEmail(stub.Email)
+		identityBuilder := testing_mocks.NewIdentityMockBuilder().WithIdentityNotFound(oidcProviderName, stub.Subject)
+		service, _ := newOIDCTestService(t, stub, userBuilder, identityBuilder)
+
+		_, err := runOIDCLogin(t, service, stub)
+
+		assert.Error(t, err)
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.UnverifiedEmail, err.(*e.AuthError).Code())
+		userBuilder.AssertExpectations(t)
+		identityBuilder.AssertExpectations(t)
+	})
+}
+
+func TestOIDCLoginState(t *testing.T) {
+	stub := testing_mocks.NewOIDCProviderStub("client-id", "client-secret")
+	defer stub.Close()
+	ctx := context.Background()
+
+	t.Run("unknown provider", func(t *testing.T) {
+		service, _ := newOIDCTestService(t, stub, testing_mocks.NewMockBuilder(), testing_mocks.NewIdentityMockBuilder())
+
+		_, _, err := service.BeginLogin(ctx, "unknown")
+
+		assert.IsType(t, &e.UserError{}, err)
+		assert.Equal(t, e.UnknownOIDCProvider, err.(*e.UserError).Code())
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		service, _ := newOIDCTestService(t, stub, testing_mocks.NewMockBuilder(), testing_mocks.NewIdentityMockBuilder())
+
+		authURL, stateToken, err := service.BeginLogin(ctx, oidcProviderName)
+		require.NoError(t, err)
+		code, _, err := stub.Authorize(authURL)
+		require.NoError(t, err)
+
+		_, err = service.CompleteLogin(ctx, oidcProviderName, code, "forged-state", stateToken, client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.InvalidOIDCState, err.(*e.AuthError).Code())
+	})
+
+	t.Run("expired state token", func(t *testing.T) {
+		service, keys := newOIDCTestService(t, stub, testing_mocks.NewMockBuilder(), testing_mocks.NewIdentityMockBuilder())
+
+		stateToken, err := keys.Sign(jwt.MapClaims{
+			"typ":      "oidc_state",
+			"provider": oidcProviderName,
+			"state":    "state",
+			"exp":      time.Now().Add(-time.Minute).Unix(),
+		})
+		require.NoError(t, err)
+
+		_, err = service.CompleteLogin(ctx, oidcProviderName, "code", "state", stateToken, client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.InvalidOIDCState, err.(*e.AuthError).Code())
+	})
+
+	t.Run("PKCE verifier from another login is rejected", func(t *testing.T) {
+		service, _ := newOIDCTestService(t, stub, testing_mocks.NewMockBuilder(), testing_mocks.NewIdentityMockBuilder())
+
+		authURL, _, err := service.BeginLogin(ctx, oidcProviderName)
+		require.NoError(t, err)
+		_, otherStateToken, err := service.BeginLogin(ctx, oidcProviderName)
+		require.NoError(t, err)
+		code, _, err := stub.Authorize(authURL)
+		require.NoError(t, err)
+
+		otherState, _, err := jwt.NewParser().ParseUnverified(otherStateToken, jwt.MapClaims{})
+		require.NoError(t, err)
+		state := otherState.Claims.(jwt.MapClaims)["state"].(string)
+
+		_, err = service.CompleteLogin(ctx, oidcProviderName, code, state, otherStateToken, client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.OIDCExchangeFailed, err.(*e.AuthError).Code())
+	})
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"log"
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval bounds how often the last seen time of a session is written.
+const sessionTouchInterval = time.Minute
+
+type SessionService interface {
+	List(userID, currentSessionID string) ([]models.SessionResponse, error)
+	Revoke(userID, sessionID string) error
+	ValidateSession(userID, sessionID string) error
+	StartSession(userID string, client models.ClientInfo) (string, error)
+	PurgeSessions() (int64, error)
+}
+
+type sessionService struct {
+	r repositories.SessionRepository
+}
+
+// NewSessionService creates a new instance of SessionService using the provided repository.
+//
+// Parameters:
+//   - r: An implementation of the SessionRepository interface.
+//
+// Returns:
+//   - SessionService: An instance of the SessionService interface.
+func NewSessionService(r repositories.SessionRepository) SessionService {
+	return &sessionService{r}
+}
+
+// List returns the active sessions of a user, most recently seen first.
+// Sessions not seen for longer than a JWT lives cannot hold a valid token anymore and are left out.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - currentSessionID: The session of the request, flagged as current in the result.
+//
+// Returns:
+//   - []models.SessionResponse: The active sessions.
+//   - error: An error if the sessions could not be retrieved.
+func (s *sessionService) List(userID, currentSessionID string) ([]models.SessionResponse, error) {
+	sessions, err := s.r.ListActive(userID, time.Now().Add(-utils.JWTLifetime))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		res[i] = models.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		}
+	}
+	return res, nil
+}
+
+// Revoke revokes a session of a user. Tokens of the session are rejected from then on.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - sessionID: The ID of the session to revoke.
+//
+// Returns:
+//   - error: An error if the user has no such active session.
+func (s *sessionService) Revoke(userID, sessionID string) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return e.NewError(e.UserErr, e.SessionNotFound, "session not found", nil)
+	}
+
+	ok, err := s.r.Revoke(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return e.NewError(e.UserErr, e.SessionNotFound, "session not found", nil)
+	}
+	return nil
+}
+
+// ValidateSession checks that a token's session belongs to the user and was not revoked,
+// and records the session as seen.
+//
+// Parameters:
+//   - userID: The subject of the token.
+//   - sessionID: The "sid" claim of the token.
+//
+// Returns:
+//   - error: An AuthError if the session is unknown or revoked.
+func (s *sessionService) ValidateSession(userID, sessionID string) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return e.NewError(e.AuthorizationErr, e.SessionRevoked, "session revoked", nil)
+	}
+
+	session, err := s.r.Find(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.RevokedAt != nil || session.UserID != userID {
+		return e.NewError(e.AuthorizationErr, e.SessionRevoked, "session revoked", nil)
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.r.Touch(sessionID); err != nil {
+			log.Printf("failed to update session %s: %v", sessionID, err)
+		}
+	}
+	return nil
+}
+
+// StartSession records a new session for a user and returns its ID, for the tokens issued
+// before sessions existed to be bound to one when refreshed.
+//
+// Parameters:
+//   - userID: The subject of the token.
+//   - client: The client the token is used from.
+//
+// Returns:
+//   - string: The ID of the new session.
+//   - error: An error if the session could not be stored.
+func (s *sessionService) StartSession(userID string, client models.ClientInfo) (string, error) {
+	return createSession(s.r, userID, client)
+}
+
+// PurgeSessions deletes the sessions that can no longer hold a valid token, revoked or
+// not seen for longer than a JWT lives, and returns how many there were. Tokens of a
+// deleted session are rejected like those of a revoked one.
+func (s *sessionService) PurgeSessions() (int64, error) {
+	return s.r.Purge(time.Now().Add(-utils.JWTLifetime))
+}
+
+// startSession records a new session for a user logging in and returns a JWT bound to it.
+func startSession(r repositories.SessionRepository, userID string, client models.ClientInfo) (string, error) {
+	sessionID, err := createSession(r, userID, client)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := utils.GenerateJWT(userID, sessionID)
+	if err != nil {
+		return "", e.NewError(e.InternalErr, e.JWTError, "internal error authenticating user", err)
+	}
+	return token, nil
+}
+
+// createSession stores a new session of a user started from client and returns its ID.
+func createSession(r repositories.SessionRepository, userID string, client models.ClientInfo) (string, error) {
+	return r.Create(&models.Session{
+		UserID:    userID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+	})
+}
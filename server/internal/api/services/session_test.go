@@ -0,0 +1,99 @@
+package services_test
+
+import (
+	s "server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/models"
+	testing_mocks "server/internal/testing"
+	"server/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSessions(t *testing.T) {
+	now := time.Now()
+	sessionBuilder := testing_mocks.NewSessionMockBuilder().WithActiveSessions("1", []models.Session{
+		{ID: testing_mocks.SessionID, UserID: "1", UserAgent: "firefox", IPAddress: "10.0.0.1", CreatedAt: now, LastSeenAt: now},
+		{ID: "other", UserID: "1", UserAgent: "curl", IPAddress: "10.0.0.2", CreatedAt: now, LastSeenAt: now},
+	})
+	service := s.NewSessionService(sessionBuilder.Build())
+
+	sessions, err := service.List("1", testing_mocks.SessionID)
+
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.True(t, sessions[0].Current)
+	assert.Equal(t, "firefox", sessions[0].UserAgent)
+	assert.False(t, sessions[1].Current)
+	sessionBuilder.AssertExpectations(t)
+}
+
+func TestRevokeSession(t *testing.T) {
+	t.Run("active session is revoked", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithRevoke("1", testing_mocks.SessionID, true)
+		service := s.NewSessionService(sessionBuilder.Build())
+
+		err := service.Revoke("1", testing_mocks.SessionID)
+
+		assert.NoError(t, err)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithRevoke("1", testing_mocks.SessionID, false)
+		service := s.NewSessionService(sessionBuilder.Build())
+
+		err := service.Revoke("1", testing_mocks.SessionID)
+
+		assert.IsType(t, &e.UserError{}, err)
+		assert.Equal(t, e.SessionNotFound, err.(*e.UserError).Code())
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("malformed session ID", func(t *testing.T) {
+		service := s.NewSessionService(testing_mocks.NewSessionMockBuilder().Build())
+
+		err := service.Revoke("1", "not-a-uuid")
+
+		assert.IsType(t, &e.UserError{}, err)
+		assert.Equal(t, e.SessionNotFound, err.(*e.UserError).Code())
+	})
+}
+
+func TestValidateSession(t *testing.T) {
+	t.Run("unknown session", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithSessionNotFound(testing_mocks.SessionID)
+		service := s.NewSessionService(sessionBuilder.Build())
+
+		err := service.ValidateSession("1", testing_mocks.SessionID)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.SessionRevoked, err.(*e.AuthError).Code())
+	})
+
+	t.Run("recently seen session is not touched", func(t *testing.T) {
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithSession("1", time.Now())
+		service := s.NewSessionService(sessionBuilder.Build())
+
+		err := service.ValidateSession("1", testing_mocks.SessionID)
+
+		assert.NoError(t, err)
+		sessionBuilder.Build().AssertNotCalled(t, "Touch", testing_mocks.SessionID)
+	})
+}
+
+func TestPurgeSessions(t *testing.T) {
+	sessionBuilder := testing_mocks.NewSessionMockBuilder().WithPurge(2)
+	service := s.NewSessionService(sessionBuilder.Build())
+
+	start := time.Now()
+	purged, err := service.PurgeSessions()
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	before := sessionBuilder.Build().Calls[0].Arguments.Get(0).(time.Time)
+	assert.WithinRange(t, before, start.Add(-utils.JWTLifetime), time.Now().Add(-utils.JWTLifetime))
+	sessionBuilder.AssertExpectations(t)
+}
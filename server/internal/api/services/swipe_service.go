@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"server/config"
+	e "server/internal/errors"
+	"server/internal/models"
+	"time"
+)
+
+const (
+	// swipeInitialBackoff is the wait before fetching again after the Dog API failed,
+	// doubled on every failure in a row up to swipeMaxBackoff.
+	swipeInitialBackoff = time.Second
+	swipeMaxBackoff     = 30 * time.Second
+)
+
+// SwipeService runs swipe sessions, offering random images to be liked or skipped one
+// after another.
+type SwipeService struct {
+	dogService         *DogService
+	likedImagesService *LikedImagesService
+	cfg                config.SwipeConfig
+}
+
+func NewSwipeService(dogService *DogService, likedImagesService *LikedImagesService, cfg config.SwipeConfig) *SwipeService {
+	return &SwipeService{
+		dogService:         dogService,
+		likedImagesService: likedImagesService,
+		cfg:                cfg,
+	}
+}
+
+// SwipeFetch is an image fetched by a session, or the error fetching it.
+type SwipeFetch struct {
+	Image models.SwipeImage
+	Err   error
+}
+
+// SwipeSession is the session of a client. It fetches images ahead in the background
+// and keeps the images offered to the client until it likes or skips them. Only Close
+// may be called concurrently with the other methods.
+type SwipeSession struct {
+	service *SwipeService
+	userID  string
+	window  int
+	fetched chan SwipeFetch
+	offered map[string]int
+	pending int
+	cancel  context.CancelFunc
+}
+
+// Start starts a session for a user. It must be closed when the client leaves.
+func (s *SwipeService) Start(userID string) *SwipeSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &SwipeSession{
+		service: s,
+		userID:  userID,
+		window:  max(s.cfg.Window, 1),
+		// The prefetching goroutine holds one more image while the buffer is full.
+		fetched: make(chan SwipeFetch, max(s.cfg.Prefetch-1, 0)),
+		offered: make(map[string]int),
+		cancel:  cancel,
+	}
+	go session.prefetch(ctx)
+	return session
+}
+
+// prefetch fetches images until ctx is done, blocking while the buffer is full so a
+// client that stops swiping stops the calls to the Dog API too.
+func (ss *SwipeSession) prefetch(ctx context.Context) {
+	backoff := swipeInitialBackoff
+	for {
+		imageURL, liked, err := ss.service.dogService.GetRandomImageAndCheckLike(ss.userID)
+		select {
+		case ss.fetched <- SwipeFetch{Image: models.SwipeImage{ImageURL: imageURL, Liked: liked}, Err: err}:
+		case <-ctx.Done():
+			return
+		}
+
+		if err == nil {
+			backoff = swipeInitialBackoff
+			continue
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(2*backoff, swipeMaxBackoff)
+	}
+}
+
+// Next returns the channel of the fetched images, or nil while the window of images
+// offered without an action is full, so the next image waits for the client.
+func (ss *SwipeSession) Next() <-chan SwipeFetch {
+	if ss.pending >= ss.window {
+		return nil
+	}
+	return ss.fetched
+}
+
+// Offer records an image as sent to the client, awaiting an action.
+func (ss *SwipeSession) Offer(image models.SwipeImage) {
+	ss.offered[image.ImageURL]++
+	ss.pending++
+}
+
+// Act likes or skips an image offered to the client, making room for the next one.
+//
+// Parameters:
+//   - action: The action of the client.
+//
+// Returns:
+//   - models.LikeOperationResult: The result of the action; a like failing, e.g.
+//     because the image is already liked, is a failed result.
+//   - error: An error if the action is unknown or the image was not offered.
+func (ss *SwipeSession) Act(action models.SwipeAction) (models.LikeOperationResult, error) {
+	if action.Action != models.SwipeLike && action.Action != models.SwipeSkip {
+		return models.LikeOperationResult{}, e.NewError(e.UserErr, e.InvalidRequest, "action must be like or skip", nil)
+	}
+	if ss.offered[action.ImageURL] == 0 {
+		return models.LikeOperationResult{}, e.NewError(e.UserErr, e.ImageNotOffered, "image was not offered in this session", nil)
+	}
+
+	ss.offered[action.ImageURL]--
+	if ss.offered[action.ImageURL] == 0 {
+		delete(ss.offered, action.ImageURL)
+	}
+	ss.pending--
+
+	if action.Action == models.SwipeLike {
+		if _, err := ss.service.likedImagesService.LikeImage(ss.userID, action.ImageURL, nil); err != nil {
+			return failedOperation(models.LikeOperation{Action: action.Action, ImageURL: action.ImageURL}, err), nil
+		}
+	}
+	return models.LikeOperationResult{Action: action.Action, ImageURL: action.ImageURL, Success: true}, nil
+}
+
+// Close stops fetching images.
+func (ss *SwipeSession) Close() {
+	ss.cancel()
+}
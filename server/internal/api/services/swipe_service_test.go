@@ -0,0 +1,149 @@
+package services_test
+
+import (
+	"errors"
+	"server/config"
+	"server/internal/api/repositories/memory"
+	s "server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/pubsub"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var swipeConfig = config.SwipeConfig{Prefetch: 2, Window: 2}
+
+// failingDogRepository is a Dog API that is down.
+type failingDogRepository struct{}
+
+func (failingDogRepository) GetRandomPicture() (string, error) {
+	return "", errors.New("connection refused")
+}
+
+// newSwipeService returns a service on a memory store serving pictures, and a user
+// who liked the liked pictures.
+func newSwipeService(t *testing.T, pictures []string, liked ...string) (*s.SwipeService, string) {
+	t.Helper()
+	store := memory.NewStore()
+	user, err := memory.NewUserRepository(store).Create(&models.User{Email: "swipe@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	likedImagesRepo := memory.NewLikedImagesRepository(store)
+	for _, picture := range liked {
+		require.NoError(t, likedImagesRepo.AddLikedImage(user.ID, picture))
+	}
+
+	likes := s.NewLikedImagesService(likedImagesRepo, memory.NewUserRepository(store), memory.NewUnitOfWork(store), pubsub.NewBroker(0), likesConfig)
+	dogs := s.NewDogService(memory.NewDogRepository(pictures), likedImagesRepo)
+	return s.NewSwipeService(dogs, likes, swipeConfig), user.ID
+}
+
+// nextImage offers the next fetched image of session.
+func nextImage(t *testing.T, session *s.SwipeSession) models.SwipeImage {
+	t.Helper()
+	select {
+	case fetch := <-session.Next():
+		require.NoError(t, fetch.Err)
+		session.Offer(fetch.Image)
+		return fetch.Image
+	case <-time.After(time.Second):
+		require.FailNow(t, "no image fetched")
+		return models.SwipeImage{}
+	}
+}
+
+func TestSwipeSession(t *testing.T) {
+	pictures := memory.DogPictures[:2]
+
+	t.Run("next - fetches images with their like status", func(t *testing.T) {
+		service, userID := newSwipeService(t, pictures[:1], pictures[0])
+		session := service.Start(userID)
+		defer session.Close()
+
+		image := nextImage(t, session)
+
+		assert.Equal(t, models.SwipeImage{ImageURL: pictures[0], Liked: true}, image)
+	})
+
+	t.Run("next - waits for an action once the window is full", func(t *testing.T) {
+		service, userID := newSwipeService(t, pictures)
+		session := service.Start(userID)
+		defer session.Close()
+
+		first := nextImage(t, session)
+		nextImage(t, session)
+		assert.Nil(t, session.Next())
+
+		_, err := session.Act(models.SwipeAction{Action: models.SwipeSkip, ImageURL: first.ImageURL})
+		require.NoError(t, err)
+		assert.NotNil(t, session.Next())
+	})
+
+	t.Run("like - likes the offered image", func(t *testing.T) {
+		service, userID := newSwipeService(t, pictures[:1])
+		session := service.Start(userID)
+		defer session.Close()
+		image := nextImage(t, session)
+		assert.False(t, image.Liked)
+
+		result, err := session.Act(models.SwipeAction{Action: models.SwipeLike, ImageURL: image.ImageURL})
+
+		require.NoError(t, err)
+		assert.Equal(t, models.LikeOperationResult{Action: models.SwipeLike, ImageURL: pictures[0], Success: true}, result)
+	})
+
+	t.Run("like - already liked image is a failed result", func(t *testing.T) {
+		service, userID := newSwipeService(t, pictures[:1], pictures[0])
+		session := service.Start(userID)
+		defer session.Close()
+		image := nextImage(t, session)
+
+		result, err := session.Act(models.SwipeAction{Action: models.SwipeLike, ImageURL: image.ImageURL})
+
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, e.ImageAlreadyLiked, result.Code)
+	})
+
+	t.Run("act - rejects images not offered", func(t *testing.T) {
+		service, userID := newSwipeService(t, pictures[:1])
+		session := service.Start(userID)
+		defer session.Close()
+		image := nextImage(t, session)
+
+		_, err := session.Act(models.SwipeAction{Action: models.SwipeLike, ImageURL: "https://example.com/other.jpg"})
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.ImageNotOffered, "", nil))
+
+		_, err = session.Act(models.SwipeAction{Action: models.SwipeSkip, ImageURL: image.ImageURL})
+		require.NoError(t, err)
+		_, err = session.Act(models.SwipeAction{Action: models.SwipeSkip, ImageURL: image.ImageURL})
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.ImageNotOffered, "", nil), "an image is acted on once")
+	})
+
+	t.Run("act - rejects unknown actions", func(t *testing.T) {
+		service, userID := newSwipeService(t, pictures[:1])
+		session := service.Start(userID)
+		defer session.Close()
+		image := nextImage(t, session)
+
+		_, err := session.Act(models.SwipeAction{Action: models.UnlikeAction, ImageURL: image.ImageURL})
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.InvalidRequest, "", nil))
+	})
+
+	t.Run("Dog API down - fetches the error", func(t *testing.T) {
+		service := s.NewSwipeService(s.NewDogService(failingDogRepository{}, nil), nil, swipeConfig)
+		session := service.Start(userID)
+		defer session.Close()
+
+		select {
+		case fetch := <-session.Next():
+			assert.ErrorIs(t, fetch.Err, e.NewError(e.InternalErr, e.ExternalAPIError, "", nil))
+		case <-time.After(time.Second):
+			assert.Fail(t, "no error fetched")
+		}
+	})
+}
@@ -1,34 +1,43 @@
 package services
 
 import (
+	"log"
 	"server/internal/api/repositories"
 	e "server/internal/errors"
 	"server/internal/models"
 	"server/internal/utils"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
 	Register(email, password string) (models.CreateUserResponse, error)
-	Login(email, password string) (models.LoginUserResponse, error)
+	Login(email, password string, client models.ClientInfo) (models.LoginUserResponse, error)
 	GetUserByID(id string) (*models.User, error)
+	VerifyMFALogin(mfaToken, code string, client models.ClientInfo) (models.LoginUserResponse, error)
+	EnrollMFA(userID string) (models.MFAEnrollmentResponse, error)
+	ConfirmMFA(userID, code string) ([]string, error)
+	DisableMFA(userID, password, code string) error
 }
 
 type userService struct {
-	r repositories.UserRepository
+	r        repositories.UserRepository
+	mfa      repositories.MFARepository
+	sessions repositories.SessionRepository
+	hasher   utils.PasswordHasher
 }
 
-// NewUserService creates a new instance of UserService using the provided UserRepository.
+// NewUserService creates a new instance of UserService using the provided repositories.
 // It returns a UserService interface which can be used to interact with user-related operations.
 //
 // Parameters:
 //   - r: An implementation of the UserRepository interface.
+//   - mfa: An implementation of the MFARepository interface.
+//   - sessions: An implementation of the SessionRepository interface.
+//   - hasher: The PasswordHasher used to hash and verify passwords.
 //
 // Returns:
 //   - UserService: An instance of the UserService interface.
-func NewUserService(r repositories.UserRepository) UserService {
-	return &userService{r}
+func NewUserService(r repositories.UserRepository, mfa repositories.MFARepository, sessions repositories.SessionRepository, hasher utils.PasswordHasher) UserService {
+	return &userService{r, mfa, sessions, hasher}
 }
 
 // Register registers a new user with the given email and password.
@@ -56,14 +65,14 @@ func (s *userService) Register(email, password string) (models.CreateUserRespons
 		}, e.NewError(e.UserErr, e.EmailAlreadyExists, "email already exists", nil)
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return models.CreateUserResponse{}, e.NewError(e.InternalErr, e.FailedHash, "failed to hash password", err)
 	}
 
 	createdUser, err := s.r.Create(&models.User{
 		Email:        email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	})
 	if err != nil {
 		return models.CreateUserResponse{}, e.NewError(e.InternalErr, e.DatabaseError, "failed to create user", err)
@@ -72,17 +81,21 @@ func (s *userService) Register(email, password string) (models.CreateUserRespons
 }
 
 // Login authenticates a user by their email and password.
-// It returns a JWT token if the authentication is successful, or an error if it fails.
+// It returns a JWT token bound to a new session if the authentication is successful, or an error if it fails.
+// When the user enabled two-factor authentication, no JWT is issued: the response
+// carries a short-lived MFA challenge token to be exchanged with VerifyMFALogin instead.
+// A password hash using an outdated algorithm or cost is rehashed once the password is verified.
 //
 // Parameters:
 //   - email: The email address of the user.
 //   - password: The password of the user.
+//   - client: The user agent and IP address recorded on the session.
 //
 // Returns:
-//   - string: A JWT token if authentication is successful.
+//   - models.LoginUserResponse: A JWT token, or an MFA challenge token, if authentication is successful.
 //   - error: An error if authentication fails, which could be due to internal server errors,
 //     database errors, user not found, or invalid credentials.
-func (s *userService) Login(email, password string) (models.LoginUserResponse, error) {
+func (s *userService) Login(email, password string, client models.ClientInfo) (models.LoginUserResponse, error) {
 	user, err := s.r.FindByEmail(email)
 	if err != nil {
 		return models.LoginUserResponse{}, e.NewError(e.InternalErr, e.DatabaseError, "internal server error", err)
@@ -91,23 +104,42 @@ func (s *userService) Login(email, password string) (models.LoginUserResponse, e
 		return models.LoginUserResponse{}, e.NewError(e.UserErr, e.UserNotFound, "invalid credentials", err)
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-	if err != nil {
+	if !s.verifyPassword(user, password) {
 		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.InvalidCredentials, "invalid credentials", nil)
 	}
 
-	token, err := utils.GenerateJWT(user.ID)
+	return completeLogin(s.mfa, s.sessions, user.ID, client)
+}
 
+// completeLogin logs in an authenticated user: it returns an MFA challenge token when
+// the user enabled two-factor authentication, and a JWT bound to a new session
+// otherwise. Every way of logging in ends here, so none of them skips the second factor.
+func completeLogin(mfaRepo repositories.MFARepository, sessions repositories.SessionRepository, userID string, client models.ClientInfo) (models.LoginUserResponse, error) {
+	mfa, err := mfaRepo.GetMFA(userID)
 	if err != nil {
-		return models.LoginUserResponse{}, e.NewError(e.InternalErr, e.JWTError, "internal error authenticating user", err)
+		return models.LoginUserResponse{}, err
+	}
+	if mfa != nil && mfa.Enabled {
+		challenge, err := utils.GenerateMFAChallengeJWT(userID)
+		if err != nil {
+			return models.LoginUserResponse{}, e.NewError(e.InternalErr, e.JWTError, "internal error authenticating user", err)
+		}
+		return models.LoginUserResponse{
+			ID:          userID,
+			MFARequired: true,
+			MFAToken:    challenge,
+		}, nil
 	}
 
-	response := models.LoginUserResponse{
-		Token: token,
-		ID:    user.ID,
+	token, err := startSession(sessions, userID, client)
+	if err != nil {
+		return models.LoginUserResponse{}, err
 	}
 
-	return response, nil
+	return models.LoginUserResponse{
+		Token: token,
+		ID:    userID,
+	}, nil
 }
 
 // GetUserByID retrieves a user from the database by their ID.
@@ -121,3 +153,25 @@ func (s *userService) GetUserByID(id string) (*models.User, error) {
 
 	return user, nil
 }
+
+// verifyPassword reports whether password matches the stored hash of user.
+// When it does and the hash is outdated, the password is rehashed with the current
+// settings. Failing to store the new hash does not fail the login.
+func (s *userService) verifyPassword(user *models.User, password string) bool {
+	ok, err := s.hasher.Verify(user.PasswordHash, password)
+	if err != nil || !ok {
+		return false
+	}
+
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		hash, err := s.hasher.Hash(password)
+		if err == nil {
+			err = s.r.UpdatePasswordHash(user.ID, hash)
+		}
+		if err != nil {
+			log.Printf("failed to rehash password of user %s: %v", user.ID, err)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	maxMFAAttempts    = 5
+	mfaLockout        = 15 * time.Minute
+)
+
+// VerifyMFALogin completes a two-factor login started by Login.
+// The code may be a current TOTP code or an unused recovery code.
+//
+// Parameters:
+//   - mfaToken: The challenge token returned by Login.
+//   - code: A TOTP code or a recovery code.
+//   - client: The user agent and IP address recorded on the session.
+//
+// Returns:
+//   - models.LoginUserResponse: The JWT token and the user ID.
+//   - error: An error if the challenge token or the code is invalid, or the second factor is locked.
+func (s *userService) VerifyMFALogin(mfaToken, code string, client models.ClientInfo) (models.LoginUserResponse, error) {
+	userID, err := utils.ParseMFAChallengeJWT(mfaToken)
+	if err != nil || userID == "" {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.InvalidToken, "invalid or expired two-factor challenge", err)
+	}
+
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return models.LoginUserResponse{}, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return models.LoginUserResponse{}, e.NewError(e.AuthorizationErr, e.InvalidToken, "invalid or expired two-factor challenge", nil)
+	}
+
+	if err := s.verifySecondFactor(mfa, code, true); err != nil {
+		return models.LoginUserResponse{}, err
+	}
+
+	token, err := startSession(s.sessions, userID, client)
+	if err != nil {
+		return models.LoginUserResponse{}, err
+	}
+
+	return models.LoginUserResponse{
+		Token: token,
+		ID:    userID,
+	}, nil
+}
+
+// EnrollMFA generates a new TOTP secret for the user. Two-factor authentication is not
+// enabled until the secret is confirmed with ConfirmMFA; enrolling again before that
+// replaces the pending secret.
+//
+// Parameters:
+//   - userID: The ID of the user enrolling.
+//
+// Returns:
+//   - models.MFAEnrollmentResponse: The secret and the otpauth:// URI to show as a QR code.
+//   - error: An error if the user does not exist or already enabled two-factor authentication.
+func (s *userService) EnrollMFA(userID string) (models.MFAEnrollmentResponse, error) {
+	user, err := s.r.FindByID(userID)
+	if err != nil {
+		return models.MFAEnrollmentResponse{}, e.NewError(e.InternalErr, e.DatabaseError, "internal server error", err)
+	}
+	if user == nil {
+		return models.MFAEnrollmentResponse{}, e.NewError(e.UserErr, e.UserNotFound, "user not found", nil)
+	}
+
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return models.MFAEnrollmentResponse{}, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return models.MFAEnrollmentResponse{}, e.NewError(e.UserErr, e.MFAAlreadyEnabled, "two-factor authentication is already enabled", nil)
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return models.MFAEnrollmentResponse{}, e.NewError(e.InternalErr, e.FailedSecretGeneration, "failed to generate two-factor secret", err)
+	}
+
+	if err := s.mfa.SavePendingMFA(userID, secret); err != nil {
+		return models.MFAEnrollmentResponse{}, err
+	}
+
+	return models.MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(utils.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables two-factor authentication once the user proves the authenticator
+// app was set up by sending its first code.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - code: The current TOTP code.
+//
+// Returns:
+//   - []string: The recovery codes. They are only stored hashed and cannot be retrieved again.
+//   - error: An error if the user has no pending enrollment or the code is invalid.
+func (s *userService) ConfirmMFA(userID, code string) ([]string, error) {
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, e.NewError(e.UserErr, e.MFANotEnrolled, "two-factor authentication enrollment not started", nil)
+	}
+	if mfa.Enabled {
+		return nil, e.NewError(e.UserErr, e.MFAAlreadyEnabled, "two-factor authentication is already enabled", nil)
+	}
+
+	counter, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now(), mfa.LastUsedCounter)
+	if !ok {
+		return nil, e.NewError(e.UserErr, e.InvalidMFACode, "invalid two-factor code", nil)
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, e.NewError(e.InternalErr, e.FailedSecretGeneration, "failed to generate recovery codes", err)
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = utils.HashRecoveryCode(c)
+	}
+
+	if err := s.mfa.EnableMFA(userID, counter, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off. Both the password and a current
+// TOTP code are required so a stolen session alone cannot remove the second factor.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - password: The user's password.
+//   - code: The current TOTP code.
+//
+// Returns:
+//   - error: An error if the password or code is invalid, or two-factor authentication is not enabled.
+func (s *userService) DisableMFA(userID, password, code string) error {
+	user, err := s.r.FindByID(userID)
+	if err != nil {
+		return e.NewError(e.InternalErr, e.DatabaseError, "internal server error", err)
+	}
+	if user == nil {
+		return e.NewError(e.UserErr, e.UserNotFound, "user not found", nil)
+	}
+
+	if !s.verifyPassword(user, password) {
+		return e.NewError(e.AuthorizationErr, e.InvalidCredentials, "invalid credentials", nil)
+	}
+
+	mfa, err := s.mfa.GetMFA(userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return e.NewError(e.UserErr, e.MFANotEnrolled, "two-factor authentication is not enabled", nil)
+	}
+
+	if err := s.verifySecondFactor(mfa, code, false); err != nil {
+		return err
+	}
+
+	return s.mfa.DisableMFA(userID)
+}
+
+// verifySecondFactor checks a TOTP code, or a recovery code when allowed, and records failures.
+// After maxMFAAttempts failures the second factor is locked for mfaLockout.
+func (s *userService) verifySecondFactor(mfa *models.UserMFA, code string, allowRecovery bool) error {
+	now := time.Now()
+	if mfa.LockedUntil != nil && now.Before(*mfa.LockedUntil) {
+		return e.NewError(e.AuthorizationErr, e.MFALocked, "too many failed attempts, try again later", nil)
+	}
+
+	if utils.IsTOTPCode(code) {
+		if counter, ok := utils.ValidateTOTP(mfa.Secret, code, now, mfa.LastUsedCounter); ok {
+			advanced, err := s.mfa.AdvanceCounter(mfa.UserID, counter)
+			if err != nil {
+				return err
+			}
+			if advanced {
+				return nil
+			}
+		}
+	} else if allowRecovery {
+		used, err := s.mfa.UseRecoveryCode(mfa.UserID, utils.HashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+
+	if err := s.mfa.RecordFailure(mfa.UserID, maxMFAAttempts, now.Add(mfaLockout)); err != nil {
+		return err
+	}
+
+	return e.NewError(e.AuthorizationErr, e.InvalidMFACode, "invalid two-factor code", nil)
+}
@@ -0,0 +1,254 @@
+package services_test
+
+import (
+	"server/config"
+	s "server/internal/api/services"
+	e "server/internal/errors"
+	testing_mocks "server/internal/testing"
+	"server/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const mfaSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func loadTestKeyRing(t *testing.T) {
+	t.Helper()
+	_, err := utils.LoadKeyRing(&config.Config{JWT: config.JWTConfig{SigningAlgorithm: utils.AlgEdDSA}})
+	require.NoError(t, err)
+}
+
+func passwordHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+// sessionClaim returns the "sid" claim of a JWT without verifying it.
+func sessionClaim(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	require.NoError(t, err)
+	sid, _ := parsed.Claims.(jwt.MapClaims)["sid"].(string)
+	return sid
+}
+
+func currentCode(t *testing.T) string {
+	t.Helper()
+	code, err := utils.TOTPCode(mfaSecret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+func TestLoginWithMFA(t *testing.T) {
+	loadTestKeyRing(t)
+	hash := passwordHash(t, validPass)
+
+	t.Run("login without 2FA returns a JWT", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithPasswordHash(email, hash)
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFADisabled("1")
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("1")
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), sessionBuilder.Build(), testHasher)
+
+		res, err := service.Login(email, validPass, client)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Token)
+		assert.False(t, res.MFARequired)
+		assert.Equal(t, testing_mocks.SessionID, sessionClaim(t, res.Token))
+		mfaBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("login with 2FA returns a challenge instead of a JWT", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithPasswordHash(email, hash)
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true)
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		res, err := service.Login(email, validPass, client)
+
+		assert.NoError(t, err)
+		assert.Empty(t, res.Token)
+		assert.True(t, res.MFARequired)
+		assert.NotEmpty(t, res.MFAToken)
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("challenge and TOTP code return a JWT", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true).WithAdvanceCounter("1", true)
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("1")
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), sessionBuilder.Build(), testHasher)
+		challenge, err := utils.GenerateMFAChallengeJWT("1")
+		require.NoError(t, err)
+
+		res, err := service.VerifyMFALogin(challenge, currentCode(t), client)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Token)
+		assert.Equal(t, "1", res.ID)
+		assert.Equal(t, testing_mocks.SessionID, sessionClaim(t, res.Token))
+		mfaBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("challenge and recovery code return a JWT", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true).
+			WithUseRecoveryCode("1", utils.HashRecoveryCode("abcd-efgh"), true)
+		sessionBuilder := testing_mocks.NewSessionMockBuilder().WithCreateSession("1")
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), sessionBuilder.Build(), testHasher)
+		challenge, err := utils.GenerateMFAChallengeJWT("1")
+		require.NoError(t, err)
+
+		res, err := service.VerifyMFALogin(challenge, "abcd-efgh", client)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Token)
+		assert.Equal(t, testing_mocks.SessionID, sessionClaim(t, res.Token))
+		mfaBuilder.AssertExpectations(t)
+		sessionBuilder.AssertExpectations(t)
+	})
+
+	t.Run("replayed code is rejected", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true).
+			WithAdvanceCounter("1", false).WithRecordFailure("1")
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+		challenge, err := utils.GenerateMFAChallengeJWT("1")
+		require.NoError(t, err)
+
+		_, err = service.VerifyMFALogin(challenge, currentCode(t), client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.InvalidMFACode, err.(*e.AuthError).Code())
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("locked second factor", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithLockedMFA("1", mfaSecret)
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+		challenge, err := utils.GenerateMFAChallengeJWT("1")
+		require.NoError(t, err)
+
+		_, err = service.VerifyMFALogin(challenge, currentCode(t), client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.MFALocked, err.(*e.AuthError).Code())
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("regular JWT is not a challenge", func(t *testing.T) {
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+		token, err := utils.GenerateJWT("1", testing_mocks.SessionID)
+		require.NoError(t, err)
+
+		_, err = service.VerifyMFALogin(token, currentCode(t), client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.InvalidToken, err.(*e.AuthError).Code())
+	})
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	t.Run("enroll returns an otpauth URI", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFADisabled("1").WithSavePendingMFA("1")
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		res, err := service.EnrollMFA("1")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Secret)
+		assert.True(t, strings.HasPrefix(res.OTPAuthURI, "otpauth://totp/"))
+		assert.Contains(t, res.OTPAuthURI, "secret="+res.Secret)
+		userBuilder.AssertExpectations(t)
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("enroll when already enabled", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithFoundByID()
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true)
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		_, err := service.EnrollMFA("1")
+
+		assert.IsType(t, &e.UserError{}, err)
+		assert.Equal(t, e.MFAAlreadyEnabled, err.(*e.UserError).Code())
+	})
+
+	t.Run("confirm with first code returns recovery codes", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, false).WithEnableMFA("1")
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		codes, err := service.ConfirmMFA("1", currentCode(t))
+
+		assert.NoError(t, err)
+		assert.Len(t, codes, 10)
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("confirm with wrong code", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, false)
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		_, err := service.ConfirmMFA("1", "abcdef")
+
+		assert.IsType(t, &e.UserError{}, err)
+		assert.Equal(t, e.InvalidMFACode, err.(*e.UserError).Code())
+	})
+
+	t.Run("confirm without enrollment", func(t *testing.T) {
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFADisabled("1")
+		service := s.NewUserService(testing_mocks.NewMockBuilder().Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		_, err := service.ConfirmMFA("1", currentCode(t))
+
+		assert.IsType(t, &e.UserError{}, err)
+		assert.Equal(t, e.MFANotEnrolled, err.(*e.UserError).Code())
+	})
+}
+
+func TestDisableMFA(t *testing.T) {
+	hash := passwordHash(t, validPass)
+
+	t.Run("password and code disable 2FA", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithPasswordHash(email, hash)
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true).
+			WithAdvanceCounter("1", true).WithDisableMFA("1")
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		err := service.DisableMFA("1", validPass, currentCode(t))
+
+		assert.NoError(t, err)
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithPasswordHash(email, hash)
+		mfaBuilder := testing_mocks.NewMFAMockBuilder()
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		err := service.DisableMFA("1", "wrongPass", currentCode(t))
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.InvalidCredentials, err.(*e.AuthError).Code())
+		mfaBuilder.AssertExpectations(t)
+	})
+
+	t.Run("recovery code is not accepted", func(t *testing.T) {
+		userBuilder := testing_mocks.NewMockBuilder().WithPasswordHash(email, hash)
+		mfaBuilder := testing_mocks.NewMFAMockBuilder().WithMFA("1", mfaSecret, true).WithRecordFailure("1")
+		service := s.NewUserService(userBuilder.Build(), mfaBuilder.Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
+
+		err := service.DisableMFA("1", validPass, "abcd-efgh")
+
+		assert.IsType(t, &e.AuthError{}, err)
+		assert.Equal(t, e.InvalidMFACode, err.(*e.AuthError).Code())
+		mfaBuilder.AssertExpectations(t)
+	})
+}
@@ -1,19 +1,28 @@
 package services_test
 
 import (
+	"server/config"
 	s "server/internal/api/services"
 	e "server/internal/errors"
 	"server/internal/models"
 	testing_mocks "server/internal/testing"
+	"server/internal/utils"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const email = "test@example.com"
 const validPass = "validPass123!"
 const validPassHash = "$2a$10$Vlm2G.ULq2M9TbNTXCxlKu.mFv3g5CJw8/OEj02aTlfsF.zEsq9ly"
 
+var client = models.ClientInfo{UserAgent: "test-agent", IPAddress: "127.0.0.1"}
+
+// testHasher uses the cheapest bcrypt cost so hashes made with bcrypt.MinCost are never rehashed.
+var testHasher, _ = utils.NewPasswordHasher(config.PasswordConfig{Algorithm: utils.AlgBcrypt, BcryptCost: bcrypt.MinCost})
+
 func TestRegister(t *testing.T) {
 	user := &models.User{
 		ID:           "1",
@@ -24,7 +33,7 @@ func TestRegister(t *testing.T) {
 	t.Run("successful registration", func(t *testing.T) {
 		builder := testing_mocks.NewMockBuilder()
 		builder.WithSuccessfulUserNotFound(user.Email).WithSuccessfulCreate()
-		service := s.NewUserService(builder.Build())
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
 
 		response, err := service.Register(user.Email, validPass)
 
@@ -37,14 +46,14 @@ func TestRegister(t *testing.T) {
 	t.Run("database error", func(t *testing.T) {
 		builder := testing_mocks.NewMockBuilder()
 		builder.WithSuccessfulUserNotFound(user.Email).WithDatabaseError()
-		service := s.NewUserService(builder.Build())
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
 
 		_, err := service.Register(user.Email, validPass)
 
 		assert.Error(t, err)
 		assert.IsType(t, &e.InternalError{}, err)
 		userErr := err.(*e.InternalError)
-		assert.Equal(t, e.DatabaseError, userErr.Code)
+		assert.Equal(t, e.DatabaseError, userErr.Code())
 		assert.Equal(t, "failed to create user", userErr.Message)
 		builder.AssertExpectations(t)
 	})
@@ -52,14 +61,14 @@ func TestRegister(t *testing.T) {
 	t.Run("duplicate email", func(t *testing.T) {
 		builder := testing_mocks.NewMockBuilder()
 		builder.WithDuplicateEmail("existing@example.com")
-		service := s.NewUserService(builder.Build())
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
 
 		_, err := service.Register("existing@example.com", validPass)
 
 		assert.Error(t, err)
 		assert.IsType(t, &e.UserError{}, err)
 		userErr := err.(*e.UserError)
-		assert.Equal(t, e.EmailAlreadyExists, userErr.Code)
+		assert.Equal(t, e.EmailAlreadyExists, userErr.Code())
 		assert.Equal(t, "email already exists", userErr.Message)
 		builder.AssertExpectations(t)
 	})
@@ -67,14 +76,14 @@ func TestRegister(t *testing.T) {
 	t.Run("invalid password", func(t *testing.T) {
 		builder := testing_mocks.NewMockBuilder()
 		builder.WithUserFound(user.Email).WithInvalidPassword("wrongPass")
-		service := s.NewUserService(builder.Build())
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
 
-		token, err := service.Login(user.Email, "wrongPass")
+		token, err := service.Login(user.Email, "wrongPass", client)
 
 		assert.Error(t, err)
 		assert.IsType(t, &e.AuthError{}, err)
 		authErr := err.(*e.AuthError)
-		assert.Equal(t, e.InvalidCredentials, authErr.Code)
+		assert.Equal(t, e.InvalidCredentials, authErr.Code())
 		assert.Equal(t, "invalid credentials", authErr.Message)
 		assert.Empty(t, token)
 		builder.AssertExpectations(t)
@@ -83,16 +92,61 @@ func TestRegister(t *testing.T) {
 	t.Run("user not found", func(t *testing.T) {
 		builder := testing_mocks.NewMockBuilder()
 		builder.WithUserNotFound("nonexistent@example.com")
-		service := s.NewUserService(builder.Build())
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), testHasher)
 
-		token, err := service.Login("nonexistent@example.com", "anyPass")
+		token, err := service.Login("nonexistent@example.com", "anyPass", client)
 
 		assert.Error(t, err)
 		assert.IsType(t, &e.UserError{}, err)
 		userErr := err.(*e.UserError)
-		assert.Equal(t, e.UserNotFound, userErr.Code)
+		assert.Equal(t, e.UserNotFound, userErr.Code())
 		assert.Equal(t, "invalid credentials", userErr.Message)
 		assert.Empty(t, token)
 		builder.AssertExpectations(t)
 	})
 }
+
+func TestLoginRehash(t *testing.T) {
+	loadTestKeyRing(t)
+	argon2Hasher, err := utils.NewPasswordHasher(config.PasswordConfig{
+		Algorithm:         utils.AlgArgon2id,
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	})
+	require.NoError(t, err)
+
+	t.Run("outdated bcrypt hash is upgraded to argon2id", func(t *testing.T) {
+		builder := testing_mocks.NewMockBuilder().WithPasswordHash(email, passwordHash(t, validPass)).WithUpdatePasswordHash()
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().WithMFADisabled("1").Build(), testing_mocks.NewSessionMockBuilder().WithCreateSession("1").Build(), argon2Hasher)
+
+		res, err := service.Login(email, validPass, client)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Token)
+		builder.AssertExpectations(t)
+	})
+
+	t.Run("current hash is kept", func(t *testing.T) {
+		hash, err := argon2Hasher.Hash(validPass)
+		require.NoError(t, err)
+		builder := testing_mocks.NewMockBuilder().WithPasswordHash(email, hash)
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().WithMFADisabled("1").Build(), testing_mocks.NewSessionMockBuilder().WithCreateSession("1").Build(), argon2Hasher)
+
+		res, err := service.Login(email, validPass, client)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Token)
+		builder.Build().AssertNotCalled(t, "UpdatePasswordHash", "1", hash)
+	})
+
+	t.Run("wrong password is not rehashed", func(t *testing.T) {
+		builder := testing_mocks.NewMockBuilder().WithPasswordHash(email, passwordHash(t, validPass))
+		service := s.NewUserService(builder.Build(), testing_mocks.NewMFAMockBuilder().Build(), testing_mocks.NewSessionMockBuilder().Build(), argon2Hasher)
+
+		_, err := service.Login(email, "wrongPass", client)
+
+		assert.IsType(t, &e.AuthError{}, err)
+		builder.Build().AssertNotCalled(t, "UpdatePasswordHash")
+	})
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/internal/api/repositories"
+	e "server/internal/errors"
+	"server/internal/jobs"
+	"server/internal/models"
+	"server/internal/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	// DeliverWebhookJob is the type of the jobs delivering an event to a webhook.
+	DeliverWebhookJob = "webhooks.deliver"
+	// MaxListedDeliveries is the number of latest deliveries listed for a webhook.
+	MaxListedDeliveries = 100
+
+	// relayBatchSize is the number of outbox events relayed per transaction.
+	relayBatchSize = 100
+)
+
+// Headers of the requests delivering events to webhooks.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// DeliverWebhookPayload is the payload of DeliverWebhookJob jobs.
+type DeliverWebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// WebhookService manages the webhooks and delivers the events of the outbox to them.
+// Events are relayed from the outbox to a delivery per subscribed webhook, each sent
+// by a DeliverWebhookJob job, which the job runner retries with backoff.
+//
+// Webhooks are owned by a user, or by the admins when the owner ID is "".
+type WebhookService struct {
+	webhooks repositories.WebhookRepository
+	uow      repositories.UnitOfWork
+	runner   *jobs.Runner
+	client   *http.Client
+	cfg      config.WebhooksConfig
+	now      func() time.Time
+}
+
+// NewWebhookService creates a new instance of WebhookService enqueuing its deliveries
+// on runner.
+func NewWebhookService(webhooks repositories.WebhookRepository, uow repositories.UnitOfWork, runner *jobs.Runner, cfg config.WebhooksConfig) *WebhookService {
+	return &WebhookService{
+		webhooks: webhooks,
+		uow:      uow,
+		runner:   runner,
+		client:   utils.NewWebhookClient(cfg.Timeout, cfg.AllowPrivateNetworks),
+		cfg:      cfg,
+		now:      time.Now,
+	}
+}
+
+// Create registers a webhook receiving the events of ownerID, or of every user for
+// admin webhooks. The response holds the secret signing the deliveries, which is not
+// returned afterwards.
+//
+// Parameters:
+//   - ownerID: The ID of the user owning the webhook, or "" for an admin webhook.
+//   - webhookURL: The HTTP or HTTPS URL the events are POSTed to.
+//
+// Returns:
+//   - models.WebhookResponse: The webhook, with its secret.
+//   - error: A ValidationError if the URL is not HTTP or HTTPS or, unless private
+//     networks are allowed, points at localhost or a non-public IP address, or an
+//     error if the webhook could not be stored.
+func (s *WebhookService) Create(ownerID, webhookURL string) (models.WebhookResponse, error) {
+	if utils.IsInvalidProtocol(webhookURL) {
+		return models.WebhookResponse{}, e.NewValidationError(e.InvalidProtocol, "webhook URL must use HTTP or HTTPS",
+			[]e.RuleViolation{{Field: "url", Rule: utils.RuleProtocol, Message: "must use HTTP or HTTPS"}})
+	}
+	if !s.cfg.AllowPrivateNetworks && utils.IsPrivateWebhookURL(webhookURL) {
+		return models.WebhookResponse{}, e.NewValidationError(e.InvalidWebhookHost, "webhook URL must point at a public host",
+			[]e.RuleViolation{{Field: "url", Rule: utils.RulePublicHost, Message: "must point at a public host"}})
+	}
+
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		return models.WebhookResponse{}, e.NewError(e.InternalErr, e.FailedSecretGeneration, "failed to generate webhook secret", err)
+	}
+
+	webhook := &models.Webhook{UserID: ownerID, URL: webhookURL, Secret: secret}
+	if err := s.webhooks.Create(webhook); err != nil {
+		return models.WebhookResponse{}, err
+	}
+
+	res := webhookResponse(*webhook)
+	res.Secret = webhook.Secret
+	return res, nil
+}
+
+// List returns the webhooks of ownerID, oldest first, without their secrets.
+func (s *WebhookService) List(ownerID string) ([]models.WebhookResponse, error) {
+	webhooks, err := s.webhooks.List(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]models.WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		res[i] = webhookResponse(webhook)
+	}
+	return res, nil
+}
+
+// Delete deletes a webhook of ownerID with its delivery log, failing with
+// WebhookNotFound if ownerID has no such webhook.
+func (s *WebhookService) Delete(ownerID, webhookID string) error {
+	if _, err := s.findWebhook(s.webhooks, ownerID, webhookID); err != nil {
+		return err
+	}
+
+	deleted, err := s.webhooks.Delete(webhookID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return e.NewError(e.UserErr, e.WebhookNotFound, "webhook not found", nil)
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest MaxListedDeliveries deliveries of a webhook of
+// ownerID, most recent first.
+func (s *WebhookService) ListDeliveries(ownerID, webhookID string) ([]models.WebhookDeliveryResponse, error) {
+	if _, err := s.findWebhook(s.webhooks, ownerID, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhooks.ListDeliveries(webhookID, MaxListedDeliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]models.WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		res[i] = deliveryResponse(delivery)
+	}
+	return res, nil
+}
+
+// Redeliver sends the event of a delivery to its webhook again, as a new delivery
+// with attempts of its own. The original delivery is left as is in the log.
+//
+// Parameters:
+//   - ownerID: The ID of the user owning the webhook, or "" for an admin webhook.
+//   - webhookID: The ID of the webhook.
+//   - deliveryID: The ID of the delivery to send again.
+//
+// Returns:
+//   - models.WebhookDeliveryResponse: The new, pending, delivery.
+//   - error: WebhookNotFound or DeliveryNotFound if ownerID has no such webhook or
+//     delivery, or an error if the delivery could not be enqueued.
+func (s *WebhookService) Redeliver(ownerID, webhookID, deliveryID string) (models.WebhookDeliveryResponse, error) {
+	var redelivery *models.WebhookDelivery
+	err := s.uow.Do(func(tx repositories.Tx) error {
+		if _, err := s.findWebhook(tx.Webhooks(), ownerID, webhookID); err != nil {
+			return err
+		}
+
+		delivery, err := tx.Webhooks().FindDelivery(deliveryID)
+		if err != nil {
+			return err
+		}
+		if delivery == nil || delivery.WebhookID != webhookID {
+			return e.NewError(e.UserErr, e.DeliveryNotFound, "delivery not found", nil)
+		}
+
+		redelivery = &models.WebhookDelivery{
+			WebhookID: webhookID,
+			EventID:   delivery.EventID,
+			EventType: delivery.EventType,
+			Payload:   delivery.Payload,
+		}
+		return s.enqueueDelivery(tx, redelivery)
+	})
+	if err != nil {
+		return models.WebhookDeliveryResponse{}, err
+	}
+	return deliveryResponse(*redelivery), nil
+}
+
+// RelayEvents moves the events of the outbox to the delivery log, creating a delivery
+// per webhook subscribed to each event and enqueuing the jobs sending them. Each batch
+// of events is relayed in a single transaction, so events are neither lost nor relayed
+// twice.
+//
+// Returns:
+//   - int: The number of events relayed.
+//   - error: An error if a batch could not be relayed; it is retried on the next call.
+func (s *WebhookService) RelayEvents(ctx context.Context) (int, error) {
+	var total int
+	for ctx.Err() == nil {
+		var relayed int
+		err := s.uow.Do(func(tx repositories.Tx) error {
+			events, err := tx.Outbox().Claim(relayBatchSize)
+			if err != nil {
+				return err
+			}
+
+			subscribers := make(map[string][]models.Webhook)
+			eventIDs := make([]string, len(events))
+			for i, event := range events {
+				webhooks, ok := subscribers[event.UserID]
+				if !ok {
+					if webhooks, err = tx.Webhooks().ListSubscribers(event.UserID); err != nil {
+						return err
+					}
+					subscribers[event.UserID] = webhooks
+				}
+				if err := s.createDeliveries(tx, event, webhooks); err != nil {
+					return err
+				}
+				eventIDs[i] = event.ID
+			}
+
+			relayed = len(events)
+			return tx.Outbox().Delete(eventIDs)
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += relayed
+		if relayed < relayBatchSize {
+			break
+		}
+	}
+	return total, ctx.Err()
+}
+
+// Deliver POSTs the event of a delivery to its webhook and records the outcome. It
+// is the handler of DeliverWebhookJob jobs: a returned error makes the job retry,
+// unless the webhook rejected the event with a 4xx status other than 408 or 429.
+// Deliveries that are no longer pending, or whose webhook was deleted, are skipped.
+//
+// The request carries the event type, the delivery ID, the Unix time and the
+// utils.SignWebhook signature of the body in the X-Webhook-* headers.
+//
+// Parameters:
+//   - ctx: The context of the job, bounding the request.
+//   - deliveryID: The ID of the delivery.
+//
+// Returns:
+//   - error: An error if the webhook did not answer with a 2xx status or the outcome
+//     could not be recorded.
+func (s *WebhookService) Deliver(ctx context.Context, deliveryID string) error {
+	delivery, err := s.webhooks.FindDelivery(deliveryID)
+	if err != nil || delivery == nil || delivery.Status != models.DeliveryPending {
+		return err
+	}
+	webhook, err := s.webhooks.Find(delivery.WebhookID)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	attempt, err := s.post(ctx, webhook, delivery)
+	switch {
+	case err == nil:
+		attempt.Status = models.DeliverySucceeded
+	case jobs.IsPermanent(err) || jobs.LastAttempt(ctx):
+		attempt.Status = models.DeliveryFailed
+	default:
+		attempt.Status = models.DeliveryPending
+	}
+
+	if recordErr := s.webhooks.RecordAttempt(deliveryID, attempt); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+// PurgeDeliveries deletes the deliveries older than the delivery retention and
+// returns how many there were.
+func (s *WebhookService) PurgeDeliveries() (int64, error) {
+	return s.webhooks.PurgeDeliveries(s.now().Add(-s.cfg.DeliveryRetention))
+}
+
+// findWebhook retrieves a webhook of ownerID, failing with WebhookNotFound if it does
+// not exist or belongs to someone else.
+func (s *WebhookService) findWebhook(webhooks repositories.WebhookRepository, ownerID, webhookID string) (*models.Webhook, error) {
+	webhook, err := webhooks.Find(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil || webhook.UserID != ownerID {
+		return nil, e.NewError(e.UserErr, e.WebhookNotFound, "webhook not found", nil)
+	}
+	return webhook, nil
+}
+
+// createDeliveries creates and enqueues the deliveries of event to webhooks.
+func (s *WebhookService) createDeliveries(tx repositories.Tx, event models.OutboxEvent, webhooks []models.Webhook) error {
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(models.WebhookPayload{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Data,
+	})
+	if err != nil {
+		return e.NewError(e.InternalErr, e.DatabaseError, "failed to encode webhook payload", err)
+	}
+
+	for _, webhook := range webhooks {
+		delivery := &models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   payload,
+		}
+		if err := s.enqueueDelivery(tx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueDelivery stores a delivery and enqueues its job in tx.
+func (s *WebhookService) enqueueDelivery(tx repositories.Tx, delivery *models.WebhookDelivery) error {
+	if err := tx.Webhooks().CreateDelivery(delivery); err != nil {
+		return err
+	}
+
+	_, err := s.runner.EnqueueIn(tx.Jobs(), DeliverWebhookJob, DeliverWebhookPayload{DeliveryID: delivery.ID}, jobs.MaxAttempts(s.cfg.MaxAttempts))
+	if err != nil {
+		return e.NewError(e.InternalErr, e.DatabaseError, "failed to enqueue webhook delivery", err)
+	}
+	return nil
+}
+
+// post sends a delivery to its webhook and returns the response status, or the
+// error, to record. The response body is not read: the log is shown to the owner of
+// the webhook, and must not reveal more of the receiver than its status. Redirects
+// are not followed, so 3xx statuses are failures. 4xx statuses other than 408 and
+// 429 are Permanent errors.
+func (s *WebhookService) post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (models.DeliveryAttempt, error) {
+	timestamp := s.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return models.DeliveryAttempt{Error: err.Error()}, jobs.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, utils.SignWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if errors.Is(err, utils.ErrPrivateAddress) {
+		return models.DeliveryAttempt{Error: err.Error()}, jobs.Permanent(err)
+	}
+	if err != nil {
+		return models.DeliveryAttempt{Error: err.Error()}, err
+	}
+	defer resp.Body.Close()
+
+	attempt := models.DeliveryAttempt{ResponseStatus: resp.StatusCode}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return attempt, nil
+	}
+
+	err = fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	attempt.Error = err.Error()
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return attempt, jobs.Permanent(err)
+	}
+	return attempt, err
+}
+
+func webhookResponse(webhook models.Webhook) models.WebhookResponse {
+	return models.WebhookResponse{ID: webhook.ID, URL: webhook.URL, CreatedAt: webhook.CreatedAt}
+}
+
+func deliveryResponse(delivery models.WebhookDelivery) models.WebhookDeliveryResponse {
+	return models.WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		Error:          delivery.Error,
+		CreatedAt:      delivery.CreatedAt,
+		AttemptedAt:    delivery.AttemptedAt,
+	}
+}
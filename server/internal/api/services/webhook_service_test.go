@@ -0,0 +1,445 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/api/repositories"
+	"server/internal/api/repositories/memory"
+	s "server/internal/api/services"
+	e "server/internal/errors"
+	"server/internal/jobs"
+	"server/internal/models"
+	"server/internal/pubsub"
+	"server/internal/utils"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhooksConfig allows private networks, as the receivers of the tests listen on
+// the loopback interface.
+var webhooksConfig = config.WebhooksConfig{Timeout: time.Second, MaxAttempts: 3, DeliveryRetention: time.Hour, AllowPrivateNetworks: true}
+
+// receivedDelivery is a request received by a receiver.
+type receivedDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a local webhook endpoint answering with the next status of statuses,
+// then 200, and recording the requests it receives.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	received []receivedDelivery
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.received = append(r.received, receivedDelivery{header: req.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte("status " + strconv.Itoa(status)))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) deliveries() []receivedDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedDelivery(nil), r.received...)
+}
+
+type webhookFixture struct {
+	likes    *s.LikedImagesService
+	webhooks *s.WebhookService
+	repo     repositories.WebhookRepository
+	runner   *jobs.Runner
+	userID   string
+}
+
+// newWebhookFixture wires the liked images and webhook services on a memory store,
+// with a job runner that is not started: tests run the jobs with runAll.
+func newWebhookFixture(t *testing.T) *webhookFixture {
+	return newWebhookFixtureWithConfig(t, webhooksConfig)
+}
+
+func newWebhookFixtureWithConfig(t *testing.T, cfg config.WebhooksConfig) *webhookFixture {
+	store := memory.NewStore()
+	user, err := memory.NewUserRepository(store).Create(&models.User{Email: "webhooks@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	uow := memory.NewUnitOfWork(store)
+	runner := jobs.NewRunner(memory.NewJobRepository(store), config.JobsConfig{LockTimeout: time.Minute, MaxAttempts: 5})
+	repo := memory.NewWebhookRepository(store)
+	webhooks := s.NewWebhookService(repo, uow, runner, cfg)
+	jobs.Register(runner, s.DeliverWebhookJob, func(ctx context.Context, payload s.DeliverWebhookPayload) error {
+		return webhooks.Deliver(ctx, payload.DeliveryID)
+	})
+
+	return &webhookFixture{
+		likes:    s.NewLikedImagesService(memory.NewLikedImagesRepository(store), memory.NewUserRepository(store), uow, pubsub.NewBroker(0), likesConfig),
+		webhooks: webhooks,
+		repo:     repo,
+		runner:   runner,
+		userID:   user.ID,
+	}
+}
+
+// runAll runs the due jobs until there are none.
+func (f *webhookFixture) runAll(t *testing.T) {
+	t.Helper()
+	for {
+		ran, err := f.runner.RunNext()
+		require.NoError(t, err)
+		if !ran {
+			return
+		}
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	t.Run("like - delivers a signed event to the user and admin webhooks", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		userEndpoint, adminEndpoint := newReceiver(t), newReceiver(t)
+		userHook, err := f.webhooks.Create(f.userID, userEndpoint.URL)
+		require.NoError(t, err)
+		_, err = f.webhooks.Create("", adminEndpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		relayed, err := f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, relayed)
+		f.runAll(t)
+
+		received := userEndpoint.deliveries()
+		require.Len(t, received, 1)
+		assert.Len(t, adminEndpoint.deliveries(), 1)
+
+		header, body := received[0].header, received[0].body
+		assert.Equal(t, "application/json", header.Get("Content-Type"))
+		assert.Equal(t, models.ImageLikedEvent, header.Get(s.WebhookEventHeader))
+		timestamp, err := strconv.ParseInt(header.Get(s.WebhookTimestampHeader), 10, 64)
+		require.NoError(t, err)
+		assert.True(t, utils.VerifyWebhookSignature(userHook.Secret, timestamp, body, header.Get(s.WebhookSignatureHeader)))
+
+		var payload models.WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, models.ImageLikedEvent, payload.Type)
+		assert.JSONEq(t, `{"user_id":"`+f.userID+`","image_url":"`+successImageURL+`"}`, string(payload.Data))
+
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, userHook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, header.Get(s.WebhookDeliveryHeader), deliveries[0].ID)
+		assert.Equal(t, payload.ID, deliveries[0].EventID)
+		assert.Equal(t, string(models.DeliverySucceeded), deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+		logged, err := json.Marshal(deliveries)
+		require.NoError(t, err)
+		assert.NotContains(t, string(logged), "status 200", "the response body is not logged")
+
+		relayed, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, relayed, "relayed events leave the outbox")
+	})
+
+	t.Run("failed like - records no event", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t)
+		_, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.Error(t, err)
+		relayed, err := f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, relayed)
+	})
+
+	t.Run("server error - retries until it succeeds", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		hook, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+
+		received := endpoint.deliveries()
+		require.Len(t, received, 3)
+		assert.Equal(t, received[0].header.Get(s.WebhookDeliveryHeader), received[2].header.Get(s.WebhookDeliveryHeader),
+			"retries are attempts of the same delivery")
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.DeliverySucceeded), deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.Empty(t, deliveries[0].Error)
+	})
+
+	t.Run("server error on every attempt - fails the delivery", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+		hook, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+
+		assert.Len(t, endpoint.deliveries(), webhooksConfig.MaxAttempts)
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.DeliveryFailed), deliveries[0].Status)
+		assert.Equal(t, webhooksConfig.MaxAttempts, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+		assert.Equal(t, "webhook responded with status 500", deliveries[0].Error)
+	})
+
+	t.Run("client error - fails the delivery without retrying", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t, http.StatusGone)
+		hook, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+
+		assert.Len(t, endpoint.deliveries(), 1)
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.DeliveryFailed), deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+	})
+
+	t.Run("unreachable endpoint - records the error", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t)
+		endpoint.Close()
+		hook, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.DeliveryFailed), deliveries[0].Status)
+		assert.Zero(t, deliveries[0].ResponseStatus)
+		assert.NotEmpty(t, deliveries[0].Error)
+	})
+
+	t.Run("redirect - is not followed", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		target := newReceiver(t)
+		redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+		t.Cleanup(redirect.Close)
+		hook, err := f.webhooks.Create(f.userID, redirect.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+
+		assert.Empty(t, target.deliveries())
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.DeliveryFailed), deliveries[0].Status)
+		assert.Equal(t, http.StatusTemporaryRedirect, deliveries[0].ResponseStatus)
+	})
+
+	t.Run("private address - is not dialed", func(t *testing.T) {
+		cfg := webhooksConfig
+		cfg.AllowPrivateNetworks = false
+		f := newWebhookFixtureWithConfig(t, cfg)
+		endpoint := newReceiver(t)
+		// Stored directly, as for a host resolving to a loopback address, which Create
+		// cannot tell.
+		hook := &models.Webhook{UserID: f.userID, URL: endpoint.URL, Secret: "whsec_test"}
+		require.NoError(t, f.repo.Create(hook))
+
+		_, err := f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+
+		assert.Empty(t, endpoint.deliveries())
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, string(models.DeliveryFailed), deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts, "blocked deliveries are not retried")
+		assert.Contains(t, deliveries[0].Error, utils.ErrPrivateAddress.Error())
+	})
+
+	t.Run("deleted webhook - skips its pending deliveries", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t)
+		hook, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, f.webhooks.Delete(f.userID, hook.ID))
+		f.runAll(t)
+
+		assert.Empty(t, endpoint.deliveries())
+	})
+}
+
+func TestRedeliver(t *testing.T) {
+	t.Run("failed delivery - is sent again as a new delivery", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		endpoint := newReceiver(t, http.StatusBadRequest)
+		hook, err := f.webhooks.Create(f.userID, endpoint.URL)
+		require.NoError(t, err)
+		_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+		require.NoError(t, err)
+		_, err = f.webhooks.RelayEvents(context.Background())
+		require.NoError(t, err)
+		f.runAll(t)
+		deliveries, err := f.webhooks.ListDeliveries(f.userID, hook.ID)
+		require.NoError(t, err)
+		failed := deliveries[0]
+
+		redelivery, err := f.webhooks.Redeliver(f.userID, hook.ID, failed.ID)
+		require.NoError(t, err)
+		assert.NotEqual(t, failed.ID, redelivery.ID)
+		assert.Equal(t, failed.EventID, redelivery.EventID)
+		assert.Equal(t, string(models.DeliveryPending), redelivery.Status)
+		f.runAll(t)
+
+		received := endpoint.deliveries()
+		require.Len(t, received, 2)
+		assert.Equal(t, received[0].body, received[1].body)
+		assert.Equal(t, redelivery.ID, received[1].header.Get(s.WebhookDeliveryHeader))
+		found, err := f.repo.FindDelivery(redelivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliverySucceeded, found.Status)
+		found, err = f.repo.FindDelivery(failed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryFailed, found.Status, "the original delivery is kept as is")
+	})
+
+	t.Run("unknown delivery - returns not found error", func(t *testing.T) {
+		f := newWebhookFixture(t)
+		hook, err := f.webhooks.Create(f.userID, "https://example.com/hooks")
+		require.NoError(t, err)
+
+		_, err = f.webhooks.Redeliver(f.userID, hook.ID, uuid.NewString())
+
+		assert.ErrorIs(t, err, e.NewError(e.UserErr, e.DeliveryNotFound, "", nil))
+	})
+}
+
+func TestWebhookOwnership(t *testing.T) {
+	f := newWebhookFixture(t)
+	userHook, err := f.webhooks.Create(f.userID, "https://example.com/user")
+	require.NoError(t, err)
+	adminHook, err := f.webhooks.Create("", "https://example.com/admin")
+	require.NoError(t, err)
+
+	t.Run("list - returns the webhooks of the owner without secrets", func(t *testing.T) {
+		webhooks, err := f.webhooks.List(f.userID)
+		require.NoError(t, err)
+		require.Len(t, webhooks, 1)
+		assert.Equal(t, userHook.ID, webhooks[0].ID)
+		assert.Empty(t, webhooks[0].Secret)
+		assert.NotEmpty(t, userHook.Secret, "the secret is returned on creation")
+
+		webhooks, err = f.webhooks.List("")
+		require.NoError(t, err)
+		require.Len(t, webhooks, 1)
+		assert.Equal(t, adminHook.ID, webhooks[0].ID)
+	})
+
+	t.Run("other owner - returns not found error", func(t *testing.T) {
+		notFound := e.NewError(e.UserErr, e.WebhookNotFound, "", nil)
+
+		_, err := f.webhooks.ListDeliveries("", userHook.ID)
+		assert.ErrorIs(t, err, notFound)
+		_, err = f.webhooks.ListDeliveries(f.userID, adminHook.ID)
+		assert.ErrorIs(t, err, notFound)
+		assert.ErrorIs(t, f.webhooks.Delete(uuid.NewString(), userHook.ID), notFound)
+		_, err = f.webhooks.Redeliver("", userHook.ID, uuid.NewString())
+		assert.ErrorIs(t, err, notFound)
+	})
+
+	t.Run("invalid protocol - returns validation error", func(t *testing.T) {
+		_, err := f.webhooks.Create(f.userID, "ftp://example.com/hooks")
+
+		assert.ErrorIs(t, err, e.NewError(e.ValidationErr, e.InvalidProtocol, "", nil))
+	})
+
+	t.Run("private host - returns validation error", func(t *testing.T) {
+		cfg := webhooksConfig
+		cfg.AllowPrivateNetworks = false
+		f := newWebhookFixtureWithConfig(t, cfg)
+
+		for _, webhookURL := range []string{"http://169.254.169.254/latest/meta-data", "http://localhost:8080/hooks", "http://10.0.0.5/hooks", "http://[::1]/hooks"} {
+			_, err := f.webhooks.Create(f.userID, webhookURL)
+			assert.ErrorIs(t, err, e.NewError(e.ValidationErr, e.InvalidWebhookHost, "", nil), webhookURL)
+		}
+		_, err := f.webhooks.Create(f.userID, "https://example.com/hooks")
+		assert.NoError(t, err)
+	})
+}
+
+func TestPurgeDeliveries(t *testing.T) {
+	f := newWebhookFixture(t)
+	endpoint := newReceiver(t)
+	_, err := f.webhooks.Create(f.userID, endpoint.URL)
+	require.NoError(t, err)
+	_, err = f.likes.LikeImage(f.userID, successImageURL, nil)
+	require.NoError(t, err)
+	_, err = f.webhooks.RelayEvents(context.Background())
+	require.NoError(t, err)
+
+	purged, err := f.webhooks.PurgeDeliveries()
+	require.NoError(t, err)
+	assert.Zero(t, purged, "deliveries within the retention are kept")
+}
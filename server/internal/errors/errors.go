@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"net/http"
 )
 
 type ErrorType string
@@ -17,170 +18,257 @@ const (
 type ErrorCode string
 
 const (
-	InvalidEmail          ErrorCode = "invalid_email"
-	FailedHash            ErrorCode = "failed_hash"
-	EmailAlreadyExists    ErrorCode = "email_already_exists"
-	DatabaseError         ErrorCode = "database_error"
-	UserNotFound          ErrorCode = "user_not_found"
-	InvalidCredentials    ErrorCode = "invalid_credentials"
-	InvalidToken          ErrorCode = "invalid_token"
-	JWTError              ErrorCode = "jwt_error"
-	ExternalAPIError      ErrorCode = "external_api_error"
-	EmptyImageURL         ErrorCode = "empty_image_url"
-	MalformedURL          ErrorCode = "malformed_url"
-	InvalidImageExtension ErrorCode = "invalid_image_extension"
-	InvalidProtocol       ErrorCode = "invalid_protocol"
-	ImageAlreadyLiked     ErrorCode = "image_already_liked"
-	ImageNotLiked         ErrorCode = "image_not_liked"
+	InvalidEmail           ErrorCode = "invalid_email"
+	FailedHash             ErrorCode = "failed_hash"
+	EmailAlreadyExists     ErrorCode = "email_already_exists"
+	DatabaseError          ErrorCode = "database_error"
+	UserNotFound           ErrorCode = "user_not_found"
+	InvalidCredentials     ErrorCode = "invalid_credentials"
+	InvalidToken           ErrorCode = "invalid_token"
+	JWTError               ErrorCode = "jwt_error"
+	ExternalAPIError       ErrorCode = "external_api_error"
+	EmptyImageURL          ErrorCode = "empty_image_url"
+	MalformedURL           ErrorCode = "malformed_url"
+	InvalidImageExtension  ErrorCode = "invalid_image_extension"
+	InvalidProtocol        ErrorCode = "invalid_protocol"
+	ImageAlreadyLiked      ErrorCode = "image_already_liked"
+	ImageNotLiked          ErrorCode = "image_not_liked"
+	NothingToUndo          ErrorCode = "nothing_to_undo"
+	ImageNotOffered        ErrorCode = "image_not_offered"
+	WebhookNotFound        ErrorCode = "webhook_not_found"
+	DeliveryNotFound       ErrorCode = "delivery_not_found"
+	InvalidWebhookHost     ErrorCode = "invalid_webhook_host"
+	UnknownOIDCProvider    ErrorCode = "unknown_oidc_provider"
+	InvalidOIDCState       ErrorCode = "invalid_oidc_state"
+	OIDCExchangeFailed     ErrorCode = "oidc_exchange_failed"
+	UnverifiedEmail        ErrorCode = "unverified_email"
+	InvalidMFACode         ErrorCode = "invalid_mfa_code"
+	MFANotEnrolled         ErrorCode = "mfa_not_enrolled"
+	MFAAlreadyEnabled      ErrorCode = "mfa_already_enabled"
+	MFALocked              ErrorCode = "mfa_locked"
+	WeakPassword           ErrorCode = "weak_password"
+	SessionNotFound        ErrorCode = "session_not_found"
+	SessionRevoked         ErrorCode = "session_revoked"
+	CSRFTokenInvalid       ErrorCode = "csrf_token_invalid"
+	InvalidRequest         ErrorCode = "invalid_request"
+	InvalidIdempotencyKey  ErrorCode = "invalid_idempotency_key"
+	IdempotencyKeyReused   ErrorCode = "idempotency_key_reused"
+	IdempotencyInProgress  ErrorCode = "idempotency_in_progress"
+	PreconditionFailed     ErrorCode = "precondition_failed"
+	FailedSecretGeneration ErrorCode = "failed_secret_generation"
 )
 
-// AppError represents a custom error interface that extends the standard error interface.
-// It includes methods to retrieve an error code and to unwrap the underlying error.
+// codeStatus maps every ErrorCode to the HTTP status of a UserError with that code.
+// The other kinds always use the status of their kind, so a code shared between
+// kinds, like InvalidMFACode, can be a 400 for a UserError and a 401 for an AuthError.
+var codeStatus = map[ErrorCode]int{
+	InvalidEmail:           http.StatusBadRequest,
+	FailedHash:             http.StatusInternalServerError,
+	EmailAlreadyExists:     http.StatusConflict,
+	DatabaseError:          http.StatusInternalServerError,
+	UserNotFound:           http.StatusNotFound,
+	InvalidCredentials:     http.StatusUnauthorized,
+	InvalidToken:           http.StatusUnauthorized,
+	JWTError:               http.StatusInternalServerError,
+	ExternalAPIError:       http.StatusInternalServerError,
+	EmptyImageURL:          http.StatusBadRequest,
+	MalformedURL:           http.StatusBadRequest,
+	InvalidImageExtension:  http.StatusBadRequest,
+	InvalidProtocol:        http.StatusBadRequest,
+	ImageAlreadyLiked:      http.StatusConflict,
+	ImageNotLiked:          http.StatusNotFound,
+	NothingToUndo:          http.StatusNotFound,
+	ImageNotOffered:        http.StatusNotFound,
+	WebhookNotFound:        http.StatusNotFound,
+	DeliveryNotFound:       http.StatusNotFound,
+	InvalidWebhookHost:     http.StatusBadRequest,
+	UnknownOIDCProvider:    http.StatusNotFound,
+	InvalidOIDCState:       http.StatusUnauthorized,
+	OIDCExchangeFailed:     http.StatusUnauthorized,
+	UnverifiedEmail:        http.StatusUnauthorized,
+	InvalidMFACode:         http.StatusBadRequest,
+	MFANotEnrolled:         http.StatusBadRequest,
+	MFAAlreadyEnabled:      http.StatusConflict,
+	MFALocked:              http.StatusUnauthorized,
+	WeakPassword:           http.StatusBadRequest,
+	SessionNotFound:        http.StatusNotFound,
+	SessionRevoked:         http.StatusUnauthorized,
+	CSRFTokenInvalid:       http.StatusForbidden,
+	InvalidRequest:         http.StatusBadRequest,
+	InvalidIdempotencyKey:  http.StatusBadRequest,
+	IdempotencyKeyReused:   http.StatusUnprocessableEntity,
+	IdempotencyInProgress:  http.StatusConflict,
+	PreconditionFailed:     http.StatusPreconditionFailed,
+	FailedSecretGeneration: http.StatusInternalServerError,
+}
+
+// StatusOf returns the HTTP status registered for code, or 0 if the code is unknown.
+func StatusOf(code ErrorCode) int {
+	return codeStatus[code]
+}
+
+// internalPublicMessage replaces the message of internal errors in responses.
+const internalPublicMessage = "an internal error occurred"
+
+// AppError is implemented by every error kind of the API.
+//
+// Methods:
+//   - Code: the stable error code reported to clients.
+//   - Status: the HTTP status of the response.
+//   - PublicMessage: the message safe to show to clients. It never includes the wrapped error.
+//   - Unwrap: the underlying error, for errors.Is and errors.As.
 type AppError interface {
 	error
 	Code() ErrorCode
+	Status() int
+	PublicMessage() string
 	Unwrap() error
 }
 
-// UserError represents an error with a specific code and message.
-// It also wraps an underlying error.
+// baseError holds what every error kind has in common.
 //
 // Fields:
-// - Code: A specific error code of type ErrorCode.
+// - code: A specific error code of type ErrorCode.
 // - Message: A human-readable message describing the error.
-// - Err: The underlying error that triggered this UserError.
-type UserError struct {
-	Code    ErrorCode
+// - Err: The underlying error that triggered this error.
+type baseError struct {
+	code    ErrorCode
 	Message string
 	Err     error
 }
 
-// Error returns the error message for the UserError type.
+// Error returns the error message.
 // If the underlying error (Err) is not nil, it includes the message and the error.
 // Otherwise, it returns only the message.
-func (e *UserError) Error() string {
+func (e *baseError) Error() string {
 	if e.Err != nil {
 		return fmt.Sprintf("%s: %v", e.Message, e.Err)
 	}
 	return e.Message
 }
 
-// Unwrap returns the underlying error of a UserError.
-// It allows access to the original error that caused the UserError.
-func Unwrap(e *UserError) error {
+func (e *baseError) Code() ErrorCode {
+	return e.code
+}
+
+func (e *baseError) PublicMessage() string {
+	return e.Message
+}
+
+// Unwrap returns the underlying error, giving access to the original error that caused it.
+func (e *baseError) Unwrap() error {
 	return e.Err
 }
 
-type AuthError struct {
-	Code    ErrorCode
-	Message string
-	Err     error
+// Is reports whether target is an AppError with the same code, so errors.Is can
+// match an error chain against a code whatever the kind and message.
+func (e *baseError) Is(target error) bool {
+	t, ok := target.(AppError)
+	return ok && t.Code() == e.code
 }
 
-func (e *AuthError) Error() string {
-	if e.Err != nil {
-		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+// UserError represents a client error, reported with the status registered for its code.
+type UserError struct {
+	baseError
+}
+
+func (e *UserError) Status() int {
+	if status := StatusOf(e.code); status != 0 {
+		return status
 	}
-	return e.Message
+	return http.StatusBadRequest
 }
 
-func (e *AuthError) Unwrap() error {
-	return e.Err
+// AuthError represents a failed authentication, reported as 401 Unauthorized.
+type AuthError struct {
+	baseError
 }
 
-type ValidationError struct {
-	Code    ErrorCode
-	Message string
-	Err     error
+func (e *AuthError) Status() int {
+	return http.StatusUnauthorized
 }
 
-func (e *ValidationError) Error() string {
-	if e.Err != nil {
-		return fmt.Sprintf("%s: %v", e.Message, e.Err)
-	}
-	return e.Message
+// RuleViolation describes a single validation rule a value failed.
+//
+// Fields:
+// - Field: The JSON name of the request field, empty when the rule applies to the whole request.
+// - Rule: A stable identifier of the rule, e.g. "min_length".
+// - Message: A human-readable description of the rule.
+// - Params: The parameters of the rule, e.g. {"min": "8"}, also used to translate Message.
+type RuleViolation struct {
+	Field   string            `json:"field,omitempty" example:"email"`
+	Rule    string            `json:"rule" example:"required" enums:"required,email,uuid,url,min,max,len,oneof,type,malformed,max_items,not_empty,no_spaces,protocol,extension,min_length,max_length,max_bytes,uppercase,lowercase,digit,special,ascii,breached"`
+	Message string            `json:"message" example:"is required"`
+	Params  map[string]string `json:"params,omitempty"`
 }
 
-func (e *ValidationError) Unwrap() error {
-	return e.Err
+// ValidationError represents invalid input, reported as 400 Bad Request. Violations
+// optionally lists every rule the input failed so clients can report them individually.
+type ValidationError struct {
+	baseError
+	Violations []RuleViolation
 }
 
-type ForbiddenError struct {
-	Code    ErrorCode
-	Message string
-	Err     error
+func (e *ValidationError) Status() int {
+	return http.StatusBadRequest
 }
 
-func (e *ForbiddenError) Error() string {
-	if e.Err != nil {
-		return fmt.Sprintf("%s: %v", e.Message, e.Err)
-	}
-	return e.Message
+// ForbiddenError represents an authenticated request that is not allowed, reported as 403 Forbidden.
+type ForbiddenError struct {
+	baseError
 }
 
-func (e *ForbiddenError) Unwrap() error {
-	return e.Err
+func (e *ForbiddenError) Status() int {
+	return http.StatusForbidden
 }
 
+// InternalError represents a failure of the server, reported as 500 Internal Server Error.
+// Its message and underlying error are only logged, never sent to clients.
 type InternalError struct {
-	Code    ErrorCode
-	Message string
-	Err     error
+	baseError
 }
 
-func (e *InternalError) Error() string {
-	if e.Err != nil {
-		return fmt.Sprintf("%s: %v", e.Message, e.Err)
-	}
-	return e.Message
+func (e *InternalError) Status() int {
+	return http.StatusInternalServerError
 }
 
-func (e *InternalError) Unwrap() error {
-	return e.Err
+func (e *InternalError) PublicMessage() string {
+	return internalPublicMessage
+}
+
+// NewValidationError creates a ValidationError listing the rules the input failed.
+func NewValidationError(code ErrorCode, message string, violations []RuleViolation) error {
+	return &ValidationError{
+		baseError:  baseError{code: code, Message: message},
+		Violations: violations,
+	}
 }
 
 // NewError creates a new error based on the provided error type, code, message, and underlying error.
-// It returns an error of type UserError, AuthError, or InternalError depending on the errType parameter.
+// It returns an error of type UserError, AuthError, ValidationError, ForbiddenError or
+// InternalError depending on the errType parameter.
 //
 // Parameters:
-//   - errType: a string representing the type of error ("user_error", "authorization_error", or other).
+//   - errType: the kind of error, InternalErr for unknown types.
 //   - code: an ErrorCode representing the specific error code.
 //   - message: a string containing the error message.
 //   - err: an error representing the underlying error.
 //
 // Returns:
-//   - error: an error of type UserError, AuthError, or InternalError.
+//   - error: an AppError of the requested kind.
 func NewError(errType ErrorType, code ErrorCode, message string, err error) error {
+	base := baseError{code: code, Message: message, Err: err}
+
 	switch errType {
-	case "user_error":
-		return &UserError{
-			Code:    code,
-			Message: message,
-			Err:     err,
-		}
-	case "authorization_error":
-		return &AuthError{
-			Code:    code,
-			Message: message,
-			Err:     err,
-		}
-	case "validation_error":
-		return &ValidationError{
-			Code:    code,
-			Message: message,
-			Err:     err,
-		}
-	case "forbidden_error":
-		return &ForbiddenError{
-			Code:    code,
-			Message: message,
-			Err:     err,
-		}
+	case UserErr:
+		return &UserError{base}
+	case AuthorizationErr:
+		return &AuthError{base}
+	case ValidationErr:
+		return &ValidationError{baseError: base}
+	case ForbiddenErr:
+		return &ForbiddenError{base}
 	default:
-		return &InternalError{
-			Code:    code,
-			Message: message,
-			Err:     err,
-		}
+		return &InternalError{base}
 	}
 }
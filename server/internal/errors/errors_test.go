@@ -0,0 +1,105 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// declaredCodes returns every ErrorCode constant declared in errors.go.
+func declaredCodes(t *testing.T) []ErrorCode {
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	require.NoError(t, err)
+
+	var codes []ErrorCode
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		if ident, ok := spec.Type.(*ast.Ident); !ok || ident.Name != "ErrorCode" {
+			return true
+		}
+		for _, value := range spec.Values {
+			lit, ok := value.(*ast.BasicLit)
+			require.True(t, ok, "ErrorCode constants must be string literals")
+			codes = append(codes, ErrorCode(lit.Value[1:len(lit.Value)-1]))
+		}
+		return true
+	})
+	return codes
+}
+
+func TestEveryCodeHasAStatus(t *testing.T) {
+	codes := declaredCodes(t)
+	require.NotEmpty(t, codes)
+
+	for _, code := range codes {
+		status := StatusOf(code)
+		assert.True(t, status >= 400 && status <= 599, "ErrorCode %q has no registered status", code)
+	}
+	assert.Len(t, codeStatus, len(codes), "codeStatus lists codes that are not declared")
+}
+
+func TestKindsImplementAppError(t *testing.T) {
+	tests := []struct {
+		errType ErrorType
+		code    ErrorCode
+		status  int
+	}{
+		{UserErr, EmailAlreadyExists, http.StatusConflict},
+		{UserErr, UserNotFound, http.StatusNotFound},
+		{AuthorizationErr, InvalidMFACode, http.StatusUnauthorized},
+		{ValidationErr, InvalidRequest, http.StatusBadRequest},
+		{ForbiddenErr, InvalidToken, http.StatusForbidden},
+		{InternalErr, DatabaseError, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.errType), func(t *testing.T) {
+			err := NewError(tt.errType, tt.code, "message", nil)
+
+			appErr, ok := err.(AppError)
+			require.True(t, ok)
+			assert.Equal(t, tt.code, appErr.Code())
+			assert.Equal(t, tt.status, appErr.Status())
+		})
+	}
+}
+
+func TestErrorChains(t *testing.T) {
+	cause := stderrors.New("connection refused")
+	err := fmt.Errorf("loading user: %w", NewError(InternalErr, DatabaseError, "failed to find user", cause))
+
+	t.Run("errors.As finds the AppError", func(t *testing.T) {
+		var appErr AppError
+		require.True(t, stderrors.As(err, &appErr))
+		assert.Equal(t, DatabaseError, appErr.Code())
+
+		var internalErr *InternalError
+		assert.True(t, stderrors.As(err, &internalErr))
+	})
+
+	t.Run("errors.Is matches the code and the cause", func(t *testing.T) {
+		assert.True(t, stderrors.Is(err, NewError(InternalErr, DatabaseError, "", nil)))
+		assert.False(t, stderrors.Is(err, NewError(InternalErr, JWTError, "", nil)))
+		assert.True(t, stderrors.Is(err, cause))
+	})
+
+	t.Run("public message hides internal details", func(t *testing.T) {
+		var appErr AppError
+		require.True(t, stderrors.As(err, &appErr))
+		assert.Equal(t, internalPublicMessage, appErr.PublicMessage())
+		assert.Contains(t, err.Error(), "connection refused")
+
+		userErr := NewError(UserErr, UserNotFound, "user not found", cause).(AppError)
+		assert.Equal(t, "user not found", userErr.PublicMessage())
+	})
+}
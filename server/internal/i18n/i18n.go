@@ -0,0 +1,135 @@
+// Package i18n translates the messages sent to clients.
+//
+// English is the source language: messages are written in English in the code and
+// every other locale has a catalog in locales/, keyed by errors.ErrorCode for error
+// details and by rule for validation violations. A missing translation falls back to
+// the base language of the locale (e.g. "fr" for "fr-CA"), then to the English message.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	e "server/internal/errors"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is the language of the messages written in the code.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// catalog holds the translations of one locale.
+//
+// Fields:
+//   - Errors: the error details, keyed by error code.
+//   - Rules: the validation rule messages, keyed by rule. They may reference the
+//     parameters of the violation as {name}.
+type catalog struct {
+	Errors map[e.ErrorCode]string `json:"errors"`
+	Rules  map[string]string      `json:"rules"`
+}
+
+var (
+	catalogs = map[string]catalog{}
+	matcher  language.Matcher
+	// supported lists the locales in matcher order, the default first.
+	supported []string
+)
+
+func init() {
+	tags := []language.Tag{language.MustParse(DefaultLocale)}
+	supported = []string{DefaultLocale}
+
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: failed to read catalogs: %v", err))
+	}
+	for _, entry := range entries {
+		locale := strings.TrimSuffix(entry.Name(), ".json")
+
+		data, err := localesFS.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic(fmt.Sprintf("i18n: failed to read catalog %s: %v", locale, err))
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog %s: %v", locale, err))
+		}
+
+		catalogs[locale] = c
+		tags = append(tags, language.MustParse(locale))
+		supported = append(supported, locale)
+	}
+
+	matcher = language.NewMatcher(tags)
+}
+
+// Supported returns the locales messages can be translated to, the default first.
+func Supported() []string {
+	return append([]string(nil), supported...)
+}
+
+// Match returns the supported locale best matching the preferences, in order of
+// precedence. Each preference is a locale ("fr-CA") or an Accept-Language header
+// value ("fr-CA,fr;q=0.9,en;q=0.8"). Empty or invalid preferences are skipped, and
+// DefaultLocale is returned when nothing matches.
+func Match(preferences ...string) string {
+	for _, preference := range preferences {
+		if preference == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(preference)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		if _, index, confidence := matcher.Match(tags...); confidence != language.No {
+			return supported[index]
+		}
+	}
+	return DefaultLocale
+}
+
+// ErrorMessage returns the detail of an error with code in locale, or fallback,
+// the English message, if there is no translation.
+func ErrorMessage(locale string, code e.ErrorCode, fallback string) string {
+	for _, c := range lookup(locale) {
+		if message, ok := c.Errors[code]; ok {
+			return message
+		}
+	}
+	return fallback
+}
+
+// RuleMessage returns the message of a failed validation rule in locale, or the
+// English message of the violation if there is no translation.
+func RuleMessage(locale string, violation e.RuleViolation) string {
+	for _, c := range lookup(locale) {
+		if message, ok := c.Rules[violation.Rule]; ok {
+			for name, value := range violation.Params {
+				message = strings.ReplaceAll(message, "{"+name+"}", value)
+			}
+			return message
+		}
+	}
+	return violation.Message
+}
+
+// lookup returns the catalogs to search for locale, most specific first.
+func lookup(locale string) []catalog {
+	var found []catalog
+	for locale != "" && locale != DefaultLocale {
+		if c, ok := catalogs[locale]; ok {
+			found = append(found, c)
+		}
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return found
+}
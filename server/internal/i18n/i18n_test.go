@@ -0,0 +1,66 @@
+package i18n
+
+import (
+	e "server/internal/errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences []string
+		want        string
+	}{
+		{name: "no preference", preferences: []string{"", ""}, want: DefaultLocale},
+		{name: "accept language", preferences: []string{"", "fr-FR,fr;q=0.9,en;q=0.8"}, want: "fr"},
+		{name: "quality order", preferences: []string{"", "de;q=0.5,es;q=0.9"}, want: "es"},
+		{name: "regional variant", preferences: []string{"", "es-MX"}, want: "es"},
+		{name: "preference wins", preferences: []string{"es", "fr"}, want: "es"},
+		{name: "unsupported preference is skipped", preferences: []string{"ja", "fr"}, want: "fr"},
+		{name: "invalid header", preferences: []string{"", "!!"}, want: DefaultLocale},
+		{name: "unsupported language", preferences: []string{"", "ja"}, want: DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.preferences...))
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "Utilisateur introuvable", ErrorMessage("fr", e.UserNotFound, "user not found"))
+	assert.Equal(t, "Utilisateur introuvable", ErrorMessage("fr-CA", e.UserNotFound, "user not found"), "falls back to the base language")
+	assert.Equal(t, "user not found", ErrorMessage(DefaultLocale, e.UserNotFound, "user not found"))
+	assert.Equal(t, "unknown", ErrorMessage("fr", e.ErrorCode("unknown"), "unknown"), "falls back to the English message")
+}
+
+func TestRuleMessage(t *testing.T) {
+	violation := e.RuleViolation{Field: "password", Rule: "min_length", Message: "must be at least 8 characters long", Params: map[string]string{"min": "8"}}
+
+	assert.Equal(t, "doit contenir au moins 8 caractères", RuleMessage("fr", violation))
+	assert.Equal(t, violation.Message, RuleMessage(DefaultLocale, violation))
+	assert.Equal(t, "unknown rule", RuleMessage("es", e.RuleViolation{Rule: "unknown", Message: "unknown rule"}))
+}
+
+func TestCatalogsUseKnownCodes(t *testing.T) {
+	for locale, c := range catalogs {
+		for code := range c.Errors {
+			assert.NotZero(t, e.StatusOf(code), "catalog %s translates unknown code %q", locale, code)
+		}
+	}
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	reference := catalogs["fr"]
+	for locale, c := range catalogs {
+		for code := range reference.Errors {
+			assert.Contains(t, c.Errors, code, "catalog %s misses code %q", locale, code)
+		}
+		for rule := range reference.Rules {
+			assert.Contains(t, c.Rules, rule, "catalog %s misses rule %q", locale, rule)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package jobs runs background work out of the request path. Jobs are stored in a
+// repositories.JobRepository, so they survive restarts and are shared by every server,
+// and are run by a pool of workers with the handler registered for their type.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"server/config"
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"sync"
+	"time"
+)
+
+// Handler runs a job. If it returns an error, the job is retried later, unless the
+// error is Permanent or the job is out of attempts, in which case the job is dead.
+// ctx is cancelled when the job exceeds the lock timeout or the runner is stopped.
+type Handler func(ctx context.Context, job models.Job) error
+
+// Option changes a job before it is enqueued.
+type Option func(job *models.Job)
+
+// RunAt delays a job until the given time.
+func RunAt(t time.Time) Option {
+	return func(job *models.Job) { job.RunAt = t }
+}
+
+// RunIn delays a job by the given duration.
+func RunIn(delay time.Duration) Option {
+	return func(job *models.Job) { job.RunAt = job.RunAt.Add(delay) }
+}
+
+// MaxAttempts overrides the number of times a job is tried.
+func MaxAttempts(attempts int) Option {
+	return func(job *models.Job) { job.MaxAttempts = attempts }
+}
+
+// UniqueKey prevents enqueuing a job while another job with the same key is queued.
+func UniqueKey(key string) Option {
+	return func(job *models.Job) { job.UniqueKey = key }
+}
+
+// lastAttemptKey is the context key telling handlers whether they run the last attempt
+// of their job.
+type lastAttemptKey struct{}
+
+// LastAttempt reports whether the job of a handler's ctx is on its last attempt, so
+// the handler can record that it failed for good.
+func LastAttempt(ctx context.Context) bool {
+	last, _ := ctx.Value(lastAttemptKey{}).(bool)
+	return last
+}
+
+// permanentError is an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. because the payload is invalid, so
+// the job is dead right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	return errors.As(err, new(permanentError))
+}
+
+// Runner enqueues jobs and runs them with a pool of workers. Handlers and schedules
+// are registered before Start, and Shutdown stops the workers.
+type Runner struct {
+	repo      repositories.JobRepository
+	cfg       config.JobsConfig
+	now       func() time.Time
+	handlers  map[string]Handler
+	schedules []scheduled
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewRunner creates a Runner storing its jobs in repo.
+func NewRunner(repo repositories.JobRepository, cfg config.JobsConfig) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		repo:     repo,
+		cfg:      cfg,
+		now:      time.Now,
+		handlers: make(map[string]Handler),
+		ctx:      ctx,
+		cancel:   cancel,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Handle registers the handler of the jobs of jobType. It panics if jobType already
+// has a handler.
+func (r *Runner) Handle(jobType string, handler Handler) {
+	if _, exists := r.handlers[jobType]; exists {
+		panic(fmt.Sprintf("jobs: handler already registered for %q", jobType))
+	}
+	r.handlers[jobType] = handler
+}
+
+// Register registers a handler receiving the payload of the jobs of jobType decoded
+// as a T. A payload that cannot be decoded fails the job permanently.
+func Register[T any](r *Runner, jobType string, handle func(ctx context.Context, payload T) error) {
+	r.Handle(jobType, func(ctx context.Context, job models.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("invalid payload: %w", err))
+		}
+		return handle(ctx, payload)
+	})
+}
+
+// Enqueue adds a job of jobType, run as soon as a worker is free unless delayed by
+// an option. payload is encoded as JSON.
+//
+// Parameters:
+//   - jobType: The type of the job, selecting its handler.
+//   - payload: The payload of the job, nil for none.
+//   - opts: Options delaying the job or changing its attempts or unique key.
+//
+// Returns:
+//   - string: The ID of the job, or "" if a job with the same unique key is queued.
+//   - error: An error if the payload cannot be encoded or the job cannot be stored.
+func (r *Runner) Enqueue(jobType string, payload any, opts ...Option) (string, error) {
+	return r.EnqueueIn(r.repo, jobType, payload, opts...)
+}
+
+// EnqueueIn is like Enqueue, but stores the job in repo, typically the JobRepository
+// of a repositories.Tx, so the job is enqueued if and only if the transaction commits.
+func (r *Runner) EnqueueIn(repo repositories.JobRepository, jobType string, payload any, opts ...Option) (string, error) {
+	data := []byte("{}")
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return "", fmt.Errorf("failed to encode payload of %s job: %w", jobType, err)
+		}
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     data,
+		MaxAttempts: r.cfg.MaxAttempts,
+		RunAt:       r.now(),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return repo.Enqueue(job)
+}
+
+// Start starts the schedules and cfg.Workers workers.
+func (r *Runner) Start() {
+	for _, s := range r.schedules {
+		r.wg.Add(1)
+		go r.runSchedule(s)
+	}
+	for range r.cfg.Workers {
+		r.wg.Add(1)
+		go r.work()
+	}
+}
+
+// Shutdown stops the workers and schedules, waiting for the running jobs to finish.
+// If ctx ends first, the jobs are cancelled and ctx's error is returned; jobs that do
+// not return are claimed again once their lock times out.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	defer r.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work runs due jobs until the runner is stopped, polling when there are none.
+func (r *Runner) work() {
+	defer r.wg.Done()
+
+	for {
+		ran, err := r.RunNext()
+		if err != nil {
+			log.Printf("failed to claim job: %v", err)
+		}
+		if ran {
+			select {
+			case <-r.stop:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// RunNext claims the next due job and runs it, reporting whether there was one. The
+// workers call it in a loop; tests can call it to run jobs without starting them.
+func (r *Runner) RunNext() (bool, error) {
+	job, err := r.repo.Claim(r.now().Add(-r.cfg.LockTimeout))
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	r.run(*job)
+	return true, nil
+}
+
+// run runs a claimed job and completes, retries or buries it.
+func (r *Runner) run(job models.Job) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.cfg.LockTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, lastAttemptKey{}, job.Attempts >= job.MaxAttempts)
+
+	err := r.handle(ctx, job)
+	switch {
+	case err == nil:
+		err = r.repo.Complete(job.ID)
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		log.Printf("%s job %s failed after %d attempts, giving up: %v", job.Type, job.ID, job.Attempts, err)
+		err = r.repo.Bury(job.ID, err.Error())
+	default:
+		delay := r.backoff(job.Attempts)
+		log.Printf("%s job %s failed, retrying in %s: %v", job.Type, job.ID, delay, err)
+		err = r.repo.Retry(job.ID, r.now().Add(delay), err.Error())
+	}
+
+	if err != nil {
+		log.Printf("failed to update %s job %s: %v", job.Type, job.ID, err)
+	}
+}
+
+// handle runs the handler of job, turning panics into errors.
+func (r *Runner) handle(ctx context.Context, job models.Job) (err error) {
+	handler, ok := r.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler registered for %s jobs", job.Type)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panicked: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff returns the delay before retrying a job that failed its nth attempt:
+// cfg.InitialBackoff, doubled on every attempt up to cfg.MaxBackoff.
+func (r *Runner) backoff(attempts int) time.Duration {
+	delay := r.cfg.InitialBackoff
+	for i := 1; i < attempts && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxBackoff)
+}
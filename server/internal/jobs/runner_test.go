@@ -0,0 +1,310 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"server/config"
+	"server/internal/api/repositories"
+	"server/internal/api/repositories/memory"
+	"server/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = config.JobsConfig{
+	Workers:        2,
+	PollInterval:   5 * time.Millisecond,
+	LockTimeout:    time.Minute,
+	MaxAttempts:    3,
+	InitialBackoff: 0,
+	MaxBackoff:     time.Hour,
+}
+
+func newTestRunner(t *testing.T) (*Runner, repositories.JobRepository) {
+	repo := memory.NewJobRepository(memory.NewStore())
+	runner := NewRunner(repo, testConfig)
+	t.Cleanup(func() { runner.Shutdown(context.Background()) })
+	return runner, repo
+}
+
+func findJob(t *testing.T, repo repositories.JobRepository, jobID string) *models.Job {
+	t.Helper()
+	job, err := repo.Find(jobID)
+	require.NoError(t, err)
+	return job
+}
+
+func TestRunNext(t *testing.T) {
+	type payload struct {
+		Email string `json:"email"`
+	}
+
+	t.Run("typed handler - completes the job", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+		var received payload
+		Register(runner, "email", func(ctx context.Context, p payload) error {
+			received = p
+			return nil
+		})
+
+		jobID, err := runner.Enqueue("email", payload{Email: "user@example.com"})
+		require.NoError(t, err)
+
+		ran, err := runner.RunNext()
+		require.NoError(t, err)
+		assert.True(t, ran)
+		assert.Equal(t, "user@example.com", received.Email)
+		assert.Nil(t, findJob(t, repo, jobID), "succeeded jobs are deleted")
+
+		ran, err = runner.RunNext()
+		require.NoError(t, err)
+		assert.False(t, ran)
+	})
+
+	t.Run("failing handler - retries then buries the job", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+		runner.Handle("flaky", func(ctx context.Context, job models.Job) error {
+			return errors.New("unavailable")
+		})
+
+		jobID, err := runner.Enqueue("flaky", nil, MaxAttempts(2))
+		require.NoError(t, err)
+
+		_, err = runner.RunNext()
+		require.NoError(t, err)
+		job := findJob(t, repo, jobID)
+		assert.Equal(t, models.JobPending, job.Status)
+		assert.Equal(t, "unavailable", job.LastError)
+
+		_, err = runner.RunNext()
+		require.NoError(t, err)
+		job = findJob(t, repo, jobID)
+		assert.Equal(t, models.JobDead, job.Status)
+		assert.Equal(t, 2, job.Attempts)
+	})
+
+	t.Run("last attempt - is reported to the handler", func(t *testing.T) {
+		runner, _ := newTestRunner(t)
+		var attempts []bool
+		runner.Handle("flaky", func(ctx context.Context, job models.Job) error {
+			attempts = append(attempts, LastAttempt(ctx))
+			return errors.New("unavailable")
+		})
+
+		_, err := runner.Enqueue("flaky", nil, MaxAttempts(2))
+		require.NoError(t, err)
+		for range 2 {
+			_, err = runner.RunNext()
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []bool{false, true}, attempts)
+	})
+
+	t.Run("permanent error - buries the job at once", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+		runner.Handle("invalid", func(ctx context.Context, job models.Job) error {
+			return Permanent(errors.New("bad request"))
+		})
+
+		jobID, err := runner.Enqueue("invalid", nil)
+		require.NoError(t, err)
+		_, err = runner.RunNext()
+		require.NoError(t, err)
+
+		job := findJob(t, repo, jobID)
+		assert.Equal(t, models.JobDead, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+	})
+
+	t.Run("invalid payload - buries the job at once", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+		Register(runner, "email", func(ctx context.Context, p payload) error { return nil })
+
+		jobID, err := runner.Enqueue("email", "not an object")
+		require.NoError(t, err)
+		_, err = runner.RunNext()
+		require.NoError(t, err)
+
+		assert.Equal(t, models.JobDead, findJob(t, repo, jobID).Status)
+	})
+
+	t.Run("panicking handler - retries the job", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+		runner.Handle("panic", func(ctx context.Context, job models.Job) error {
+			panic("oops")
+		})
+
+		jobID, err := runner.Enqueue("panic", nil)
+		require.NoError(t, err)
+		_, err = runner.RunNext()
+		require.NoError(t, err)
+
+		job := findJob(t, repo, jobID)
+		assert.Equal(t, models.JobPending, job.Status)
+		assert.Contains(t, job.LastError, "oops")
+	})
+
+	t.Run("unknown type - retries the job", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+
+		jobID, err := runner.Enqueue("unknown", nil)
+		require.NoError(t, err)
+		_, err = runner.RunNext()
+		require.NoError(t, err)
+
+		job := findJob(t, repo, jobID)
+		assert.Equal(t, models.JobPending, job.Status)
+		assert.Contains(t, job.LastError, "no handler")
+	})
+
+	t.Run("delayed job - is not run before its time", func(t *testing.T) {
+		runner, _ := newTestRunner(t)
+		runner.Handle("later", func(ctx context.Context, job models.Job) error { return nil })
+
+		_, err := runner.Enqueue("later", nil, RunIn(time.Hour))
+		require.NoError(t, err)
+
+		ran, err := runner.RunNext()
+		require.NoError(t, err)
+		assert.False(t, ran)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	runner := NewRunner(nil, config.JobsConfig{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute})
+
+	assert.Equal(t, 10*time.Second, runner.backoff(1))
+	assert.Equal(t, 20*time.Second, runner.backoff(2))
+	assert.Equal(t, 40*time.Second, runner.backoff(3))
+	assert.Equal(t, time.Minute, runner.backoff(4))
+	assert.Equal(t, time.Minute, runner.backoff(10))
+}
+
+func TestWorkers(t *testing.T) {
+	t.Run("run enqueued jobs", func(t *testing.T) {
+		runner, _ := newTestRunner(t)
+		done := make(chan string, 3)
+		Register(runner, "echo", func(ctx context.Context, message string) error {
+			done <- message
+			return nil
+		})
+		runner.Start()
+
+		for _, message := range []string{"a", "b", "c"} {
+			_, err := runner.Enqueue("echo", message)
+			require.NoError(t, err)
+		}
+
+		var received []string
+		for range 3 {
+			select {
+			case message := <-done:
+				received = append(received, message)
+			case <-time.After(time.Second):
+				t.Fatal("jobs were not run")
+			}
+		}
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, received)
+	})
+
+	t.Run("shutdown waits for running jobs", func(t *testing.T) {
+		runner, repo := newTestRunner(t)
+		started, release := make(chan struct{}), make(chan struct{})
+		runner.Handle("slow", func(ctx context.Context, job models.Job) error {
+			close(started)
+			<-release
+			return nil
+		})
+		runner.Start()
+
+		jobID, err := runner.Enqueue("slow", nil)
+		require.NoError(t, err)
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, runner.Shutdown(ctx), context.DeadlineExceeded)
+
+		close(release)
+		assert.NoError(t, runner.Shutdown(context.Background()))
+		assert.Nil(t, findJob(t, repo, jobID))
+	})
+
+	t.Run("shutdown cancels jobs after the timeout", func(t *testing.T) {
+		runner, _ := newTestRunner(t)
+		started, cancelled := make(chan struct{}), make(chan struct{})
+		runner.Handle("stuck", func(ctx context.Context, job models.Job) error {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		})
+		runner.Start()
+
+		_, err := runner.Enqueue("stuck", nil)
+		require.NoError(t, err)
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, runner.Shutdown(ctx), context.Canceled)
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("the job was not cancelled")
+		}
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	t.Run("every - aligns occurrences", func(t *testing.T) {
+		at := time.Date(2024, 5, 1, 10, 17, 42, 0, time.UTC)
+
+		assert.Equal(t, time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), Every(time.Hour).Next(at))
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), Every(15*time.Minute).Next(at))
+	})
+
+	t.Run("cron - parses standard expressions", func(t *testing.T) {
+		schedule, err := Cron("CRON_TZ=UTC 0 3 * * *")
+		require.NoError(t, err)
+		at := time.Date(2024, 5, 1, 10, 17, 42, 0, time.UTC)
+		assert.Equal(t, time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC), schedule.Next(at))
+
+		_, err = Cron("not a schedule")
+		assert.Error(t, err)
+	})
+
+	t.Run("enqueues each occurrence once", func(t *testing.T) {
+		repo := memory.NewJobRepository(memory.NewStore())
+		cfg := testConfig
+		cfg.Workers = 0
+		// The runners are an hour late, so the next occurrence is already due.
+		lateNow := func() time.Time { return time.Now().Add(-time.Hour) }
+		for range 2 {
+			runner := NewRunner(repo, cfg)
+			runner.now = lateNow
+			runner.Schedule("report", Every(time.Hour), map[string]int{"n": 1})
+			runner.Start()
+			defer runner.Shutdown(context.Background())
+		}
+
+		var job *models.Job
+		require.Eventually(t, func() bool {
+			var err error
+			job, err = repo.Claim(time.Now().Add(-time.Minute))
+			return err == nil && job != nil
+		}, time.Second, 5*time.Millisecond, "the occurrence was not enqueued")
+		assert.Equal(t, "report", job.Type)
+		assert.JSONEq(t, `{"n":1}`, string(job.Payload))
+
+		time.Sleep(20 * time.Millisecond)
+		job, err := repo.Claim(time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Nil(t, job, "each occurrence is enqueued once")
+	})
+}
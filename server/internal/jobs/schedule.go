@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule computes the occurrences of a scheduled job.
+type Schedule interface {
+	// Next returns the first occurrence after the given time, or the zero time if
+	// there is none.
+	Next(after time.Time) time.Time
+}
+
+// every is a Schedule with an occurrence at every multiple of a duration.
+type every time.Duration
+
+// Every returns a Schedule with an occurrence every interval, which must be positive.
+// Occurrences are aligned on multiples of interval since the Unix epoch, so every
+// server agrees on them.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+func (e every) Next(after time.Time) time.Time {
+	interval := time.Duration(e)
+	return after.Truncate(interval).Add(interval)
+}
+
+// Cron returns the Schedule of a standard cron expression, e.g. "0 3 * * *" for every
+// day at 03:00, or a descriptor like "@hourly". Times are in the local time zone,
+// unless the expression starts with one like "CRON_TZ=UTC ".
+func Cron(spec string) (Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// scheduled is a job enqueued at every occurrence of a schedule.
+type scheduled struct {
+	jobType  string
+	schedule Schedule
+	payload  any
+}
+
+// Schedule enqueues a job of jobType with payload at every occurrence of schedule,
+// once the runner is started. Each occurrence is enqueued with a unique key, so it
+// runs once however many servers schedule it.
+func (r *Runner) Schedule(jobType string, schedule Schedule, payload any) {
+	r.schedules = append(r.schedules, scheduled{jobType: jobType, schedule: schedule, payload: payload})
+}
+
+// runSchedule enqueues the next occurrence of s ahead of time, then the following one
+// once it is reached, until the runner is stopped.
+func (r *Runner) runSchedule(s scheduled) {
+	defer r.wg.Done()
+
+	for next := s.schedule.Next(r.now()); !next.IsZero(); next = s.schedule.Next(r.now()) {
+		_, err := r.Enqueue(s.jobType, s.payload, RunAt(next), UniqueKey(occurrenceKey(s.jobType, next)))
+		if err != nil {
+			log.Printf("failed to schedule %s job: %v", s.jobType, err)
+		}
+
+		timer := time.NewTimer(next.Sub(r.now()))
+		select {
+		case <-r.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// occurrenceKey is the unique key of the occurrence of a scheduled job at the given time.
+func occurrenceKey(jobType string, at time.Time) string {
+	return fmt.Sprintf("%s@%d", jobType, at.Unix())
+}
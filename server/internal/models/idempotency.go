@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the outcome of a request sent with an Idempotency-Key.
+//
+// Fields:
+//   - Scope: the user the key belongs to, empty for unauthenticated requests.
+//   - Key: the Idempotency-Key header.
+//   - Fingerprint: the HMAC-SHA-256 of the method, path and body of the request.
+//   - StatusCode: the status of the response, 0 while the request is in progress.
+//   - ContentType, Body: the stored response.
+//   - Headers: the replayed headers of the response, such as its ETag.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	Headers     map[string]string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	// JobPending jobs run once their RunAt is reached.
+	JobPending JobStatus = "pending"
+	// JobRunning jobs are claimed by a worker. They are claimed again if the worker
+	// does not finish them in time.
+	JobRunning JobStatus = "running"
+	// JobDead jobs failed permanently or ran out of attempts, and are kept for inspection.
+	JobDead JobStatus = "dead"
+)
+
+// Job is a unit of background work, handled by the handler registered for its Type.
+// Succeeded jobs are deleted.
+type Job struct {
+	ID          string
+	Type        string
+	Payload     []byte
+	Status      JobStatus
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	// UniqueKey, when set, prevents enqueuing the job while another job with the same
+	// key is queued, e.g. for the occurrences of a scheduled job.
+	UniqueKey string
+	CreatedAt time.Time
+}
@@ -1,11 +1,19 @@
 package models
 
+import (
+	"server/internal/errors"
+	"time"
+)
+
 type RequiredUserID struct {
 	UserID string `uri:"id" binding:"required,uuid"`
 }
 
+// ImageURLField is the JSON name of the image URL in request bodies.
+const ImageURLField = "imageURL"
+
 type RequiredImageURL struct {
-	ImageURL string `uri:"image_url" binding:"required,url"`
+	ImageURL string `json:"imageURL" uri:"image_url" binding:"required,url"`
 }
 
 // Get Image Types
@@ -29,3 +37,93 @@ type UnlikeImageResponse struct {
 	Success bool   `json:"success"`
 	Image   string `json:"image"`
 }
+
+// Undo Unlike types
+type UndoUnlikeRequestURL RequiredUserID
+type UndoUnlikeRequestBody RequiredImageURL
+type UndoUnlikeResponse struct {
+	Success bool   `json:"success"`
+	Image   string `json:"image"`
+}
+
+// Batch types
+const (
+	LikeAction   = "like"
+	UnlikeAction = "unlike"
+)
+
+type LikeOperation struct {
+	Action   string `json:"action" binding:"required,oneof=like unlike" enums:"like,unlike" example:"like"`
+	ImageURL string `json:"imageURL" binding:"required" example:"https://images.dog.ceo/breeds/hound-afghan/n02088094_1003.jpg"`
+}
+
+type BatchLikeImagesRequestURL RequiredUserID
+type BatchLikeImagesRequestBody struct {
+	Operations []LikeOperation `json:"operations" binding:"required,dive"`
+}
+
+// LikeOperationResult is the outcome of one operation of a batch or import, or of an
+// action of a swipe session. Failed operations have the code and message of the
+// error, successful ones have neither.
+type LikeOperationResult struct {
+	Action   string           `json:"action" enums:"like,unlike,skip"`
+	ImageURL string           `json:"imageURL"`
+	Success  bool             `json:"success"`
+	Code     errors.ErrorCode `json:"code,omitempty"`
+	Detail   string           `json:"detail,omitempty"`
+}
+
+type BatchLikeImagesResponse struct {
+	Applied int                   `json:"applied"`
+	Failed  int                   `json:"failed"`
+	Results []LikeOperationResult `json:"results"`
+}
+
+// Import and export types
+type ImportLikedImagesRequestURL RequiredUserID
+type ExportLikedImagesRequestURL RequiredUserID
+
+// ImportLikedImagesRequestBody is the JSON import format, the same as the JSON export.
+type ImportLikedImagesRequestBody struct {
+	Images []string `json:"images" binding:"required"`
+}
+
+type ImportLikedImagesResponse BatchLikeImagesResponse
+
+const (
+	ExportFormatJSON = "json"
+	ExportFormatCSV  = "csv"
+)
+
+type ExportLikedImagesQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=json csv"`
+}
+
+// Stream types
+type StreamLikedImagesRequestURL RequiredUserID
+
+// LikesVersion identifies a state of the list of images liked by a user.
+//
+// Fields:
+//   - Version: incremented on every like and unlike, 0 if the user never liked an image.
+//   - UpdatedAt: the time of the last change, zero if the user never liked an image.
+type LikesVersion struct {
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// LikeStreamEvent is a like or unlike pushed to the streams of a user. Its ID is the
+// LikesVersion of the user after the change, so a stream can resume after it.
+type LikeStreamEvent struct {
+	ID        int64     `json:"id" example:"42"`
+	Type      string    `json:"type" enums:"image.liked,image.unliked"`
+	UserID    string    `json:"user_id"`
+	ImageURL  string    `json:"image_url" example:"https://images.dog.ceo/breeds/hound-afghan/n02088094_1003.jpg"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LikesStreamSync is the data of the ready and reset events of a stream: the version
+// of the liked images the stream is in sync with.
+type LikesStreamSync struct {
+	Version int64 `json:"version" example:"42"`
+}
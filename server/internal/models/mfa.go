@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// UserMFA holds the TOTP enrollment of a user.
+// Enabled is false until the enrollment is confirmed with a first code.
+type UserMFA struct {
+	UserID          string
+	Secret          string
+	Enabled         bool
+	LastUsedCounter int64
+	FailedAttempts  int
+	LockedUntil     *time.Time
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFAConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "server/internal/errors"
+
+// Actions of swipe sessions.
+const (
+	SwipeLike = "like"
+	SwipeSkip = "skip"
+)
+
+// Types of the messages sent in swipe sessions.
+const (
+	SwipeImageMessage  = "image"
+	SwipeResultMessage = "result"
+	SwipeErrorMessage  = "error"
+)
+
+type SwipeRequestURL RequiredUserID
+
+// SwipeAction is a message of the client, liking or skipping an image it was sent.
+type SwipeAction struct {
+	Action   string `json:"action" enums:"like,skip" example:"like"`
+	ImageURL string `json:"imageURL" example:"https://images.dog.ceo/breeds/hound-afghan/n02088094_1003.jpg"`
+}
+
+// SwipeImage is a random image offered to the client, Liked if the user already
+// liked it.
+type SwipeImage struct {
+	ImageURL string `json:"imageURL" example:"https://images.dog.ceo/breeds/hound-afghan/n02088094_1003.jpg"`
+	Liked    bool   `json:"liked"`
+}
+
+// SwipeError is a failure not tied to an action, such as a malformed message or the
+// Dog API being unavailable.
+type SwipeError struct {
+	Code   errors.ErrorCode `json:"code,omitempty"`
+	Detail string           `json:"detail"`
+}
+
+// SwipeMessage is a message sent to the client: an image, the result of an action,
+// or an error, depending on its Type.
+type SwipeMessage struct {
+	Type   string               `json:"type" enums:"image,result,error"`
+	Image  *SwipeImage          `json:"image,omitempty"`
+	Result *LikeOperationResult `json:"result,omitempty"`
+	Error  *SwipeError          `json:"error,omitempty"`
+}
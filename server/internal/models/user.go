@@ -12,7 +12,7 @@ type User struct {
 
 type UserCredentials struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"`
 }
 
 type UserResponse struct {
@@ -27,6 +27,8 @@ type LoginUserRequest = UserCredentials
 type CreateUserResponse = UserResponse
 
 type LoginUserResponse struct {
-	Token string `json:"token"`
-	ID    string `json:"id"`
+	Token       string `json:"token"`
+	ID          string `json:"id"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
 }
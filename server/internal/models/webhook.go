@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of the events delivered to webhooks.
+const (
+	ImageLikedEvent   = "image.liked"
+	ImageUnlikedEvent = "image.unliked"
+)
+
+// OutboxEvent is an event written in the transaction of the change it describes,
+// waiting to be relayed to the webhooks.
+type OutboxEvent struct {
+	ID        string
+	Type      string
+	UserID    string
+	Data      []byte
+	CreatedAt time.Time
+}
+
+// LikeEventData is the data of ImageLikedEvent and ImageUnlikedEvent.
+type LikeEventData struct {
+	UserID   string `json:"user_id" example:"5f8d0d55-b54a-4b8e-9c1a-3d6f2a7b9c10"`
+	ImageURL string `json:"image_url" example:"https://images.dog.ceo/breeds/hound-afghan/n02088094_1003.jpg"`
+}
+
+// NewLikeEvent returns the event of a like or unlike of an image by a user.
+func NewLikeEvent(eventType, userID, imageURL string) OutboxEvent {
+	data, _ := json.Marshal(LikeEventData{UserID: userID, ImageURL: imageURL})
+	return OutboxEvent{Type: eventType, UserID: userID, Data: data}
+}
+
+// WebhookPayload is the body POSTed to webhooks.
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type" enums:"image.liked,image.unliked"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// Webhook is an endpoint events are delivered to. Webhooks of a user receive the
+// events of that user; webhooks registered by an admin have no UserID and receive
+// the events of every user.
+type Webhook struct {
+	ID        string
+	UserID    string
+	URL       string
+	Secret    string
+	CreatedAt time.Time
+}
+
+// DeliveryStatus is the state of a webhook delivery.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are waiting for their first attempt or a retry.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded deliveries got a 2xx response.
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed deliveries are out of attempts or were rejected by the endpoint.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook, with the outcome of its
+// latest attempt.
+type WebhookDelivery struct {
+	ID             string
+	WebhookID      string
+	EventID        string
+	EventType      string
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	ResponseStatus int
+	Error          string
+	CreatedAt      time.Time
+	AttemptedAt    *time.Time
+}
+
+// DeliveryAttempt is the outcome of an attempt to deliver an event.
+type DeliveryAttempt struct {
+	Status         DeliveryStatus
+	ResponseStatus int
+	Error          string
+}
+
+// Webhook types
+type WebhookRequestURL struct {
+	WebhookID string `uri:"webhook_id" binding:"required,uuid"`
+}
+
+type CreateWebhookRequestBody struct {
+	URL string `json:"url" binding:"required,url" example:"https://example.com/hooks/likes"`
+}
+
+type WebhookResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret signs the deliveries. It is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryRequestURL struct {
+	WebhookID  string `uri:"webhook_id" binding:"required,uuid"`
+	DeliveryID string `uri:"delivery_id" binding:"required,uuid"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             string     `json:"id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type" enums:"image.liked,image.unliked"`
+	Status         string     `json:"status" enums:"pending,succeeded,failed"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AttemptedAt    *time.Time `json:"attempted_at,omitempty"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
@@ -0,0 +1,184 @@
+// Package pubsub delivers the like activity of users to the streams subscribed to it.
+// A Broker delivers events to the subscriptions of its server; with a transport, such
+// as the PostgreSQL one, events are received from it so every server gets them.
+package pubsub
+
+import (
+	"server/internal/models"
+	"sync"
+)
+
+// subscriptionBuffer is the number of events a subscription holds before it is
+// considered too slow and closed.
+const subscriptionBuffer = 64
+
+// Broker dispatches the events of users to their subscriptions and keeps the latest
+// events, so reconnecting subscribers can catch up. The zero value is not usable, use
+// NewBroker.
+type Broker struct {
+	mu sync.Mutex
+	// transported is set when the events are received from a transport rather than
+	// delivered by Publish.
+	transported bool
+	subscribers map[string]map[*Subscription]struct{}
+	closed      bool
+
+	// history is a ring of the latest events of every user, next being the index
+	// the next event is written at.
+	history []models.LikeStreamEvent
+	next    int
+	full    bool
+}
+
+// NewBroker creates a Broker keeping the latest historySize events.
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		history:     make([]models.LikeStreamEvent, max(historySize, 0)),
+	}
+}
+
+// UseTransport makes the broker receive the events from a transport, which passes
+// them to Deliver on every server, this one included. The events are then sent by the
+// repositories in the transaction of the changes they describe, and Publish leaves
+// them to the transport.
+func (b *Broker) UseTransport() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.transported = true
+}
+
+// Publish delivers events, in order, once the changes they describe are committed.
+// With a transport, it does nothing, as the events are delivered when received.
+func (b *Broker) Publish(events ...models.LikeStreamEvent) {
+	b.mu.Lock()
+	transported := b.transported
+	b.mu.Unlock()
+
+	if !transported {
+		b.Deliver(events...)
+	}
+}
+
+// Deliver records events in the history and dispatches them to the subscriptions of
+// their users. Subscriptions whose buffer is full are closed, so their subscriber
+// reconnects and catches up instead of blocking the others.
+func (b *Broker) Deliver(events ...models.LikeStreamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		if len(b.history) > 0 {
+			b.history[b.next] = event
+			b.next = (b.next + 1) % len(b.history)
+			b.full = b.full || b.next == 0
+		}
+
+		for sub := range b.subscribers[event.UserID] {
+			select {
+			case sub.events <- event:
+			default:
+				b.unsubscribe(sub)
+			}
+		}
+	}
+}
+
+// History returns the events of a user with an ID greater than after still in the
+// history, oldest first.
+func (b *Broker) History(userID string, after int64) []models.LikeStreamEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start, count := 0, b.next
+	if b.full {
+		start, count = b.next, len(b.history)
+	}
+
+	var events []models.LikeStreamEvent
+	for i := range count {
+		event := b.history[(start+i)%len(b.history)]
+		if event.UserID == userID && event.ID > after {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Subscribe subscribes to the events of a user. The subscription must be closed when
+// it is no longer used. After Close, subscriptions are closed from the start.
+func (b *Broker) Subscribe(userID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{broker: b, userID: userID, events: make(chan models.LikeStreamEvent, subscriptionBuffer)}
+	if b.closed {
+		close(sub.events)
+		sub.done = true
+		return sub
+	}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Interrupt closes every subscription, making subscribers reconnect and catch up,
+// e.g. because events may have been missed while a transport was down.
+func (b *Broker) Interrupt() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subs := range b.subscribers {
+		for sub := range subs {
+			b.unsubscribe(sub)
+		}
+	}
+}
+
+// Close closes every subscription and the subscriptions made afterwards, so the
+// streams end when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.Interrupt()
+}
+
+// unsubscribe removes sub and closes its channel. b.mu must be held.
+func (b *Broker) unsubscribe(sub *Subscription) {
+	if sub.done {
+		return
+	}
+	sub.done = true
+	close(sub.events)
+
+	delete(b.subscribers[sub.userID], sub)
+	if len(b.subscribers[sub.userID]) == 0 {
+		delete(b.subscribers, sub.userID)
+	}
+}
+
+// Subscription receives the events of a user.
+type Subscription struct {
+	broker *Broker
+	userID string
+	events chan models.LikeStreamEvent
+	// done is set, under the lock of the broker, once events is closed.
+	done bool
+}
+
+// Events returns the channel of the events, closed when the subscription ends.
+func (s *Subscription) Events() <-chan models.LikeStreamEvent {
+	return s.events
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.unsubscribe(s)
+}
@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"server/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func likeEvent(userID string, id int64) models.LikeStreamEvent {
+	return models.LikeStreamEvent{ID: id, Type: models.ImageLikedEvent, UserID: userID, ImageURL: "https://example.com/dog.jpg"}
+}
+
+// receive returns the events buffered in sub, and whether it is still open.
+func receive(sub *Subscription) ([]models.LikeStreamEvent, bool) {
+	var events []models.LikeStreamEvent
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return events, false
+			}
+			events = append(events, event)
+		default:
+			return events, true
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("publish - delivers to the subscriptions of the user", func(t *testing.T) {
+		broker := NewBroker(10)
+		sub := broker.Subscribe("user-1")
+		other := broker.Subscribe("user-2")
+		defer sub.Close()
+		defer other.Close()
+
+		broker.Publish(likeEvent("user-1", 1), likeEvent("user-1", 2))
+
+		events, open := receive(sub)
+		assert.True(t, open)
+		assert.Equal(t, []models.LikeStreamEvent{likeEvent("user-1", 1), likeEvent("user-1", 2)}, events)
+		events, _ = receive(other)
+		assert.Empty(t, events)
+	})
+
+	t.Run("publish - leaves the events to the transport", func(t *testing.T) {
+		broker := NewBroker(10)
+		broker.UseTransport()
+		sub := broker.Subscribe("user-1")
+		defer sub.Close()
+
+		broker.Publish(likeEvent("user-1", 1))
+
+		events, _ := receive(sub)
+		assert.Empty(t, events, "events are delivered when received from the transport")
+	})
+
+	t.Run("history - returns the latest events of the user", func(t *testing.T) {
+		broker := NewBroker(3)
+		broker.Deliver(likeEvent("user-1", 1), likeEvent("user-2", 1), likeEvent("user-1", 2), likeEvent("user-1", 3))
+
+		assert.Equal(t, []models.LikeStreamEvent{likeEvent("user-1", 2), likeEvent("user-1", 3)}, broker.History("user-1", 0), "the first event is evicted")
+		assert.Equal(t, []models.LikeStreamEvent{likeEvent("user-1", 3)}, broker.History("user-1", 2))
+		assert.Equal(t, []models.LikeStreamEvent{likeEvent("user-2", 1)}, broker.History("user-2", 0))
+		assert.Empty(t, broker.History("user-3", 0))
+	})
+
+	t.Run("deliver - closes slow subscriptions", func(t *testing.T) {
+		broker := NewBroker(0)
+		slow := broker.Subscribe("user-1")
+
+		for i := range subscriptionBuffer + 1 {
+			broker.Deliver(likeEvent("user-1", int64(i+1)))
+		}
+
+		events, open := receive(slow)
+		assert.False(t, open)
+		assert.Len(t, events, subscriptionBuffer)
+
+		fresh := broker.Subscribe("user-1")
+		defer fresh.Close()
+		broker.Deliver(likeEvent("user-1", 100))
+		events, open = receive(fresh)
+		assert.True(t, open)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("interrupt - closes the subscriptions", func(t *testing.T) {
+		broker := NewBroker(0)
+		sub := broker.Subscribe("user-1")
+
+		broker.Interrupt()
+
+		_, open := receive(sub)
+		assert.False(t, open)
+		sub.Close()
+
+		again := broker.Subscribe("user-1")
+		defer again.Close()
+		_, open = receive(again)
+		assert.True(t, open, "subscriptions can be made after an interruption")
+	})
+
+	t.Run("close - closes current and new subscriptions", func(t *testing.T) {
+		broker := NewBroker(0)
+		sub := broker.Subscribe("user-1")
+
+		broker.Close()
+
+		_, open := receive(sub)
+		assert.False(t, open)
+		late := broker.Subscribe("user-1")
+		_, open = receive(late)
+		require.False(t, open)
+		late.Close()
+	})
+}
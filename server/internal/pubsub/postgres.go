@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"server/db/queries"
+	"server/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	listenInitialBackoff = time.Second
+	listenMaxBackoff     = 30 * time.Second
+)
+
+// PostgresTransport receives the events notified with PostgreSQL NOTIFY by the
+// repositories, in the transaction of their changes, so the events of any server
+// reach the brokers of all the servers sharing the database once committed.
+type PostgresTransport struct {
+	url string
+}
+
+// NewPostgresTransport creates a transport receiving the events notified on the
+// database at url.
+func NewPostgresTransport(url string) *PostgresTransport {
+	return &PostgresTransport{url: url}
+}
+
+// Listen delivers the notified events to broker until ctx is done. It listens on a
+// connection of its own, reconnecting with backoff when it is lost; as events may
+// have been missed meanwhile, the subscriptions of broker are then interrupted.
+func (t *PostgresTransport) Listen(ctx context.Context, broker *Broker) {
+	backoff := listenInitialBackoff
+	connected := false
+
+	for ctx.Err() == nil {
+		err := t.listen(ctx, broker, func() {
+			if connected {
+				broker.Interrupt()
+			}
+			connected = true
+			backoff = listenInitialBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("like events listener failed, reconnecting in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, listenMaxBackoff)
+	}
+}
+
+// listen connects, calls onListen once listening, and delivers the notifications
+// until the connection fails or ctx is done.
+func (t *PostgresTransport) listen(ctx context.Context, broker *Broker, onListen func()) error {
+	conn, err := pgx.Connect(ctx, t.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+queries.LikeEventsChannel); err != nil {
+		return err
+	}
+	onListen()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event models.LikeStreamEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Printf("ignoring malformed like event: %v", err)
+			continue
+		}
+		broker.Deliver(event)
+	}
+}
@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"server/db/queries"
+	"server/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// TestPostgresTransport runs against the database at TEST_DATABASE_URL. It is
+// skipped when the variable is not set.
+func TestPostgresTransport(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("pgx", url)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	transport := NewPostgresTransport(url)
+	listener := NewBroker(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		transport.Listen(ctx, listener)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	sub := listener.Subscribe("user-1")
+	defer sub.Close()
+
+	// notify notifies event in a transaction, committed if commit is set.
+	notify := func(event models.LikeStreamEvent, commit bool) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, queries.NotifyLikeEvents(tx, []models.LikeStreamEvent{event}))
+		if commit {
+			require.NoError(t, tx.Commit())
+		} else {
+			require.NoError(t, tx.Rollback())
+		}
+	}
+
+	// The listener may not be listening yet, so events are notified until one
+	// arrives.
+	require.Eventually(t, func() bool {
+		notify(likeEvent("user-1", 1), true)
+		select {
+		case event := <-sub.Events():
+			assert.Equal(t, likeEvent("user-1", 1), event)
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 10*time.Millisecond)
+
+	notify(likeEvent("user-1", 2), false)
+	notify(likeEvent("user-1", 3), true)
+	for {
+		select {
+		case event := <-sub.Events():
+			if event.ID == 1 {
+				// A late copy of the first event.
+				continue
+			}
+			assert.Equal(t, likeEvent("user-1", 3), event, "events of rolled back transactions are not sent")
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatal("the event of the committed transaction was not received")
+		}
+	}
+}
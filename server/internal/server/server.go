@@ -1,14 +1,17 @@
 package server
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"server/config"
 	h "server/internal/api/handlers"
 	m "server/internal/api/middleware"
+	"server/internal/api/repositories"
+	"server/internal/utils"
+	"sync"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
-	cors "github.com/rs/cors/wrapper/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -20,52 +23,131 @@ type Server struct {
 	userHandler        *h.UserHandler
 	dogHandler         *h.DogHandler
 	likedImagesHandler *h.LikedImagesHandler
+	jwksHandler        *h.JWKSHandler
+	oidcHandler        *h.OIDCHandler
+	sessionHandler     *h.SessionHandler
+	webhookHandler     *h.WebhookHandler
+	swipeHandler       *h.SwipeHandler
+	sessions           m.SessionValidator
+	idempotencyRepo    repositories.IdempotencyRepository
+	cookies            config.CookieConfig
+	http               config.HTTPConfig
+	adminAPIKey        string
+	onShutdown         []func()
 }
 
 // NewServer creates a new instance of Server with the provided UserHandler.
-// It initializes the router using the default settings from the gin framework.
+// It initializes the router using the default settings from the gin framework, trusting
+// the X-Forwarded-For header of the configured proxies only.
 //
 // Parameters:
 //   - userHandler: an instance of h.UserHandler to handle user-related routes.
+//   - jwksHandler: an instance of h.JWKSHandler serving the public signing keys.
+//   - oidcHandler: an instance of h.OIDCHandler to handle OpenID Connect logins.
+//   - sessionHandler: an instance of h.SessionHandler to handle session routes.
+//   - webhookHandler: an instance of h.WebhookHandler to handle webhook routes.
+//   - swipeHandler: an instance of h.SwipeHandler serving swipe sessions.
+//   - sessions: the m.SessionValidator rejecting tokens of revoked sessions.
+//   - idempotencyRepo: the repositories.IdempotencyRepository storing Idempotency-Key responses.
+//   - cookies: the attributes of the cookies set by the CSRF middleware.
+//   - httpCfg: the CORS, security header, Swagger and trusted proxy settings.
+//   - adminAPIKey: the key of the admin routes, which are not served if it is empty.
 //
 // Returns:
 //   - A pointer to a newly created Server instance.
-func NewServer(userHandler h.UserHandler, dogHandler h.DogHandler, likedImagesHandler h.LikedImagesHandler) *Server {
+func NewServer(userHandler h.UserHandler, dogHandler h.DogHandler, likedImagesHandler h.LikedImagesHandler, jwksHandler h.JWKSHandler, oidcHandler h.OIDCHandler, sessionHandler h.SessionHandler, webhookHandler h.WebhookHandler, swipeHandler h.SwipeHandler, sessions m.SessionValidator, idempotencyRepo repositories.IdempotencyRepository, cookies config.CookieConfig, httpCfg config.HTTPConfig, adminAPIKey string) *Server {
+	router := gin.Default()
+	if err := router.SetTrustedProxies(httpCfg.TrustedProxies); err != nil {
+		log.Printf("invalid TRUSTED_PROXIES: %v, trusting no proxy", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+
 	return &Server{
-		router:             gin.Default(),
+		router:             router,
 		userHandler:        &userHandler,
 		dogHandler:         &dogHandler,
 		likedImagesHandler: &likedImagesHandler,
+		jwksHandler:        &jwksHandler,
+		oidcHandler:        &oidcHandler,
+		sessionHandler:     &sessionHandler,
+		webhookHandler:     &webhookHandler,
+		swipeHandler:       &swipeHandler,
+		sessions:           sessions,
+		idempotencyRepo:    idempotencyRepo,
+		cookies:            cookies,
+		http:               httpCfg,
+		adminAPIKey:        adminAPIKey,
 	}
 }
 
 // setupRoutes initializes the API routes for the server.
 func (s *Server) setupRoutes(baseRoute string) {
+	s.router.GET("/.well-known/jwks.json", s.jwksHandler.GetJWKS)
+
 	v1 := s.router.Group(baseRoute)
+	idempotency := m.NewIdempotencyMiddleware(s.idempotencyRepo, s.http.IdempotencyWindow, []byte(s.http.IdempotencySecret))
 
 	public := v1.Group("")
 	{
 		auth := public.Group("/auth")
 		{
 			// Verify Auth Route is in protected group
-			auth.POST("register", s.userHandler.Register)
+			auth.POST("register", idempotency.Idempotent(), s.userHandler.Register)
 			auth.POST("login", s.userHandler.Login)
+			auth.POST("login/2fa", s.userHandler.LoginMFA)
+
+			oidc := auth.Group("/oidc")
+			{
+				oidc.GET("providers", s.oidcHandler.ListProviders)
+				oidc.GET("/:provider/login", s.oidcHandler.Login)
+				oidc.GET("/:provider/callback", s.oidcHandler.Callback)
+			}
 		}
 
 		public.GET("/dog/random", s.dogHandler.GetRandomImage)
-		public.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+		if s.http.EnableSwagger {
+			security := m.NewSecurityHeadersMiddleware(s.http.SecurityHeaders)
+			public.GET("/swagger/*any", security.SwaggerCSP(), ginSwagger.WrapHandler(swaggerFiles.Handler))
+		}
 		public.GET("/health", s.healthCheck)
 	}
 
-	auth := m.NewAuthMiddleware(config.GetConfig().JWTSecret)
+	auth := m.NewAuthMiddlewareWithKeyRing(utils.GetKeyRing()).WithSessions(s.sessions).WithCookies(s.cookies)
+	csrf := m.NewCSRFMiddleware(s.cookies)
 	protected := v1.Group("")
-	protected.Use(auth.VerifyJWT())
+	protected.Use(auth.VerifyJWT(), csrf.VerifyCSRF())
 	{
 		protected.GET("/auth/verify", s.userHandler.VerifyAuth)
+		protected.POST("/auth/logout", s.sessionHandler.Logout)
 	}
 	user := protected.Group("/user")
 	{
 		user.GET("/:id", s.userHandler.GetUser)
+
+		mfa := user.Group("/:id/2fa")
+		mfa.Use(auth.VerifyRequestOwnership())
+		{
+			mfa.POST("/enroll", s.userHandler.EnrollMFA)
+			mfa.POST("/confirm", s.userHandler.ConfirmMFA)
+			mfa.POST("/disable", s.userHandler.DisableMFA)
+		}
+
+		sessions := user.Group("/:id/sessions")
+		sessions.Use(auth.VerifyRequestOwnership())
+		{
+			sessions.GET("", s.sessionHandler.ListSessions)
+			sessions.DELETE("/:sid", s.sessionHandler.RevokeSession)
+		}
+
+		webhooks := user.Group("/:id/webhooks")
+		webhooks.Use(auth.VerifyRequestOwnership())
+		s.webhookRoutes(webhooks)
+	}
+
+	if s.adminAPIKey != "" {
+		admin := v1.Group("/admin")
+		admin.Use(m.RequireAdminKey(s.adminAPIKey))
+		s.webhookRoutes(admin.Group("/webhooks"))
 	}
 
 	liked_images := protected.Group("/liked_images")
@@ -73,14 +155,76 @@ func (s *Server) setupRoutes(baseRoute string) {
 	{
 		liked_images.DELETE("/:id", s.likedImagesHandler.UnlikeImage)
 		liked_images.GET("/:id", s.likedImagesHandler.GetLikedImages)
-		liked_images.POST("/:id", s.likedImagesHandler.LikeImage)
+		liked_images.POST("/:id", idempotency.Idempotent(), s.likedImagesHandler.LikeImage)
+		liked_images.POST("/:id/undo", idempotency.Idempotent(), s.likedImagesHandler.UndoUnlike)
+		liked_images.POST("/:id/batch", idempotency.Idempotent(), s.likedImagesHandler.BatchLikeImages)
+		liked_images.POST("/:id/import", idempotency.Idempotent(), s.likedImagesHandler.ImportLikedImages)
+		liked_images.GET("/:id/export", s.likedImagesHandler.ExportLikedImages)
+		liked_images.GET("/:id/stream", s.likedImagesHandler.StreamLikedImages)
+	}
+
+	dog := protected.Group("/dog")
+	dog.Use(auth.VerifyRequestOwnership())
+	{
+		dog.GET("/:id/swipe", s.swipeHandler.Swipe)
 	}
 }
 
-func (s *Server) Run(addr, baseRoute string) error {
-	s.router.Use(cors.Default())
+// webhookRoutes registers the webhook routes on group, for the user of its path or,
+// without one, for the admins.
+func (s *Server) webhookRoutes(group *gin.RouterGroup) {
+	group.GET("", s.webhookHandler.ListWebhooks)
+	group.POST("", s.webhookHandler.CreateWebhook)
+	group.DELETE("/:webhook_id", s.webhookHandler.DeleteWebhook)
+	group.GET("/:webhook_id/deliveries", s.webhookHandler.ListDeliveries)
+	group.POST("/:webhook_id/deliveries/:delivery_id/redeliver", s.webhookHandler.Redeliver)
+}
+
+// OnShutdown registers fn to be called when the server starts shutting down, e.g. to
+// end the long-lived requests that would otherwise delay the shutdown, or the hijacked
+// connections it does not track. Run waits for fn to return.
+func (s *Server) OnShutdown(fn func()) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Run serves the API on addr until ctx is done, then stops accepting connections and
+// waits for the in-flight requests, up to the configured shutdown timeout.
+func (s *Server) Run(ctx context.Context, addr, baseRoute string) error {
+	s.router.Use(
+		m.RequestID(),
+		m.Locale(),
+		m.NewCORSMiddleware(s.http.CORS),
+		m.NewSecurityHeadersMiddleware(s.http.SecurityHeaders).SetHeaders(),
+	)
 	s.setupRoutes(baseRoute)
-	return s.router.Run(addr)
+
+	srv := &http.Server{Addr: addr, Handler: s.router}
+	var onShutdown sync.WaitGroup
+	for _, fn := range s.onShutdown {
+		onShutdown.Add(1)
+		srv.RegisterOnShutdown(func() {
+			defer onShutdown.Done()
+			fn()
+		})
+	}
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("Listening and serving HTTP on %s", addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", s.http.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.http.ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	onShutdown.Wait()
+	return err
 }
 
 // HealthCheck godoc
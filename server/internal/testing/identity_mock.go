@@ -0,0 +1,50 @@
+package testing
+
+import (
+	"server/internal/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdentityBuilder struct {
+	mock *MockIdentityRepository
+}
+
+func NewIdentityMockBuilder() *MockIdentityBuilder {
+	return &MockIdentityBuilder{
+		mock: &MockIdentityRepository{},
+	}
+}
+
+// WithIdentityFound sets up the mock to return an identity linked to userID.
+func (b *MockIdentityBuilder) WithIdentityFound(provider, subject, userID string) *MockIdentityBuilder {
+	b.mock.On("FindIdentity", provider, subject).Return(&models.UserIdentity{
+		ID:       "identity-1",
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+	}, nil)
+	return b
+}
+
+// WithIdentityNotFound sets up the mock to report that the identity was never seen.
+func (b *MockIdentityBuilder) WithIdentityNotFound(provider, subject string) *MockIdentityBuilder {
+	b.mock.On("FindIdentity", provider, subject).Return(nil, nil)
+	return b
+}
+
+// WithCreateIdentity sets up the mock to link the identity to userID.
+func (b *MockIdentityBuilder) WithCreateIdentity(provider, subject, userID string) *MockIdentityBuilder {
+	b.mock.On("CreateIdentity", mock.MatchedBy(func(identity *models.UserIdentity) bool {
+		return identity.Provider == provider && identity.Subject == subject && identity.UserID == userID
+	})).Return(nil)
+	return b
+}
+
+func (b *MockIdentityBuilder) Build() *MockIdentityRepository {
+	return b.mock
+}
+
+func (b *MockIdentityBuilder) AssertExpectations(t mock.TestingT) {
+	b.mock.AssertExpectations(t)
+}
@@ -2,6 +2,8 @@ package testing
 
 import (
 	e "server/internal/errors"
+	"server/internal/models"
+	"server/internal/utils"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -33,7 +35,7 @@ func (b *MockLikedImagesBuilder) WithAddLikedImage(userID, imageURL string) *Moc
 
 // WithAddLikedImageError sets up the mock to handle AddLikedImage calls with an error (e.g., duplicate).
 func (b *MockLikedImagesBuilder) WithAddLikedImageError(userID, imageURL string) *MockLikedImagesBuilder {
-	b.mock.On("AddLikedImage", userID, imageURL).Return(e.NewError(e.ValidationErr, e.ImageAlreadyLiked, "image already liked", nil))
+	b.mock.On("AddLikedImage", userID, imageURL).Return(e.NewError(e.UserErr, e.ImageAlreadyLiked, "image already liked", nil))
 	return b
 }
 
@@ -50,13 +52,79 @@ func (b *MockLikedImagesBuilder) WithGetLikedImages(userID string) *MockLikedIma
 // WithRemovedLikedImage sets up the mock to return a successful response when removing a liked image.
 func (b *MockLikedImagesBuilder) WithRemoveLikedImage(userID, imageURL string) *MockLikedImagesBuilder {
 	b.mock.On("RemoveLikedImage", userID, imageURL).Return(nil).Run(func(args mock.Arguments) {
-		for i, img := range b.likedImages[userID] {
-			if img == imageURL {
-				b.likedImages[userID] = append(b.likedImages[userID][:i], b.likedImages[userID][i+1:]...)
-				break
+		b.removeLikedImage(userID, imageURL)
+	})
+	return b
+}
+
+// WithRemoveLikedImageError sets up the mock to handle RemoveLikedImage calls for an image that is not liked.
+func (b *MockLikedImagesBuilder) WithRemoveLikedImageError(userID, imageURL string) *MockLikedImagesBuilder {
+	b.mock.On("RemoveLikedImage", userID, imageURL).Return(e.NewError(e.UserErr, e.ImageNotLiked, "image not liked", nil))
+	return b
+}
+
+// WithRestoreLikedImage sets up the mock to return a successful response when undoing an unlike.
+func (b *MockLikedImagesBuilder) WithRestoreLikedImage(userID, imageURL string) *MockLikedImagesBuilder {
+	b.mock.On("RestoreLikedImage", userID, imageURL, mock.AnythingOfType("time.Time")).Return(nil).Run(func(args mock.Arguments) {
+		b.likedImages[userID] = append(b.likedImages[userID], imageURL)
+	})
+	return b
+}
+
+// WithRestoreLikedImageError sets up the mock to handle RestoreLikedImage calls for an image that was not recently unliked.
+func (b *MockLikedImagesBuilder) WithRestoreLikedImageError(userID, imageURL string) *MockLikedImagesBuilder {
+	b.mock.On("RestoreLikedImage", userID, imageURL, mock.AnythingOfType("time.Time")).Return(e.NewError(e.UserErr, e.NothingToUndo, "no unlike to undo", nil))
+	return b
+}
+
+// WithPurgeUnlikedImages sets up the mock to report purged unliked images.
+func (b *MockLikedImagesBuilder) WithPurgeUnlikedImages(purged int64) *MockLikedImagesBuilder {
+	b.mock.On("PurgeUnlikedImages", mock.AnythingOfType("time.Time")).Return(purged, nil)
+	return b
+}
+
+func (b *MockLikedImagesBuilder) removeLikedImage(userID, imageURL string) {
+	for i, img := range b.likedImages[userID] {
+		if img == imageURL {
+			b.likedImages[userID] = append(b.likedImages[userID][:i], b.likedImages[userID][i+1:]...)
+			break
+		}
+	}
+}
+
+// WithGetLikesVersion sets up the mock to return the given likes version.
+func (b *MockLikedImagesBuilder) WithGetLikesVersion(userID string, version models.LikesVersion) *MockLikedImagesBuilder {
+	b.mock.On("GetLikesVersion", userID).Return(version, nil)
+	return b
+}
+
+// WithLockLikesVersion sets up the mock to lock and return the given likes version.
+func (b *MockLikedImagesBuilder) WithLockLikesVersion(userID string, version models.LikesVersion) *MockLikedImagesBuilder {
+	b.mock.On("LockLikesVersion", userID).Return(version, nil)
+	return b
+}
+
+// WithApplyLikeOperations sets up the mock to apply like operations against the
+// initial liked images, failing likes of liked images and unlikes of other images.
+func (b *MockLikedImagesBuilder) WithApplyLikeOperations(userID string) *MockLikedImagesBuilder {
+	b.mock.On("ApplyLikeOperations", userID, mock.Anything).Return(func(userID string, operations []models.LikeOperation) []models.LikeOperationResult {
+		results := make([]models.LikeOperationResult, len(operations))
+		for i, op := range operations {
+			results[i] = models.LikeOperationResult{Action: op.Action, ImageURL: op.ImageURL, Success: true}
+			liked := utils.IsImageLiked(b.likedImages[userID], op.ImageURL)
+			switch {
+			case op.Action == models.LikeAction && liked:
+				results[i].Success, results[i].Code = false, e.ImageAlreadyLiked
+			case op.Action == models.UnlikeAction && !liked:
+				results[i].Success, results[i].Code = false, e.ImageNotLiked
+			case op.Action == models.LikeAction:
+				b.likedImages[userID] = append(b.likedImages[userID], op.ImageURL)
+			default:
+				b.removeLikedImage(userID, op.ImageURL)
 			}
 		}
-	})
+		return results
+	}, nil)
 	return b
 }
 
@@ -0,0 +1,85 @@
+package testing
+
+import (
+	"server/internal/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMFABuilder struct {
+	mock *MockMFARepository
+}
+
+func NewMFAMockBuilder() *MockMFABuilder {
+	return &MockMFABuilder{
+		mock: &MockMFARepository{},
+	}
+}
+
+// WithMFADisabled sets up the mock to report that the user never enrolled.
+func (b *MockMFABuilder) WithMFADisabled(userID string) *MockMFABuilder {
+	b.mock.On("GetMFA", userID).Return(nil, nil)
+	return b
+}
+
+// WithMFA sets up the mock to return an enrollment with the given secret.
+func (b *MockMFABuilder) WithMFA(userID, secret string, enabled bool) *MockMFABuilder {
+	b.mock.On("GetMFA", userID).Return(&models.UserMFA{
+		UserID:  userID,
+		Secret:  secret,
+		Enabled: enabled,
+	}, nil)
+	return b
+}
+
+// WithLockedMFA sets up the mock to return an enabled enrollment locked after too many failures.
+func (b *MockMFABuilder) WithLockedMFA(userID, secret string) *MockMFABuilder {
+	lockedUntil := time.Now().Add(time.Hour)
+	b.mock.On("GetMFA", userID).Return(&models.UserMFA{
+		UserID:      userID,
+		Secret:      secret,
+		Enabled:     true,
+		LockedUntil: &lockedUntil,
+	}, nil)
+	return b
+}
+
+func (b *MockMFABuilder) WithSavePendingMFA(userID string) *MockMFABuilder {
+	b.mock.On("SavePendingMFA", userID, mock.AnythingOfType("string")).Return(nil)
+	return b
+}
+
+func (b *MockMFABuilder) WithEnableMFA(userID string) *MockMFABuilder {
+	b.mock.On("EnableMFA", userID, mock.AnythingOfType("int64"), mock.AnythingOfType("[]string")).Return(nil)
+	return b
+}
+
+func (b *MockMFABuilder) WithDisableMFA(userID string) *MockMFABuilder {
+	b.mock.On("DisableMFA", userID).Return(nil)
+	return b
+}
+
+// WithAdvanceCounter sets up the mock to accept (or reject as replayed) any verified code.
+func (b *MockMFABuilder) WithAdvanceCounter(userID string, advanced bool) *MockMFABuilder {
+	b.mock.On("AdvanceCounter", userID, mock.AnythingOfType("int64")).Return(advanced, nil)
+	return b
+}
+
+func (b *MockMFABuilder) WithUseRecoveryCode(userID, codeHash string, used bool) *MockMFABuilder {
+	b.mock.On("UseRecoveryCode", userID, codeHash).Return(used, nil)
+	return b
+}
+
+func (b *MockMFABuilder) WithRecordFailure(userID string) *MockMFABuilder {
+	b.mock.On("RecordFailure", userID, mock.AnythingOfType("int"), mock.AnythingOfType("time.Time")).Return(nil)
+	return b
+}
+
+func (b *MockMFABuilder) Build() *MockMFARepository {
+	return b.mock
+}
+
+func (b *MockMFABuilder) AssertExpectations(t mock.TestingT) {
+	b.mock.AssertExpectations(t)
+}
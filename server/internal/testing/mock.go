@@ -2,6 +2,7 @@ package testing
 
 import (
 	"server/internal/models"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -12,6 +13,14 @@ type Mock struct {
 type MockUserRepository = Mock
 type MockDogRepository = Mock
 type MockLikedImagesRepository = Mock
+type MockIdentityRepository = Mock
+type MockMFARepository = Mock
+
+// MockSessionRepository is a separate type because its Create method
+// clashes with the one of MockUserRepository.
+type MockSessionRepository struct {
+	mock.Mock
+}
 
 // Create inserts a new user into the repository and returns a response containing
 // the details of the created user or an error if the operation fails.
@@ -68,6 +77,12 @@ func (m *MockUserRepository) FindByID(id string) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+// UpdatePasswordHash mocks replacing the stored password hash of a user.
+func (m *MockUserRepository) UpdatePasswordHash(userID, passwordHash string) error {
+	args := m.Called(userID, passwordHash)
+	return args.Error(0)
+}
+
 // FindAll retrieves all users from the mock repository.
 // It returns a slice of User models and an error if the operation fails.
 //
@@ -133,3 +148,199 @@ func (m *MockLikedImagesRepository) RemoveLikedImage(userID, imageURL string) er
 	args := m.Called(userID, imageURL)
 	return args.Error(0)
 }
+
+// GetLikesVersion retrieves the version of the list of liked images of a user from the mock repository.
+//
+// Parameters:
+//   - userID: The ID of the user whose likes version is to be retrieved.
+//
+// Returns:
+//   - models.LikesVersion: The version of the user's liked images.
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockLikedImagesRepository) GetLikesVersion(userID string) (models.LikesVersion, error) {
+	args := m.Called(userID)
+	return args.Get(0).(models.LikesVersion), args.Error(1)
+}
+
+// LockLikesVersion retrieves and locks the version of the list of liked images of a user from the mock repository.
+//
+// Parameters:
+//   - userID: The ID of the user whose likes version is to be locked.
+//
+// Returns:
+//   - models.LikesVersion: The version of the user's liked images.
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockLikedImagesRepository) LockLikesVersion(userID string) (models.LikesVersion, error) {
+	args := m.Called(userID)
+	return args.Get(0).(models.LikesVersion), args.Error(1)
+}
+
+// ApplyLikeOperations likes and unlikes images for a user in the mock repository.
+//
+// Parameters:
+//   - userID: The ID of the user liking and unliking the images.
+//   - operations: The operations to apply.
+//
+// Returns:
+//   - []models.LikeOperationResult: The result of each operation.
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockLikedImagesRepository) ApplyLikeOperations(userID string, operations []models.LikeOperation) ([]models.LikeOperationResult, error) {
+	args := m.Called(userID, operations)
+	if apply, ok := args.Get(0).(func(string, []models.LikeOperation) []models.LikeOperationResult); ok {
+		return apply(userID, operations), args.Error(1)
+	}
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.LikeOperationResult), args.Error(1)
+}
+
+// RestoreLikedImage restores a like removed after unlikedSince in the mock repository.
+//
+// Parameters:
+//   - userID: The ID of the user who unliked the image.
+//   - imageURL: The URL of the unliked image.
+//   - unlikedSince: The earliest time the image can have been unliked at.
+//
+// Returns:
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockLikedImagesRepository) RestoreLikedImage(userID, imageURL string, unlikedSince time.Time) error {
+	args := m.Called(userID, imageURL, unlikedSince)
+	return args.Error(0)
+}
+
+// PurgeUnlikedImages deletes the likes removed before the given time from the mock repository.
+//
+// Parameters:
+//   - before: The time before which unliked images are purged.
+//
+// Returns:
+//   - int64: The number of purged likes.
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockLikedImagesRepository) PurgeUnlikedImages(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// FindIdentity retrieves the identity a provider issued for a subject from the mock repository.
+//
+// Parameters:
+//   - provider: The name of the OIDC provider.
+//   - subject: The subject identifier issued by the provider.
+//
+// Returns:
+//   - *models.UserIdentity: A pointer to the identity if found, otherwise nil.
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockIdentityRepository) FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	args := m.Called(provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserIdentity), args.Error(1)
+}
+
+// CreateIdentity stores a new identity in the mock repository.
+//
+// Parameters:
+//   - identity: A pointer to the identity to be created.
+//
+// Returns:
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockIdentityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+// GetMFA retrieves the two-factor enrollment of a user from the mock repository.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - *models.UserMFA: A pointer to the enrollment if found, otherwise nil.
+//   - error: An error object if the operation fails, otherwise nil.
+func (m *MockMFARepository) GetMFA(userID string) (*models.UserMFA, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserMFA), args.Error(1)
+}
+
+// SavePendingMFA stores a pending TOTP secret in the mock repository.
+func (m *MockMFARepository) SavePendingMFA(userID, secret string) error {
+	args := m.Called(userID, secret)
+	return args.Error(0)
+}
+
+// EnableMFA confirms the enrollment of a user in the mock repository.
+func (m *MockMFARepository) EnableMFA(userID string, counter int64, recoveryCodeHashes []string) error {
+	args := m.Called(userID, counter, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+// DisableMFA removes the enrollment of a user from the mock repository.
+func (m *MockMFARepository) DisableMFA(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// AdvanceCounter records the time step of a verified code in the mock repository.
+func (m *MockMFARepository) AdvanceCounter(userID string, counter int64) (bool, error) {
+	args := m.Called(userID, counter)
+	return args.Bool(0), args.Error(1)
+}
+
+// UseRecoveryCode consumes a recovery code in the mock repository.
+func (m *MockMFARepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	args := m.Called(userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+// RecordFailure counts a failed verification in the mock repository.
+func (m *MockMFARepository) RecordFailure(userID string, maxAttempts int, lockUntil time.Time) error {
+	args := m.Called(userID, maxAttempts, lockUntil)
+	return args.Error(0)
+}
+
+// Create stores a new session in the mock repository and returns its ID.
+func (m *MockSessionRepository) Create(session *models.Session) (string, error) {
+	args := m.Called(session)
+	return args.String(0), args.Error(1)
+}
+
+// Find retrieves a session by its ID from the mock repository.
+func (m *MockSessionRepository) Find(sessionID string) (*models.Session, error) {
+	args := m.Called(sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+// ListActive retrieves the active sessions of a user from the mock repository.
+func (m *MockSessionRepository) ListActive(userID string, since time.Time) ([]models.Session, error) {
+	args := m.Called(userID, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+// Touch records that a session was used in the mock repository.
+func (m *MockSessionRepository) Touch(sessionID string) error {
+	args := m.Called(sessionID)
+	return args.Error(0)
+}
+
+// Revoke revokes a session in the mock repository.
+func (m *MockSessionRepository) Revoke(userID, sessionID string) (bool, error) {
+	args := m.Called(userID, sessionID)
+	return args.Bool(0), args.Error(1)
+}
+
+// Purge deletes the sessions revoked or last seen before the given time from the mock repository.
+func (m *MockSessionRepository) Purge(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
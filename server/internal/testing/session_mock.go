@@ -0,0 +1,87 @@
+package testing
+
+import (
+	"server/internal/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// SessionID is the ID of the sessions created by the session mock.
+const SessionID = "6f1c2b0e-8d4a-4c2e-9a57-3b8e1f0d2c44"
+
+type MockSessionBuilder struct {
+	mock *MockSessionRepository
+}
+
+func NewSessionMockBuilder() *MockSessionBuilder {
+	return &MockSessionBuilder{
+		mock: &MockSessionRepository{},
+	}
+}
+
+// WithCreateSession sets up the mock to create a session with ID SessionID for the user.
+func (b *MockSessionBuilder) WithCreateSession(userID string) *MockSessionBuilder {
+	b.mock.On("Create", mock.MatchedBy(func(s *models.Session) bool {
+		return s.UserID == userID
+	})).Return(SessionID, nil)
+	return b
+}
+
+// WithSession sets up the mock to return the session SessionID of the user, last seen at lastSeen.
+func (b *MockSessionBuilder) WithSession(userID string, lastSeen time.Time) *MockSessionBuilder {
+	b.mock.On("Find", SessionID).Return(&models.Session{
+		ID:         SessionID,
+		UserID:     userID,
+		LastSeenAt: lastSeen,
+	}, nil)
+	return b
+}
+
+// WithRevokedSession sets up the mock to return the session SessionID of the user, revoked.
+func (b *MockSessionBuilder) WithRevokedSession(userID string) *MockSessionBuilder {
+	revokedAt := time.Now()
+	b.mock.On("Find", SessionID).Return(&models.Session{
+		ID:         SessionID,
+		UserID:     userID,
+		LastSeenAt: revokedAt,
+		RevokedAt:  &revokedAt,
+	}, nil)
+	return b
+}
+
+func (b *MockSessionBuilder) WithSessionNotFound(sessionID string) *MockSessionBuilder {
+	b.mock.On("Find", sessionID).Return(nil, nil)
+	return b
+}
+
+func (b *MockSessionBuilder) WithTouch(sessionID string) *MockSessionBuilder {
+	b.mock.On("Touch", sessionID).Return(nil)
+	return b
+}
+
+// WithActiveSessions sets up the mock to list the given sessions of the user.
+func (b *MockSessionBuilder) WithActiveSessions(userID string, sessions []models.Session) *MockSessionBuilder {
+	b.mock.On("ListActive", userID, mock.AnythingOfType("time.Time")).Return(sessions, nil)
+	return b
+}
+
+// WithRevoke sets up the mock to report whether revoking the session succeeded.
+func (b *MockSessionBuilder) WithRevoke(userID, sessionID string, revoked bool) *MockSessionBuilder {
+	b.mock.On("Revoke", userID, sessionID).Return(revoked, nil)
+	return b
+}
+
+// WithPurge sets up the mock to report purging the given number of sessions.
+func (b *MockSessionBuilder) WithPurge(purged int64) *MockSessionBuilder {
+	b.mock.On("Purge", mock.AnythingOfType("time.Time")).Return(purged, nil)
+	return b
+}
+
+func (b *MockSessionBuilder) Build() *MockSessionRepository {
+	return b.mock
+}
+
+func (b *MockSessionBuilder) AssertExpectations(t mock.TestingT) {
+	b.mock.AssertExpectations(t)
+}
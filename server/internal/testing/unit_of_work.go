@@ -0,0 +1,88 @@
+package testing
+
+import (
+	"server/internal/api/repositories"
+	"server/internal/models"
+	"slices"
+)
+
+// UnitOfWork is a repositories.UnitOfWork running its work directly on the given
+// mocks. It records whether the last work succeeded, as a database would commit it,
+// and the outbox events of the committed work. It has no webhook or job repository;
+// services using them are tested on the memory repositories.
+type UnitOfWork struct {
+	users       repositories.UserRepository
+	likedImages repositories.LikedImagesRepository
+
+	Committed  int
+	RolledBack int
+	Events     []models.OutboxEvent
+}
+
+// NewUnitOfWork creates a UnitOfWork handing out the given repositories.
+func NewUnitOfWork(users repositories.UserRepository, likedImages repositories.LikedImagesRepository) *UnitOfWork {
+	return &UnitOfWork{users: users, likedImages: likedImages}
+}
+
+func (u *UnitOfWork) Do(fn func(tx repositories.Tx) error) error {
+	events := len(u.Events)
+	if err := fn(u); err != nil {
+		u.Events = u.Events[:events]
+		u.RolledBack++
+		return err
+	}
+	u.Committed++
+	return nil
+}
+
+func (u *UnitOfWork) Users() repositories.UserRepository {
+	return u.users
+}
+
+func (u *UnitOfWork) LikedImages() repositories.LikedImagesRepository {
+	return u.likedImages
+}
+
+func (u *UnitOfWork) Outbox() repositories.OutboxRepository {
+	return outbox{u}
+}
+
+func (u *UnitOfWork) Webhooks() repositories.WebhookRepository {
+	return nil
+}
+
+func (u *UnitOfWork) Jobs() repositories.JobRepository {
+	return nil
+}
+
+func (u *UnitOfWork) LikeEvents() repositories.LikeEventRepository {
+	return likeEvents{}
+}
+
+// likeEvents sends no events; the broker of the service delivers them.
+type likeEvents struct{}
+
+func (likeEvents) Notify([]models.LikeStreamEvent) error {
+	return nil
+}
+
+// outbox records the events added in a UnitOfWork.
+type outbox struct {
+	uow *UnitOfWork
+}
+
+func (o outbox) Add(events ...models.OutboxEvent) error {
+	o.uow.Events = append(o.uow.Events, events...)
+	return nil
+}
+
+func (o outbox) Claim(limit int) ([]models.OutboxEvent, error) {
+	return slices.Clone(o.uow.Events[:min(limit, len(o.uow.Events))]), nil
+}
+
+func (o outbox) Delete(eventIDs []string) error {
+	o.uow.Events = slices.DeleteFunc(o.uow.Events, func(event models.OutboxEvent) bool {
+		return slices.Contains(eventIDs, event.ID)
+	})
+	return nil
+}
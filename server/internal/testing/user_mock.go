@@ -67,10 +67,7 @@ func (b *MockBuilder) WithDuplicateEmail(email string) *MockBuilder {
 }
 
 func (b *MockBuilder) WithDatabaseError() *MockBuilder {
-	internalErr := &e.InternalError{
-		Code:    e.DatabaseError,
-		Message: "failed to create user",
-	}
+	internalErr := e.NewError(e.InternalErr, e.DatabaseError, "failed to create user", nil)
 
 	b.mock.On("Create", mock.Anything).Return(models.CreateUserResponse{}, internalErr)
 	return b
@@ -83,6 +80,35 @@ func (b *MockBuilder) WithInvalidPassword(password string) *MockBuilder {
 	return b
 }
 
+// WithFoundByEmail sets up the mock to return the default user with the given email.
+func (b *MockBuilder) WithFoundByEmail(email string) *MockBuilder {
+	b.mock.On("FindByEmail", email).Return(&models.User{
+		ID:           user.ID,
+		Email:        email,
+		PasswordHash: successHash,
+	}, nil)
+	return b
+}
+
+// WithPasswordHash sets up the mock to return the default user with the given email and password hash
+// from both FindByEmail and FindByID.
+func (b *MockBuilder) WithPasswordHash(email, passwordHash string) *MockBuilder {
+	found := &models.User{
+		ID:           user.ID,
+		Email:        email,
+		PasswordHash: passwordHash,
+	}
+	b.mock.On("FindByEmail", email).Return(found, nil).Maybe()
+	b.mock.On("FindByID", user.ID).Return(found, nil).Maybe()
+	return b
+}
+
+// WithUpdatePasswordHash sets up the mock to accept a rehashed password for the default user.
+func (b *MockBuilder) WithUpdatePasswordHash() *MockBuilder {
+	b.mock.On("UpdatePasswordHash", user.ID, mock.AnythingOfType("string")).Return(nil)
+	return b
+}
+
 func (b *MockBuilder) WithFoundByID() *MockBuilder {
 	b.mock.On("FindByID", user.ID).Return(user, nil)
 	return b
@@ -94,10 +120,7 @@ func (b *MockBuilder) WithNotFoundByID() *MockBuilder {
 }
 
 func (b *MockBuilder) WithErrorFindByID() *MockBuilder {
-	internalErr := &e.InternalError{
-		Code:    e.DatabaseError,
-		Message: "failed to find user",
-	}
+	internalErr := e.NewError(e.InternalErr, e.DatabaseError, "failed to find user", nil)
 	b.mock.On("FindByID", user.ID).Return(nil, internalErr)
 	return b
 }
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	e "server/internal/errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Binding rules reported in RuleViolation.Rule, besides the validator tags
+// (required, email, uuid, url, ...) which are reported as is.
+const (
+	RuleMalformed = "malformed"
+	RuleType      = "type"
+	RuleMaxItems  = "max_items"
+)
+
+func init() {
+	// Report fields by the name clients send rather than the Go struct field name.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
+
+// fieldName returns the json, uri or form name of a struct field, in that order.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "uri", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// NewBindingError converts an error returned by gin binding into a ValidationError
+// listing every failed field.
+//
+// Parameters:
+//   - code: The error code of the request.
+//   - message: A human-readable description of the expected input.
+//   - err: The error returned by ShouldBindJSON, ShouldBindUri, ...
+//
+// Returns:
+//   - error: A ValidationError with one violation per failed field.
+func NewBindingError(code e.ErrorCode, message string, err error) error {
+	return e.NewValidationError(code, message, bindingViolations(err))
+}
+
+// NewMaxItemsError returns a ValidationError for a list field with more than max items.
+func NewMaxItemsError(code e.ErrorCode, field string, max int) error {
+	return e.NewValidationError(code, fmt.Sprintf("%s must have at most %d items", field, max), []e.RuleViolation{{
+		Field:   field,
+		Rule:    RuleMaxItems,
+		Message: fmt.Sprintf("must have at most %d items", max),
+		Params:  map[string]string{"max": strconv.Itoa(max)},
+	}})
+}
+
+func bindingViolations(err error) []e.RuleViolation {
+	var (
+		validationErrs validator.ValidationErrors
+		typeErr        *json.UnmarshalTypeError
+	)
+
+	switch {
+	case stderrors.As(err, &validationErrs):
+		violations := make([]e.RuleViolation, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			violation := e.RuleViolation{
+				Field:   fieldErr.Field(),
+				Rule:    fieldErr.Tag(),
+				Message: ruleMessage(fieldErr),
+			}
+			if fieldErr.Param() != "" {
+				violation.Params = map[string]string{fieldErr.Tag(): fieldErr.Param()}
+			}
+			violations = append(violations, violation)
+		}
+		return violations
+	case stderrors.As(err, &typeErr):
+		return []e.RuleViolation{{
+			Field:   typeErr.Field,
+			Rule:    RuleType,
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+			Params:  map[string]string{RuleType: typeErr.Type.String()},
+		}}
+	default:
+		return []e.RuleViolation{{
+			Rule:    RuleMalformed,
+			Message: "request is not valid JSON",
+		}}
+	}
+}
+
+func ruleMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "uuid":
+		return "must be a valid UUID"
+	case "url":
+		return "must be a valid URL"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fieldErr.Param())
+	default:
+		return fmt.Sprintf("failed the %s rule", fieldErr.Tag())
+	}
+}
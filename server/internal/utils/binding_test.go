@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	e "server/internal/errors"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBindingError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type request struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required,min=8"`
+		Age      int    `json:"age"`
+	}
+
+	bind := func(body string) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		var req request
+		err := c.ShouldBindJSON(&req)
+		require.Error(t, err)
+		return NewBindingError(e.InvalidRequest, "invalid request", err)
+	}
+
+	violations := func(err error) []e.RuleViolation {
+		validationErr, ok := err.(*e.ValidationError)
+		require.True(t, ok)
+		assert.Equal(t, e.InvalidRequest, validationErr.Code())
+		return validationErr.Violations
+	}
+
+	t.Run("reports every field by its JSON name", func(t *testing.T) {
+		err := bind(`{"email":"not-an-email","password":"short"}`)
+
+		assert.Equal(t, []e.RuleViolation{
+			{Field: "email", Rule: "email", Message: "must be a valid email address"},
+			{Field: "password", Rule: "min", Message: "must be at least 8 characters long", Params: map[string]string{"min": "8"}},
+		}, violations(err))
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		err := bind(`{}`)
+
+		assert.Equal(t, []e.RuleViolation{
+			{Field: "email", Rule: "required", Message: "is required"},
+			{Field: "password", Rule: "required", Message: "is required"},
+		}, violations(err))
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		err := bind(`{"age":"ten"}`)
+
+		assert.Equal(t, []e.RuleViolation{
+			{Field: "age", Rule: RuleType, Message: "must be of type int", Params: map[string]string{RuleType: "int"}},
+		}, violations(err))
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		err := bind(`{`)
+
+		assert.Equal(t, []e.RuleViolation{
+			{Rule: RuleMalformed, Message: "request is not valid JSON"},
+		}, violations(err))
+	})
+}
+
+func TestNewMaxItemsError(t *testing.T) {
+	err := NewMaxItemsError(e.InvalidRequest, "operations", 100)
+
+	validationErr, ok := err.(*e.ValidationError)
+	require.True(t, ok)
+	assert.Equal(t, e.InvalidRequest, validationErr.Code())
+	assert.Equal(t, []e.RuleViolation{
+		{Field: "operations", Rule: RuleMaxItems, Message: "must have at most 100 items", Params: map[string]string{"max": "100"}},
+	}, validationErr.Violations)
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserAgentLength bounds the user agent stored on a session, in bytes.
+const maxUserAgentLength = 512
+
+// ClientInfo describes the client of the request for the session it starts.
+func ClientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: TruncateUTF8(c.Request.UserAgent(), maxUserAgentLength),
+		IPAddress: c.ClientIP(),
+	}
+}
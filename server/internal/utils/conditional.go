@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StrongETag quotes tag into a strong entity tag.
+func StrongETag(tag string) string {
+	return `"` + tag + `"`
+}
+
+// MatchesETag reports whether an If-Match or If-None-Match header value lists etag,
+// or is "*". With weak comparison, W/ prefixes are ignored, as required for
+// If-None-Match; with strong comparison, as required for If-Match, weak tags never match.
+//
+// Parameters:
+//   - header: The comma separated list of entity tags sent by the client.
+//   - etag: The current entity tag of the resource.
+//   - weak: Whether to use the weak comparison function.
+//
+// Returns:
+//   - bool: true if the header matches etag.
+func MatchesETag(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// SetValidators adds the ETag and, if known, Last-Modified headers of the response.
+// A zero lastModified is omitted.
+func SetValidators(c *gin.Context, etag string, lastModified time.Time) {
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// NotModified reports whether the client's cached copy of a resource, described by
+// the If-None-Match or If-Modified-Since headers, is still current. If-None-Match takes
+// precedence; If-Modified-Since is only compared at the second precision of HTTP dates.
+//
+// Parameters:
+//   - c: The context of the GET or HEAD request.
+//   - etag: The current entity tag of the resource.
+//   - lastModified: The time of the last change to the resource, zero if unknown.
+//
+// Returns:
+//   - bool: true if the request should be answered with 304 Not Modified.
+func NotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		return MatchesETag(ifNoneMatch, etag, true)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// PreconditionFailed reports whether the request has an If-Match header that does
+// not match the current entity tag of the resource. Requests without If-Match
+// always pass.
+func PreconditionFailed(c *gin.Context, etag string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	return ifMatch != "" && !MatchesETag(ifMatch, etag, false)
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{"same tag", `"v1"`, `"v1"`, false, true},
+		{"other tag", `"v2"`, `"v1"`, false, false},
+		{"tag in list", `"v0", "v1"`, `"v1"`, false, true},
+		{"wildcard", `*`, `"v1"`, false, true},
+		{"weak header, weak comparison", `W/"v1"`, `"v1"`, true, true},
+		{"weak header, strong comparison", `W/"v1"`, `"v1"`, false, false},
+		{"weak etag, strong comparison", `"v1"`, `W/"v1"`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MatchesETag(tt.header, tt.etag, tt.weak))
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+
+	check := func(headers map[string]string, lastModified time.Time) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(http.MethodGet, "/liked_images/1", nil)
+		for name, value := range headers {
+			c.Request.Header.Set(name, value)
+		}
+		return NotModified(c, `"v1"`, lastModified)
+	}
+
+	t.Run("no validators", func(t *testing.T) {
+		assert.False(t, check(nil, lastModified))
+	})
+
+	t.Run("matching If-None-Match", func(t *testing.T) {
+		assert.True(t, check(map[string]string{"If-None-Match": `W/"v1"`}, lastModified))
+	})
+
+	t.Run("If-None-Match takes precedence over If-Modified-Since", func(t *testing.T) {
+		assert.False(t, check(map[string]string{
+			"If-None-Match":     `"v0"`,
+			"If-Modified-Since": lastModified.Format(http.TimeFormat),
+		}, lastModified))
+	})
+
+	t.Run("not modified since", func(t *testing.T) {
+		assert.True(t, check(map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, lastModified))
+	})
+
+	t.Run("modified since", func(t *testing.T) {
+		since := lastModified.Add(-time.Minute).Format(http.TimeFormat)
+		assert.False(t, check(map[string]string{"If-Modified-Since": since}, lastModified))
+	})
+
+	t.Run("unknown last modification", func(t *testing.T) {
+		assert.False(t, check(map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, time.Time{}))
+	})
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	check := func(ifMatch string) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(http.MethodPost, "/liked_images/1", nil)
+		if ifMatch != "" {
+			c.Request.Header.Set("If-Match", ifMatch)
+		}
+		return PreconditionFailed(c, `"v1"`)
+	}
+
+	assert.False(t, check(""))
+	assert.False(t, check(`"v1"`))
+	assert.True(t, check(`"v0"`))
+	assert.True(t, check(`W/"v1"`))
+}
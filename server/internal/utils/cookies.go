@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"server/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AuthCookieName holds "Bearer <jwt>". It is HttpOnly so scripts cannot read the token.
+	AuthCookieName = "auth_token"
+	// CSRFCookieName holds the CSRF token. It is readable by scripts of our origin,
+	// which echo it in CSRFHeaderName on unsafe requests.
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+
+	csrfTokenSize = 32
+)
+
+// SetCookie sets a cookie on the root path with the configured attributes.
+//
+// Parameters:
+//   - c: The gin context of the request.
+//   - cfg: The cookie attributes.
+//   - name: The cookie name.
+//   - value: The cookie value.
+//   - maxAge: The lifetime in seconds, negative to delete the cookie.
+//   - httpOnly: Whether scripts are prevented from reading the cookie.
+func SetCookie(c *gin.Context, cfg config.CookieConfig, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	})
+}
+
+// SetAuthCookies stores the JWT of a login in the HttpOnly auth cookie and
+// issues a fresh CSRF token alongside it.
+func SetAuthCookies(c *gin.Context, cfg config.CookieConfig, token string) error {
+	SetCookie(c, cfg, AuthCookieName, "Bearer "+token, int(JWTLifetime.Seconds()), true)
+	_, err := SetCSRFCookie(c, cfg)
+	return err
+}
+
+// ClearAuthCookies deletes the auth and CSRF cookies.
+func ClearAuthCookies(c *gin.Context, cfg config.CookieConfig) {
+	SetCookie(c, cfg, AuthCookieName, "", -1, true)
+	SetCookie(c, cfg, CSRFCookieName, "", -1, false)
+}
+
+// SetCSRFCookie issues a new random CSRF token and returns it.
+func SetCSRFCookie(c *gin.Context, cfg config.CookieConfig) (string, error) {
+	b := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	SetCookie(c, cfg, CSRFCookieName, token, int(JWTLifetime.Seconds()), false)
+	return token, nil
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAuthCookies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resp := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(resp)
+	cfg := config.CookieConfig{Domain: "example.com", Secure: true, SameSite: http.SameSiteLaxMode}
+
+	require.NoError(t, SetAuthCookies(c, cfg, "token"))
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range resp.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	auth := cookies[AuthCookieName]
+	require.NotNil(t, auth)
+	assert.Equal(t, "Bearer token", auth.Value)
+	assert.True(t, auth.HttpOnly)
+	assert.True(t, auth.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, auth.SameSite)
+	assert.Equal(t, "example.com", auth.Domain)
+
+	csrf := cookies[CSRFCookieName]
+	require.NotNil(t, csrf)
+	assert.NotEmpty(t, csrf.Value)
+	assert.False(t, csrf.HttpOnly)
+	assert.True(t, csrf.Secure)
+}
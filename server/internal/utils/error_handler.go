@@ -1,105 +1,154 @@
 package utils
 
 import (
+	stderrors "errors"
 	"log"
 	"net/http"
 	"server/internal/errors"
+	"server/internal/i18n"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
 )
 
+const (
+	// MIMEProblemJSON is the media type of RFC 7807 problem details.
+	MIMEProblemJSON = "application/problem+json"
+	// ProblemTypeBaseURI prefixes the error code to form the problem type URI.
+	ProblemTypeBaseURI = "/problems/"
+)
+
+// ErrorResponse is the legacy error shape, still sent to clients that only accept application/json.
 type ErrorResponse struct {
-	Error  string           `json:"error"`
-	Code   errors.ErrorCode `json:"code"`
-	Detail string           `json:"detail,omitempty"` // Optional field for detailed error messages
+	Error      string                 `json:"error"`
+	Code       errors.ErrorCode       `json:"code"`
+	Detail     string                 `json:"detail,omitempty"`     // Optional field for detailed error messages
+	Violations []errors.RuleViolation `json:"violations,omitempty"` // Optional list of failed validation rules
 }
 
-// HandleError handles different types of errors and sends an appropriate JSON response.
-// It takes a gin.Context and an error as parameters, determines the type of the error,
-// and sets the corresponding HTTP status code and error response.
+// ProblemDetails is an RFC 7807 error response, extended with our error code,
+// the id of the request and the failed validation rules.
+type ProblemDetails struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Code       errors.ErrorCode       `json:"code,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Violations []errors.RuleViolation `json:"violations,omitempty"`
+}
+
+// HandleError handles different types of errors and sends an appropriate error response.
+// It takes a gin.Context and an error as parameters, finds the first errors.AppError
+// in the error chain, so errors wrapped with fmt.Errorf("...: %w", err) are handled
+// too, and responds with its status, code and public message.
 //
 // Parameters:
 //   - c: *gin.Context - the context of the HTTP request
 //   - err: error - the error to be handled
 //
-// The function distinguishes between UserError, AuthError, and InternalError types,
-// logging internal errors for debugging purposes, and sends a JSON response with
-// the appropriate status code and error message.
+// Internal and unknown errors are logged for debugging purposes and their details
+// are never sent. The detail and violation messages are translated to the locale of
+// the request when a translation exists; the code stays the same in every language.
+// The response is an application/problem+json document, unless the client only
+// accepts application/json, in which case the legacy ErrorResponse is sent.
 func HandleError(c *gin.Context, err error) {
 
 	var (
 		statusCode    int
 		errorResponse ErrorResponse
+		appErr        errors.AppError
 	)
 
-	switch e := err.(type) {
-	case *errors.UserError:
-		statusCode, errorResponse = handleUserError(e)
-	case *errors.ForbiddenError:
-		statusCode, errorResponse = http.StatusForbidden, ErrorResponse{
-			Error:  "Forbidden",
-			Code:   e.Code,
-			Detail: e.Error(),
-		}
-	case *errors.AuthError:
-		statusCode, errorResponse = http.StatusUnauthorized, ErrorResponse{
-			Error:  "Authentication error",
-			Code:   e.Code,
-			Detail: e.Error(),
+	locale := Locale(c)
+
+	if stderrors.As(err, &appErr) {
+		statusCode, errorResponse = appErr.Status(), ErrorResponse{
+			Error:  errorTitle(appErr),
+			Code:   appErr.Code(),
+			Detail: i18n.ErrorMessage(locale, appErr.Code(), appErr.PublicMessage()),
 		}
-	case *errors.ValidationError:
-		statusCode, errorResponse = http.StatusBadRequest, ErrorResponse{
-			Error:  "Validation error",
-			Code:   e.Code,
-			Detail: e.Error(),
+
+		var validationErr *errors.ValidationError
+		if stderrors.As(err, &validationErr) {
+			errorResponse.Violations = localizeViolations(locale, validationErr.Violations)
 		}
-	case *errors.InternalError:
-		// Log internal errors for debuggin purposes
-		log.Printf("Internal error: Code=%s, Message=%s, Detail=%v", e.Code, e.Message, e.Err)
 
-		statusCode, errorResponse = http.StatusInternalServerError, ErrorResponse{
-			Error: "Internal Server Error",
-			Code:  e.Code,
+		if statusCode >= http.StatusInternalServerError {
+			// Log internal errors for debugging purposes
+			log.Printf("Internal error: Code=%s, Detail=%v", appErr.Code(), err)
 		}
-	default:
+	} else {
 		log.Printf("Unknown error: %v", err)
 		statusCode, errorResponse = http.StatusInternalServerError, ErrorResponse{
 			Error: "Unknown error ocurred",
 		}
 	}
 	c.Abort()
-	c.JSON(statusCode, errorResponse)
+	c.Header("Content-Language", locale)
+
+	if c.NegotiateFormat(MIMEProblemJSON, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(statusCode, errorResponse)
+		return
+	}
+
+	c.Header("Content-Type", MIMEProblemJSON)
+	c.Render(statusCode, render.JSON{Data: newProblemDetails(c, statusCode, errorResponse)})
 }
 
-// handleUserError processes a UserError and returns the corresponding HTTP status code
-// and an ErrorResponse. It handles specific user error codes such as InvalidEmail and
-// EmailAlreadyExists, and provides appropriate error messages and details.
-//
-// Parameters:
-//   - e: A pointer to a UserError containing the error code and message.
-//
-// Returns:
-//   - int: The HTTP status code corresponding to the user error.
-//   - ErrorResponse: An ErrorResponse struct containing the error message, code, and details.
-func handleUserError(e *errors.UserError) (int, ErrorResponse) {
-	switch e.Code {
-	case errors.InvalidEmail:
-		return http.StatusBadRequest, ErrorResponse{
-			Error:  "Invalid email format",
-			Code:   e.Code,
-			Detail: e.Error(),
-		}
-	case errors.EmailAlreadyExists:
-		return http.StatusConflict, ErrorResponse{
-			Error:  "Email already registered",
-			Code:   e.Code,
-			Detail: e.Error(),
+// newProblemDetails converts the legacy error response into problem details for the request.
+func newProblemDetails(c *gin.Context, status int, resp ErrorResponse) ProblemDetails {
+	problemType := "about:blank"
+	if resp.Code != "" {
+		problemType = ProblemTypeBaseURI + string(resp.Code)
+	}
+
+	return ProblemDetails{
+		Type:       problemType,
+		Title:      resp.Error,
+		Status:     status,
+		Detail:     resp.Detail,
+		Instance:   c.Request.URL.Path,
+		Code:       resp.Code,
+		RequestID:  RequestID(c),
+		Violations: resp.Violations,
+	}
+}
+
+// localizeViolations returns a copy of violations with their messages in locale.
+func localizeViolations(locale string, violations []errors.RuleViolation) []errors.RuleViolation {
+	if len(violations) == 0 {
+		return nil
+	}
+	localized := make([]errors.RuleViolation, len(violations))
+	for i, violation := range violations {
+		localized[i] = violation
+		localized[i].Message = i18n.RuleMessage(locale, violation)
+	}
+	return localized
+}
+
+// errorTitle returns the short summary of an error kind, with more specific titles
+// for some user error codes.
+func errorTitle(err errors.AppError) string {
+	switch err.(type) {
+	case *errors.UserError:
+		switch err.Code() {
+		case errors.InvalidEmail:
+			return "Invalid email format"
+		case errors.EmailAlreadyExists:
+			return "Email already registered"
+		default:
+			return "User error"
 		}
+	case *errors.ForbiddenError:
+		return "Forbidden"
+	case *errors.AuthError:
+		return "Authentication error"
+	case *errors.ValidationError:
+		return "Validation error"
 	default:
-		return http.StatusBadRequest, ErrorResponse{
-			Error:  "User error",
-			Code:   e.Code,
-			Detail: e.Error(),
-		}
+		return "Internal Server Error"
 	}
 }
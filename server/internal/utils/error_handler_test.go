@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(accept string, err error) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(resp)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/liked_images/1", nil)
+		if accept != "" {
+			c.Request.Header.Set("Accept", accept)
+		}
+		c.Set(RequestIDKey, "req-1")
+
+		HandleError(c, err)
+		return resp
+	}
+
+	conflict := errors.NewError(errors.UserErr, errors.EmailAlreadyExists, "email taken", nil)
+
+	t.Run("problem details by default", func(t *testing.T) {
+		resp := serve("", conflict)
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		assert.Equal(t, MIMEProblemJSON, resp.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"type": "/problems/email_already_exists",
+			"title": "Email already registered",
+			"status": 409,
+			"detail": "email taken",
+			"instance": "/liked_images/1",
+			"code": "email_already_exists",
+			"request_id": "req-1"
+		}`, resp.Body.String())
+	})
+
+	t.Run("problem details when accepted", func(t *testing.T) {
+		resp := serve("application/problem+json, application/json", conflict)
+
+		assert.Equal(t, MIMEProblemJSON, resp.Header().Get("Content-Type"))
+	})
+
+	t.Run("legacy shape for application/json clients", func(t *testing.T) {
+		resp := serve("application/json", conflict)
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		assert.Contains(t, resp.Header().Get("Content-Type"), gin.MIMEJSON)
+		assert.JSONEq(t, `{"error":"Email already registered","code":"email_already_exists","detail":"email taken"}`, resp.Body.String())
+	})
+
+	t.Run("internal errors hide their detail", func(t *testing.T) {
+		resp := serve("", errors.NewError(errors.InternalErr, errors.DatabaseError, "query failed", assert.AnError))
+
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.JSONEq(t, `{
+			"type": "/problems/database_error",
+			"title": "Internal Server Error",
+			"status": 500,
+			"detail": "an internal error occurred",
+			"instance": "/liked_images/1",
+			"code": "database_error",
+			"request_id": "req-1"
+		}`, resp.Body.String())
+	})
+
+	t.Run("unknown errors", func(t *testing.T) {
+		resp := serve("", assert.AnError)
+
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"type":"about:blank"`)
+	})
+}
+
+func TestHandleWrappedError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resp := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(resp)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/user/1", nil)
+	c.Request.Header.Set("Accept", gin.MIMEJSON)
+
+	HandleError(c, fmt.Errorf("get user: %w", errors.NewError(errors.UserErr, errors.UserNotFound, "user not found", assert.AnError)))
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.JSONEq(t, `{"error":"User error","code":"user_not_found","detail":"user not found"}`, resp.Body.String())
+}
+
+func TestHandleErrorLocalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resp := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(resp)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/auth/register", nil)
+	c.Request.Header.Set("Accept", gin.MIMEJSON)
+	c.Set(LocaleKey, "fr")
+
+	HandleError(c, errors.NewValidationError(errors.WeakPassword, "password does not meet the password policy", []errors.RuleViolation{
+		{Field: "password", Rule: RuleMinLength, Message: "must be at least 12 characters long", Params: map[string]string{"min": "12"}},
+	}))
+
+	assert.Equal(t, "fr", resp.Header().Get("Content-Language"))
+	assert.JSONEq(t, `{
+		"error": "Validation error",
+		"code": "weak_password",
+		"detail": "Le mot de passe ne respecte pas la politique de mots de passe",
+		"violations": [{"field": "password", "rule": "min_length", "message": "doit contenir au moins 12 caractères", "params": {"min": "12"}}]
+	}`, resp.Body.String())
+}
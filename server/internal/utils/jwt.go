@@ -1,44 +1,45 @@
 package utils
 
 import (
-	"server/config"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// JWTLifetime is how long a JWT is valid after it is issued or refreshed.
+const JWTLifetime = 24 * time.Hour
+
 // GenerateJWT generates a JSON Web Token (JWT) for a given user ID.
-// The token is signed using the secret key from the configuration and includes standard claims:
+// The token is signed with the active key of the application KeyRing, which also sets the
+// "kid" header, and includes standard claims:
 // - "iss" (issuer): Identifies the principal that issued the JWT.
 // - "sub" (subject): Identifies the subject of the JWT, in this case, the user ID.
 // - "exp" (expiration time): Identifies the expiration time on or after which the JWT must not be accepted for processing.
 // - "nbf" (not before): Identifies the time before which the JWT must not be accepted for processing.
 // - "iat" (issued at): Identifies the time at which the JWT was issued.
 // - "jti" (JWT ID): Provides a unique identifier for the JWT.
+// - "sid" (session ID): Identifies the login session, so the token can be revoked.
 //
 // Parameters:
 // - userId: The ID of the user for whom the JWT is being generated.
+// - sessionID: The ID of the session the JWT belongs to.
 //
 // Returns:
 // - A signed JWT as a string.
 // - An error if there was a problem generating the token.
-func GenerateJWT(userId string) (string, error) {
-	cfg := config.GetConfig()
-	jwtKey := []byte(cfg.JWTSecret)
-
+func GenerateJWT(userId, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"iss": "wti-tech-interview",
 		"sub": userId,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"sid": sessionID,
+		"exp": time.Now().Add(JWTLifetime).Unix(),
 		"nbf": time.Now().Unix(),
 		"iat": time.Now().Unix(),
 		"jti": uuid.New().String(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	signedToken, err := token.SignedString(jwtKey)
+	signedToken, err := GetKeyRing().Sign(claims)
 
 	if err != nil {
 		return "", err
@@ -48,7 +49,8 @@ func GenerateJWT(userId string) (string, error) {
 }
 
 // RefreshJWT generates a new JWT token with an extended expiration time.
-// The new token is signed using the secret key from the configuration and includes the same claims as the original token.
+// The new token is signed with the active key of the application KeyRing and includes the same claims as the original token,
+// so tokens signed with a rotated or legacy HS256 key are re-issued with the current key.
 //
 // Parameters:
 // - tokenString: The original JWT token that needs to be refreshed.
@@ -57,20 +59,30 @@ func GenerateJWT(userId string) (string, error) {
 // - A signed JWT with an extended expiration time.
 // - An error if there was a problem refreshing the token.
 func RefreshJWT(tokenString string) (string, error) {
-	cfg := config.GetConfig()
-	jwtKey := []byte(cfg.JWTSecret)
+	return GetKeyRing().Refresh(tokenString)
+}
+
+// Refresh re-signs tokenString with the active key of the ring and a new JWTLifetime expiration time.
+// The token must already have been verified by the caller.
+func (kr *KeyRing) Refresh(tokenString string) (string, error) {
+	return kr.RefreshWithSession(tokenString, "")
+}
 
+// RefreshWithSession is Refresh binding the new token to the session sessionID, for the
+// tokens issued before sessions existed. An empty sessionID keeps the "sid" claim of the token.
+func (kr *KeyRing) RefreshWithSession(tokenString, sessionID string) (string, error) {
 	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
 	if err != nil {
 		return "", err
 	}
 
 	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	claims["exp"] = time.Now().Add(JWTLifetime).Unix()
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
 
-	signedToken, err := newToken.SignedString(jwtKey)
+	signedToken, err := kr.Sign(claims)
 
 	if err != nil {
 		return "", err
@@ -78,3 +90,55 @@ func RefreshJWT(tokenString string) (string, error) {
 
 	return signedToken, nil
 }
+
+// MFAChallengeTokenType is the "typ" claim of tokens returned by a password login
+// when two-factor authentication is enabled. They are rejected by the auth middleware.
+const MFAChallengeTokenType = "mfa_challenge"
+
+// mfaChallengeTTL bounds how long a user has to enter the second factor after the password.
+const mfaChallengeTTL = 5 * time.Minute
+
+// GenerateMFAChallengeJWT generates a short-lived token proving the user passed the password
+// step of a two-factor login. It can only be exchanged for a regular JWT through the second step.
+//
+// Parameters:
+// - userId: The ID of the user who passed the password step.
+//
+// Returns:
+// - A signed JWT as a string.
+// - An error if there was a problem generating the token.
+func GenerateMFAChallengeJWT(userId string) (string, error) {
+	claims := jwt.MapClaims{
+		"iss": "wti-tech-interview",
+		"sub": userId,
+		"typ": MFAChallengeTokenType,
+		"exp": time.Now().Add(mfaChallengeTTL).Unix(),
+		"iat": time.Now().Unix(),
+		"jti": uuid.New().String(),
+	}
+
+	return GetKeyRing().Sign(claims)
+}
+
+// ParseMFAChallengeJWT verifies a token created by GenerateMFAChallengeJWT and returns its subject.
+//
+// Parameters:
+// - tokenString: The challenge token.
+//
+// Returns:
+// - The ID of the user the challenge was issued to.
+// - An error if the token is invalid, expired or not a challenge token.
+func ParseMFAChallengeJWT(tokenString string) (string, error) {
+	kr := GetKeyRing()
+	token, err := jwt.Parse(tokenString, kr.Keyfunc, jwt.WithValidMethods(kr.ValidMethods()), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != MFAChallengeTokenType {
+		return "", jwt.ErrTokenInvalidClaims
+	}
+
+	return claims.GetSubject()
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	keyFileExt         = ".pem"
+	keyFileBlockType   = "PRIVATE KEY"
+	keyIDHeader        = "Key-Id"
+	keyCreatedAtHeader = "Created-At"
+	keyFileTempPattern = ".key-*.tmp"
+)
+
+// KeyDir is a KeyStore keeping each key in a PEM file of a directory, named after the
+// key ID and carrying its ID and creation time in headers. Replicas share their keys
+// by mounting the same directory.
+type KeyDir struct {
+	path string
+}
+
+// NewKeyDir returns the KeyDir of path, creating the directory if needed.
+func NewKeyDir(path string) (*KeyDir, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating JWT key directory: %w", err)
+	}
+	return &KeyDir{path: path}, nil
+}
+
+// LoadKeys reads every key file of the directory.
+func (d *KeyDir) LoadKeys() ([]*SigningKey, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*SigningKey
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), keyFileExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.path, entry.Name()))
+		if errors.Is(err, os.ErrNotExist) {
+			// Deleted by another replica pruning expired keys.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeKeyFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SaveKey writes the file of a key. It is written to a temporary file first and
+// renamed, so other replicas never read a partial key.
+func (d *KeyDir) SaveKey(key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("error encoding private key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{
+		Type: keyFileBlockType,
+		Headers: map[string]string{
+			keyIDHeader:        key.ID,
+			keyCreatedAtHeader: key.CreatedAt.UTC().Format(time.RFC3339Nano),
+		},
+		Bytes: der,
+	})
+
+	tmp, err := os.CreateTemp(d.path, keyFileTempPattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.keyFile(key.ID))
+}
+
+// DeleteKey deletes the file of a key, succeeding if it is already gone.
+func (d *KeyDir) DeleteKey(id string) error {
+	err := os.Remove(d.keyFile(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d *KeyDir) keyFile(id string) string {
+	return filepath.Join(d.path, filepath.Base(id)+keyFileExt)
+}
+
+// decodeKeyFile parses a key file written by SaveKey.
+func decodeKeyFile(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != keyFileBlockType {
+		return nil, errors.New("error decoding PEM private key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+	key, err := signingKeyOf(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = block.Headers[keyIDHeader]
+	if key.ID == "" {
+		return nil, errors.New("missing key ID")
+	}
+	key.CreatedAt, err = time.Parse(time.RFC3339Nano, block.Headers[keyCreatedAtHeader])
+	if err != nil {
+		return nil, fmt.Errorf("invalid creation time: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"server/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for key rings.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+// useClock makes rings use a clock starting now.
+func useClock(rings ...*KeyRing) *clock {
+	c := &clock{t: time.Now()}
+	for _, kr := range rings {
+		kr.now = c.now
+	}
+	return c
+}
+
+func newStoredTestRing(t *testing.T, dir string) *KeyRing {
+	t.Helper()
+	store, err := NewKeyDir(dir)
+	require.NoError(t, err)
+	kr, err := NewStoredKeyRing(AlgEdDSA, store, time.Hour, "", time.Time{})
+	require.NoError(t, err)
+	return kr
+}
+
+func verify(kr *KeyRing, tokenString string) error {
+	_, err := jwt.Parse(tokenString, kr.Keyfunc, jwt.WithValidMethods(kr.ValidMethods()))
+	return err
+}
+
+func TestStoredKeyRing(t *testing.T) {
+	t.Run("keys survive a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		before := newStoredTestRing(t, dir)
+		token, err := before.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+
+		after := newStoredTestRing(t, dir)
+
+		assert.Equal(t, before.ActiveKeyID(), after.ActiveKeyID())
+		assert.NoError(t, verify(after, token))
+		info, err := os.Stat(filepath.Join(dir, after.ActiveKeyID()+keyFileExt))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("replicas verify the keys rotated by the others", func(t *testing.T) {
+		dir := t.TempDir()
+		first := newStoredTestRing(t, dir)
+		second := newStoredTestRing(t, dir)
+		c := useClock(first, second)
+		oldKid := first.ActiveKeyID()
+
+		require.NoError(t, first.Rotate())
+		token, err := first.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+
+		c.t = c.t.Add(keyRefreshMinInterval)
+		assert.NoError(t, verify(second, token), "an unknown kid reloads the store")
+		assert.Equal(t, first.ActiveKeyID(), second.ActiveKeyID())
+		assert.NotEqual(t, oldKid, second.ActiveKeyID())
+		assert.Len(t, second.JWKS().Keys, 2)
+	})
+
+	t.Run("rotation - only rotates once the newest stored key is due", func(t *testing.T) {
+		dir := t.TempDir()
+		first := newStoredTestRing(t, dir)
+		second := newStoredTestRing(t, dir)
+		c := useClock(first, second)
+		c.t = c.t.Add(2 * time.Hour)
+
+		rotated, err := first.rotateIfDue(2 * time.Hour)
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		rotated, err = second.rotateIfDue(2 * time.Hour)
+		require.NoError(t, err)
+		assert.False(t, rotated, "the key rotated by the first replica is used")
+		assert.Equal(t, first.ActiveKeyID(), second.ActiveKeyID())
+	})
+
+	t.Run("rotation - deletes the keys past their retention", func(t *testing.T) {
+		dir := t.TempDir()
+		kr := newStoredTestRing(t, dir)
+		c := useClock(kr)
+		oldKid := kr.ActiveKeyID()
+		oldToken, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+		require.NoError(t, kr.Rotate())
+
+		c.t = c.t.Add(time.Hour)
+		require.NoError(t, kr.reload())
+
+		assert.ErrorIs(t, verify(kr, oldToken), ErrUnknownKey)
+		_, err = os.Stat(filepath.Join(dir, oldKid+keyFileExt))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("emptied key directory - keeps signing with the loaded keys", func(t *testing.T) {
+		dir := t.TempDir()
+		kr := newStoredTestRing(t, dir)
+		c := useClock(kr)
+		kid := kr.ActiveKeyID()
+		require.NoError(t, os.Remove(filepath.Join(dir, kid+keyFileExt)))
+
+		c.t = c.t.Add(keyRefreshMinInterval)
+		require.NoError(t, kr.reload())
+
+		assert.Equal(t, kid, kr.ActiveKeyID())
+		token, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+		assert.NoError(t, verify(kr, token))
+	})
+}
+
+func TestKeyRingSignWithoutActiveKey(t *testing.T) {
+	kr := &KeyRing{algorithm: AlgEdDSA, keys: make(map[string]*SigningKey), now: time.Now}
+
+	_, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func TestLoadKeyRingSources(t *testing.T) {
+	t.Run("production without a key source - fails", func(t *testing.T) {
+		_, err := newKeyRing(&config.Config{Env: "production", JWT: config.JWTConfig{SigningAlgorithm: AlgRS256}})
+		assert.Error(t, err)
+	})
+
+	t.Run("key directory and PEM file - fails", func(t *testing.T) {
+		_, err := newKeyRing(&config.Config{JWT: config.JWTConfig{SigningAlgorithm: AlgEdDSA, KeyDir: t.TempDir(), PrivateKeyFile: "key.pem"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("legacy secret without a cutoff - accepts HS256 tokens for a JWT lifetime", func(t *testing.T) {
+		kr, err := newKeyRing(&config.Config{JWTSecret: "secret", JWT: config.JWTConfig{SigningAlgorithm: AlgEdDSA}})
+		require.NoError(t, err)
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "123"}).SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		assert.NoError(t, verify(kr, token))
+		kr.now = func() time.Time { return time.Now().Add(JWTLifetime) }
+		assert.ErrorIs(t, verify(kr, token), ErrLegacyHS256Ended)
+	})
+
+	t.Run("production with a key directory - stores the keys", func(t *testing.T) {
+		dir := t.TempDir()
+		kr, err := newKeyRing(&config.Config{Env: "production", JWT: config.JWTConfig{SigningAlgorithm: AlgEdDSA, KeyDir: dir}})
+		require.NoError(t, err)
+
+		assert.FileExists(t, filepath.Join(dir, kr.ActiveKeyID()+keyFileExt))
+	})
+}
@@ -0,0 +1,715 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"server/config"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+
+	rsaKeyBits = 2048
+
+	// keyRefreshInterval is how often a ring backed by a KeyStore reloads it, to pick
+	// up the keys rotated by other replicas.
+	keyRefreshInterval = time.Minute
+	// keyRefreshMinInterval bounds how often a token signed with an unknown key makes
+	// the ring reload its store.
+	keyRefreshMinInterval = 5 * time.Second
+)
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrLegacyHS256Ended = errors.New("HS256 tokens are no longer accepted")
+	ErrNoSigningKey     = errors.New("no active signing key")
+)
+
+// SigningKey is an asymmetric key pair identified by its key ID ("kid").
+//
+// Fields:
+//   - ID: The key ID written to the "kid" header of every token signed with this key.
+//   - Algorithm: The JWS algorithm, either RS256 or EdDSA.
+//   - PrivateKey: The key used for signing. Nil for verification-only keys.
+//   - PublicKey: The key used for verification and published in the JWKS.
+//   - CreatedAt: When the key was created or loaded.
+//   - RetireAt: When the key stops being accepted. Zero while the key is active.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	CreatedAt  time.Time
+	RetireAt   time.Time
+}
+
+// KeyRing holds the active signing key together with every key that is
+// still valid for verification. Rotating the ring replaces the active key
+// while keeping the previous ones until their retention period elapses,
+// so tokens issued before a rotation remain valid.
+//
+// Rings backed by a KeyStore share their keys with every replica using the same store
+// and keep them across restarts; the other rings hold their keys in memory only.
+//
+// Until a configured time the ring also accepts HS256 tokens signed with the legacy
+// shared secret.
+type KeyRing struct {
+	mu           sync.RWMutex
+	algorithm    string
+	active       *SigningKey
+	keys         map[string]*SigningKey
+	retention    time.Duration
+	legacySecret []byte
+	legacyUntil  time.Time
+	store        KeyStore
+	loadedAt     time.Time
+	static       bool
+	now          func() time.Time
+}
+
+// KeyStore persists the signing keys of a KeyRing. Retirement times are not stored:
+// every key but the newest retires once the next key has been active for the
+// retention period, so replicas sharing a store agree on them.
+type KeyStore interface {
+	// LoadKeys returns every stored key.
+	LoadKeys() ([]*SigningKey, error)
+	// SaveKey stores a new key.
+	SaveKey(key *SigningKey) error
+	// DeleteKey deletes a key, succeeding if it is already gone.
+	DeleteKey(id string) error
+}
+
+// JSONWebKey is the public part of a SigningKey in JWK format (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewKeyRing creates a KeyRing for the given algorithm.
+//
+// For RS256 and EdDSA the signing key is loaded from privateKeyPEM when provided, and
+// never rotated, otherwise a new one is generated in memory. For HS256 the ring signs
+// with the legacy secret and no asymmetric keys are created.
+//
+// Parameters:
+//   - algorithm: The signing algorithm (RS256, EdDSA or HS256).
+//   - privateKeyPEM: An optional PEM encoded PKCS#8 or PKCS#1 private key.
+//   - retention: How long rotated keys are kept for verification.
+//   - legacySecret: The HS256 shared secret, may be empty.
+//   - legacyUntil: When HS256 tokens stop being accepted, ignored when algorithm is
+//     HS256. The zero time rejects them.
+//
+// Returns:
+//   - A pointer to the created KeyRing.
+//   - An error if the algorithm is unsupported or the key could not be loaded.
+func NewKeyRing(algorithm string, privateKeyPEM []byte, retention time.Duration, legacySecret string, legacyUntil time.Time) (*KeyRing, error) {
+	kr := &KeyRing{
+		algorithm:    algorithm,
+		keys:         make(map[string]*SigningKey),
+		retention:    retention,
+		legacySecret: []byte(legacySecret),
+		now:          time.Now,
+	}
+
+	switch algorithm {
+	case AlgHS256:
+		if legacySecret == "" {
+			return nil, errors.New("HS256 signing requires a secret")
+		}
+		return kr, nil
+	case AlgRS256, AlgEdDSA:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, algorithm)
+	}
+
+	if legacySecret != "" {
+		kr.legacyUntil = legacyUntil
+	}
+
+	var key *SigningKey
+	var err error
+	if len(privateKeyPEM) > 0 {
+		key, err = parseSigningKey(algorithm, privateKeyPEM)
+		kr.static = true
+	} else {
+		key, err = generateSigningKey(algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key.CreatedAt = kr.now()
+	kr.active = key
+	kr.keys[key.ID] = key
+
+	return kr, nil
+}
+
+// NewStoredKeyRing creates a KeyRing for RS256 or EdDSA whose keys are kept in store.
+// The newest stored key signs the tokens; a new one is generated and stored when the
+// store is empty or its newest key is of another algorithm.
+//
+// Parameters:
+//   - algorithm: The signing algorithm (RS256 or EdDSA).
+//   - store: The KeyStore shared by the replicas.
+//   - retention: How long rotated keys are kept for verification.
+//   - legacySecret: The HS256 shared secret, may be empty.
+//   - legacyUntil: When HS256 tokens stop being accepted. The zero time rejects them.
+//
+// Returns:
+//   - A pointer to the created KeyRing.
+//   - An error if the algorithm is unsupported or the store could not be read or written.
+func NewStoredKeyRing(algorithm string, store KeyStore, retention time.Duration, legacySecret string, legacyUntil time.Time) (*KeyRing, error) {
+	if algorithm != AlgRS256 && algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, algorithm)
+	}
+
+	kr := &KeyRing{
+		algorithm:    algorithm,
+		keys:         make(map[string]*SigningKey),
+		retention:    retention,
+		legacySecret: []byte(legacySecret),
+		store:        store,
+		now:          time.Now,
+	}
+	if legacySecret != "" {
+		kr.legacyUntil = legacyUntil
+	}
+
+	if err := kr.reload(); err != nil {
+		return nil, err
+	}
+	if active := kr.activeKey(); active == nil || active.Algorithm != algorithm {
+		if err := kr.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+// NewLegacyKeyRing creates a KeyRing that signs and verifies HS256 tokens with the given secret.
+func NewLegacyKeyRing(secret string) *KeyRing {
+	return &KeyRing{
+		algorithm:    AlgHS256,
+		keys:         make(map[string]*SigningKey),
+		legacySecret: []byte(secret),
+		now:          time.Now,
+	}
+}
+
+// Algorithm returns the algorithm used for newly signed tokens.
+func (kr *KeyRing) Algorithm() string {
+	return kr.algorithm
+}
+
+// Rotate generates a new active signing key. The previous active key is kept
+// for verification until the retention period elapses. Expired keys are pruned.
+// Rings backed by a KeyStore store the new key, for the other replicas to load it.
+//
+// Rotating an HS256 ring is a no-op.
+func (kr *KeyRing) Rotate() error {
+	if kr.algorithm == AlgHS256 {
+		return nil
+	}
+
+	key, err := generateSigningKey(kr.algorithm)
+	if err != nil {
+		return err
+	}
+
+	if kr.store != nil {
+		key.CreatedAt = kr.now()
+		if err := kr.store.SaveKey(key); err != nil {
+			return fmt.Errorf("error storing signing key: %w", err)
+		}
+		return kr.reload()
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	now := kr.now()
+	key.CreatedAt = now
+	if kr.active != nil {
+		kr.active.RetireAt = now.Add(kr.retention)
+	}
+	kr.active = key
+	kr.keys[key.ID] = key
+	kr.pruneLocked(now)
+
+	return nil
+}
+
+// AddVerificationKey registers a public key that is accepted for verification only,
+// for instance the key of another replica during a rolling deployment.
+func (kr *KeyRing) AddVerificationKey(key *SigningKey) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	kr.keys[key.ID] = &SigningKey{
+		ID:        key.ID,
+		Algorithm: key.Algorithm,
+		PublicKey: key.PublicKey,
+		CreatedAt: key.CreatedAt,
+		RetireAt:  key.RetireAt,
+	}
+}
+
+// StartRotation rotates the ring every interval until the returned stop function is called.
+// A non-positive interval disables rotation, as does a key loaded from a PEM file.
+//
+// Rings backed by a KeyStore reload it periodically instead, and rotate once its newest
+// key is interval old: the replicas sharing the store take turns rotating, and use the
+// keys rotated by the others.
+func (kr *KeyRing) StartRotation(interval time.Duration) (stop func()) {
+	if interval <= 0 || kr.algorithm == AlgHS256 {
+		return func() {}
+	}
+	if kr.static {
+		log.Printf("JWT signing key rotation disabled: the key is loaded from a PEM file")
+		return func() {}
+	}
+
+	tick := interval
+	if kr.store != nil {
+		tick = min(interval, keyRefreshInterval)
+	}
+	ticker := time.NewTicker(tick)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				rotated, err := kr.rotateIfDue(interval)
+				if err != nil {
+					log.Printf("failed to rotate signing key: %v", err)
+					continue
+				}
+				if rotated {
+					log.Printf("rotated JWT signing key, active kid=%s", kr.ActiveKeyID())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// rotateIfDue rotates the ring if its active key is interval old, reloading the store
+// first so a key rotated by another replica is used rather than replaced.
+func (kr *KeyRing) rotateIfDue(interval time.Duration) (bool, error) {
+	if kr.store != nil {
+		if err := kr.reload(); err != nil {
+			return false, err
+		}
+	}
+
+	active := kr.activeKey()
+	if active != nil && kr.now().Sub(active.CreatedAt) < interval {
+		return false, nil
+	}
+	return true, kr.Rotate()
+}
+
+// reload replaces the keys of the ring with those of its store, and deletes the
+// stored keys whose retention has elapsed. An empty store leaves the keys of the ring
+// untouched, so tokens can still be signed until the next rotation stores a key.
+func (kr *KeyRing) reload() error {
+	stored, err := kr.store.LoadKeys()
+	if err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+	if len(stored) == 0 {
+		kr.mu.Lock()
+		if kr.active != nil {
+			log.Printf("JWT key store is empty, keeping the loaded signing keys")
+		}
+		kr.loadedAt = kr.now()
+		kr.mu.Unlock()
+		return nil
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+	})
+
+	now := kr.now()
+	keys := make(map[string]*SigningKey, len(stored))
+	var expired []string
+	for i, key := range stored {
+		if i < len(stored)-1 {
+			key.RetireAt = stored[i+1].CreatedAt.Add(kr.retention)
+			if !now.Before(key.RetireAt) {
+				expired = append(expired, key.ID)
+				continue
+			}
+		}
+		keys[key.ID] = key
+	}
+
+	kr.mu.Lock()
+	kr.keys = keys
+	kr.active = stored[len(stored)-1]
+	kr.loadedAt = now
+	kr.mu.Unlock()
+
+	for _, id := range expired {
+		if err := kr.store.DeleteKey(id); err != nil {
+			log.Printf("failed to delete expired signing key %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// activeKey returns the active signing key, nil for HS256 rings.
+func (kr *KeyRing) activeKey() *SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active
+}
+
+// ActiveKeyID returns the key ID of the active signing key, or an empty string for HS256 rings.
+func (kr *KeyRing) ActiveKeyID() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	if kr.active == nil {
+		return ""
+	}
+	return kr.active.ID
+}
+
+// Sign signs the claims with the active key and sets the "kid" header.
+// It fails with ErrNoSigningKey if the ring has no active key.
+func (kr *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	if kr.algorithm == AlgHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(kr.legacySecret)
+	}
+
+	key := kr.activeKey()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
+}
+
+// Keyfunc resolves the verification key for a parsed token. It is meant to be passed to jwt.Parse.
+//
+// Tokens carrying a "kid" header are verified with the matching key, provided its algorithm matches.
+// HS256 tokens are verified with the legacy secret while the migration window is open.
+func (kr *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if token.Method.Alg() != AlgHS256 || len(kr.legacySecret) == 0 {
+			return nil, ErrUnsupportedAlg
+		}
+		if kr.algorithm != AlgHS256 && !kr.now().Before(kr.legacyUntil) {
+			return nil, ErrLegacyHS256Ended
+		}
+		return kr.legacySecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key, ok := kr.verificationKey(kid)
+	if !ok && kr.refreshDue() {
+		// The key may have been rotated by another replica since the last reload.
+		if err := kr.reload(); err != nil {
+			log.Printf("failed to reload signing keys: %v", err)
+		}
+		key, ok = kr.verificationKey(kid)
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrUnsupportedAlg
+	}
+
+	return key.PublicKey, nil
+}
+
+// IsLegacyToken reports whether a verified token was signed with the legacy HS256 secret
+// of an asymmetric ring while the migration window is open. Such tokens may predate
+// sessions and carry no "sid" claim.
+func (kr *KeyRing) IsLegacyToken(token *jwt.Token) bool {
+	return token.Method.Alg() == AlgHS256 && kr.algorithm != AlgHS256 && kr.now().Before(kr.legacyUntil)
+}
+
+// verificationKey returns the key identified by kid, unless it is unknown or retired.
+func (kr *KeyRing) verificationKey(kid string) (*SigningKey, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	key, ok := kr.keys[kid]
+	if !ok || (!key.RetireAt.IsZero() && !kr.now().Before(key.RetireAt)) {
+		return nil, false
+	}
+	return key, true
+}
+
+// refreshDue reports whether the ring is backed by a KeyStore it may reload now.
+func (kr *KeyRing) refreshDue() bool {
+	if kr.store == nil {
+		return false
+	}
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.now().Sub(kr.loadedAt) >= keyRefreshMinInterval
+}
+
+// ValidMethods returns the algorithms currently accepted by the ring.
+func (kr *KeyRing) ValidMethods() []string {
+	methods := []string{AlgRS256, AlgEdDSA}
+	if len(kr.legacySecret) > 0 {
+		methods = append(methods, AlgHS256)
+	}
+	return methods
+}
+
+// JWKS returns the public keys that are still valid for verification, newest first.
+func (kr *KeyRing) JWKS() JSONWebKeySet {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	kr.pruneLocked(kr.now())
+
+	keys := make([]*SigningKey, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(keys))}
+	for _, k := range keys {
+		if jwk, ok := toJSONWebKey(k); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// pruneLocked removes retired keys. The caller must hold the write lock.
+func (kr *KeyRing) pruneLocked(now time.Time) {
+	for id, k := range kr.keys {
+		if !k.RetireAt.IsZero() && !now.Before(k.RetireAt) {
+			delete(kr.keys, id)
+		}
+	}
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	key := &SigningKey{
+		ID:        uuid.New().String(),
+		Algorithm: algorithm,
+	}
+
+	switch algorithm {
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("error generating RSA key: %w", err)
+		}
+		key.PrivateKey, key.PublicKey = priv, &priv.PublicKey
+	case AlgEdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("error generating Ed25519 key: %w", err)
+		}
+		key.PrivateKey, key.PublicKey = priv, pub
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, algorithm)
+	}
+
+	return key, nil
+}
+
+// parseSigningKey decodes a PEM private key. The key ID is derived from the public key
+// so every replica loading the same file advertises the same "kid".
+func parseSigningKey(algorithm string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("error decoding PEM private key")
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+
+	key, err := signingKeyOf(parsed)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != algorithm {
+		return nil, fmt.Errorf("%w: %s key used with %s", ErrUnsupportedAlg, key.Algorithm, algorithm)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding public key: %w", err)
+	}
+	key.ID = uuid.NewSHA1(uuid.NameSpaceOID, der).String()
+
+	return key, nil
+}
+
+// signingKeyOf returns the SigningKey, without ID, of an RSA or Ed25519 private key.
+func signingKeyOf(priv interface{}) (*SigningKey, error) {
+	switch priv := priv.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Algorithm: AlgRS256, PrivateKey: priv, PublicKey: &priv.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Algorithm: AlgEdDSA, PrivateKey: priv, PublicKey: priv.Public()}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported private key type %T", ErrUnsupportedAlg, priv)
+	}
+}
+
+func toJSONWebKey(k *SigningKey) (JSONWebKey, bool) {
+	jwk := JSONWebKey{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JSONWebKey{}, false
+	}
+
+	return jwk, true
+}
+
+var (
+	keyRing     *KeyRing
+	keyRingOnce sync.Once
+	keyRingErr  error
+)
+
+// LoadKeyRing creates the application-wide KeyRing from the configuration.
+// Subsequent calls return the same instance.
+//
+// The keys are kept in the JWT.KeyDir directory when set, or loaded from the
+// JWT.PrivateKeyFile PEM file. Without either, keys are generated in memory, which
+// logs everyone out on restart and cannot be shared by replicas, so it fails in
+// production. HS256 tokens signed with the JWTSecret are accepted until
+// JWT.LegacyHS256Until, or for a JWT lifetime after startup when it is not set.
+func LoadKeyRing(cfg *config.Config) (*KeyRing, error) {
+	keyRingOnce.Do(func() {
+		keyRing, keyRingErr = newKeyRing(cfg)
+	})
+
+	return keyRing, keyRingErr
+}
+
+func newKeyRing(cfg *config.Config) (*KeyRing, error) {
+	jwtCfg := cfg.JWT
+	if jwtCfg.LegacyHS256Until.IsZero() && cfg.JWTSecret != "" && jwtCfg.SigningAlgorithm != AlgHS256 {
+		// Without a cutoff, the tokens issued before the switch to asymmetric keys
+		// are accepted until they expire or are refreshed.
+		jwtCfg.LegacyHS256Until = time.Now().Add(JWTLifetime)
+		log.Printf("no JWT_LEGACY_HS256_UNTIL: HS256 tokens are accepted until %s", jwtCfg.LegacyHS256Until.Format(time.RFC3339))
+	}
+	if jwtCfg.SigningAlgorithm == AlgHS256 {
+		return NewKeyRing(AlgHS256, nil, jwtCfg.KeyRetention, cfg.JWTSecret, jwtCfg.LegacyHS256Until)
+	}
+
+	switch {
+	case jwtCfg.KeyDir != "" && jwtCfg.PrivateKeyFile != "":
+		return nil, errors.New("JWT_KEY_DIR and JWT_PRIVATE_KEY_FILE cannot both be set")
+
+	case jwtCfg.KeyDir != "":
+		store, err := NewKeyDir(jwtCfg.KeyDir)
+		if err != nil {
+			return nil, err
+		}
+		return NewStoredKeyRing(jwtCfg.SigningAlgorithm, store, jwtCfg.KeyRetention, cfg.JWTSecret, jwtCfg.LegacyHS256Until)
+
+	case jwtCfg.PrivateKeyFile != "":
+		pemData, err := os.ReadFile(jwtCfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading JWT private key: %w", err)
+		}
+		return NewKeyRing(jwtCfg.SigningAlgorithm, pemData, jwtCfg.KeyRetention, cfg.JWTSecret, jwtCfg.LegacyHS256Until)
+
+	case cfg.Env == "production":
+		return nil, errors.New("JWT_KEY_DIR or JWT_PRIVATE_KEY_FILE is required in production")
+
+	default:
+		log.Printf("no JWT_KEY_DIR or JWT_PRIVATE_KEY_FILE: signing keys are generated in memory and tokens do not survive a restart")
+		return NewKeyRing(jwtCfg.SigningAlgorithm, nil, jwtCfg.KeyRetention, cfg.JWTSecret, jwtCfg.LegacyHS256Until)
+	}
+}
+
+// GetKeyRing returns the application-wide KeyRing. LoadKeyRing must be called first.
+func GetKeyRing() *KeyRing {
+	if keyRing == nil {
+		log.Fatal("Key ring not loaded. Call LoadKeyRing() first.")
+	}
+	return keyRing
+}
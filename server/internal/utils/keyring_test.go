@@ -0,0 +1,165 @@
+package utils_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"server/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseWithKeyRing(kr *utils.KeyRing, tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, kr.Keyfunc, jwt.WithValidMethods(kr.ValidMethods()))
+}
+
+func TestKeyRingSignAndVerify(t *testing.T) {
+	for _, alg := range []string{utils.AlgRS256, utils.AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			kr, err := utils.NewKeyRing(alg, nil, time.Hour, "", time.Time{})
+			require.NoError(t, err)
+
+			tokenString, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+			require.NoError(t, err)
+
+			token, err := parseWithKeyRing(kr, tokenString)
+			require.NoError(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, alg, token.Method.Alg())
+			assert.Equal(t, kr.ActiveKeyID(), token.Header["kid"])
+		})
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	t.Run("tokens signed before rotation remain valid", func(t *testing.T) {
+		kr, err := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+		require.NoError(t, err)
+
+		oldKid := kr.ActiveKeyID()
+		oldToken, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+
+		require.NoError(t, kr.Rotate())
+		assert.NotEqual(t, oldKid, kr.ActiveKeyID())
+
+		_, err = parseWithKeyRing(kr, oldToken)
+		assert.NoError(t, err)
+		assert.Len(t, kr.JWKS().Keys, 2)
+	})
+
+	t.Run("rotated keys are dropped after retention", func(t *testing.T) {
+		kr, err := utils.NewKeyRing(utils.AlgEdDSA, nil, 0, "", time.Time{})
+		require.NoError(t, err)
+
+		oldToken, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+
+		require.NoError(t, kr.Rotate())
+
+		_, err = parseWithKeyRing(kr, oldToken)
+		assert.ErrorIs(t, err, utils.ErrUnknownKey)
+		assert.Len(t, kr.JWKS().Keys, 1)
+	})
+
+	t.Run("unknown kid is rejected", func(t *testing.T) {
+		kr, err := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+		require.NoError(t, err)
+		other, err := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+		require.NoError(t, err)
+
+		tokenString, err := other.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+
+		_, err = parseWithKeyRing(kr, tokenString)
+		assert.ErrorIs(t, err, utils.ErrUnknownKey)
+	})
+}
+
+func TestKeyRingLegacyHS256(t *testing.T) {
+	secret := "secret"
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "123"}).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	t.Run("accepted until the cutoff", func(t *testing.T) {
+		kr, err := utils.NewKeyRing(utils.AlgRS256, nil, time.Hour, secret, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		_, err = parseWithKeyRing(kr, legacyToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejected after the cutoff", func(t *testing.T) {
+		kr, err := utils.NewKeyRing(utils.AlgRS256, nil, time.Hour, secret, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		_, err = parseWithKeyRing(kr, legacyToken)
+		assert.ErrorIs(t, err, utils.ErrLegacyHS256Ended)
+	})
+
+	t.Run("rejected without a cutoff", func(t *testing.T) {
+		kr, err := utils.NewKeyRing(utils.AlgRS256, nil, time.Hour, secret, time.Time{})
+		require.NoError(t, err)
+
+		_, err = parseWithKeyRing(kr, legacyToken)
+		assert.ErrorIs(t, err, utils.ErrLegacyHS256Ended)
+	})
+
+	t.Run("legacy ring signs HS256 without kid", func(t *testing.T) {
+		kr := utils.NewLegacyKeyRing(secret)
+
+		tokenString, err := kr.Sign(jwt.MapClaims{"sub": "123"})
+		require.NoError(t, err)
+
+		token, err := parseWithKeyRing(kr, tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, utils.AlgHS256, token.Method.Alg())
+		assert.Nil(t, token.Header["kid"])
+		assert.Empty(t, kr.JWKS().Keys)
+	})
+}
+
+func TestKeyRingFromPEM(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	t.Run("same key yields the same kid", func(t *testing.T) {
+		a, err := utils.NewKeyRing(utils.AlgEdDSA, pemData, time.Hour, "", time.Time{})
+		require.NoError(t, err)
+		b, err := utils.NewKeyRing(utils.AlgEdDSA, pemData, time.Hour, "", time.Time{})
+		require.NoError(t, err)
+
+		assert.Equal(t, a.ActiveKeyID(), b.ActiveKeyID())
+	})
+
+	t.Run("key type must match algorithm", func(t *testing.T) {
+		_, err := utils.NewKeyRing(utils.AlgRS256, pemData, time.Hour, "", time.Time{})
+		assert.ErrorIs(t, err, utils.ErrUnsupportedAlg)
+	})
+}
+
+func TestKeyRingJWKS(t *testing.T) {
+	rsaRing, err := utils.NewKeyRing(utils.AlgRS256, nil, time.Hour, "", time.Time{})
+	require.NoError(t, err)
+	edRing, err := utils.NewKeyRing(utils.AlgEdDSA, nil, time.Hour, "", time.Time{})
+	require.NoError(t, err)
+
+	rsaKey := rsaRing.JWKS().Keys[0]
+	assert.Equal(t, "RSA", rsaKey.Kty)
+	assert.Equal(t, utils.AlgRS256, rsaKey.Alg)
+	assert.Equal(t, "AQAB", rsaKey.E)
+	assert.NotEmpty(t, rsaKey.N)
+
+	edKey := edRing.JWKS().Keys[0]
+	assert.Equal(t, "OKP", edKey.Kty)
+	assert.Equal(t, "Ed25519", edKey.Crv)
+	assert.NotEmpty(t, edKey.X)
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"server/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// LocaleCookieName holds the language picked by the user. It takes precedence over Accept-Language.
+	LocaleCookieName = "locale"
+	// LocaleKey is the gin context key of the locale of the request.
+	LocaleKey = "locale"
+)
+
+// Locale returns the locale the Locale middleware selected for the request,
+// or i18n.DefaultLocale.
+func Locale(c *gin.Context) string {
+	if locale := c.GetString(LocaleKey); locale != "" {
+		return locale
+	}
+	return i18n.DefaultLocale
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"server/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgArgon2id = "argon2id"
+	AlgBcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// bcryptMaxPasswordBytes is the number of bytes of a password bcrypt uses.
+	bcryptMaxPasswordBytes = 72
+)
+
+var (
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+	ErrPasswordTooLong   = errors.New("password too long for bcrypt")
+)
+
+// PasswordHasher hashes and verifies passwords.
+//
+// Hashes are self-describing strings: Argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash) and bcrypt hashes use their
+// modular crypt format ($2a$cost$...), so the algorithm and its parameters can
+// be read back from a stored hash.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash.
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether the encoded hash uses another algorithm
+	// or weaker parameters than the hasher would use today.
+	NeedsRehash(encoded string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt.
+//
+// bcrypt only uses the first 72 bytes of a password; longer passwords are
+// rejected with ErrPasswordTooLong instead of being silently truncated.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	if len(password) > bcryptMaxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
+
+// MaxPasswordBytes returns the length of the longest password Hash accepts, in bytes.
+func (h BcryptHasher) MaxPasswordBytes() int {
+	return bcryptMaxPasswordBytes
+}
+
+// MaxPasswordBytes returns the length in bytes of the longest password hasher accepts,
+// or 0 if it accepts passwords of any length.
+func MaxPasswordBytes(hasher PasswordHasher) int {
+	if limited, ok := hasher.(interface{ MaxPasswordBytes() int }); ok {
+		return limited.MaxPasswordBytes()
+	}
+	return 0
+}
+
+// Argon2idHasher hashes passwords with Argon2id (RFC 9106).
+//
+// Fields:
+//   - Memory: the memory used in KiB.
+//   - Iterations: the number of passes over the memory.
+//   - Parallelism: the number of threads.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// argon2Params are the parameters decoded from a PHC string.
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	p, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.memory < h.Memory || p.iterations < h.Iterations || p.parallelism < h.Parallelism ||
+		len(p.salt) < argon2SaltLength || len(p.key) < argon2KeyLength
+}
+
+// decodeArgon2id parses a $argon2id$v=19$m=65536,t=3,p=2$salt$hash PHC string.
+func decodeArgon2id(encoded string) (*argon2Params, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgArgon2id {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrUnknownHashFormat
+	}
+
+	p := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) == 0 {
+		return nil, ErrUnknownHashFormat
+	}
+
+	return p, nil
+}
+
+// passwordHasher hashes new passwords with the preferred algorithm and verifies
+// hashes of every supported algorithm, so the algorithm can change without
+// invalidating existing passwords.
+type passwordHasher struct {
+	preferred PasswordHasher
+	argon2id  Argon2idHasher
+	bcrypt    BcryptHasher
+}
+
+// NewPasswordHasher returns the PasswordHasher configured by cfg.
+//
+// Parameters:
+//   - cfg: The password hashing settings.
+//
+// Returns:
+//   - PasswordHasher: A hasher that hashes with cfg.Algorithm and verifies both bcrypt and Argon2id hashes.
+//   - error: An error if the algorithm is unknown or the parameters are out of range.
+func NewPasswordHasher(cfg config.PasswordConfig) (PasswordHasher, error) {
+	h := &passwordHasher{
+		argon2id: Argon2idHasher{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		},
+		bcrypt: BcryptHasher{Cost: cfg.BcryptCost},
+	}
+
+	switch cfg.Algorithm {
+	case AlgArgon2id:
+		if cfg.Argon2Memory < 8*uint32(cfg.Argon2Parallelism) || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+		}
+		h.preferred = h.argon2id
+	case AlgBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", cfg.BcryptCost)
+		}
+		h.preferred = h.bcrypt
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+
+	return h, nil
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *passwordHasher) Verify(encoded, password string) (bool, error) {
+	hasher := h.hasherFor(encoded)
+	if hasher == nil {
+		return false, ErrUnknownHashFormat
+	}
+	return hasher.Verify(encoded, password)
+}
+
+// MaxPasswordBytes returns the limit of the preferred hasher, which hashes new passwords.
+func (h *passwordHasher) MaxPasswordBytes() int {
+	return MaxPasswordBytes(h.preferred)
+}
+
+func (h *passwordHasher) NeedsRehash(encoded string) bool {
+	if h.hasherFor(encoded) != h.preferred {
+		return true
+	}
+	return h.preferred.NeedsRehash(encoded)
+}
+
+// hasherFor returns the hasher able to verify encoded, or nil if the format is unknown.
+func (h *passwordHasher) hasherFor(encoded string) PasswordHasher {
+	switch {
+	case strings.HasPrefix(encoded, "$"+AlgArgon2id+"$"):
+		return h.argon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return h.bcrypt
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"fmt"
+	"server/config"
+	e "server/internal/errors"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rules reported in RuleViolation.Rule.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleMaxBytes  = "max_bytes"
+	RuleUppercase = "uppercase"
+	RuleLowercase = "lowercase"
+	RuleDigit     = "digit"
+	RuleSpecial   = "special"
+	RuleASCII     = "ascii"
+	RuleBreached  = "breached"
+)
+
+// breachedFalsePositiveRate is the share of passwords wrongly reported as breached.
+const breachedFalsePositiveRate = 0.001
+
+// PasswordPolicy checks new passwords against the configured rules.
+type PasswordPolicy struct {
+	cfg      config.PasswordPolicyConfig
+	maxBytes int
+	breached *BloomFilter
+}
+
+// NewPasswordPolicy creates a PasswordPolicy from cfg, loading the breached
+// passwords file when one is configured. Passwords longer than hasher accepts are
+// rejected too, whatever MaxLength is, as MaxLength counts characters and hashers
+// such as bcrypt limit bytes.
+//
+// Parameters:
+//   - cfg: The password policy settings.
+//   - hasher: The hasher of new passwords, nil if their length in bytes is not limited.
+//
+// Returns:
+//   - *PasswordPolicy: The policy.
+//   - error: An error if the length bounds are invalid or the breached passwords file cannot be read.
+func NewPasswordPolicy(cfg config.PasswordPolicyConfig, hasher PasswordHasher) (*PasswordPolicy, error) {
+	if cfg.MinLength < 1 || cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("invalid password length bounds %d-%d", cfg.MinLength, cfg.MaxLength)
+	}
+
+	policy := &PasswordPolicy{cfg: cfg}
+	if hasher != nil {
+		policy.maxBytes = MaxPasswordBytes(hasher)
+	}
+	if cfg.BreachedPasswordsFile != "" {
+		filter, err := LoadBloomFilter(cfg.BreachedPasswordsFile, breachedFalsePositiveRate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load breached passwords: %w", err)
+		}
+		policy.breached = filter
+	}
+
+	return policy, nil
+}
+
+// Validate returns every rule password fails, or nil if it satisfies the policy.
+// Length is counted in characters, so multi-byte characters count once.
+func (p *PasswordPolicy) Validate(password string) []e.RuleViolation {
+	var violations []e.RuleViolation
+	fail := func(rule, message string, params ...string) {
+		violation := e.RuleViolation{Rule: rule, Message: message}
+		if len(params) == 2 {
+			violation.Params = map[string]string{params[0]: params[1]}
+		}
+		violations = append(violations, violation)
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		fail(RuleMinLength, fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength), "min", strconv.Itoa(p.cfg.MinLength))
+	}
+	if length > p.cfg.MaxLength {
+		fail(RuleMaxLength, fmt.Sprintf("must be at most %d characters long", p.cfg.MaxLength), "max", strconv.Itoa(p.cfg.MaxLength))
+	}
+	if p.maxBytes > 0 && len(password) > p.maxBytes {
+		fail(RuleMaxBytes, fmt.Sprintf("must be at most %d bytes long", p.maxBytes), "max", strconv.Itoa(p.maxBytes))
+	}
+
+	var upper, lower, digit, special, nonASCII bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			special = true
+		}
+		if r > unicode.MaxASCII {
+			nonASCII = true
+		}
+	}
+
+	if p.cfg.RequireUpper && !upper {
+		fail(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		fail(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		fail(RuleDigit, "must contain a number")
+	}
+	if p.cfg.RequireSpecial && !special {
+		fail(RuleSpecial, "must contain a special character")
+	}
+	if !p.cfg.AllowUnicode && nonASCII {
+		fail(RuleASCII, "must only contain ASCII characters")
+	}
+	if p.breached != nil && p.breached.Test(password) {
+		fail(RuleBreached, "appears in a list of breached passwords")
+	}
+
+	return violations
+}
@@ -0,0 +1,195 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"server/config"
+	e "server/internal/errors"
+	"server/internal/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func defaultPolicyConfig() config.PasswordPolicyConfig {
+	return config.PasswordPolicyConfig{
+		MinLength:      8,
+		MaxLength:      32,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		AllowUnicode:   true,
+	}
+}
+
+func rules(violations []e.RuleViolation) []string {
+	var r []string
+	for _, v := range violations {
+		r = append(r, v.Rule)
+	}
+	return r
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy, err := utils.NewPasswordPolicy(defaultPolicyConfig(), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{
+			name:     "Valid password",
+			password: "Password1!",
+		},
+		{
+			name:     "Valid password - any punctuation is special",
+			password: "Password1~",
+		},
+		{
+			name:     "Valid password - passphrase with spaces",
+			password: "Correct horse 4 battery",
+		},
+		{
+			name:     "Valid password - unicode letters count once",
+			password: "Pässwörd1!",
+		},
+		{
+			name:     "Invalid password - missing uppercase letter",
+			password: "password1!",
+			want:     []string{utils.RuleUppercase},
+		},
+		{
+			name:     "Invalid password - missing lowercase letter",
+			password: "PASSWORD1!",
+			want:     []string{utils.RuleLowercase},
+		},
+		{
+			name:     "Invalid password - missing number",
+			password: "Password!",
+			want:     []string{utils.RuleDigit},
+		},
+		{
+			name:     "Invalid password - missing special character",
+			password: "Password1",
+			want:     []string{utils.RuleSpecial},
+		},
+		{
+			name:     "Invalid password - too short",
+			password: "Pass1!",
+			want:     []string{utils.RuleMinLength},
+		},
+		{
+			name:     "Invalid password - too long",
+			password: "Password1234567890123456789012345678901!",
+			want:     []string{utils.RuleMaxLength},
+		},
+		{
+			name:     "Invalid password - every failed rule is reported",
+			password: "pass",
+			want:     []string{utils.RuleMinLength, utils.RuleUppercase, utils.RuleDigit, utils.RuleSpecial},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rules(policy.Validate(tt.password)))
+		})
+	}
+}
+
+func TestPasswordPolicyConfig(t *testing.T) {
+	t.Run("character classes can be disabled", func(t *testing.T) {
+		cfg := defaultPolicyConfig()
+		cfg.RequireUpper, cfg.RequireDigit, cfg.RequireSpecial = false, false, false
+		policy, err := utils.NewPasswordPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		assert.Empty(t, policy.Validate("correct horse battery staple"))
+	})
+
+	t.Run("unicode can be disallowed", func(t *testing.T) {
+		cfg := defaultPolicyConfig()
+		cfg.AllowUnicode = false
+		policy, err := utils.NewPasswordPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{utils.RuleASCII}, rules(policy.Validate("Pässwörd1!")))
+	})
+
+	t.Run("invalid length bounds", func(t *testing.T) {
+		cfg := defaultPolicyConfig()
+		cfg.MaxLength = 4
+		_, err := utils.NewPasswordPolicy(cfg, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("breached passwords are rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "breached.txt")
+		require.NoError(t, os.WriteFile(path, []byte("Password1!\n\nP@ssw0rd123\n"), 0o600))
+
+		cfg := defaultPolicyConfig()
+		cfg.BreachedPasswordsFile = path
+		policy, err := utils.NewPasswordPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{utils.RuleBreached}, rules(policy.Validate("Password1!")))
+		assert.Equal(t, []string{utils.RuleBreached}, rules(policy.Validate("P@ssw0rd123")))
+		assert.Empty(t, policy.Validate("Unl1kely-To-Be-Breached"))
+	})
+
+	t.Run("passwords longer than bcrypt accepts are rejected", func(t *testing.T) {
+		cfg := defaultPolicyConfig()
+		cfg.MaxLength = 128
+		policy, err := utils.NewPasswordPolicy(cfg, utils.BcryptHasher{Cost: 4})
+		require.NoError(t, err)
+
+		// 45 characters, but 82 bytes.
+		password := "Pässwörd1!" + strings.Repeat("é", 35)
+		violations := policy.Validate(password)
+		assert.Equal(t, []string{utils.RuleMaxBytes}, rules(violations))
+		assert.Equal(t, map[string]string{"max": "72"}, violations[0].Params)
+
+		_, err = utils.BcryptHasher{Cost: 4}.Hash(password[:len(password)-10])
+		assert.NoError(t, err, "passwords the policy accepts can be hashed")
+		assert.Empty(t, policy.Validate(password[:len(password)-10]))
+	})
+
+	t.Run("argon2id does not limit the length in bytes", func(t *testing.T) {
+		cfg := defaultPolicyConfig()
+		cfg.MaxLength = 128
+		policy, err := utils.NewPasswordPolicy(cfg, utils.Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1})
+		require.NoError(t, err)
+
+		assert.Empty(t, policy.Validate("Pässwörd1!"+strings.Repeat("é", 35)))
+	})
+
+	t.Run("missing breached passwords file", func(t *testing.T) {
+		cfg := defaultPolicyConfig()
+		cfg.BreachedPasswordsFile = filepath.Join(t.TempDir(), "missing.txt")
+		_, err := utils.NewPasswordPolicy(cfg, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := utils.NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		filter.Add(string(rune('a'+i%26)) + string(rune(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		assert.True(t, filter.Test(string(rune('a'+i%26))+string(rune(i))))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		if filter.Test("absent-" + string(rune(i))) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 50)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"server/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testArgon2Config() config.PasswordConfig {
+	return config.PasswordConfig{
+		Algorithm:         AlgArgon2id,
+		BcryptCost:        bcrypt.MinCost,
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	}
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	h, err := NewPasswordHasher(testArgon2Config())
+	require.NoError(t, err)
+
+	hash, err := h.Hash("validPass123!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	ok, err := h.Verify(hash, "validPass123!")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify(hash, "wrongPass")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, h.NeedsRehash(hash))
+}
+
+func TestPasswordHasherRehash(t *testing.T) {
+	cfg := testArgon2Config()
+	h, err := NewPasswordHasher(cfg)
+	require.NoError(t, err)
+
+	t.Run("bcrypt hash is verified and needs rehash", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("validPass123!"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		ok, err := h.Verify(string(hash), "validPass123!")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, h.NeedsRehash(string(hash)))
+	})
+
+	t.Run("weaker argon2id parameters need rehash", func(t *testing.T) {
+		hash, err := h.Hash("validPass123!")
+		require.NoError(t, err)
+
+		cfg.Argon2Iterations = 2
+		stronger, err := NewPasswordHasher(cfg)
+		require.NoError(t, err)
+
+		ok, err := stronger.Verify(hash, "validPass123!")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, stronger.NeedsRehash(hash))
+	})
+
+	t.Run("lower bcrypt cost needs rehash", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("validPass123!"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		bcryptHasher, err := NewPasswordHasher(config.PasswordConfig{Algorithm: AlgBcrypt, BcryptCost: bcrypt.MinCost + 1})
+		require.NoError(t, err)
+		assert.True(t, bcryptHasher.NeedsRehash(string(hash)))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		ok, err := h.Verify("", "validPass123!")
+		assert.ErrorIs(t, err, ErrUnknownHashFormat)
+		assert.False(t, ok)
+	})
+}
+
+func TestBcryptRejectsLongPasswords(t *testing.T) {
+	h, err := NewPasswordHasher(config.PasswordConfig{Algorithm: AlgBcrypt, BcryptCost: bcrypt.MinCost})
+	require.NoError(t, err)
+
+	_, err = h.Hash(strings.Repeat("a", 73))
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+}
+
+func TestNewPasswordHasherInvalidConfig(t *testing.T) {
+	_, err := NewPasswordHasher(config.PasswordConfig{Algorithm: "md5"})
+	assert.Error(t, err)
+
+	_, err = NewPasswordHasher(config.PasswordConfig{Algorithm: AlgBcrypt, BcryptCost: 99})
+	assert.Error(t, err)
+
+	_, err = NewPasswordHasher(config.PasswordConfig{Algorithm: AlgArgon2id})
+	assert.Error(t, err)
+}
@@ -0,0 +1,15 @@
+package utils
+
+import "github.com/gin-gonic/gin"
+
+const (
+	// RequestIDHeader carries the id of a request, from the client or a proxy, and back in the response.
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the gin context key of the request id.
+	RequestIDKey = "requestID"
+)
+
+// RequestID returns the id the RequestID middleware assigned to the request, or "".
+func RequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
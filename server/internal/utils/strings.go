@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncateUTF8 returns s cut to at most maxBytes bytes without splitting a character.
+// Invalid UTF-8 sequences are replaced first, so the result is always valid UTF-8.
+func TruncateUTF8(s string, maxBytes int) string {
+	s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	if len(s) <= maxBytes {
+		return s
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
@@ -0,0 +1,34 @@
+package utils_test
+
+import (
+	"server/internal/utils"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		maxBytes int
+		want     string
+	}{
+		{"short string - kept", "Mozilla/5.0", 20, "Mozilla/5.0"},
+		{"ASCII - cut at the limit", "Mozilla/5.0", 7, "Mozilla"},
+		{"multi-byte character across the limit - left out", "abcé", 4, "abc"},
+		{"multi-byte character within the limit - kept", "abcé", 5, "abcé"},
+		{"four-byte character across the limit - left out", "a🐶", 3, "a"},
+		{"invalid UTF-8 - replaced", "ab\xffc", 10, "ab�c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utils.TruncateUTF8(tt.s, tt.maxBytes)
+
+			assert.Equal(t, tt.want, got)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package utils_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"server/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 seed of the RFC 6238 test vectors, base32 encoded.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// Last six digits of the RFC 6238 appendix B SHA1 vectors.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := utils.TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "time %d", tt.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := utils.TOTPCode(rfc6238Secret, now)
+	require.NoError(t, err)
+
+	t.Run("current code", func(t *testing.T) {
+		counter, ok := utils.ValidateTOTP(rfc6238Secret, code, now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, now.Unix()/30, counter)
+	})
+
+	t.Run("one step of clock drift", func(t *testing.T) {
+		_, ok := utils.ValidateTOTP(rfc6238Secret, code, now.Add(30*time.Second), 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		_, ok := utils.ValidateTOTP(rfc6238Secret, code, now.Add(2*time.Minute), 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("replayed code", func(t *testing.T) {
+		_, ok := utils.ValidateTOTP(rfc6238Secret, code, now, now.Unix()/30)
+		assert.False(t, ok)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		_, ok := utils.ValidateTOTP(rfc6238Secret, "000000", now, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := utils.TOTPURI("PawPics", "test@example.com", "SECRET")
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/PawPics:test@example.com", u.Path)
+	assert.Equal(t, "SECRET", u.Query().Get("secret"))
+	assert.Equal(t, "PawPics", u.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.Len(t, c, 9)
+		assert.False(t, utils.IsTOTPCode(c))
+		assert.False(t, seen[c])
+		seen[c] = true
+	}
+
+	assert.Equal(t, utils.HashRecoveryCode(codes[0]), utils.HashRecoveryCode(" "+strings.ToUpper(codes[0])+" "))
+}
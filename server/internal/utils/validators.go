@@ -2,47 +2,18 @@ package utils
 
 import (
 	"net/url"
-	"regexp"
+	e "server/internal/errors"
 	"strings"
 )
 
-var (
-	uppercaseRegex   = regexp.MustCompile(`[A-Z]`)
-	lowercaseRegex   = regexp.MustCompile(`[a-z]`)
-	digitRegex       = regexp.MustCompile(`\d`)
-	specialCharRegex = regexp.MustCompile(`[#?!@$%^&*-]`)
-	minLength        = 8
-	maxLength        = 32
+// Image URL rules reported in RuleViolation.Rule.
+const (
+	RuleNotEmpty  = "not_empty"
+	RuleNoSpaces  = "no_spaces"
+	RuleProtocol  = "protocol"
+	RuleExtension = "extension"
 )
 
-// IsValidPassword reports whether the password is valid.
-//
-// A valid password must contain:
-//   - Minimum eight characters,
-//   - At most thirty-two characters,
-//   - At least one uppercase letter,
-//   - At least one lowercase letter,
-//   - At least one number,
-//   - At least one special character.
-func IsValidPassword(password string) bool {
-	if len(password) < minLength || len(password) > maxLength {
-		return false
-	}
-	if !uppercaseRegex.MatchString(password) {
-		return false
-	}
-	if !lowercaseRegex.MatchString(password) {
-		return false
-	}
-	if !digitRegex.MatchString(password) {
-		return false
-	}
-	if !specialCharRegex.MatchString(password) {
-		return false
-	}
-	return true
-}
-
 var validImageExtensions = map[string]bool{
 	".jpg":  true,
 	".jpeg": true,
@@ -87,6 +58,38 @@ func IsInvalidProtocol(s string) bool {
 	return err != nil || parsedURL.Scheme != "http" && parsedURL.Scheme != "https"
 }
 
+// ValidateImageURL checks imageURL against the custom image URL rules.
+//
+// Parameters:
+//   - field: The name of the request field holding the URL, reported in the violation.
+//   - imageURL: The URL to check.
+//
+// Returns:
+//   - error: A ValidationError naming the first failed rule, or nil if the URL is valid.
+func ValidateImageURL(field, imageURL string) error {
+	invalid := func(code e.ErrorCode, message, rule, ruleMessage string) error {
+		return e.NewValidationError(code, message, []e.RuleViolation{{Field: field, Rule: rule, Message: ruleMessage}})
+	}
+
+	if IsEmptyString(imageURL) {
+		return invalid(e.EmptyImageURL, "empty image URL", RuleNotEmpty, "must not be empty")
+	}
+
+	if ContainsEmptySpace(imageURL) {
+		return invalid(e.MalformedURL, "URL contains empty spaces", RuleNoSpaces, "must not contain spaces")
+	}
+
+	if IsInvalidProtocol(imageURL) || IsMalformedURL(imageURL) {
+		return invalid(e.MalformedURL, "malformed or invalid image URL", RuleProtocol, "must be an http or https URL with a host")
+	}
+
+	if !HasImageValidExtension(imageURL) {
+		return invalid(e.InvalidImageExtension, "invalid image extension", RuleExtension, "must end with .jpg, .jpeg, .png, .gif or .webp")
+	}
+
+	return nil
+}
+
 func IsImageLiked(images []string, imageURL string) bool {
 	for _, img := range images {
 		if img == imageURL {
@@ -1,61 +1,12 @@
 package utils_test
 
 import (
+	e "server/internal/errors"
 	"server/internal/utils"
 	"testing"
-)
-
-func TestIsValidPassword(t *testing.T) {
-	tests := []struct {
-		name     string
-		password string
-		want     bool
-	}{
-		{
-			name:     "Valid password",
-			password: "Password1!",
-			want:     true,
-		},
-		{
-			name:     "Invalid password - missing uppercase letter",
-			password: "password1!",
-			want:     false,
-		},
-		{
-			name:     "Invalid password - missing lowercase letter",
-			password: "PASSWORD1!",
-			want:     false,
-		},
-		{
-			name:     "Invalid password - missing number",
-			password: "Password!",
-			want:     false,
-		},
-		{
-			name:     "Invalid password - missing special character",
-			password: "Password1",
-			want:     false,
-		},
-		{
-			name:     "Invalid password - too short",
-			password: "Pass1!",
-			want:     false,
-		},
-		{
-			name:     "Invalid password - too long",
-			password: "Password1234567890123456789012345678901!",
-			want:     false,
-		},
-	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := utils.IsValidPassword(tt.password); got != tt.want {
-				t.Errorf("IsValidPassword() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
+	"github.com/stretchr/testify/assert"
+)
 
 func TestHasImageValidExtension(t *testing.T) {
 	tests := []struct {
@@ -327,3 +278,35 @@ func TestIsImageLiked(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateImageURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		imageURL string
+		code     e.ErrorCode
+		rule     string
+	}{
+		{name: "Empty", imageURL: " ", code: e.EmptyImageURL, rule: utils.RuleNotEmpty},
+		{name: "Spaces", imageURL: "https://dog.ceo/my dog.jpg", code: e.MalformedURL, rule: utils.RuleNoSpaces},
+		{name: "Protocol", imageURL: "ftp://dog.ceo/dog.jpg", code: e.MalformedURL, rule: utils.RuleProtocol},
+		{name: "Extension", imageURL: "https://dog.ceo/dog.txt", code: e.InvalidImageExtension, rule: utils.RuleExtension},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := utils.ValidateImageURL("imageURL", tt.imageURL)
+
+			validationErr, ok := err.(*e.ValidationError)
+			if assert.True(t, ok) {
+				assert.Equal(t, tt.code, validationErr.Code())
+				assert.Len(t, validationErr.Violations, 1)
+				assert.Equal(t, "imageURL", validationErr.Violations[0].Field)
+				assert.Equal(t, tt.rule, validationErr.Violations[0].Rule)
+			}
+		})
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, utils.ValidateImageURL("imageURL", "https://dog.ceo/dog.jpg"))
+	})
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookSecretSize   = 32
+	webhookSecretPrefix = "whsec_"
+	signaturePrefix     = "sha256="
+)
+
+// RulePublicHost is the webhook URL rule reported in RuleViolation.Rule.
+const RulePublicHost = "public_host"
+
+// ErrPrivateAddress is returned when dialing a webhook resolving to an address that
+// is not publicly routable.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// nonPublicPrefixes are the ranges not covered by the netip.Addr predicates that must
+// not be reached by webhooks: "this network" and the carrier-grade NAT shared space,
+// which some clouds use for their metadata services.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// GenerateWebhookSecret returns a random secret to sign the deliveries of a webhook.
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(b), nil
+}
+
+// SignWebhook returns the signature of a webhook delivery, sent in the
+// X-Webhook-Signature header: "sha256=" followed by the hex encoded HMAC-SHA256, keyed
+// with the secret of the webhook, of the timestamp, a dot and the body. Signing the
+// timestamp lets receivers reject replayed deliveries.
+//
+// Parameters:
+//   - secret: The secret of the webhook.
+//   - timestamp: The Unix time of the delivery, sent in the X-Webhook-Timestamp header.
+//   - body: The body of the delivery.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature is the SignWebhook signature of
+// body sent at timestamp, comparing them in constant time.
+func VerifyWebhookSignature(secret string, timestamp int64, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(SignWebhook(secret, timestamp, body)))
+}
+
+// IsPublicAddr reports whether addr is a publicly routable unicast address, i.e. not
+// a loopback, private, link-local, multicast or unspecified address.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPrivateWebhookURL reports whether the host of webhookURL is localhost or an IP
+// address that is not public. Hosts resolving to such addresses are rejected when
+// dialing, by the client of NewWebhookClient.
+func IsPrivateWebhookURL(webhookURL string) bool {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && !IsPublicAddr(addr)
+}
+
+// NewWebhookClient returns the client delivering events to webhooks. Unless
+// allowPrivateNetworks is set, it refuses to connect to addresses that are not
+// public, checking the address actually dialed so hostnames resolving, or rebinding,
+// to internal hosts are refused too. Redirects are not followed, and proxies are not
+// used as they would dial on the client's behalf.
+//
+// Parameters:
+//   - timeout: How long a webhook has to respond.
+//   - allowPrivateNetworks: Whether webhooks may be on private networks, e.g. in
+//     development.
+func NewWebhookClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"server/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWebhookSecret(t *testing.T) {
+	first, err := utils.GenerateWebhookSecret()
+	require.NoError(t, err)
+	second, err := utils.GenerateWebhookSecret()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first, "whsec_"))
+	assert.Len(t, first, len("whsec_")+64)
+	assert.NotEqual(t, first, second)
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"type":"image.liked"}`)
+
+	t.Run("known vector", func(t *testing.T) {
+		// HMAC-SHA256 of `1700000000.{"type":"image.liked"}` keyed with "secret".
+		signature := utils.SignWebhook("secret", 1700000000, body)
+		assert.Equal(t, "sha256=f4fce6fbb0bccdc15a9d720f2002ff350c2d6ebdc877376756bf726f6cd2b6e3", signature)
+	})
+
+	t.Run("verify - accepts the signature", func(t *testing.T) {
+		signature := utils.SignWebhook("secret", 1700000000, body)
+		assert.True(t, utils.VerifyWebhookSignature("secret", 1700000000, body, signature))
+	})
+
+	t.Run("verify - rejects other secrets, timestamps and bodies", func(t *testing.T) {
+		signature := utils.SignWebhook("secret", 1700000000, body)
+		assert.False(t, utils.VerifyWebhookSignature("other", 1700000000, body, signature))
+		assert.False(t, utils.VerifyWebhookSignature("secret", 1700000001, body, signature))
+		assert.False(t, utils.VerifyWebhookSignature("secret", 1700000000, []byte("{}"), signature))
+		assert.False(t, utils.VerifyWebhookSignature("secret", 1700000000, body, strings.TrimPrefix(signature, "sha256=")))
+	})
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::1":   true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"100.100.100.200":      false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		assert.Equal(t, public, utils.IsPublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestIsPrivateWebhookURL(t *testing.T) {
+	assert.True(t, utils.IsPrivateWebhookURL("http://localhost:8080/hooks"))
+	assert.True(t, utils.IsPrivateWebhookURL("http://api.localhost/hooks"))
+	assert.True(t, utils.IsPrivateWebhookURL("http://127.0.0.1/hooks"))
+	assert.True(t, utils.IsPrivateWebhookURL("http://[fe80::1]/hooks"))
+	assert.False(t, utils.IsPrivateWebhookURL("https://example.com/hooks"))
+	assert.False(t, utils.IsPrivateWebhookURL("https://93.184.216.34/hooks"))
+}
+
+func TestNewWebhookClient(t *testing.T) {
+	server := httptest.NewServer(http.RedirectHandler("/elsewhere", http.StatusFound))
+	defer server.Close()
+
+	t.Run("private networks - refuses to dial them", func(t *testing.T) {
+		_, err := utils.NewWebhookClient(time.Second, false).Get(server.URL)
+		assert.ErrorIs(t, err, utils.ErrPrivateAddress)
+	})
+
+	t.Run("private networks allowed - does not follow redirects", func(t *testing.T) {
+		resp, err := utils.NewWebhookClient(time.Second, true).Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+	})
+}